@@ -3,75 +3,278 @@ package main
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/bedrock-chat-poc/backend/config"
+	"github.com/bedrock-chat-poc/backend/domain/entities"
+	"github.com/bedrock-chat-poc/backend/domain/services"
 	"github.com/bedrock-chat-poc/backend/infrastructure/bedrock"
+	"github.com/bedrock-chat-poc/backend/infrastructure/logging"
+	"github.com/bedrock-chat-poc/backend/infrastructure/metrics"
 	"github.com/bedrock-chat-poc/backend/infrastructure/repositories"
+	"github.com/bedrock-chat-poc/backend/infrastructure/storage"
+	"github.com/bedrock-chat-poc/backend/infrastructure/tracing"
 	"github.com/bedrock-chat-poc/backend/interfaces/chat"
+	"github.com/bedrock-chat-poc/backend/interfaces/health"
+	"github.com/bedrock-chat-poc/backend/interfaces/middleware"
+	"github.com/bedrock-chat-poc/backend/interfaces/router"
 )
 
 func main() {
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
+		fatal("Failed to load configuration", "error", err)
 	}
 
+	// Initialize structured logging
+	logger, err := logging.New(logging.Config{
+		Level:         cfg.Logging.Level,
+		Format:        cfg.Logging.Format,
+		RedactContent: cfg.Logging.RedactContent,
+	}, os.Stdout)
+	if err != nil {
+		fatal("Failed to initialize logging", "error", err)
+	}
+	slog.SetDefault(logger)
+
 	// Log startup information
-	log.Printf("Starting chat backend server")
-	log.Printf("Environment: %s", cfg.Environment)
-	log.Printf("Server: %s:%s", cfg.Server.Host, cfg.Server.Port)
-	log.Printf("AWS Region: %s", cfg.AWS.Region)
-	log.Printf("Log Level: %s", cfg.Logging.Level)
-	log.Printf("Debug - Agent ID: '%s', Alias ID: '%s'", cfg.Bedrock.AgentID, cfg.Bedrock.AgentAliasID)
+	slog.Info("Starting chat backend server",
+		"environment", cfg.Environment,
+		"address", cfg.Server.Host+":"+cfg.Server.Port,
+		"read_timeout", cfg.Server.ReadTimeout,
+		"write_timeout", cfg.Server.WriteTimeout,
+		"idle_timeout", cfg.Server.IdleTimeout,
+		"aws_region", cfg.AWS.Region,
+		"log_level", cfg.Logging.Level,
+		"log_format", cfg.Logging.Format,
+		"log_redact_content", cfg.Logging.RedactContent,
+	)
+	slog.Debug("Bedrock agent configuration", "agent_id", cfg.Bedrock.AgentID, "alias_id", cfg.Bedrock.AgentAliasID)
+
+	// Initialize tracing
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.OTLPEndpoint,
+		Insecure:    cfg.Tracing.OTLPInsecure,
+		ServiceName: cfg.Tracing.ServiceName,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	}, os.Stdout)
+	if err != nil {
+		fatal("Failed to initialize tracing", "error", err)
+	}
+	slog.Info("Tracing initialized", "exporter", cfg.Tracing.Exporter, "sample_ratio", cfg.Tracing.SampleRatio)
+
+	// Initialize metrics
+	var appMetrics *metrics.Metrics
+	if cfg.Metrics.Enabled {
+		appMetrics = metrics.New()
+		slog.Info("Metrics enabled", "path", cfg.Metrics.Path)
+	}
 
 	// Initialize dependencies
-	sessionRepo := repositories.NewMemorySessionRepository()
+	sessionRepo := repositories.NewMemorySessionRepositoryWithConfig(repositories.MemorySessionRepositoryConfig{
+		Metrics: appMetrics,
+	})
+
+	// Initialize Bedrock services
+	bedrockConfig := bedrock.AdapterConfig{
+		MaxRetries:     cfg.Bedrock.MaxRetries,
+		InitialBackoff: cfg.Bedrock.InitialBackoff,
+		MaxBackoff:     cfg.Bedrock.MaxBackoff,
+		RequestTimeout: cfg.Bedrock.RequestTimeout,
+		Jitter:         bedrock.JitterMode(cfg.Bedrock.RetryJitter),
+		RetryBudget:    cfg.Bedrock.RetryBudget,
+		Metrics:        appMetrics,
+		Guardrail: bedrock.GuardrailConfig{
+			ID:      cfg.Bedrock.GuardrailID,
+			Version: cfg.Bedrock.GuardrailVersion,
+		},
+	}
+	modeServices := make(map[entities.ChatMode]services.BedrockService)
+	var retriever services.KnowledgeBaseRetriever
+
+	// Readiness reports every component; adapters that failed to initialize
+	// are down if they serve the default mode and degraded otherwise
+	defaultMode := entities.ChatMode(cfg.Bedrock.Mode)
+	healthHandler := health.NewHandler()
+	healthHandler.Register("repository", sessionRepo)
+	// The initialization error is logged where it happens; readiness is
+	// public, so it only says that the adapter failed
+	adapterFailed := func(mode entities.ChatMode) {
+		status := services.HealthDegraded
+		if mode == defaultMode {
+			status = services.HealthDown
+		}
+		healthHandler.Register("bedrock_"+string(mode), services.HealthCheckFunc(func(ctx context.Context) services.ComponentHealth {
+			return services.ComponentHealth{Status: status, Message: "Initialization failed"}
+		}))
+	}
+
+	// Adapters are guarded by a circuit breaker per mode, so a Bedrock
+	// incident fails turns fast instead of each retrying to exhaustion
+	adapterReady := func(mode entities.ChatMode, adapter interface {
+		services.BedrockService
+		services.HealthChecker
+	}) {
+		var service services.BedrockService = adapter
+		var checker services.HealthChecker = adapter
+		if cfg.Bedrock.BreakerEnabled {
+			breaker := bedrock.NewCircuitBreaker(adapter, bedrock.CircuitBreakerConfig{
+				Name:           string(mode),
+				FailureRatio:   cfg.Bedrock.BreakerFailureRatio,
+				MinRequests:    cfg.Bedrock.BreakerMinRequests,
+				Window:         cfg.Bedrock.BreakerWindow,
+				OpenTimeout:    cfg.Bedrock.BreakerOpenTimeout,
+				HalfOpenProbes: cfg.Bedrock.BreakerHalfOpenProbes,
+				Metrics:        appMetrics,
+			})
+			service, checker = breaker, breaker
+		}
+		modeServices[mode] = service
+		healthHandler.Register("bedrock_"+string(mode), checker)
+	}
 
-	// Initialize Bedrock adapter
-	var bedrockService *bedrock.Adapter
 	if cfg.Bedrock.AgentID != "" && cfg.Bedrock.AgentAliasID != "" {
-		bedrockConfig := bedrock.AdapterConfig{
-			MaxRetries:     cfg.Bedrock.MaxRetries,
-			InitialBackoff: cfg.Bedrock.InitialBackoff,
-			MaxBackoff:     cfg.Bedrock.MaxBackoff,
-			RequestTimeout: cfg.Bedrock.RequestTimeout,
+		agentAdapter, err := bedrock.NewAdapter(context.Background(), cfg.Bedrock.AgentID, cfg.Bedrock.AgentAliasID, bedrockConfig)
+		if err != nil {
+			slog.Warn("Failed to initialize Bedrock adapter", "error", err)
+			adapterFailed(entities.ModeAgent)
+		} else {
+			adapterReady(entities.ModeAgent, agentAdapter)
+			slog.Info("Bedrock agent adapter initialized",
+				"agent_id", cfg.Bedrock.AgentID,
+				"alias_id", cfg.Bedrock.AgentAliasID,
+				"knowledge_base_id", cfg.Bedrock.KnowledgeBaseID,
+			)
 		}
+	}
 
-		bedrockService, err = bedrock.NewAdapter(context.Background(), cfg.Bedrock.AgentID, cfg.Bedrock.AgentAliasID, bedrockConfig)
+	if cfg.Bedrock.KnowledgeBaseID != "" {
+		kbAdapter, err := bedrock.NewKnowledgeBaseAdapter(context.Background(), cfg.Bedrock.KnowledgeBaseID, cfg.Bedrock.ModelID, bedrockConfig)
 		if err != nil {
-			log.Printf("Warning: Failed to initialize Bedrock adapter: %v", err)
-			log.Printf("Running in mock mode without Bedrock integration")
+			slog.Warn("Failed to initialize knowledge base adapter", "error", err)
+			adapterFailed(entities.ModeKnowledgeBase)
 		} else {
-			log.Printf("Bedrock adapter initialized")
-			log.Printf("  Agent ID: %s", cfg.Bedrock.AgentID)
-			log.Printf("  Alias ID: %s", cfg.Bedrock.AgentAliasID)
-			if cfg.Bedrock.KnowledgeBaseID != "" {
-				log.Printf("  Knowledge Base ID: %s", cfg.Bedrock.KnowledgeBaseID)
-			}
-			log.Printf("  Model ID: %s", cfg.Bedrock.ModelID)
-			log.Printf("  Max Retries: %d", cfg.Bedrock.MaxRetries)
-			log.Printf("  Request Timeout: %v", cfg.Bedrock.RequestTimeout)
+			adapterReady(entities.ModeKnowledgeBase, kbAdapter)
+			retriever = kbAdapter
+			// Bedrock session IDs are kept only as long as our sessions
+			sessionRepo.OnRemove(kbAdapter.ForgetSession)
+			slog.Info("Bedrock knowledge base adapter initialized",
+				"knowledge_base_id", cfg.Bedrock.KnowledgeBaseID,
+				"model_id", cfg.Bedrock.ModelID,
+				"guardrail_id", cfg.Bedrock.GuardrailID,
+				"guardrail_version", cfg.Bedrock.GuardrailVersion,
+			)
+		}
+	}
+
+	// Direct model chat needs nothing provisioned beyond model access, so it is
+	// only offered when it is the default mode or a model was chosen explicitly
+	if cfg.Bedrock.ModelModeEnabled() {
+		modelConfig := bedrock.ModelConfig{
+			ModelID:            cfg.Bedrock.ModelID,
+			SystemPrompt:       cfg.Bedrock.SystemPrompt,
+			Temperature:        float32(cfg.Bedrock.Temperature),
+			MaxTokens:          int32(cfg.Bedrock.MaxTokens),
+			MaxHistoryMessages: cfg.Bedrock.MaxHistoryMessages,
 		}
+		modelAdapter, err := bedrock.NewModelAdapter(context.Background(), modelConfig, bedrockConfig)
+		if err != nil {
+			slog.Warn("Failed to initialize model adapter", "error", err)
+			adapterFailed(entities.ModeModel)
+		} else {
+			adapterReady(entities.ModeModel, modelAdapter)
+			slog.Info("Bedrock model adapter initialized",
+				"model_id", cfg.Bedrock.ModelID,
+				"temperature", cfg.Bedrock.Temperature,
+				"max_tokens", cfg.Bedrock.MaxTokens,
+				"guardrail_id", cfg.Bedrock.GuardrailID,
+				"guardrail_version", cfg.Bedrock.GuardrailVersion,
+			)
+		}
+	}
+
+	bedrockService := modeServices[defaultMode]
+	if bedrockService != nil {
+		slog.Info("Default chat mode",
+			"mode", defaultMode,
+			"max_retries", cfg.Bedrock.MaxRetries,
+			"retry_jitter", cfg.Bedrock.RetryJitter,
+			"retry_budget", cfg.Bedrock.RetryBudget,
+			"request_timeout", cfg.Bedrock.RequestTimeout,
+			"circuit_breaker", cfg.Bedrock.BreakerEnabled,
+		)
 	} else {
-		log.Printf("Bedrock configuration not set, running in mock mode")
 		if cfg.IsProduction() {
-			log.Fatalf("Bedrock configuration is required in production environment")
+			fatal("Bedrock configuration is required in production environment", "mode", defaultMode)
+		}
+		slog.Warn("Bedrock mode not configured, running in mock mode", "mode", defaultMode)
+		healthHandler.Register("bedrock", services.HealthCheckFunc(func(ctx context.Context) services.ComponentHealth {
+			return services.ComponentHealth{
+				Status:  services.HealthDegraded,
+				Message: "No Bedrock service configured; answering in mock mode",
+				Details: map[string]interface{}{"mode": string(defaultMode)},
+			}
+		}))
+	}
+
+	// Initialize citation URL resolver
+	var urlResolver services.CitationURLResolver = storage.NewPassthroughResolver()
+	if cfg.Citation.PresignS3 {
+		presignResolver, err := storage.NewS3PresignResolver(context.Background(), storage.S3PresignConfig{
+			Expiry:         cfg.Citation.PresignExpiry,
+			AllowedBuckets: cfg.Citation.AllowedBuckets,
+			Endpoint:       cfg.Citation.S3Endpoint,
+			UsePathStyle:   cfg.Citation.S3UsePathStyle,
+		})
+		if err != nil {
+			fatal("Failed to initialize S3 presign resolver", "error", err)
 		}
+		urlResolver = presignResolver
+		slog.Info("S3 citation presigning enabled",
+			"expiry", cfg.Citation.PresignExpiry,
+			"allowed_buckets", cfg.Citation.AllowedBuckets,
+		)
 	}
 
 	// Initialize stream processor
+	streamMiddlewares, err := bedrock.NewStreamMiddlewares(cfg.WebSocket.StreamMiddlewares, bedrock.StreamMiddlewareOptions{
+		ProfanityWords: cfg.WebSocket.StreamProfanityWords,
+	})
+	if err != nil {
+		fatal("Failed to initialize stream middlewares", "error", err)
+	}
 	streamProcessorConfig := bedrock.StreamProcessorConfig{
-		StreamTimeout: cfg.WebSocket.StreamTimeout,
-		ChunkTimeout:  cfg.WebSocket.ChunkTimeout,
+		StreamTimeout:         cfg.WebSocket.StreamTimeout,
+		ChunkTimeout:          cfg.WebSocket.ChunkTimeout,
+		InlineCitationMarkers: cfg.WebSocket.InlineCitationMarkers,
+		ResumePolicy:          bedrock.ResumePolicy(cfg.WebSocket.StreamResume),
+		MaxResumes:            cfg.WebSocket.StreamMaxResumes,
+		Middlewares:           streamMiddlewares,
+		URLResolver:           urlResolver,
+		MaxCitations:          cfg.Citation.MaxPerAnswer,
+		Metrics:               appMetrics,
 	}
 	streamProcessor := bedrock.NewStreamProcessor(streamProcessorConfig)
-	log.Printf("Stream processor initialized")
-	log.Printf("  Stream Timeout: %v", cfg.WebSocket.StreamTimeout)
-	log.Printf("  Chunk Timeout: %v", cfg.WebSocket.ChunkTimeout)
+	slog.Info("Stream processor initialized",
+		"stream_timeout", cfg.WebSocket.StreamTimeout,
+		"chunk_timeout", cfg.WebSocket.ChunkTimeout,
+		"inline_citation_markers", cfg.WebSocket.InlineCitationMarkers,
+		"stream_resume", cfg.WebSocket.StreamResume,
+		"stream_max_resumes", cfg.WebSocket.StreamMaxResumes,
+		"stream_middlewares", cfg.WebSocket.StreamMiddlewares,
+		"coalesce_bytes", cfg.WebSocket.CoalesceBytes,
+		"coalesce_interval", cfg.WebSocket.CoalesceInterval,
+		"send_queue_size", cfg.WebSocket.SendQueueSize,
+		"slow_consumer_timeout", cfg.WebSocket.SlowConsumerTimeout,
+		"max_citations_per_answer", cfg.Citation.MaxPerAnswer,
+	)
 
 	// Initialize chat handler with WebSocket configuration
 	chatHandler := chat.NewHandlerWithConfig(
@@ -79,56 +282,62 @@ func main() {
 		bedrockService,
 		streamProcessor,
 		chat.HandlerConfig{
-			ReadBufferSize:   cfg.WebSocket.ReadBufferSize,
-			WriteBufferSize:  cfg.WebSocket.WriteBufferSize,
-			KnowledgeBaseID:  cfg.Bedrock.KnowledgeBaseID,
+			ReadBufferSize:  cfg.WebSocket.ReadBufferSize,
+			WriteBufferSize: cfg.WebSocket.WriteBufferSize,
+			KnowledgeBaseID: cfg.Bedrock.KnowledgeBaseID,
+			DefaultMode:     defaultMode,
+			ModeServices:    modeServices,
+			Retriever:       retriever,
+			Metrics:         appMetrics,
+			StreamTimeout:   cfg.WebSocket.StreamTimeout,
+			Coalescing: &bedrock.CoalescingConfig{
+				MaxBytes:            cfg.WebSocket.CoalesceBytes,
+				FlushInterval:       cfg.WebSocket.CoalesceInterval,
+				QueueSize:           cfg.WebSocket.SendQueueSize,
+				MaxCoarsening:       bedrock.DefaultCoalescingConfig().MaxCoarsening,
+				SlowConsumerTimeout: cfg.WebSocket.SlowConsumerTimeout,
+				Metrics:             appMetrics,
+			},
+			Compression:      cfg.WebSocket.Compression,
+			CompressionLevel: cfg.WebSocket.CompressionLevel,
 		},
 	)
+	healthHandler.Register("chat", chatHandler)
 
-	// Set up routes
-	mux := http.NewServeMux()
-
-	// Session management endpoints
-	mux.HandleFunc("/api/sessions", func(w http.ResponseWriter, r *http.Request) {
-		chat.SetCORSHeaders(w)
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-		if r.Method == http.MethodPost {
-			chatHandler.HandleCreateSession(w, r)
-		} else if r.Method == http.MethodGet {
-			chatHandler.HandleListSessions(w, r)
-		} else {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		}
-	})
-
-	mux.HandleFunc("/api/sessions/", func(w http.ResponseWriter, r *http.Request) {
-		chat.SetCORSHeaders(w)
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-		chatHandler.HandleGetSession(w, r)
-	})
+	// Set up routes. Every request is logged with a request ID and recovered
+	// from panics; CORS preflights are answered before routing.
+	r := router.New(
+		middleware.RequestID(),
+		middleware.Logging(),
+		middleware.Recovery(),
+		middleware.CORS(),
+	)
 
-	// WebSocket endpoint for streaming chat
-	mux.HandleFunc("/api/chat/stream", func(w http.ResponseWriter, r *http.Request) {
-		chat.SetCORSHeaders(w)
-		chatHandler.HandleWebSocket(w, r)
-	})
+	// API routes are traced and, when tokens are configured, authenticated
+	api := r.Group(middleware.Tracing(), middleware.BearerAuth(cfg.Auth.APITokens))
+	chatHandler.RegisterRoutes(api)
+	if len(cfg.Auth.APITokens) > 0 {
+		slog.Info("API authentication enabled", "tokens", len(cfg.Auth.APITokens))
+	} else {
+		slog.Warn("API authentication disabled; set AUTH_API_TOKENS to require bearer tokens")
+	}
 
-	// Health check endpoint
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+	// Health check endpoints
+	r.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
 	})
+	r.HandleFunc("GET /health/live", healthHandler.HandleLive)
+	r.HandleFunc("GET /health/ready", healthHandler.HandleReady)
+
+	// Prometheus metrics endpoint
+	if appMetrics != nil {
+		r.Handle("GET "+cfg.Metrics.Path, appMetrics.Handler())
+	}
 
 	// Configuration endpoint (development only)
 	if cfg.IsDevelopment() {
-		mux.HandleFunc("/api/config", func(w http.ResponseWriter, r *http.Request) {
-			chat.SetCORSHeaders(w)
+		api.HandleFunc("GET /api/config", func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusOK)
 			// Return sanitized configuration (no credentials)
@@ -144,15 +353,65 @@ func main() {
 	// Create server with timeouts
 	server := &http.Server{
 		Addr:         fmt.Sprintf("%s:%s", cfg.Server.Host, cfg.Server.Port),
-		Handler:      mux,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		Handler:      r,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+		ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelError),
 	}
 
 	// Start server
-	log.Printf("Server listening on %s", server.Addr)
-	if err := server.ListenAndServe(); err != nil {
-		log.Fatalf("Server failed to start: %v", err)
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("Server listening", "address", server.Addr)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	// Wait for SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	select {
+	case err := <-serverErr:
+		shutdownTracing(context.Background())
+		fatal("Server failed to start", "error", err)
+	case <-ctx.Done():
+		stop()
 	}
+
+	// Drain: stop accepting connections, let in-flight answers finish, then
+	// release resources. A second signal exits immediately.
+	slog.Info("Shutting down", "drain_timeout", cfg.Server.DrainTimeout)
+	drainCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.DrainTimeout)
+	defer cancel()
+
+	// WebSocket connections are hijacked, so server.Shutdown does not wait for
+	// them; they drain alongside it rather than after slow HTTP requests
+	wsDrained := make(chan struct{})
+	go func() {
+		defer close(wsDrained)
+		if err := chatHandler.Shutdown(drainCtx); err != nil {
+			slog.Warn("WebSocket drain incomplete", "error", err)
+		}
+	}()
+	if err := server.Shutdown(drainCtx); err != nil {
+		slog.Warn("HTTP server shutdown incomplete", "error", err)
+	}
+	<-wsDrained
+	sessionRepo.Close()
+
+	// Flush spans with a fresh deadline; the drain may have used up its own
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelFlush()
+	if err := shutdownTracing(flushCtx); err != nil {
+		slog.Warn("Failed to flush traces", "error", err)
+	}
+
+	slog.Info("Server stopped")
+}
+
+// fatal logs an error and exits
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
 }
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
@@ -8,6 +10,7 @@ import (
 	"os/signal"
 
 	"github.com/gorilla/websocket"
+	"github.com/vmihailenco/msgpack/v5"
 )
 
 type MessageRequest struct {
@@ -16,15 +19,17 @@ type MessageRequest struct {
 }
 
 type StreamChunk struct {
-	Type     string                 `json:"type"`
-	Content  string                 `json:"content,omitempty"`
-	Citation map[string]interface{} `json:"citation,omitempty"`
-	Error    map[string]interface{} `json:"error,omitempty"`
+	Type      string                   `json:"type"`
+	Content   string                   `json:"content,omitempty"`
+	Citation  map[string]interface{}   `json:"citation,omitempty"`
+	Citations []map[string]interface{} `json:"citations,omitempty"`
+	Error     map[string]interface{}   `json:"error,omitempty"`
 }
 
 func main() {
 	sessionID := flag.String("session", "", "Session ID")
 	message := flag.String("message", "Hello, world!", "Message to send")
+	useMsgPack := flag.Bool("msgpack", false, "Ask for MessagePack server messages")
 	flag.Parse()
 
 	if *sessionID == "" {
@@ -35,7 +40,11 @@ func main() {
 	url := "ws://localhost:8080/api/chat/stream"
 	log.Printf("Connecting to %s", url)
 
-	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	dialer := websocket.Dialer{EnableCompression: true}
+	if *useMsgPack {
+		dialer.Subprotocols = []string{"bedrock-chat.msgpack"}
+	}
+	conn, _, err := dialer.Dial(url, nil)
 	if err != nil {
 		log.Fatalf("Failed to connect: %v", err)
 	}
@@ -51,8 +60,7 @@ func main() {
 	go func() {
 		defer close(done)
 		for {
-			var chunk StreamChunk
-			err := conn.ReadJSON(&chunk)
+			chunk, err := readChunk(conn)
 			if err != nil {
 				log.Printf("Read error: %v", err)
 				return
@@ -63,6 +71,10 @@ func main() {
 				fmt.Print(chunk.Content)
 			case "citation":
 				fmt.Printf("\n[Citation: %v]\n", chunk.Citation)
+			case "citations":
+				fmt.Printf("\n[Sources: %d]\n", len(chunk.Citations))
+			case "restart":
+				fmt.Println("\n[Restarting answer]")
 			case "error":
 				fmt.Printf("\n[Error: %v]\n", chunk.Error)
 				return
@@ -96,3 +108,19 @@ func main() {
 		}
 	}
 }
+
+// readChunk reads the next server message, decoding MessagePack binary
+// frames and JSON text frames
+func readChunk(conn *websocket.Conn) (StreamChunk, error) {
+	var chunk StreamChunk
+	messageType, data, err := conn.ReadMessage()
+	if err != nil {
+		return chunk, err
+	}
+	if messageType == websocket.BinaryMessage {
+		dec := msgpack.NewDecoder(bytes.NewReader(data))
+		dec.SetCustomStructTag("json")
+		return chunk, dec.Decode(&chunk)
+	}
+	return chunk, json.Unmarshal(data, &chunk)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -14,14 +15,28 @@ type Config struct {
 	AWS         AWSConfig
 	Bedrock     BedrockConfig
 	WebSocket   WebSocketConfig
+	Citation    CitationConfig
 	Session     SessionConfig
 	Logging     LoggingConfig
+	Metrics     MetricsConfig
+	Tracing     TracingConfig
+	Auth        AuthConfig
 }
 
 // ServerConfig holds server configuration
 type ServerConfig struct {
 	Port string
 	Host string
+	// ReadTimeout bounds reading a request, including its body
+	ReadTimeout time.Duration
+	// WriteTimeout bounds writing a REST response; streaming routes use the
+	// WebSocket stream timeout instead
+	WriteTimeout time.Duration
+	// IdleTimeout bounds how long a keep-alive connection waits for the next request
+	IdleTimeout time.Duration
+	// DrainTimeout is how long shutdown waits for in-flight chat turns to
+	// finish before cancelling them
+	DrainTimeout time.Duration
 }
 
 // AWSConfig holds AWS configuration
@@ -34,24 +49,94 @@ type AWSConfig struct {
 
 // BedrockConfig holds Bedrock Agent Core configuration
 type BedrockConfig struct {
-	AgentID          string
-	AgentAliasID     string
-	KnowledgeBaseID  string
-	ModelID          string
-	MaxRetries       int
-	InitialBackoff   time.Duration
-	MaxBackoff       time.Duration
-	RequestTimeout   time.Duration
+	// Mode is the default chat mode: "agent", "knowledge_base" or "model"
+	Mode            string
+	AgentID         string
+	AgentAliasID    string
+	KnowledgeBaseID string
+	ModelID         string
+	// ModelIDSet is true when BEDROCK_MODEL_ID was set rather than defaulted
+	ModelIDSet     bool
+	MaxRetries     int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	RequestTimeout time.Duration
+	// RetryJitter randomizes retry backoff: "full", "decorrelated" or "none"
+	RetryJitter string
+	// RetryBudget bounds the time a call may spend retrying; zero means no bound
+	RetryBudget time.Duration
+	// BreakerEnabled wraps each Bedrock service in a circuit breaker that
+	// fails fast once BreakerFailureRatio of the calls in BreakerWindow fail
+	// (after at least BreakerMinRequests calls), then probes again after
+	// BreakerOpenTimeout with BreakerHalfOpenProbes calls
+	BreakerEnabled        bool
+	BreakerFailureRatio   float64
+	BreakerMinRequests    int
+	BreakerWindow         time.Duration
+	BreakerOpenTimeout    time.Duration
+	BreakerHalfOpenProbes int
+	// SystemPrompt, Temperature and MaxTokens configure direct model chat
+	SystemPrompt string
+	Temperature  float64
+	MaxTokens    int
+	// MaxHistoryMessages bounds how many earlier messages are sent to the model
+	MaxHistoryMessages int
+	// GuardrailID and GuardrailVersion select the guardrail applied in
+	// knowledge_base and model modes; agents use their own guardrail
+	GuardrailID      string
+	GuardrailVersion string
 }
 
 // WebSocketConfig holds WebSocket configuration
 type WebSocketConfig struct {
-	Timeout          time.Duration
-	BufferSize       int
-	ReadBufferSize   int
-	WriteBufferSize  int
-	StreamTimeout    time.Duration
-	ChunkTimeout     time.Duration
+	Timeout         time.Duration
+	BufferSize      int
+	ReadBufferSize  int
+	WriteBufferSize int
+	StreamTimeout   time.Duration
+	ChunkTimeout    time.Duration
+	// InlineCitationMarkers injects [n] footnote markers into streamed content
+	InlineCitationMarkers bool
+	// StreamResume says how an answer resumes after its stream fails part way
+	// with a retryable error: "off", "restart" or "continue"
+	StreamResume string
+	// StreamMaxResumes bounds how many times one answer is resumed
+	StreamMaxResumes int
+	// StreamMiddlewares names the filters streamed content passes through,
+	// in order: utf8, null_bytes, pii, profanity, markdown
+	StreamMiddlewares []string
+	// StreamProfanityWords are masked by the profanity middleware in
+	// addition to its built-in list
+	StreamProfanityWords []string
+	// CoalesceBytes writes buffered content once it reaches this size;
+	// zero means no size limit
+	CoalesceBytes int
+	// CoalesceInterval writes buffered content this long after it started
+	// buffering; zero means no time limit
+	CoalesceInterval time.Duration
+	// SendQueueSize bounds the chunks waiting to be sent to a client
+	SendQueueSize int
+	// SlowConsumerTimeout is how long an answer waits for a client that
+	// stopped reading before it is abandoned
+	SlowConsumerTimeout time.Duration
+	// Compression negotiates permessage-deflate with clients that offer it
+	Compression bool
+	// CompressionLevel is the deflate level, from -2 (Huffman only) to 9
+	CompressionLevel int
+}
+
+// CitationConfig holds configuration for citation source links
+type CitationConfig struct {
+	// PresignS3 replaces s3:// source URIs with presigned HTTPS URLs
+	PresignS3     bool
+	PresignExpiry time.Duration
+	// AllowedBuckets lists the buckets whose objects may be linked
+	AllowedBuckets []string
+	// S3Endpoint and S3UsePathStyle point presigning at an S3-compatible store
+	S3Endpoint     string
+	S3UsePathStyle bool
+	// MaxPerAnswer caps the citations sent for one answer; 0 means no cap
+	MaxPerAnswer int
 }
 
 // SessionConfig holds session configuration
@@ -63,15 +148,50 @@ type SessionConfig struct {
 type LoggingConfig struct {
 	Level  string
 	Format string
+	// RedactContent keeps message content and search queries out of logs
+	RedactContent bool
+}
+
+// MetricsConfig holds Prometheus metrics configuration
+type MetricsConfig struct {
+	// Enabled serves metrics at Path
+	Enabled bool
+	Path    string
+}
+
+// TracingConfig holds OpenTelemetry tracing configuration
+type TracingConfig struct {
+	// Exporter is where spans are sent: none, otlp or stdout
+	Exporter string
+	// OTLPEndpoint is the OTLP/HTTP collector address
+	OTLPEndpoint string
+	// OTLPInsecure sends spans to the collector over plain HTTP
+	OTLPInsecure bool
+	ServiceName  string
+	// SampleRatio is the fraction of new traces recorded
+	SampleRatio float64
 }
 
+// AuthConfig holds API authentication configuration
+type AuthConfig struct {
+	// APITokens are the bearer tokens accepted on /api routes; none disables authentication
+	APITokens []string
+}
+
+// minAPITokenLength is the shortest bearer token accepted
+const minAPITokenLength = 16
+
 // Load loads configuration from environment variables
 func Load() (*Config, error) {
 	cfg := &Config{
 		Environment: getEnv("ENVIRONMENT", "development"),
 		Server: ServerConfig{
-			Port: getEnv("SERVER_PORT", "8080"),
-			Host: getEnv("SERVER_HOST", "0.0.0.0"),
+			Port:         getEnv("SERVER_PORT", "8080"),
+			Host:         getEnv("SERVER_HOST", "0.0.0.0"),
+			ReadTimeout:  getEnvAsDuration("SERVER_READ_TIMEOUT", 15*time.Second),
+			WriteTimeout: getEnvAsDuration("SERVER_WRITE_TIMEOUT", 15*time.Second),
+			IdleTimeout:  getEnvAsDuration("SERVER_IDLE_TIMEOUT", 60*time.Second),
+			DrainTimeout: getEnvAsDuration("SERVER_DRAIN_TIMEOUT", 30*time.Second),
 		},
 		AWS: AWSConfig{
 			Region:          getEnv("AWS_REGION", "us-east-1"),
@@ -80,29 +200,79 @@ func Load() (*Config, error) {
 			SessionToken:    getEnv("AWS_SESSION_TOKEN", ""),
 		},
 		Bedrock: BedrockConfig{
-			AgentID:          getEnv("BEDROCK_AGENT_ID", ""),
-			AgentAliasID:     getEnv("BEDROCK_AGENT_ALIAS_ID", ""),
-			KnowledgeBaseID:  getEnv("BEDROCK_KNOWLEDGE_BASE_ID", ""),
-			ModelID:          getEnv("BEDROCK_MODEL_ID", "anthropic.claude-v2"),
-			MaxRetries:       getEnvAsInt("BEDROCK_MAX_RETRIES", 3),
-			InitialBackoff:   getEnvAsDuration("BEDROCK_INITIAL_BACKOFF", 1*time.Second),
-			MaxBackoff:       getEnvAsDuration("BEDROCK_MAX_BACKOFF", 30*time.Second),
-			RequestTimeout:   getEnvAsDuration("BEDROCK_REQUEST_TIMEOUT", 60*time.Second),
+			Mode:                  getEnv("BEDROCK_MODE", "agent"),
+			AgentID:               getEnv("BEDROCK_AGENT_ID", ""),
+			AgentAliasID:          getEnv("BEDROCK_AGENT_ALIAS_ID", ""),
+			KnowledgeBaseID:       getEnv("BEDROCK_KNOWLEDGE_BASE_ID", ""),
+			ModelID:               getEnv("BEDROCK_MODEL_ID", "anthropic.claude-v2"),
+			ModelIDSet:            os.Getenv("BEDROCK_MODEL_ID") != "",
+			MaxRetries:            getEnvAsInt("BEDROCK_MAX_RETRIES", 3),
+			InitialBackoff:        getEnvAsDuration("BEDROCK_INITIAL_BACKOFF", 1*time.Second),
+			MaxBackoff:            getEnvAsDuration("BEDROCK_MAX_BACKOFF", 30*time.Second),
+			RequestTimeout:        getEnvAsDuration("BEDROCK_REQUEST_TIMEOUT", 60*time.Second),
+			RetryJitter:           getEnv("BEDROCK_RETRY_JITTER", "full"),
+			RetryBudget:           getEnvAsDuration("BEDROCK_RETRY_BUDGET", 45*time.Second),
+			BreakerEnabled:        getEnvAsBool("BEDROCK_BREAKER_ENABLED", true),
+			BreakerFailureRatio:   getEnvAsFloat("BEDROCK_BREAKER_FAILURE_RATIO", 0.5),
+			BreakerMinRequests:    getEnvAsInt("BEDROCK_BREAKER_MIN_REQUESTS", 10),
+			BreakerWindow:         getEnvAsDuration("BEDROCK_BREAKER_WINDOW", 60*time.Second),
+			BreakerOpenTimeout:    getEnvAsDuration("BEDROCK_BREAKER_OPEN_TIMEOUT", 30*time.Second),
+			BreakerHalfOpenProbes: getEnvAsInt("BEDROCK_BREAKER_HALF_OPEN_PROBES", 1),
+			SystemPrompt:          getEnv("BEDROCK_SYSTEM_PROMPT", ""),
+			Temperature:           getEnvAsFloat("BEDROCK_TEMPERATURE", 0.7),
+			MaxTokens:             getEnvAsInt("BEDROCK_MAX_TOKENS", 1024),
+			MaxHistoryMessages:    getEnvAsInt("BEDROCK_MAX_HISTORY_MESSAGES", 20),
+			GuardrailID:           getEnv("BEDROCK_GUARDRAIL_ID", ""),
+			GuardrailVersion:      getEnv("BEDROCK_GUARDRAIL_VERSION", ""),
 		},
 		WebSocket: WebSocketConfig{
-			Timeout:         getEnvAsDuration("WS_TIMEOUT", 30*time.Second),
-			BufferSize:      getEnvAsInt("WS_BUFFER_SIZE", 8192),
-			ReadBufferSize:  getEnvAsInt("WS_READ_BUFFER_SIZE", 1024),
-			WriteBufferSize: getEnvAsInt("WS_WRITE_BUFFER_SIZE", 1024),
-			StreamTimeout:   getEnvAsDuration("WS_STREAM_TIMEOUT", 5*time.Minute),
-			ChunkTimeout:    getEnvAsDuration("WS_CHUNK_TIMEOUT", 30*time.Second),
+			Timeout:               getEnvAsDuration("WS_TIMEOUT", 30*time.Second),
+			BufferSize:            getEnvAsInt("WS_BUFFER_SIZE", 8192),
+			ReadBufferSize:        getEnvAsInt("WS_READ_BUFFER_SIZE", 1024),
+			WriteBufferSize:       getEnvAsInt("WS_WRITE_BUFFER_SIZE", 1024),
+			StreamTimeout:         getEnvAsDuration("WS_STREAM_TIMEOUT", 5*time.Minute),
+			ChunkTimeout:          getEnvAsDuration("WS_CHUNK_TIMEOUT", 30*time.Second),
+			InlineCitationMarkers: getEnvAsBool("WS_INLINE_CITATION_MARKERS", false),
+			StreamResume:          getEnv("WS_STREAM_RESUME", "off"),
+			StreamMaxResumes:      getEnvAsInt("WS_STREAM_MAX_RESUMES", 1),
+			StreamMiddlewares:     getEnvAsListOr("WS_STREAM_MIDDLEWARES", []string{"utf8", "null_bytes"}),
+			StreamProfanityWords:  getEnvAsList("WS_STREAM_PROFANITY_WORDS"),
+			CoalesceBytes:         getEnvAsInt("WS_COALESCE_BYTES", 256),
+			CoalesceInterval:      getEnvAsDuration("WS_COALESCE_INTERVAL", 50*time.Millisecond),
+			SendQueueSize:         getEnvAsInt("WS_SEND_QUEUE_SIZE", 64),
+			SlowConsumerTimeout:   getEnvAsDuration("WS_SLOW_CONSUMER_TIMEOUT", 10*time.Second),
+			Compression:           getEnvAsBool("WS_COMPRESSION", true),
+			CompressionLevel:      getEnvAsInt("WS_COMPRESSION_LEVEL", 1),
+		},
+		Citation: CitationConfig{
+			PresignS3:      getEnvAsBool("CITATION_PRESIGN_S3", false),
+			PresignExpiry:  getEnvAsDuration("CITATION_PRESIGN_EXPIRY", 15*time.Minute),
+			AllowedBuckets: getEnvAsList("CITATION_ALLOWED_BUCKETS"),
+			S3Endpoint:     getEnv("S3_ENDPOINT", ""),
+			S3UsePathStyle: getEnvAsBool("S3_USE_PATH_STYLE", false),
+			MaxPerAnswer:   getEnvAsInt("CITATION_MAX_PER_ANSWER", 10),
 		},
 		Session: SessionConfig{
 			Timeout: getEnvAsDuration("SESSION_TIMEOUT", 30*time.Minute),
 		},
 		Logging: LoggingConfig{
-			Level:  getEnv("LOG_LEVEL", "info"),
-			Format: getEnv("LOG_FORMAT", "text"),
+			Level:         getEnv("LOG_LEVEL", "info"),
+			Format:        getEnv("LOG_FORMAT", "text"),
+			RedactContent: getEnvAsBool("LOG_REDACT_CONTENT", false),
+		},
+		Metrics: MetricsConfig{
+			Enabled: getEnvAsBool("METRICS_ENABLED", true),
+			Path:    getEnv("METRICS_PATH", "/metrics"),
+		},
+		Tracing: TracingConfig{
+			Exporter:     getEnv("TRACING_EXPORTER", "none"),
+			OTLPEndpoint: getEnv("TRACING_OTLP_ENDPOINT", "localhost:4318"),
+			OTLPInsecure: getEnvAsBool("TRACING_OTLP_INSECURE", false),
+			ServiceName:  getEnv("TRACING_SERVICE_NAME", "bedrock-chat-backend"),
+			SampleRatio:  getEnvAsFloat("TRACING_SAMPLE_RATIO", 1.0),
+		},
+		Auth: AuthConfig{
+			APITokens: getEnvAsList("AUTH_API_TOKENS"),
 		},
 	}
 
@@ -125,27 +295,82 @@ func (c *Config) Validate() error {
 	if c.Server.Port == "" {
 		return fmt.Errorf("server port is required")
 	}
+	// Zero server timeouts mean no timeout
+	if c.Server.ReadTimeout < 0 || c.Server.WriteTimeout < 0 || c.Server.IdleTimeout < 0 {
+		return fmt.Errorf("server read, write and idle timeouts cannot be negative")
+	}
+	if c.Server.DrainTimeout < 0 {
+		return fmt.Errorf("server drain timeout cannot be negative")
+	}
 
 	// Validate AWS region
 	if c.AWS.Region == "" {
 		return fmt.Errorf("AWS region is required")
 	}
 
-	// Validate Bedrock configuration (only in production and staging)
-	if c.Environment == "production" {
-		if c.Bedrock.AgentID == "" {
-			return fmt.Errorf("Bedrock agent ID is required in production")
+	// Validate Bedrock mode (empty defaults to agent)
+	if c.Bedrock.Mode != "" && c.Bedrock.Mode != "agent" && c.Bedrock.Mode != "knowledge_base" && c.Bedrock.Mode != "model" {
+		return fmt.Errorf("invalid Bedrock mode: %s (must be agent, knowledge_base, or model)", c.Bedrock.Mode)
+	}
+
+	// Validate model inference settings
+	if c.Bedrock.Temperature < 0 || c.Bedrock.Temperature > 1 {
+		return fmt.Errorf("Bedrock temperature must be between 0 and 1")
+	}
+	if c.Bedrock.MaxTokens < 0 {
+		return fmt.Errorf("Bedrock max tokens cannot be negative")
+	}
+	if c.Bedrock.MaxHistoryMessages < 0 {
+		return fmt.Errorf("Bedrock max history messages cannot be negative")
+	}
+	if c.Bedrock.RetryJitter != "" && c.Bedrock.RetryJitter != "full" && c.Bedrock.RetryJitter != "decorrelated" && c.Bedrock.RetryJitter != "none" {
+		return fmt.Errorf("invalid Bedrock retry jitter: %s (must be full, decorrelated, or none)", c.Bedrock.RetryJitter)
+	}
+	if c.Bedrock.RetryBudget < 0 {
+		return fmt.Errorf("Bedrock retry budget cannot be negative")
+	}
+	if c.Bedrock.GuardrailID != "" {
+		if c.Bedrock.GuardrailVersion == "" {
+			return fmt.Errorf("Bedrock guardrail version is required with a guardrail ID")
 		}
-		if c.Bedrock.AgentAliasID == "" {
-			return fmt.Errorf("Bedrock agent alias ID is required in production")
+		version, err := strconv.Atoi(c.Bedrock.GuardrailVersion)
+		if c.Bedrock.GuardrailVersion != "DRAFT" && (err != nil || version <= 0) {
+			return fmt.Errorf("invalid Bedrock guardrail version: %s (must be DRAFT or a positive number)", c.Bedrock.GuardrailVersion)
 		}
 	}
-	if c.Environment == "staging" {
-		if c.Bedrock.AgentID == "" {
-			return fmt.Errorf("Bedrock agent ID is required in staging")
+	if c.Bedrock.BreakerEnabled {
+		if c.Bedrock.BreakerFailureRatio <= 0 || c.Bedrock.BreakerFailureRatio > 1 {
+			return fmt.Errorf("Bedrock breaker failure ratio must be greater than 0 and at most 1")
 		}
-		if c.Bedrock.AgentAliasID == "" {
-			return fmt.Errorf("Bedrock agent alias ID is required in staging")
+		if c.Bedrock.BreakerMinRequests <= 0 {
+			return fmt.Errorf("Bedrock breaker min requests must be positive")
+		}
+		if c.Bedrock.BreakerWindow <= 0 || c.Bedrock.BreakerOpenTimeout <= 0 {
+			return fmt.Errorf("Bedrock breaker window and open timeout must be positive")
+		}
+		if c.Bedrock.BreakerHalfOpenProbes <= 0 {
+			return fmt.Errorf("Bedrock breaker half-open probes must be positive")
+		}
+	}
+
+	// Validate Bedrock configuration (only in production and staging)
+	if c.Environment == "production" || c.Environment == "staging" {
+		switch c.Bedrock.Mode {
+		case "knowledge_base":
+			if c.Bedrock.KnowledgeBaseID == "" {
+				return fmt.Errorf("Bedrock knowledge base ID is required in %s", c.Environment)
+			}
+		case "model":
+			if c.Bedrock.ModelID == "" {
+				return fmt.Errorf("Bedrock model ID is required in %s", c.Environment)
+			}
+		default:
+			if c.Bedrock.AgentID == "" {
+				return fmt.Errorf("Bedrock agent ID is required in %s", c.Environment)
+			}
+			if c.Bedrock.AgentAliasID == "" {
+				return fmt.Errorf("Bedrock agent alias ID is required in %s", c.Environment)
+			}
 		}
 	}
 
@@ -156,6 +381,85 @@ func (c *Config) Validate() error {
 	if c.WebSocket.BufferSize <= 0 {
 		return fmt.Errorf("WebSocket buffer size must be positive")
 	}
+	if c.WebSocket.StreamResume != "" && c.WebSocket.StreamResume != "off" && c.WebSocket.StreamResume != "restart" && c.WebSocket.StreamResume != "continue" {
+		return fmt.Errorf("invalid WebSocket stream resume policy: %s (must be off, restart, or continue)", c.WebSocket.StreamResume)
+	}
+	if c.WebSocket.StreamMaxResumes < 0 {
+		return fmt.Errorf("WebSocket stream max resumes cannot be negative")
+	}
+	if c.WebSocket.CoalesceBytes < 0 {
+		return fmt.Errorf("WebSocket coalesce bytes cannot be negative")
+	}
+	if c.WebSocket.CoalesceInterval < 0 {
+		return fmt.Errorf("WebSocket coalesce interval cannot be negative")
+	}
+	if c.WebSocket.SendQueueSize < 0 {
+		return fmt.Errorf("WebSocket send queue size cannot be negative")
+	}
+	if c.WebSocket.SlowConsumerTimeout < 0 {
+		return fmt.Errorf("WebSocket slow consumer timeout cannot be negative")
+	}
+	if c.WebSocket.Compression && (c.WebSocket.CompressionLevel < -2 || c.WebSocket.CompressionLevel > 9) {
+		return fmt.Errorf("WebSocket compression level must be between -2 and 9")
+	}
+	for _, middleware := range c.WebSocket.StreamMiddlewares {
+		switch middleware {
+		case "utf8", "null_bytes", "pii", "profanity", "markdown":
+		default:
+			return fmt.Errorf("invalid WebSocket stream middleware: %s (must be utf8, null_bytes, pii, profanity, or markdown)", middleware)
+		}
+	}
+
+	// Validate citation settings
+	if c.Citation.MaxPerAnswer < 0 {
+		return fmt.Errorf("citation max per answer cannot be negative")
+	}
+	if c.Citation.PresignS3 {
+		if c.Citation.PresignExpiry <= 0 || c.Citation.PresignExpiry > 7*24*time.Hour {
+			return fmt.Errorf("citation presign expiry must be positive and at most 7 days")
+		}
+		if len(c.Citation.AllowedBuckets) == 0 {
+			return fmt.Errorf("citation allowed buckets are required when presigning is enabled")
+		}
+	}
+
+	// Validate logging settings (empty values use the defaults)
+	switch strings.ToLower(c.Logging.Level) {
+	case "", "debug", "info", "warn", "warning", "error":
+	default:
+		return fmt.Errorf("invalid log level: %s (must be debug, info, warn, or error)", c.Logging.Level)
+	}
+	switch strings.ToLower(c.Logging.Format) {
+	case "", "text", "json":
+	default:
+		return fmt.Errorf("invalid log format: %s (must be text or json)", c.Logging.Format)
+	}
+
+	// Validate metrics settings
+	if c.Metrics.Enabled && !strings.HasPrefix(c.Metrics.Path, "/") {
+		return fmt.Errorf("metrics path must start with /")
+	}
+
+	// Validate tracing settings (an empty exporter disables tracing)
+	switch strings.ToLower(c.Tracing.Exporter) {
+	case "", "none", "stdout":
+	case "otlp":
+		if c.Tracing.OTLPEndpoint == "" {
+			return fmt.Errorf("tracing OTLP endpoint is required for the otlp exporter")
+		}
+	default:
+		return fmt.Errorf("invalid tracing exporter: %s (must be none, otlp, or stdout)", c.Tracing.Exporter)
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		return fmt.Errorf("tracing sample ratio must be between 0 and 1")
+	}
+
+	// Validate API tokens (none disables authentication)
+	for _, token := range c.Auth.APITokens {
+		if len(token) < minAPITokenLength {
+			return fmt.Errorf("API tokens must be at least %d characters", minAPITokenLength)
+		}
+	}
 
 	// Validate session timeout
 	if c.Session.Timeout <= 0 {
@@ -165,6 +469,12 @@ func (c *Config) Validate() error {
 	return nil
 }
 
+// ModelModeEnabled reports whether direct model chat is offered: when it is
+// the default mode, or when a model was chosen with BEDROCK_MODEL_ID
+func (c *BedrockConfig) ModelModeEnabled() bool {
+	return c.Mode == "model" || c.ModelIDSet
+}
+
 // IsDevelopment returns true if running in development mode
 func (c *Config) IsDevelopment() bool {
 	return c.Environment == "development"
@@ -209,6 +519,58 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return value
 }
 
+// getEnvAsBool gets an environment variable as a boolean with a default value
+func getEnvAsBool(key string, defaultValue bool) bool {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+
+	value, err := strconv.ParseBool(valueStr)
+	if err != nil {
+		return defaultValue
+	}
+
+	return value
+}
+
+// getEnvAsFloat gets an environment variable as a float with a default value
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+
+	value, err := strconv.ParseFloat(valueStr, 64)
+	if err != nil {
+		return defaultValue
+	}
+
+	return value
+}
+
+// getEnvAsList gets a comma-separated environment variable as a list,
+// ignoring blank entries
+func getEnvAsList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// getEnvAsListOr gets a comma-separated environment variable as a list like
+// getEnvAsList, or defaultValue if the variable is not set. Setting it empty
+// gives an empty list.
+func getEnvAsListOr(key string, defaultValue []string) []string {
+	if _, ok := os.LookupEnv(key); !ok {
+		return defaultValue
+	}
+	return getEnvAsList(key)
+}
+
 // getEnvAsDuration gets an environment variable as a duration with a default value
 func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
 	valueStr := os.Getenv(key)
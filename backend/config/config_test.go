@@ -242,6 +242,75 @@ func TestConfig_Validate(t *testing.T) {
 	}
 }
 
+func TestConfig_ValidateBedrockMode(t *testing.T) {
+	base := func(environment string, bedrock BedrockConfig) *Config {
+		return &Config{
+			Environment: environment,
+			Server:      ServerConfig{Port: "8080"},
+			AWS:         AWSConfig{Region: "ap-southeast-1"},
+			Bedrock:     bedrock,
+			WebSocket:   WebSocketConfig{Timeout: 30 * time.Second, BufferSize: 8192},
+			Session:     SessionConfig{Timeout: 30 * time.Minute},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		config  *Config
+		wantErr bool
+	}{
+		{
+			name:    "unknown mode",
+			config:  base("development", BedrockConfig{Mode: "oracle"}),
+			wantErr: true,
+		},
+		{
+			name:    "knowledge base mode in production with knowledge base ID",
+			config:  base("production", BedrockConfig{Mode: "knowledge_base", KnowledgeBaseID: "KB123"}),
+			wantErr: false,
+		},
+		{
+			name:    "knowledge base mode in staging without knowledge base ID",
+			config:  base("staging", BedrockConfig{Mode: "knowledge_base", AgentID: "agent", AgentAliasID: "alias"}),
+			wantErr: true,
+		},
+		{
+			name:    "model mode in production",
+			config:  base("production", BedrockConfig{Mode: "model", ModelID: "anthropic.claude-3-haiku-20240307-v1:0"}),
+			wantErr: false,
+		},
+		{
+			name:    "model mode in production without model ID",
+			config:  base("production", BedrockConfig{Mode: "model"}),
+			wantErr: true,
+		},
+		{
+			name:    "temperature out of range",
+			config:  base("development", BedrockConfig{Mode: "model", Temperature: 1.5}),
+			wantErr: true,
+		},
+		{
+			name:    "negative max tokens",
+			config:  base("development", BedrockConfig{Mode: "model", MaxTokens: -1}),
+			wantErr: true,
+		},
+		{
+			name:    "agent mode in production without agent",
+			config:  base("production", BedrockConfig{Mode: "agent", KnowledgeBaseID: "KB123"}),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Config.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestConfig_EnvironmentChecks(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -381,3 +450,536 @@ func TestGetEnvAsInt(t *testing.T) {
 		})
 	}
 }
+
+func TestConfig_ValidateCitation(t *testing.T) {
+	tests := []struct {
+		name     string
+		citation CitationConfig
+		wantErr  bool
+	}{
+		{
+			name:     "presigning disabled",
+			citation: CitationConfig{},
+			wantErr:  false,
+		},
+		{
+			name:     "presigning with allowed buckets",
+			citation: CitationConfig{PresignS3: true, PresignExpiry: 15 * time.Minute, AllowedBuckets: []string{"company-docs"}},
+			wantErr:  false,
+		},
+		{
+			name:     "presigning without allowed buckets",
+			citation: CitationConfig{PresignS3: true, PresignExpiry: 15 * time.Minute},
+			wantErr:  true,
+		},
+		{
+			name:     "negative max per answer",
+			citation: CitationConfig{MaxPerAnswer: -1},
+			wantErr:  true,
+		},
+		{
+			name:     "presign expiry beyond seven days",
+			citation: CitationConfig{PresignS3: true, PresignExpiry: 8 * 24 * time.Hour, AllowedBuckets: []string{"company-docs"}},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{
+				Environment: "development",
+				Server:      ServerConfig{Port: "8080"},
+				AWS:         AWSConfig{Region: "ap-southeast-1"},
+				WebSocket:   WebSocketConfig{Timeout: 30 * time.Second, BufferSize: 8192},
+				Citation:    tt.citation,
+				Session:     SessionConfig{Timeout: 30 * time.Minute},
+			}
+			err := config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Config.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateLogging(t *testing.T) {
+	tests := []struct {
+		name    string
+		logging LoggingConfig
+		wantErr bool
+	}{
+		{
+			name:    "defaults",
+			logging: LoggingConfig{},
+			wantErr: false,
+		},
+		{
+			name:    "debug json with redaction",
+			logging: LoggingConfig{Level: "debug", Format: "json", RedactContent: true},
+			wantErr: false,
+		},
+		{
+			name:    "level is case insensitive",
+			logging: LoggingConfig{Level: "WARN", Format: "TEXT"},
+			wantErr: false,
+		},
+		{
+			name:    "invalid level",
+			logging: LoggingConfig{Level: "verbose", Format: "text"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid format",
+			logging: LoggingConfig{Level: "info", Format: "xml"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{
+				Environment: "development",
+				Server:      ServerConfig{Port: "8080"},
+				AWS:         AWSConfig{Region: "ap-southeast-1"},
+				WebSocket:   WebSocketConfig{Timeout: 30 * time.Second, BufferSize: 8192},
+				Session:     SessionConfig{Timeout: 30 * time.Minute},
+				Logging:     tt.logging,
+			}
+			err := config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Config.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateMetrics(t *testing.T) {
+	tests := []struct {
+		name    string
+		metrics MetricsConfig
+		wantErr bool
+	}{
+		{
+			name:    "enabled",
+			metrics: MetricsConfig{Enabled: true, Path: "/metrics"},
+			wantErr: false,
+		},
+		{
+			name:    "disabled without path",
+			metrics: MetricsConfig{},
+			wantErr: false,
+		},
+		{
+			name:    "relative path",
+			metrics: MetricsConfig{Enabled: true, Path: "metrics"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{
+				Environment: "development",
+				Server:      ServerConfig{Port: "8080"},
+				AWS:         AWSConfig{Region: "ap-southeast-1"},
+				WebSocket:   WebSocketConfig{Timeout: 30 * time.Second, BufferSize: 8192},
+				Session:     SessionConfig{Timeout: 30 * time.Minute},
+				Metrics:     tt.metrics,
+			}
+			err := config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Config.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateTracing(t *testing.T) {
+	tests := []struct {
+		name    string
+		tracing TracingConfig
+		wantErr bool
+	}{
+		{
+			name:    "disabled",
+			tracing: TracingConfig{Exporter: "none"},
+			wantErr: false,
+		},
+		{
+			name:    "otlp",
+			tracing: TracingConfig{Exporter: "otlp", OTLPEndpoint: "collector:4318", SampleRatio: 0.5},
+			wantErr: false,
+		},
+		{
+			name:    "stdout",
+			tracing: TracingConfig{Exporter: "stdout", SampleRatio: 1},
+			wantErr: false,
+		},
+		{
+			name:    "otlp without endpoint",
+			tracing: TracingConfig{Exporter: "otlp", SampleRatio: 1},
+			wantErr: true,
+		},
+		{
+			name:    "unknown exporter",
+			tracing: TracingConfig{Exporter: "zipkin"},
+			wantErr: true,
+		},
+		{
+			name:    "sample ratio above one",
+			tracing: TracingConfig{Exporter: "stdout", SampleRatio: 1.5},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{
+				Environment: "development",
+				Server:      ServerConfig{Port: "8080"},
+				AWS:         AWSConfig{Region: "ap-southeast-1"},
+				WebSocket:   WebSocketConfig{Timeout: 30 * time.Second, BufferSize: 8192},
+				Session:     SessionConfig{Timeout: 30 * time.Minute},
+				Tracing:     tt.tracing,
+			}
+			err := config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Config.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateServerTimeouts(t *testing.T) {
+	tests := []struct {
+		name    string
+		server  ServerConfig
+		wantErr bool
+	}{
+		{
+			name:    "defaults",
+			server:  ServerConfig{Port: "8080", ReadTimeout: 15 * time.Second, WriteTimeout: 15 * time.Second, IdleTimeout: 60 * time.Second, DrainTimeout: 30 * time.Second},
+			wantErr: false,
+		},
+		{name: "zero means no timeout", server: ServerConfig{Port: "8080"}, wantErr: false},
+		{name: "negative read timeout", server: ServerConfig{Port: "8080", ReadTimeout: -time.Second}, wantErr: true},
+		{name: "negative write timeout", server: ServerConfig{Port: "8080", WriteTimeout: -time.Second}, wantErr: true},
+		{name: "negative idle timeout", server: ServerConfig{Port: "8080", IdleTimeout: -time.Second}, wantErr: true},
+		{name: "negative drain timeout", server: ServerConfig{Port: "8080", DrainTimeout: -time.Second}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{
+				Environment: "development",
+				Server:      tt.server,
+				AWS:         AWSConfig{Region: "ap-southeast-1"},
+				WebSocket:   WebSocketConfig{Timeout: 30 * time.Second, BufferSize: 8192},
+				Session:     SessionConfig{Timeout: 30 * time.Minute},
+			}
+			err := config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Config.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateBedrockRetry(t *testing.T) {
+	tests := []struct {
+		name    string
+		bedrock BedrockConfig
+		wantErr bool
+	}{
+		{name: "defaults", bedrock: BedrockConfig{RetryJitter: "full", RetryBudget: 45 * time.Second}, wantErr: false},
+		{name: "unset", bedrock: BedrockConfig{}, wantErr: false},
+		{name: "decorrelated jitter", bedrock: BedrockConfig{RetryJitter: "decorrelated"}, wantErr: false},
+		{name: "no jitter", bedrock: BedrockConfig{RetryJitter: "none"}, wantErr: false},
+		{name: "unknown jitter", bedrock: BedrockConfig{RetryJitter: "random"}, wantErr: true},
+		{name: "negative budget", bedrock: BedrockConfig{RetryBudget: -time.Second}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{
+				Environment: "development",
+				Server:      ServerConfig{Port: "8080"},
+				AWS:         AWSConfig{Region: "ap-southeast-1"},
+				Bedrock:     tt.bedrock,
+				WebSocket:   WebSocketConfig{Timeout: 30 * time.Second, BufferSize: 8192},
+				Session:     SessionConfig{Timeout: 30 * time.Minute},
+			}
+			err := config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Config.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateBedrockGuardrail(t *testing.T) {
+	tests := []struct {
+		name    string
+		bedrock BedrockConfig
+		wantErr bool
+	}{
+		{name: "no guardrail", bedrock: BedrockConfig{}, wantErr: false},
+		{name: "numbered version", bedrock: BedrockConfig{GuardrailID: "gr-abc123", GuardrailVersion: "3"}, wantErr: false},
+		{name: "draft version", bedrock: BedrockConfig{GuardrailID: "gr-abc123", GuardrailVersion: "DRAFT"}, wantErr: false},
+		{name: "missing version", bedrock: BedrockConfig{GuardrailID: "gr-abc123"}, wantErr: true},
+		{name: "zero version", bedrock: BedrockConfig{GuardrailID: "gr-abc123", GuardrailVersion: "0"}, wantErr: true},
+		{name: "unknown version", bedrock: BedrockConfig{GuardrailID: "gr-abc123", GuardrailVersion: "latest"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{
+				Environment: "development",
+				Server:      ServerConfig{Port: "8080"},
+				AWS:         AWSConfig{Region: "ap-southeast-1"},
+				Bedrock:     tt.bedrock,
+				WebSocket:   WebSocketConfig{Timeout: 30 * time.Second, BufferSize: 8192},
+				Session:     SessionConfig{Timeout: 30 * time.Minute},
+			}
+			err := config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Config.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateBedrockBreaker(t *testing.T) {
+	valid := BedrockConfig{
+		BreakerEnabled:        true,
+		BreakerFailureRatio:   0.5,
+		BreakerMinRequests:    10,
+		BreakerWindow:         time.Minute,
+		BreakerOpenTimeout:    30 * time.Second,
+		BreakerHalfOpenProbes: 1,
+	}
+	with := func(change func(*BedrockConfig)) BedrockConfig {
+		bedrock := valid
+		change(&bedrock)
+		return bedrock
+	}
+
+	tests := []struct {
+		name    string
+		bedrock BedrockConfig
+		wantErr bool
+	}{
+		{name: "defaults", bedrock: valid, wantErr: false},
+		{name: "disabled", bedrock: BedrockConfig{}, wantErr: false},
+		{name: "zero failure ratio", bedrock: with(func(b *BedrockConfig) { b.BreakerFailureRatio = 0 }), wantErr: true},
+		{name: "failure ratio above 1", bedrock: with(func(b *BedrockConfig) { b.BreakerFailureRatio = 1.5 }), wantErr: true},
+		{name: "no min requests", bedrock: with(func(b *BedrockConfig) { b.BreakerMinRequests = 0 }), wantErr: true},
+		{name: "no window", bedrock: with(func(b *BedrockConfig) { b.BreakerWindow = 0 }), wantErr: true},
+		{name: "no open timeout", bedrock: with(func(b *BedrockConfig) { b.BreakerOpenTimeout = 0 }), wantErr: true},
+		{name: "no probes", bedrock: with(func(b *BedrockConfig) { b.BreakerHalfOpenProbes = 0 }), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{
+				Environment: "development",
+				Server:      ServerConfig{Port: "8080"},
+				AWS:         AWSConfig{Region: "ap-southeast-1"},
+				Bedrock:     tt.bedrock,
+				WebSocket:   WebSocketConfig{Timeout: 30 * time.Second, BufferSize: 8192},
+				Session:     SessionConfig{Timeout: 30 * time.Minute},
+			}
+			err := config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Config.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateStreamResume(t *testing.T) {
+	tests := []struct {
+		name      string
+		websocket WebSocketConfig
+		wantErr   bool
+	}{
+		{name: "unset", websocket: WebSocketConfig{}, wantErr: false},
+		{name: "off", websocket: WebSocketConfig{StreamResume: "off", StreamMaxResumes: 1}, wantErr: false},
+		{name: "restart", websocket: WebSocketConfig{StreamResume: "restart", StreamMaxResumes: 2}, wantErr: false},
+		{name: "continue", websocket: WebSocketConfig{StreamResume: "continue", StreamMaxResumes: 1}, wantErr: false},
+		{name: "unknown policy", websocket: WebSocketConfig{StreamResume: "retry"}, wantErr: true},
+		{name: "negative max resumes", websocket: WebSocketConfig{StreamResume: "restart", StreamMaxResumes: -1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.websocket.Timeout = 30 * time.Second
+			tt.websocket.BufferSize = 8192
+			config := &Config{
+				Environment: "development",
+				Server:      ServerConfig{Port: "8080"},
+				AWS:         AWSConfig{Region: "ap-southeast-1"},
+				WebSocket:   tt.websocket,
+				Session:     SessionConfig{Timeout: 30 * time.Minute},
+			}
+			err := config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Config.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateStreamMiddlewares(t *testing.T) {
+	tests := []struct {
+		name        string
+		middlewares []string
+		wantErr     bool
+	}{
+		{name: "none", middlewares: nil, wantErr: false},
+		{name: "all", middlewares: []string{"utf8", "null_bytes", "pii", "profanity", "markdown"}, wantErr: false},
+		{name: "unknown", middlewares: []string{"utf8", "spellcheck"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{
+				Environment: "development",
+				Server:      ServerConfig{Port: "8080"},
+				AWS:         AWSConfig{Region: "ap-southeast-1"},
+				WebSocket:   WebSocketConfig{Timeout: 30 * time.Second, BufferSize: 8192, StreamMiddlewares: tt.middlewares},
+				Session:     SessionConfig{Timeout: 30 * time.Minute},
+			}
+			err := config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Config.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateSocketWrites(t *testing.T) {
+	tests := []struct {
+		name      string
+		websocket WebSocketConfig
+		wantErr   bool
+	}{
+		{name: "defaults", websocket: WebSocketConfig{CoalesceBytes: 256, CoalesceInterval: 50 * time.Millisecond, SendQueueSize: 64, SlowConsumerTimeout: 10 * time.Second}, wantErr: false},
+		{name: "coalescing off", websocket: WebSocketConfig{}, wantErr: false},
+		{name: "negative coalesce bytes", websocket: WebSocketConfig{CoalesceBytes: -1}, wantErr: true},
+		{name: "negative coalesce interval", websocket: WebSocketConfig{CoalesceInterval: -time.Millisecond}, wantErr: true},
+		{name: "negative send queue size", websocket: WebSocketConfig{SendQueueSize: -1}, wantErr: true},
+		{name: "negative slow consumer timeout", websocket: WebSocketConfig{SlowConsumerTimeout: -time.Second}, wantErr: true},
+		{name: "compression level", websocket: WebSocketConfig{Compression: true, CompressionLevel: 9}, wantErr: false},
+		{name: "compression level out of range", websocket: WebSocketConfig{Compression: true, CompressionLevel: 10}, wantErr: true},
+		{name: "level ignored without compression", websocket: WebSocketConfig{CompressionLevel: 10}, wantErr: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.websocket.Timeout = 30 * time.Second
+			tt.websocket.BufferSize = 8192
+			config := &Config{
+				Environment: "development",
+				Server:      ServerConfig{Port: "8080"},
+				AWS:         AWSConfig{Region: "ap-southeast-1"},
+				WebSocket:   tt.websocket,
+				Session:     SessionConfig{Timeout: 30 * time.Minute},
+			}
+			err := config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Config.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetEnvAsListOr(t *testing.T) {
+	defaults := []string{"utf8", "null_bytes"}
+
+	os.Unsetenv("TEST_LIST_OR")
+	if got := getEnvAsListOr("TEST_LIST_OR", defaults); len(got) != 2 {
+		t.Errorf("Expected the default when unset, got %v", got)
+	}
+
+	t.Setenv("TEST_LIST_OR", "")
+	if got := getEnvAsListOr("TEST_LIST_OR", defaults); len(got) != 0 {
+		t.Errorf("Expected an empty list when set empty, got %v", got)
+	}
+
+	t.Setenv("TEST_LIST_OR", "pii, markdown")
+	if got := getEnvAsListOr("TEST_LIST_OR", defaults); len(got) != 2 || got[0] != "pii" || got[1] != "markdown" {
+		t.Errorf("Expected [pii markdown], got %v", got)
+	}
+}
+
+func TestConfig_ValidateAuth(t *testing.T) {
+	tests := []struct {
+		name    string
+		auth    AuthConfig
+		wantErr bool
+	}{
+		{name: "disabled", auth: AuthConfig{}, wantErr: false},
+		{name: "long tokens", auth: AuthConfig{APITokens: []string{"0123456789abcdef", "fedcba9876543210"}}, wantErr: false},
+		{name: "short token", auth: AuthConfig{APITokens: []string{"0123456789abcdef", "secret"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{
+				Environment: "development",
+				Server:      ServerConfig{Port: "8080"},
+				AWS:         AWSConfig{Region: "ap-southeast-1"},
+				WebSocket:   WebSocketConfig{Timeout: 30 * time.Second, BufferSize: 8192},
+				Session:     SessionConfig{Timeout: 30 * time.Minute},
+				Auth:        tt.auth,
+			}
+			err := config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Config.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetEnvAsList(t *testing.T) {
+	os.Setenv("TEST_LIST", " company-docs, ,hr-policies ")
+	defer os.Unsetenv("TEST_LIST")
+
+	got := getEnvAsList("TEST_LIST")
+	if len(got) != 2 || got[0] != "company-docs" || got[1] != "hr-policies" {
+		t.Errorf("getEnvAsList() = %q, want [company-docs hr-policies]", got)
+	}
+	if got := getEnvAsList("TEST_LIST_UNSET"); len(got) != 0 {
+		t.Errorf("getEnvAsList() of unset variable = %q, want empty", got)
+	}
+}
+
+func TestBedrockConfig_ModelModeEnabled(t *testing.T) {
+	t.Setenv("ENVIRONMENT", "development")
+	t.Setenv("BEDROCK_AGENT_ID", "AGENT123")
+	t.Setenv("BEDROCK_AGENT_ALIAS_ID", "ALIAS123")
+	t.Setenv("BEDROCK_MODE", "agent")
+	t.Setenv("BEDROCK_MODEL_ID", "")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Bedrock.ModelModeEnabled() {
+		t.Error("Expected model mode to stay off with the default model ID")
+	}
+
+	t.Setenv("BEDROCK_MODEL_ID", "anthropic.claude-3-haiku-20240307-v1:0")
+	if cfg, err = Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !cfg.Bedrock.ModelModeEnabled() {
+		t.Error("Expected model mode when BEDROCK_MODEL_ID is set")
+	}
+
+	cfg.Bedrock = BedrockConfig{Mode: "model"}
+	if !cfg.Bedrock.ModelModeEnabled() {
+		t.Error("Expected model mode when it is the default mode")
+	}
+}
@@ -17,6 +17,8 @@ const (
 	StatusSending MessageStatus = "sending"
 	StatusSent    MessageStatus = "sent"
 	StatusError   MessageStatus = "error"
+	// StatusBlocked marks an answer a guardrail stopped
+	StatusBlocked MessageStatus = "blocked"
 )
 
 // Message represents a single message in a conversation
@@ -28,14 +30,49 @@ type Message struct {
 	Timestamp time.Time
 	Citations []Citation
 	Status    MessageStatus
+	// CorrelationID identifies the chat turn; a question and its answer share it
+	CorrelationID string
+	// RequestID is the AWS request ID of the call that produced an agent message
+	RequestID string
+	// Guardrail describes why a guardrail blocked an agent message, if it did
+	Guardrail *GuardrailIntervention
+}
+
+// Guardrail stages: where a guardrail intervened
+const (
+	GuardrailStageInput  = "input"
+	GuardrailStageOutput = "output"
+)
+
+// GuardrailIntervention describes a Bedrock guardrail blocking a turn
+type GuardrailIntervention struct {
+	// Stage is GuardrailStageInput when the question was blocked and
+	// GuardrailStageOutput when the answer was; empty if Bedrock didn't say
+	Stage string
+	// Categories are the policies that triggered, as policy:name, e.g.
+	// content:VIOLENCE or topic:Investment advice; empty if Bedrock didn't say
+	Categories []string
 }
 
 // Citation represents a knowledge base citation
 type Citation struct {
 	SourceID   string
 	SourceName string
+	// SourceType is the kind of data source, e.g. S3, WEB, CONFLUENCE
+	SourceType string
+	// Excerpt is the retrieved passage the response relies on
 	Excerpt    string
 	Confidence float64
 	URL        string
 	Metadata   map[string]interface{}
+	// ResponseText is the part of the generated response the citation supports
+	ResponseText string
+	// ResponseSpan locates ResponseText in the generated response, if known
+	ResponseSpan *TextSpan
+}
+
+// TextSpan is a range of character offsets in a generated response
+type TextSpan struct {
+	Start int
+	End   int
 }
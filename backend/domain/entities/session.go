@@ -2,10 +2,29 @@ package entities
 
 import "time"
 
+// ChatMode selects how a session's messages are answered
+type ChatMode string
+
+const (
+	// ModeAgent answers through a Bedrock agent
+	ModeAgent ChatMode = "agent"
+	// ModeKnowledgeBase answers through knowledge base RetrieveAndGenerate without an agent
+	ModeKnowledgeBase ChatMode = "knowledge_base"
+	// ModeModel answers directly from a foundation model using the session's history
+	ModeModel ChatMode = "model"
+)
+
+// IsValid returns true if the mode is a known chat mode
+func (m ChatMode) IsValid() bool {
+	return m == ModeAgent || m == ModeKnowledgeBase || m == ModeModel
+}
+
 // Session represents a conversation session
 type Session struct {
 	ID            string
 	CreatedAt     time.Time
 	LastMessageAt *time.Time
 	MessageCount  int
+	// Mode overrides the deployment's default chat mode when set
+	Mode ChatMode
 }
@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	"time"
 
 	"github.com/bedrock-chat-poc/backend/domain/entities"
 )
@@ -21,6 +22,22 @@ type AgentInput struct {
 	SessionID        string
 	Message          string
 	KnowledgeBaseIDs []string
+	// Filter optionally restricts knowledge base retrieval by document metadata
+	Filter *RetrievalFilter
+	// History holds the session's earlier messages, oldest first. Services that
+	// keep conversation state server-side may ignore it.
+	History []entities.Message
+	// Continuation is the start of an answer whose stream failed part way.
+	// Services that implement AnswerContinuer stream only the rest of it;
+	// others ignore it and answer from the start.
+	Continuation string
+}
+
+// AnswerContinuer is implemented by Bedrock services that can continue a
+// partial answer given in AgentInput.Continuation
+type AnswerContinuer interface {
+	// ContinuesAnswers reports whether the service honours Continuation
+	ContinuesAnswers() bool
 }
 
 // AgentResponse represents the complete response from the Bedrock agent
@@ -31,16 +48,52 @@ type AgentResponse struct {
 	RequestID string
 }
 
-// StreamReader provides an interface for reading streaming responses
+// StreamReader provides an interface for reading streaming responses as a
+// sequence of typed events
 type StreamReader interface {
-	// Read returns the next chunk of content, a done flag, and any error
-	Read() (chunk string, done bool, err error)
-
-	// ReadCitation returns the next citation if available
-	ReadCitation() (*entities.Citation, error)
+	// Next returns the stream's next event. It blocks until an event arrives,
+	// the stream ends or ctx is done; in the last case it returns ctx.Err()
+	// and leaves the stream open for another Next or Close. A stream that
+	// ends cleanly ends with a StreamEventDone, which Next keeps returning.
+	Next(ctx context.Context) (StreamEvent, error)
 
 	// Close closes the stream reader
 	Close() error
+
+	// RequestID returns the AWS request ID of the call that opened the stream,
+	// or "" if unknown
+	RequestID() string
+}
+
+// KnowledgeBaseRetriever defines the interface for retrieving raw passages
+// from a knowledge base without generating a response
+type KnowledgeBaseRetriever interface {
+	// Retrieve returns the passages that best match the query, highest score first
+	Retrieve(ctx context.Context, input RetrieveInput) ([]RetrievedPassage, error)
+}
+
+// RetrieveInput represents a knowledge base retrieval query
+type RetrieveInput struct {
+	Query      string
+	MaxResults int
+	Filter     *RetrievalFilter
+}
+
+// RetrievedPassage represents a single passage returned by a knowledge base
+type RetrievedPassage struct {
+	Content  string
+	Score    float64
+	SourceID string
+	URL      string
+	Metadata map[string]interface{}
+}
+
+// CitationURLResolver turns a citation's source location into a URL that a
+// browser can open
+type CitationURLResolver interface {
+	// ResolveURL returns the URL to show for a source. An empty result means
+	// the source must not be linked.
+	ResolveURL(ctx context.Context, sourceURL string) (string, error)
 }
 
 // DomainError represents errors that occur in the domain layer
@@ -49,6 +102,12 @@ type DomainError struct {
 	Message   string
 	Retryable bool
 	Cause     error
+	// RequestID is the AWS request ID of the failed call, if any
+	RequestID string
+	// RetryAfter hints how long to wait before retrying; zero means no hint
+	RetryAfter time.Duration
+	// Guardrail describes the intervention behind a GUARDRAIL_BLOCKED error
+	Guardrail *entities.GuardrailIntervention
 }
 
 func (e *DomainError) Error() string {
@@ -64,11 +123,14 @@ func (e *DomainError) Unwrap() error {
 
 // Common error codes
 const (
-	ErrCodeRateLimit       = "RATE_LIMIT_EXCEEDED"
-	ErrCodeInvalidInput    = "INVALID_INPUT"
-	ErrCodeServiceError    = "SERVICE_ERROR"
-	ErrCodeNetworkError    = "NETWORK_ERROR"
-	ErrCodeTimeout         = "TIMEOUT"
-	ErrCodeUnauthorized    = "UNAUTHORIZED"
-	ErrCodeMalformedStream = "MALFORMED_STREAM"
+	ErrCodeRateLimit        = "RATE_LIMIT_EXCEEDED"
+	ErrCodeInvalidInput     = "INVALID_INPUT"
+	ErrCodeServiceError     = "SERVICE_ERROR"
+	ErrCodeNetworkError     = "NETWORK_ERROR"
+	ErrCodeTimeout          = "TIMEOUT"
+	ErrCodeUnauthorized     = "UNAUTHORIZED"
+	ErrCodeMalformedStream  = "MALFORMED_STREAM"
+	ErrCodeSlowConsumer     = "SLOW_CONSUMER"
+	ErrCodeGuardrailBlocked = "GUARDRAIL_BLOCKED"
+	ErrCodeInternal         = "INTERNAL_ERROR"
 )
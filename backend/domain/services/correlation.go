@@ -0,0 +1,17 @@
+package services
+
+import "context"
+
+// correlationIDKey is the context key for a chat turn's correlation ID
+type correlationIDKey struct{}
+
+// WithCorrelationID returns a context carrying the correlation ID of a chat turn
+func WithCorrelationID(ctx context.Context, correlationID string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, correlationID)
+}
+
+// CorrelationID returns the correlation ID carried by ctx, or "" if none
+func CorrelationID(ctx context.Context) string {
+	correlationID, _ := ctx.Value(correlationIDKey{}).(string)
+	return correlationID
+}
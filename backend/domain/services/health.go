@@ -0,0 +1,42 @@
+package services
+
+import "context"
+
+// HealthStatus is the health of a component
+type HealthStatus string
+
+const (
+	// HealthUp means the component is working
+	HealthUp HealthStatus = "up"
+	// HealthDegraded means the component works with reduced capability; the
+	// server stays ready
+	HealthDegraded HealthStatus = "degraded"
+	// HealthDown means the component cannot serve requests; the server is
+	// not ready
+	HealthDown HealthStatus = "down"
+)
+
+// ComponentHealth is the result of a component's health check
+type ComponentHealth struct {
+	Status HealthStatus
+	// Message explains a degraded or down status
+	Message string
+	// Details holds component-specific facts, e.g. counts or IDs
+	Details map[string]interface{}
+}
+
+// HealthChecker is implemented by components that report their health to
+// the readiness probe
+type HealthChecker interface {
+	// CheckHealth reports the component's health. It should return promptly
+	// once ctx is done.
+	CheckHealth(ctx context.Context) ComponentHealth
+}
+
+// HealthCheckFunc adapts a function to the HealthChecker interface
+type HealthCheckFunc func(ctx context.Context) ComponentHealth
+
+// CheckHealth calls f(ctx)
+func (f HealthCheckFunc) CheckHealth(ctx context.Context) ComponentHealth {
+	return f(ctx)
+}
@@ -0,0 +1,110 @@
+package services
+
+import "fmt"
+
+// FilterOperator identifies a metadata filter operation
+type FilterOperator string
+
+const (
+	FilterEquals     FilterOperator = "equals"
+	FilterIn         FilterOperator = "in"
+	FilterStartsWith FilterOperator = "startsWith"
+	FilterAndAll     FilterOperator = "andAll"
+	FilterOrAll      FilterOperator = "orAll"
+)
+
+// MaxFilterDepth is the maximum nesting depth of andAll/orAll groups
+const MaxFilterDepth = 5
+
+// RetrievalFilter restricts knowledge base retrieval to documents whose
+// metadata matches the expression
+type RetrievalFilter struct {
+	Operator FilterOperator
+	// Key is the metadata attribute name (equals, in, startsWith)
+	Key string
+	// Value is the value to compare against (equals, startsWith)
+	Value interface{}
+	// Values is the list of accepted values (in)
+	Values []interface{}
+	// Filters are the nested expressions (andAll, orAll)
+	Filters []RetrievalFilter
+}
+
+// Validate checks that the filter is well formed and returns an
+// INVALID_INPUT DomainError describing the first problem found
+func (f *RetrievalFilter) Validate() error {
+	if err := f.validate("filter", 1); err != nil {
+		return &DomainError{
+			Code:      ErrCodeInvalidInput,
+			Message:   fmt.Sprintf("Invalid filter: %v", err),
+			Retryable: false,
+		}
+	}
+	return nil
+}
+
+// validate recursively validates the filter, reporting errors by path
+func (f *RetrievalFilter) validate(path string, depth int) error {
+	path = fmt.Sprintf("%s.%s", path, f.Operator)
+
+	switch f.Operator {
+	case FilterEquals:
+		if f.Key == "" {
+			return fmt.Errorf("%s: key is required", path)
+		}
+		if !isScalarFilterValue(f.Value) {
+			return fmt.Errorf("%s: value must be a string, number or boolean", path)
+		}
+
+	case FilterStartsWith:
+		if f.Key == "" {
+			return fmt.Errorf("%s: key is required", path)
+		}
+		if s, ok := f.Value.(string); !ok || s == "" {
+			return fmt.Errorf("%s: value must be a non-empty string", path)
+		}
+
+	case FilterIn:
+		if f.Key == "" {
+			return fmt.Errorf("%s: key is required", path)
+		}
+		if len(f.Values) == 0 {
+			return fmt.Errorf("%s: value must be a non-empty list", path)
+		}
+		for i, v := range f.Values {
+			if !isScalarFilterValue(v) {
+				return fmt.Errorf("%s: value[%d] must be a string, number or boolean", path, i)
+			}
+		}
+
+	case FilterAndAll, FilterOrAll:
+		if depth > MaxFilterDepth {
+			return fmt.Errorf("%s: filter nesting exceeds maximum depth of %d", path, MaxFilterDepth)
+		}
+		if len(f.Filters) < 2 {
+			return fmt.Errorf("%s: requires at least 2 filters", path)
+		}
+		for i := range f.Filters {
+			if err := f.Filters[i].validate(fmt.Sprintf("%s[%d]", path, i), depth+1); err != nil {
+				return err
+			}
+		}
+
+	default:
+		return fmt.Errorf("%s: unsupported operator %q", path, f.Operator)
+	}
+
+	return nil
+}
+
+// isScalarFilterValue reports whether v can be compared against a metadata attribute
+func isScalarFilterValue(v interface{}) bool {
+	switch val := v.(type) {
+	case string:
+		return val != ""
+	case bool, float64, float32, int, int32, int64:
+		return true
+	default:
+		return false
+	}
+}
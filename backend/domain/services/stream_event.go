@@ -0,0 +1,54 @@
+package services
+
+import "github.com/bedrock-chat-poc/backend/domain/entities"
+
+// StreamEventType identifies the kind of a StreamEvent
+type StreamEventType string
+
+const (
+	// StreamEventContent carries a piece of the answer's text in Content
+	StreamEventContent StreamEventType = "content"
+	// StreamEventCitation carries the sources supporting text already
+	// streamed in Citations
+	StreamEventCitation StreamEventType = "citation"
+	// StreamEventTrace describes a step the agent took in Metadata
+	StreamEventTrace StreamEventType = "trace"
+	// StreamEventToolCall carries a tool the model asked to call in ToolCall
+	StreamEventToolCall StreamEventType = "tool_call"
+	// StreamEventMetadata carries details about the answer in Metadata, such
+	// as token usage or why generation stopped
+	StreamEventMetadata StreamEventType = "metadata"
+	// StreamEventGuardrail reports a guardrail blocking the question or the
+	// answer in Guardrail; nothing more of the answer follows it
+	StreamEventGuardrail StreamEventType = "guardrail"
+	// StreamEventDone ends a stream that completed successfully
+	StreamEventDone StreamEventType = "done"
+)
+
+// StreamEvent is one event of a streaming response. Only the fields of its
+// type are set. Consumers should skip types they do not know, so new kinds
+// of event can be added without breaking them.
+type StreamEvent struct {
+	Type StreamEventType
+	// Content is the text of a content event
+	Content string
+	// Citations are the sources of a citation event; Bedrock reports several
+	// sources for one part of the answer together
+	Citations []entities.Citation
+	// ToolCall is the call of a tool call event
+	ToolCall *ToolCall
+	// Metadata holds the details of a trace or metadata event
+	Metadata map[string]interface{}
+	// Guardrail describes the intervention of a guardrail event
+	Guardrail *entities.GuardrailIntervention
+}
+
+// ToolCall is a request from the model or agent to call a tool
+type ToolCall struct {
+	// ID identifies the call, to match it with its result
+	ID string
+	// Name is the tool or action group function to call
+	Name string
+	// Input is the call's input, usually JSON
+	Input string
+}
@@ -4,18 +4,23 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
-	"math"
+	"log/slog"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/types"
 	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+	"go.opentelemetry.io/otel/attribute"
 
 	"github.com/bedrock-chat-poc/backend/domain/entities"
 	"github.com/bedrock-chat-poc/backend/domain/services"
+	"github.com/bedrock-chat-poc/backend/infrastructure/logging"
+	"github.com/bedrock-chat-poc/backend/infrastructure/metrics"
+	"github.com/bedrock-chat-poc/backend/infrastructure/tracing"
 )
 
 // BedrockClient interface for testing
@@ -29,6 +34,8 @@ type Adapter struct {
 	agentID string
 	aliasID string
 	config  AdapterConfig
+	// credentials are checked by CheckHealth
+	credentials aws.CredentialsProvider
 }
 
 // AdapterConfig holds configuration for the Bedrock adapter
@@ -39,8 +46,24 @@ type AdapterConfig struct {
 	InitialBackoff time.Duration
 	// MaxBackoff is the maximum backoff duration for retries
 	MaxBackoff time.Duration
-	// RequestTimeout is the timeout for individual requests
+	// RequestTimeout is the timeout for individual requests. For streaming
+	// calls it bounds opening the stream, not reading it.
 	RequestTimeout time.Duration
+	// Jitter randomizes retry backoff; empty means full jitter
+	Jitter JitterMode
+	// RetryBudget bounds the time from a call's first attempt within which
+	// retries must finish waiting; zero means no bound
+	RetryBudget time.Duration
+	// RetryRules says which AWS error codes are retried; nil uses
+	// DefaultRetryRules
+	RetryRules map[string]RetryRule
+	// Clock times retry backoff; nil uses the system clock
+	Clock Clock
+	// Metrics records call attempts, retries and errors; nil records nothing
+	Metrics *metrics.Metrics
+	// Guardrail is applied to model and knowledge base calls; the zero
+	// value applies none
+	Guardrail GuardrailConfig
 }
 
 // DefaultConfig returns the default adapter configuration
@@ -50,6 +73,7 @@ func DefaultConfig() AdapterConfig {
 		InitialBackoff: 1 * time.Second,
 		MaxBackoff:     30 * time.Second,
 		RequestTimeout: 60 * time.Second,
+		Jitter:         JitterFull,
 	}
 }
 
@@ -71,10 +95,11 @@ func NewAdapter(ctx context.Context, agentID, aliasID string, cfg AdapterConfig)
 	client := bedrockagentruntime.NewFromConfig(awsCfg)
 
 	return &Adapter{
-		client:  client,
-		agentID: agentID,
-		aliasID: aliasID,
-		config:  cfg,
+		client:      client,
+		agentID:     agentID,
+		aliasID:     aliasID,
+		config:      cfg,
+		credentials: awsCfg.Credentials,
 	}, nil
 }
 
@@ -94,58 +119,26 @@ func (a *Adapter) InvokeAgent(ctx context.Context, input services.AgentInput) (*
 	reqCtx, cancel := context.WithTimeout(ctx, a.config.RequestTimeout)
 	defer cancel()
 
-	// Build the invoke request
-	invokeInput := &bedrockagentruntime.InvokeAgentInput{
-		AgentId:      aws.String(a.agentID),
-		AgentAliasId: aws.String(a.aliasID),
-		SessionId:    aws.String(input.SessionID),
-		InputText:    aws.String(input.Message),
-	}
-
-	// Knowledge Base is already associated with the agent via Terraform
-	// No need to specify it in SessionState - the agent will use it automatically
-	if len(input.KnowledgeBaseIDs) > 0 {
-		log.Printf("[Bedrock] Agent will use associated Knowledge Base ID: %s", input.KnowledgeBaseIDs[0])
+	invokeInput, err := a.buildInvokeInput(ctx, input)
+	if err != nil {
+		return nil, err
 	}
 
-	// Execute with retry logic
 	var response *bedrockagentruntime.InvokeAgentOutput
-	var err error
-
-	for attempt := 0; attempt <= a.config.MaxRetries; attempt++ {
-		if attempt > 0 {
-			// Calculate exponential backoff
-			backoff := a.calculateBackoff(attempt)
-			log.Printf("[Bedrock] Retry attempt %d after %v (RequestID: %s)", attempt, backoff, getRequestID(err))
-			
-			select {
-			case <-time.After(backoff):
-			case <-reqCtx.Done():
-				return nil, a.transformError(reqCtx.Err(), "")
-			}
-		}
-
-		log.Printf("[Bedrock] InvokeAgent request - SessionID: %s, AgentID: %s", input.SessionID, a.agentID)
-		response, err = a.client.InvokeAgent(reqCtx, invokeInput)
-		
-		if err == nil {
-			break
+	err = a.withRetry(reqCtx, "InvokeAgent", input.SessionID, func(ctx context.Context) (string, error) {
+		var callErr error
+		response, callErr = a.client.InvokeAgent(ctx, invokeInput)
+		if callErr != nil {
+			return "", callErr
 		}
-
-		// Check if error is retryable
-		if !a.isRetryable(err) {
-			break
-		}
-	}
-
+		return responseRequestID(response.ResultMetadata), nil
+	})
 	if err != nil {
-		requestID := getRequestID(err)
-		log.Printf("[Bedrock] InvokeAgent failed - RequestID: %s, Error: %v", requestID, err)
-		return nil, a.transformError(err, requestID)
+		return nil, err
 	}
 
 	// Process the streaming response
-	return a.processInvokeResponse(ctx, response)
+	return a.processInvokeResponse(ctx, response.GetStream(), responseRequestID(response.ResultMetadata))
 }
 
 // InvokeAgentStream sends a message to the Bedrock agent and returns a streaming response
@@ -160,54 +153,29 @@ func (a *Adapter) InvokeAgentStream(ctx context.Context, input services.AgentInp
 		}
 	}
 
-	// Build the invoke request
-	invokeInput := &bedrockagentruntime.InvokeAgentInput{
-		AgentId:      aws.String(a.agentID),
-		AgentAliasId: aws.String(a.aliasID),
-		SessionId:    aws.String(input.SessionID),
-		InputText:    aws.String(input.Message),
-	}
-
-	// Knowledge Base is already associated with the agent via Terraform
-	// No need to specify it in SessionState - the agent will use it automatically
-	if len(input.KnowledgeBaseIDs) > 0 {
-		log.Printf("[Bedrock] Agent will use associated Knowledge Base ID: %s", input.KnowledgeBaseIDs[0])
+	invokeInput, err := a.buildInvokeInput(ctx, input)
+	if err != nil {
+		return nil, err
 	}
 
-	// Execute with retry logic
+	// Opening the stream, including retries, is bounded by the request
+	// timeout; reading it is bounded by the stream processor
+	openCtx, open := withOpenTimeout(ctx, a.config.RequestTimeout)
+	defer open.close()
 	var response *bedrockagentruntime.InvokeAgentOutput
-	var err error
-
-	for attempt := 0; attempt <= a.config.MaxRetries; attempt++ {
-		if attempt > 0 {
-			// Calculate exponential backoff
-			backoff := a.calculateBackoff(attempt)
-			log.Printf("[Bedrock] Stream retry attempt %d after %v", attempt, backoff)
-			
-			select {
-			case <-time.After(backoff):
-			case <-ctx.Done():
-				return nil, a.transformError(ctx.Err(), "")
-			}
-		}
-
-		log.Printf("[Bedrock] InvokeAgentStream request - SessionID: %s, AgentID: %s", input.SessionID, a.agentID)
-		response, err = a.client.InvokeAgent(ctx, invokeInput)
-		
-		if err == nil {
-			break
-		}
-
-		// Check if error is retryable
-		if !a.isRetryable(err) {
-			break
+	err = a.withRetry(openCtx, "InvokeAgentStream", input.SessionID, func(ctx context.Context) (string, error) {
+		var callErr error
+		response, callErr = a.client.InvokeAgent(ctx, invokeInput)
+		if callErr != nil {
+			return "", callErr
 		}
+		return responseRequestID(response.ResultMetadata), nil
+	})
+	if err == nil {
+		err = open.opened()
 	}
-
 	if err != nil {
-		requestID := getRequestID(err)
-		log.Printf("[Bedrock] InvokeAgentStream failed - RequestID: %s, Error: %v", requestID, err)
-		return nil, a.transformError(err, requestID)
+		return nil, err
 	}
 
 	// Return stream reader
@@ -219,7 +187,39 @@ func (a *Adapter) InvokeAgentStream(ctx context.Context, input services.AgentInp
 			Retryable: false,
 		}
 	}
-	return newStreamReader(ctx, stream, getRequestID(err)), nil
+	requestID := responseRequestID(response.ResultMetadata)
+	slog.InfoContext(ctx, "[Bedrock] InvokeAgentStream opened", logging.KeyRequestID, requestID)
+	return open.reader(newStreamReader(ctx, stream, requestID)), nil
+}
+
+// buildInvokeInput builds the InvokeAgent request for a message. Tracing is
+// enabled because guardrail interventions are only reported in traces.
+func (a *Adapter) buildInvokeInput(ctx context.Context, input services.AgentInput) (*bedrockagentruntime.InvokeAgentInput, error) {
+	invokeInput := &bedrockagentruntime.InvokeAgentInput{
+		AgentId:      aws.String(a.agentID),
+		AgentAliasId: aws.String(a.aliasID),
+		SessionId:    aws.String(input.SessionID),
+		InputText:    aws.String(input.Message),
+		EnableTrace:  aws.Bool(true),
+	}
+
+	// Knowledge Base is already associated with the agent via Terraform
+	// SessionState is only needed to apply a per-request metadata filter
+	if len(input.KnowledgeBaseIDs) > 0 {
+		slog.DebugContext(ctx, "[Bedrock] Agent will use associated knowledge base", "knowledge_base_id", input.KnowledgeBaseIDs[0])
+	}
+
+	sessionState, err := buildSessionState(input)
+	if err != nil {
+		return nil, &services.DomainError{
+			Code:      services.ErrCodeInvalidInput,
+			Message:   "Invalid filter",
+			Retryable: false,
+			Cause:     err,
+		}
+	}
+	invokeInput.SessionState = sessionState
+	return invokeInput, nil
 }
 
 // validateInput validates the agent input
@@ -233,19 +233,27 @@ func (a *Adapter) validateInput(input services.AgentInput) error {
 	if len(input.Message) > 25000 {
 		return errors.New("message exceeds maximum length of 25000 characters")
 	}
+	if input.Filter != nil {
+		if len(input.KnowledgeBaseIDs) == 0 {
+			return errors.New("filter requires a knowledge base ID")
+		}
+		if err := input.Filter.Validate(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 // processInvokeResponse processes the complete invoke response
-func (a *Adapter) processInvokeResponse(ctx context.Context, output *bedrockagentruntime.InvokeAgentOutput) (*services.AgentResponse, error) {
+func (a *Adapter) processInvokeResponse(ctx context.Context, stream *bedrockagentruntime.InvokeAgentEventStream, requestID string) (*services.AgentResponse, error) {
 	response := &services.AgentResponse{
 		Content:   "",
 		Citations: []entities.Citation{},
 		Metadata:  make(map[string]interface{}),
+		RequestID: requestID,
 	}
 
 	// Process event stream
-	stream := output.GetStream()
 	if stream == nil {
 		return response, nil
 	}
@@ -261,101 +269,60 @@ func (a *Adapter) processInvokeResponse(ctx context.Context, output *bedrockagen
 			// Extract citations if available
 			if e.Value.Attribution != nil && e.Value.Attribution.Citations != nil {
 				for _, citation := range e.Value.Attribution.Citations {
-					response.Citations = append(response.Citations, a.convertCitation(citation))
+					response.Citations = append(response.Citations, convertCitation(citation)...)
 				}
 			}
 
 		case *types.ResponseStreamMemberTrace:
+			if guardrail, ok := e.Value.Trace.(*types.TraceMemberGuardrailTrace); ok && guardrail.Value.Action == types.GuardrailActionIntervened {
+				intervention := agentGuardrailIntervention(guardrail.Value)
+				slog.WarnContext(ctx, "[Bedrock] Guardrail intervened",
+					"stage", intervention.Stage, "categories", intervention.Categories, logging.KeyRequestID, requestID)
+				stream.Close()
+				return nil, guardrailBlockedError(intervention, requestID)
+			}
 			// Log trace information for debugging
-			log.Printf("[Bedrock] Trace event received")
+			slog.DebugContext(ctx, "[Bedrock] Trace event received", logging.KeyRequestID, requestID)
 
 		default:
-			log.Printf("[Bedrock] Unknown event type: %T", e)
+			slog.DebugContext(ctx, "[Bedrock] Unknown event type", "type", fmt.Sprintf("%T", e), logging.KeyRequestID, requestID)
 		}
 	}
 
 	// Check for stream errors
 	if err := stream.Err(); err != nil {
-		return nil, a.transformError(err, "")
+		return nil, a.transformError(err, requestID)
 	}
 
-	log.Printf("[Bedrock] InvokeAgent completed - Content length: %d, Citations: %d", len(response.Content), len(response.Citations))
+	slog.InfoContext(ctx, "[Bedrock] InvokeAgent completed", "content_length", len(response.Content), "citations", len(response.Citations), logging.KeyRequestID, requestID)
 	return response, nil
 }
 
-// convertCitation converts a Bedrock citation to domain citation
-func (a *Adapter) convertCitation(citation types.Citation) entities.Citation {
-	domainCitation := entities.Citation{
-		Metadata: make(map[string]interface{}),
-	}
-
-	if citation.GeneratedResponsePart != nil && citation.GeneratedResponsePart.TextResponsePart != nil {
-		domainCitation.Excerpt = aws.ToString(citation.GeneratedResponsePart.TextResponsePart.Text)
-	}
-
-	if len(citation.RetrievedReferences) > 0 {
-		ref := citation.RetrievedReferences[0]
-		
-		if ref.Content != nil && ref.Content.Text != nil {
-			domainCitation.SourceName = aws.ToString(ref.Content.Text)
-		}
-
-		if ref.Location != nil && ref.Location.S3Location != nil {
-			domainCitation.SourceID = aws.ToString(ref.Location.S3Location.Uri)
-			domainCitation.URL = aws.ToString(ref.Location.S3Location.Uri)
-		}
-
-		if ref.Metadata != nil {
-			for k, v := range ref.Metadata {
-				domainCitation.Metadata[k] = v
-			}
-		}
-	}
-
-	return domainCitation
+// withRetry runs an agent call with the adapter's retry policy
+func (a *Adapter) withRetry(ctx context.Context, operation, sessionID string, call func(ctx context.Context) (string, error)) error {
+	slog.InfoContext(ctx, "[Bedrock] "+operation+" request", logging.KeySessionID, sessionID, "agent_id", a.agentID)
+	return retryCall(ctx, a.config, operation, call, tracing.AttrSessionID.String(sessionID), attribute.String("agent_id", a.agentID))
 }
 
-// calculateBackoff calculates exponential backoff duration
-func (a *Adapter) calculateBackoff(attempt int) time.Duration {
-	backoff := float64(a.config.InitialBackoff) * math.Pow(2, float64(attempt-1))
-	if backoff > float64(a.config.MaxBackoff) {
-		backoff = float64(a.config.MaxBackoff)
-	}
-	return time.Duration(backoff)
+// transformError transforms AWS SDK errors to domain errors
+func (a *Adapter) transformError(err error, requestID string) error {
+	return transformError(err, requestID)
 }
 
-// isRetryable determines if an error is retryable
-func (a *Adapter) isRetryable(err error) bool {
+// transformError transforms AWS SDK errors to domain errors carrying the
+// AWS request ID
+func transformError(err error, requestID string) error {
 	if err == nil {
-		return false
-	}
-
-	// Check for context errors (timeout, cancellation)
-	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
-		return false
-	}
-
-	// Check for AWS SDK errors
-	var apiErr smithy.APIError
-	if errors.As(err, &apiErr) {
-		code := apiErr.ErrorCode()
-		
-		// Retryable error codes
-		switch code {
-		case "ThrottlingException", "TooManyRequestsException", "ServiceUnavailableException":
-			return true
-		}
+		return nil
 	}
 
-	return false
+	domainErr := classifyError(err, requestID)
+	domainErr.RequestID = requestID
+	return domainErr
 }
 
-// transformError transforms AWS SDK errors to domain errors
-func (a *Adapter) transformError(err error, requestID string) error {
-	if err == nil {
-		return nil
-	}
-
+// classifyError maps an error to the domain error code and message it surfaces as
+func classifyError(err error, requestID string) *services.DomainError {
 	// Context errors
 	if errors.Is(err, context.DeadlineExceeded) {
 		return &services.DomainError{
@@ -381,7 +348,7 @@ func (a *Adapter) transformError(err error, requestID string) error {
 		code := apiErr.ErrorCode()
 		message := apiErr.ErrorMessage()
 
-		log.Printf("[Bedrock] AWS API Error - Code: %s, Message: %s, RequestID: %s", code, message, requestID)
+		slog.Warn("[Bedrock] AWS API error", "code", code, "message", message, logging.KeyRequestID, requestID)
 
 		switch code {
 		case "ThrottlingException", "TooManyRequestsException":
@@ -435,18 +402,32 @@ func (a *Adapter) transformError(err error, requestID string) error {
 	}
 }
 
-// getRequestID extracts the request ID from an error
-func getRequestID(err error) string {
+// errorCode returns the code metrics label an error with: the AWS error code
+// for API errors, otherwise the domain error code. It is empty for nil.
+func errorCode(err error) string {
 	if err == nil {
 		return ""
 	}
-
 	var apiErr smithy.APIError
 	if errors.As(err, &apiErr) {
-		// Try to extract request ID from error metadata
-		// This is AWS SDK specific and may vary
-		return "unknown"
+		return apiErr.ErrorCode()
 	}
+	return classifyError(err, "").Code
+}
 
+// getRequestID extracts the AWS request ID (x-amzn-RequestId) from a failed
+// call's error, or "" if the request never reached AWS
+func getRequestID(err error) string {
+	var respErr interface{ ServiceRequestID() string }
+	if errors.As(err, &respErr) {
+		return respErr.ServiceRequestID()
+	}
 	return ""
 }
+
+// responseRequestID extracts the AWS request ID from a successful call's
+// result metadata
+func responseRequestID(metadata middleware.Metadata) string {
+	requestID, _ := awsmiddleware.GetRequestIDMetadata(metadata)
+	return requestID
+}
@@ -6,6 +6,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+
 	"github.com/bedrock-chat-poc/backend/domain/services"
 )
 
@@ -84,12 +86,7 @@ func TestValidateInput(t *testing.T) {
 }
 
 func TestCalculateBackoff(t *testing.T) {
-	adapter := &Adapter{
-		config: AdapterConfig{
-			InitialBackoff: 1 * time.Second,
-			MaxBackoff:     30 * time.Second,
-		},
-	}
+	initial, limit := 1*time.Second, 30*time.Second
 
 	tests := []struct {
 		name    string
@@ -125,17 +122,15 @@ func TestCalculateBackoff(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := adapter.calculateBackoff(tt.attempt)
+			got := exponentialBackoff(initial, limit, tt.attempt)
 			if got != tt.want {
-				t.Errorf("calculateBackoff() = %v, want %v", got, tt.want)
+				t.Errorf("exponentialBackoff() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
 
 func TestIsRetryable(t *testing.T) {
-	adapter := &Adapter{}
-
 	tests := []struct {
 		name string
 		err  error
@@ -165,7 +160,7 @@ func TestIsRetryable(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := adapter.isRetryable(tt.err)
+			got := isRetryable(tt.err)
 			if got != tt.want {
 				t.Errorf("isRetryable() = %v, want %v", got, tt.want)
 			}
@@ -366,3 +361,30 @@ func TestKnowledgeBaseInputValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildInvokeInput(t *testing.T) {
+	adapter := &Adapter{
+		agentID: "test-agent",
+		aliasID: "test-alias",
+		config:  DefaultConfig(),
+	}
+
+	invokeInput, err := adapter.buildInvokeInput(context.Background(), services.AgentInput{
+		SessionID: "session-123",
+		Message:   "Hello, world!",
+	})
+	if err != nil {
+		t.Fatalf("buildInvokeInput() error = %v", err)
+	}
+
+	if aws.ToString(invokeInput.AgentId) != "test-agent" || aws.ToString(invokeInput.AgentAliasId) != "test-alias" {
+		t.Errorf("Expected agent test-agent/test-alias, got %s/%s", aws.ToString(invokeInput.AgentId), aws.ToString(invokeInput.AgentAliasId))
+	}
+	if aws.ToString(invokeInput.SessionId) != "session-123" {
+		t.Errorf("Expected session session-123, got %s", aws.ToString(invokeInput.SessionId))
+	}
+	// Guardrail interventions are only reported in trace events
+	if !aws.ToBool(invokeInput.EnableTrace) {
+		t.Error("Expected tracing to be enabled")
+	}
+}
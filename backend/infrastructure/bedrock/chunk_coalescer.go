@@ -0,0 +1,348 @@
+package bedrock
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bedrock-chat-poc/backend/domain/entities"
+	"github.com/bedrock-chat-poc/backend/domain/services"
+	"github.com/bedrock-chat-poc/backend/infrastructure/metrics"
+)
+
+// CoalescingConfig holds configuration for a CoalescingChunkWriter
+type CoalescingConfig struct {
+	// MaxBytes writes buffered content once it reaches this size; zero
+	// means no size limit
+	MaxBytes int
+	// FlushInterval writes buffered content this long after its first
+	// chunk arrived; zero means no time limit. With neither limit, each
+	// chunk is written as it arrives.
+	FlushInterval time.Duration
+	// QueueSize bounds the chunks waiting to be sent to the client
+	QueueSize int
+	// MaxCoarsening is how many times batching may be coarsened for a slow
+	// client, each time doubling MaxBytes and FlushInterval
+	MaxCoarsening int
+	// SlowConsumerTimeout is how long a write waits for room in the queue,
+	// once batching is as coarse as it gets, before the answer is abandoned
+	SlowConsumerTimeout time.Duration
+	// Metrics records slow clients; nil records nothing
+	Metrics *metrics.Metrics
+}
+
+// DefaultCoalescingConfig returns default configuration
+func DefaultCoalescingConfig() CoalescingConfig {
+	return CoalescingConfig{
+		MaxBytes:            256,
+		FlushInterval:       50 * time.Millisecond,
+		QueueSize:           64,
+		MaxCoarsening:       3,
+		SlowConsumerTimeout: 10 * time.Second,
+	}
+}
+
+// errWriterClosed is returned by writes after Close
+var errWriterClosed = errors.New("chunk writer is closed")
+
+// CoalescingChunkWriter is a ChunkWriter that batches content chunks and
+// sends chunks to the wrapped writer from its own goroutine, through a
+// bounded queue, so a slow client does not hold up the Bedrock stream.
+//
+// When the queue is full, content stays buffered and batching is coarsened.
+// Once batching is as coarse as configured, writes wait up to
+// SlowConsumerTimeout for room and then fail with a SLOW_CONSUMER error.
+// Citation, error, guardrail, restart and done chunks are never dropped: buffered
+// content is queued before them so the client sees chunks in order.
+// Errors from the wrapped writer are returned by later writes and by Close.
+type CoalescingChunkWriter struct {
+	next   ChunkWriter
+	config CoalescingConfig
+
+	mu      sync.Mutex
+	pending strings.Builder
+	timer   *time.Timer
+	level   int
+	closed  bool
+
+	queue chan func() error
+	done  chan struct{}
+
+	errMu sync.Mutex
+	err   error
+}
+
+// NewCoalescingChunkWriter creates a coalescing writer sending to next. Close
+// must be called when the answer ends.
+func NewCoalescingChunkWriter(next ChunkWriter, config CoalescingConfig) *CoalescingChunkWriter {
+	if config.QueueSize <= 0 {
+		config.QueueSize = DefaultCoalescingConfig().QueueSize
+	}
+	if config.SlowConsumerTimeout <= 0 {
+		config.SlowConsumerTimeout = DefaultCoalescingConfig().SlowConsumerTimeout
+	}
+	w := &CoalescingChunkWriter{
+		next:   next,
+		config: config,
+		queue:  make(chan func() error, config.QueueSize),
+		done:   make(chan struct{}),
+	}
+	go w.send()
+	return w
+}
+
+// send writes queued chunks to the wrapped writer until the queue is closed.
+// After a failure the rest are discarded.
+func (w *CoalescingChunkWriter) send() {
+	defer close(w.done)
+	for write := range w.queue {
+		if w.failed() != nil {
+			continue
+		}
+		if err := w.sendChunk(write); err != nil {
+			w.fail(err)
+		}
+	}
+}
+
+// sendChunk writes one queued chunk. Nothing above this goroutine recovers
+// panics, so a writer that panics fails the answer instead of the process.
+func (w *CoalescingChunkWriter) sendChunk(write func() error) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			slog.Error("[ChunkWriter] Recovered from panic in chunk writer",
+				"panic", recovered,
+				"stack", string(debug.Stack()),
+			)
+			err = internalError(fmt.Errorf("chunk writer panicked: %v", recovered))
+		}
+	}()
+	return write()
+}
+
+// WriteContentChunk buffers content, queueing it once a batch is full
+func (w *CoalescingChunkWriter) WriteContentChunk(content string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.checkLocked(); err != nil {
+		return err
+	}
+
+	w.pending.WriteString(content)
+	if w.batchFullLocked() {
+		return w.flushLocked(false)
+	}
+	w.startTimerLocked()
+	return nil
+}
+
+// WriteCitationChunk queues buffered content and then the citation
+func (w *CoalescingChunkWriter) WriteCitationChunk(citation CitationChunk) error {
+	return w.write(func() error { return w.next.WriteCitationChunk(citation) })
+}
+
+// WriteCitationListChunk queues buffered content and then the citation list
+func (w *CoalescingChunkWriter) WriteCitationListChunk(citations []CitationChunk) error {
+	return w.write(func() error { return w.next.WriteCitationListChunk(citations) })
+}
+
+// WriteErrorChunk queues buffered content and then the error
+func (w *CoalescingChunkWriter) WriteErrorChunk(code, message string) error {
+	return w.write(func() error { return w.next.WriteErrorChunk(code, message) })
+}
+
+// WriteGuardrailChunk queues buffered content and then the intervention
+func (w *CoalescingChunkWriter) WriteGuardrailChunk(intervention entities.GuardrailIntervention) error {
+	return w.write(func() error { return w.next.WriteGuardrailChunk(intervention) })
+}
+
+// WriteRestartChunk queues buffered content and then the restart
+func (w *CoalescingChunkWriter) WriteRestartChunk() error {
+	return w.write(func() error { return w.next.WriteRestartChunk() })
+}
+
+// WriteDoneChunk queues buffered content and then the done chunk
+func (w *CoalescingChunkWriter) WriteDoneChunk() error {
+	return w.write(func() error { return w.next.WriteDoneChunk() })
+}
+
+// Close queues buffered content and waits for the queue to be sent, or for
+// SlowConsumerTimeout if the client stops reading. It returns the first
+// error writing to the client, if any. Close may be called more than once.
+func (w *CoalescingChunkWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return w.failed()
+	}
+	if w.failed() == nil {
+		_ = w.flushLocked(true)
+	}
+	w.stopTimerLocked()
+	w.closed = true
+	close(w.queue)
+	w.mu.Unlock()
+
+	select {
+	case <-w.done:
+	case <-time.After(w.config.SlowConsumerTimeout):
+		w.fail(w.slowConsumerError())
+	}
+	return w.failed()
+}
+
+// write queues buffered content and then a chunk that must not be dropped
+func (w *CoalescingChunkWriter) write(chunk func() error) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.checkLocked(); err != nil {
+		return err
+	}
+	if err := w.flushLocked(true); err != nil {
+		return err
+	}
+	return w.enqueueLocked(chunk)
+}
+
+// flushLocked queues the buffered content. Unless wait is set, a full queue
+// coarsens batching and leaves the content buffered while it can.
+func (w *CoalescingChunkWriter) flushLocked(wait bool) error {
+	if w.pending.Len() == 0 {
+		return nil
+	}
+	text := w.pending.String()
+	chunk := func() error { return w.next.WriteContentChunk(text) }
+
+	if !wait {
+		select {
+		case w.queue <- chunk:
+			w.resetLocked()
+			return nil
+		default:
+		}
+		if w.level < w.config.MaxCoarsening {
+			w.level++
+			w.config.Metrics.IncSlowConsumer("coarsen")
+			slog.Warn("[ChunkWriter] Client is falling behind, batching content more coarsely",
+				"level", w.level,
+				"buffered_bytes", len(text),
+			)
+			w.startTimerLocked()
+			return nil
+		}
+	}
+
+	if err := w.enqueueLocked(chunk); err != nil {
+		return err
+	}
+	w.resetLocked()
+	return nil
+}
+
+// enqueueLocked waits up to SlowConsumerTimeout for room in the queue
+func (w *CoalescingChunkWriter) enqueueLocked(chunk func() error) error {
+	select {
+	case w.queue <- chunk:
+		return nil
+	default:
+	}
+
+	timer := time.NewTimer(w.config.SlowConsumerTimeout)
+	defer timer.Stop()
+	select {
+	case w.queue <- chunk:
+		return nil
+	case <-timer.C:
+		w.config.Metrics.IncSlowConsumer("abort")
+		slog.Error("[ChunkWriter] Client stopped reading, abandoning answer",
+			"timeout", w.config.SlowConsumerTimeout,
+			"queued_chunks", len(w.queue),
+		)
+		err := w.slowConsumerError()
+		w.fail(err)
+		return err
+	}
+}
+
+// flushOnTimer queues content buffered for FlushInterval
+func (w *CoalescingChunkWriter) flushOnTimer() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.timer = nil
+	if w.closed || w.failed() != nil {
+		return
+	}
+	if err := w.flushLocked(false); err != nil {
+		return
+	}
+	// Content left buffered by coarser batching waits for the next interval
+	w.startTimerLocked()
+}
+
+// batchFullLocked reports whether the buffered content should be queued now
+func (w *CoalescingChunkWriter) batchFullLocked() bool {
+	if w.config.MaxBytes <= 0 {
+		return w.config.FlushInterval <= 0
+	}
+	return w.pending.Len() >= w.config.MaxBytes<<w.level
+}
+
+// startTimerLocked schedules a flush of buffered content, unless one is
+// already scheduled
+func (w *CoalescingChunkWriter) startTimerLocked() {
+	if w.timer != nil || w.config.FlushInterval <= 0 || w.pending.Len() == 0 {
+		return
+	}
+	w.timer = time.AfterFunc(w.config.FlushInterval<<w.level, w.flushOnTimer)
+}
+
+func (w *CoalescingChunkWriter) stopTimerLocked() {
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+}
+
+// resetLocked empties the buffer after its content was queued
+func (w *CoalescingChunkWriter) resetLocked() {
+	w.pending.Reset()
+	w.stopTimerLocked()
+}
+
+// checkLocked returns the error writes should fail with, if any
+func (w *CoalescingChunkWriter) checkLocked() error {
+	if err := w.failed(); err != nil {
+		return err
+	}
+	if w.closed {
+		return errWriterClosed
+	}
+	return nil
+}
+
+func (w *CoalescingChunkWriter) failed() error {
+	w.errMu.Lock()
+	defer w.errMu.Unlock()
+	return w.err
+}
+
+// fail records the first error writing to the client
+func (w *CoalescingChunkWriter) fail(err error) {
+	w.errMu.Lock()
+	defer w.errMu.Unlock()
+	if w.err == nil {
+		w.err = err
+	}
+}
+
+func (w *CoalescingChunkWriter) slowConsumerError() error {
+	return &services.DomainError{
+		Code:      services.ErrCodeSlowConsumer,
+		Message:   "Client is not reading the response fast enough",
+		Retryable: false,
+	}
+}
@@ -0,0 +1,171 @@
+package bedrock
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bedrock-chat-poc/backend/domain/services"
+)
+
+// slowChunkWriter reports each content chunk on written, after waiting for
+// gate when it is set
+type slowChunkWriter struct {
+	mockChunkWriter
+	gate    chan struct{}
+	written chan string
+}
+
+func newSlowChunkWriter(gated bool) *slowChunkWriter {
+	w := &slowChunkWriter{written: make(chan string, 100)}
+	if gated {
+		w.gate = make(chan struct{})
+	}
+	return w
+}
+
+func (w *slowChunkWriter) WriteContentChunk(content string) error {
+	if w.gate != nil {
+		<-w.gate
+	}
+	w.written <- content
+	return w.mockChunkWriter.WriteContentChunk(content)
+}
+
+// panickingChunkWriter panics on every content chunk
+type panickingChunkWriter struct {
+	mockChunkWriter
+}
+
+func (w *panickingChunkWriter) WriteContentChunk(content string) error {
+	panic("writer bug")
+}
+
+func TestCoalescingChunkWriter_BatchesBySize(t *testing.T) {
+	next := &mockChunkWriter{}
+	w := NewCoalescingChunkWriter(next, CoalescingConfig{MaxBytes: 8, QueueSize: 4})
+
+	for _, chunk := range []string{"The ", "leave ", "policy ", "allows"} {
+		if err := w.WriteContentChunk(chunk); err != nil {
+			t.Fatalf("WriteContentChunk() error = %v", err)
+		}
+	}
+	if err := w.WriteCitationChunk(CitationChunk{SourceID: "s3://docs/leave.pdf"}); err != nil {
+		t.Fatalf("WriteCitationChunk() error = %v", err)
+	}
+	if err := w.WriteContentChunk(" 20 days."); err != nil {
+		t.Fatalf("WriteContentChunk() error = %v", err)
+	}
+	if err := w.WriteDoneChunk(); err != nil {
+		t.Fatalf("WriteDoneChunk() error = %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	want := []string{"The leave ", "policy allows", " 20 days."}
+	if strings.Join(next.contentChunks, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected content %q, got %q", want, next.contentChunks)
+	}
+	if len(next.citationChunks) != 1 || !next.doneWritten {
+		t.Errorf("Expected the citation and done chunks, got %+v", next)
+	}
+}
+
+func TestCoalescingChunkWriter_BatchesByTime(t *testing.T) {
+	next := newSlowChunkWriter(false)
+	w := NewCoalescingChunkWriter(next, CoalescingConfig{FlushInterval: 20 * time.Millisecond, QueueSize: 4})
+	defer w.Close()
+
+	for _, chunk := range []string{"Hel", "lo", "!"} {
+		if err := w.WriteContentChunk(chunk); err != nil {
+			t.Fatalf("WriteContentChunk() error = %v", err)
+		}
+	}
+
+	// The batch is written once the interval passes, without waiting for
+	// more content
+	select {
+	case content := <-next.written:
+		if content != "Hello!" {
+			t.Errorf("Expected one batch %q, got %q", "Hello!", content)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the batch to be written after the flush interval")
+	}
+}
+
+func TestCoalescingChunkWriter_SlowConsumer(t *testing.T) {
+	next := newSlowChunkWriter(true)
+	w := NewCoalescingChunkWriter(next, CoalescingConfig{
+		MaxBytes:            1,
+		QueueSize:           1,
+		MaxCoarsening:       1,
+		SlowConsumerTimeout: 50 * time.Millisecond,
+	})
+	defer close(next.gate)
+
+	// The first chunk is taken by the sender, which blocks on the client,
+	// and the second fills the queue
+	if err := w.WriteContentChunk("a"); err != nil {
+		t.Fatalf("WriteContentChunk() error = %v", err)
+	}
+	waitFor(t, func() bool { return len(w.queue) == 0 })
+	if err := w.WriteContentChunk("b"); err != nil {
+		t.Fatalf("WriteContentChunk() error = %v", err)
+	}
+
+	// A full queue coarsens batching instead of blocking the stream
+	start := time.Now()
+	if err := w.WriteContentChunk("c"); err != nil {
+		t.Fatalf("Expected the chunk to be buffered, got %v", err)
+	}
+	if w.level != 1 || time.Since(start) > 25*time.Millisecond {
+		t.Errorf("Expected batching to coarsen without waiting, level %d after %v", w.level, time.Since(start))
+	}
+
+	// Once batching is as coarse as it gets, the answer is abandoned
+	err := w.WriteContentChunk("d")
+	var domainErr *services.DomainError
+	if !errors.As(err, &domainErr) || domainErr.Code != services.ErrCodeSlowConsumer {
+		t.Fatalf("Expected a slow consumer error, got %v", err)
+	}
+	if err := w.WriteDoneChunk(); !errors.Is(err, domainErr) {
+		t.Errorf("Expected later writes to fail with the same error, got %v", err)
+	}
+	if err := w.Close(); !errors.Is(err, domainErr) {
+		t.Errorf("Expected Close to report the error, got %v", err)
+	}
+}
+
+// waitFor polls cond until it holds or a second passes
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("Condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCoalescingChunkWriter_WriterPanic(t *testing.T) {
+	next := &panickingChunkWriter{}
+	w := NewCoalescingChunkWriter(next, CoalescingConfig{QueueSize: 4})
+
+	if err := w.WriteContentChunk("Hello"); err != nil {
+		t.Fatalf("WriteContentChunk() error = %v", err)
+	}
+
+	// The panic fails the answer rather than the process
+	err := w.Close()
+	var domainErr *services.DomainError
+	if !errors.As(err, &domainErr) || domainErr.Code != services.ErrCodeInternal {
+		t.Fatalf("Expected %s error from Close, got %v", services.ErrCodeInternal, err)
+	}
+	if err := w.WriteDoneChunk(); !errors.As(err, &domainErr) || domainErr.Code != services.ErrCodeInternal {
+		t.Errorf("Expected later writes to fail with %s, got %v", services.ErrCodeInternal, err)
+	}
+}
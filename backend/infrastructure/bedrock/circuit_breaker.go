@@ -0,0 +1,356 @@
+package bedrock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/bedrock-chat-poc/backend/domain/services"
+	"github.com/bedrock-chat-poc/backend/infrastructure/metrics"
+)
+
+// ErrCircuitOpen is the cause of calls failed fast by an open circuit breaker
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// BreakerState is the state of a circuit breaker
+type BreakerState string
+
+const (
+	// BreakerClosed passes calls through and counts their failures
+	BreakerClosed BreakerState = metrics.CircuitClosed
+	// BreakerOpen fails calls fast until the open timeout has passed
+	BreakerOpen BreakerState = metrics.CircuitOpen
+	// BreakerHalfOpen lets a few probe calls through to test whether Bedrock
+	// has recovered
+	BreakerHalfOpen BreakerState = metrics.CircuitHalfOpen
+)
+
+// CircuitBreakerConfig holds configuration for a circuit breaker
+type CircuitBreakerConfig struct {
+	// Name identifies the breaker in logs and metrics, e.g. the chat mode
+	Name string
+	// FailureRatio is the share of failed calls in a window that opens the
+	// breaker
+	FailureRatio float64
+	// MinRequests is the number of calls a window needs before its failure
+	// ratio is judged
+	MinRequests int
+	// Window is how long calls are counted before the counts are reset
+	Window time.Duration
+	// OpenTimeout is how long the breaker fails fast before probing
+	OpenTimeout time.Duration
+	// HalfOpenProbes is the number of probe calls let through, and that must
+	// succeed, before the breaker closes
+	HalfOpenProbes int
+	// Clock tells the time; nil uses the system clock
+	Clock Clock
+	// Metrics records state changes and rejected calls; nil records nothing
+	Metrics *metrics.Metrics
+}
+
+// DefaultCircuitBreakerConfig returns the default circuit breaker configuration
+func DefaultCircuitBreakerConfig() CircuitBreakerConfig {
+	return CircuitBreakerConfig{
+		FailureRatio:   0.5,
+		MinRequests:    10,
+		Window:         60 * time.Second,
+		OpenTimeout:    30 * time.Second,
+		HalfOpenProbes: 1,
+	}
+}
+
+// CircuitBreaker is a BedrockService that stops calling Bedrock while it is
+// failing. Once the failure ratio in a window is reached the breaker opens
+// and calls fail fast with a retry hint instead of each burning through its
+// retries. After the open timeout a few probe calls are let through; if they
+// succeed the breaker closes, otherwise it opens again.
+//
+// Only errors that say Bedrock is unavailable count as failures: throttling,
+// service errors, network errors and timeouts. Invalid input and access
+// errors show Bedrock answering, and canceled calls are not counted.
+type CircuitBreaker struct {
+	service services.BedrockService
+	config  CircuitBreakerConfig
+
+	mu    sync.Mutex
+	state BreakerState
+	// generation changes with every state change, so results of calls let
+	// through in an earlier state are ignored
+	generation  uint64
+	windowStart time.Time
+	successes   int
+	failures    int
+	openedAt    time.Time
+	// probes is the number of probe calls in flight; passed is the number
+	// that succeeded
+	probes int
+	passed int
+}
+
+// NewCircuitBreaker wraps service in a circuit breaker
+func NewCircuitBreaker(service services.BedrockService, config CircuitBreakerConfig) *CircuitBreaker {
+	if config.Clock == nil {
+		config.Clock = systemClock{}
+	}
+	if config.HalfOpenProbes <= 0 {
+		config.HalfOpenProbes = 1
+	}
+	if config.MinRequests <= 0 {
+		config.MinRequests = 1
+	}
+
+	b := &CircuitBreaker{
+		service:     service,
+		config:      config,
+		state:       BreakerClosed,
+		windowStart: config.Clock.Now(),
+	}
+	config.Metrics.SetCircuitState(config.Name, string(BreakerClosed))
+	return b
+}
+
+// InvokeAgent calls the wrapped service unless the breaker is open
+func (b *CircuitBreaker) InvokeAgent(ctx context.Context, input services.AgentInput) (*services.AgentResponse, error) {
+	generation, err := b.allow(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := b.service.InvokeAgent(ctx, input)
+	b.record(generation, err)
+	return response, err
+}
+
+// InvokeAgentStream opens a stream on the wrapped service unless the breaker
+// is open. The call is counted when the stream ends, so failures while
+// reading count as well as failures to open.
+func (b *CircuitBreaker) InvokeAgentStream(ctx context.Context, input services.AgentInput) (services.StreamReader, error) {
+	generation, err := b.allow(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := b.service.InvokeAgentStream(ctx, input)
+	if err != nil {
+		b.record(generation, err)
+		return nil, err
+	}
+	return &breakerStreamReader{
+		StreamReader: reader,
+		finish:       func(err error) { b.record(generation, err) },
+	}, nil
+}
+
+// ContinuesAnswers reports whether the wrapped service can continue a
+// partial answer
+func (b *CircuitBreaker) ContinuesAnswers() bool {
+	continuer, ok := b.service.(services.AnswerContinuer)
+	return ok && continuer.ContinuesAnswers()
+}
+
+// State returns the breaker's current state
+func (b *CircuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// CheckHealth reports the wrapped service's health along with the breaker's
+// state. An open or half-open breaker degrades an otherwise healthy service:
+// every server shares the same Bedrock, so taking this one out of rotation
+// would not help.
+func (b *CircuitBreaker) CheckHealth(ctx context.Context) services.ComponentHealth {
+	health := services.ComponentHealth{Status: services.HealthUp}
+	if checker, ok := b.service.(services.HealthChecker); ok {
+		health = checker.CheckHealth(ctx)
+	}
+	if health.Details == nil {
+		health.Details = map[string]interface{}{}
+	}
+
+	b.mu.Lock()
+	state, retryAfter := b.state, b.retryAfter()
+	b.mu.Unlock()
+
+	health.Details["circuit_breaker"] = string(state)
+	if health.Status != services.HealthUp {
+		return health
+	}
+	switch state {
+	case BreakerOpen:
+		health.Status = services.HealthDegraded
+		health.Message = fmt.Sprintf("Circuit breaker open after repeated Bedrock failures; probing in %s", retryAfter.Round(time.Second))
+	case BreakerHalfOpen:
+		health.Status = services.HealthDegraded
+		health.Message = "Circuit breaker half-open; probing Bedrock"
+	}
+	return health
+}
+
+// allow admits a call, returning the generation to record its result under,
+// or a domain error if the breaker is open
+func (b *CircuitBreaker) allow(ctx context.Context) (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen && !b.config.Clock.Now().Before(b.openedAt.Add(b.config.OpenTimeout)) {
+		b.setState(ctx, BreakerHalfOpen)
+	}
+
+	switch b.state {
+	case BreakerOpen:
+		return 0, b.reject(ctx, b.retryAfter())
+	case BreakerHalfOpen:
+		if b.probes+b.passed >= b.config.HalfOpenProbes {
+			return 0, b.reject(ctx, b.config.OpenTimeout)
+		}
+		b.probes++
+	}
+	return b.generation, nil
+}
+
+// record counts the result of a call admitted under generation
+func (b *CircuitBreaker) record(generation uint64, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if generation != b.generation {
+		return
+	}
+	ctx := context.Background()
+
+	if b.state == BreakerHalfOpen {
+		b.probes--
+		switch {
+		case errors.Is(err, context.Canceled):
+		case isBreakerFailure(err):
+			b.setState(ctx, BreakerOpen)
+		default:
+			b.passed++
+			if b.passed >= b.config.HalfOpenProbes {
+				b.setState(ctx, BreakerClosed)
+			}
+		}
+		return
+	}
+
+	now := b.config.Clock.Now()
+	if b.config.Window > 0 && now.Sub(b.windowStart) >= b.config.Window {
+		b.windowStart, b.successes, b.failures = now, 0, 0
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		return
+	case isBreakerFailure(err):
+		b.failures++
+	default:
+		b.successes++
+	}
+
+	total := b.successes + b.failures
+	if total >= b.config.MinRequests && float64(b.failures)/float64(total) >= b.config.FailureRatio {
+		slog.Warn("[Bedrock] Circuit breaker failure ratio reached", "breaker", b.config.Name, "failures", b.failures, "requests", total)
+		b.setState(ctx, BreakerOpen)
+	}
+}
+
+// setState moves the breaker to state and starts a new generation. Callers
+// hold b.mu.
+func (b *CircuitBreaker) setState(ctx context.Context, state BreakerState) {
+	slog.InfoContext(ctx, "[Bedrock] Circuit breaker state changed", "breaker", b.config.Name, "from", b.state, "to", state)
+
+	now := b.config.Clock.Now()
+	b.state = state
+	b.generation++
+	b.probes, b.passed = 0, 0
+	b.windowStart, b.successes, b.failures = now, 0, 0
+	if state == BreakerOpen {
+		b.openedAt = now
+	}
+	b.config.Metrics.SetCircuitState(b.config.Name, string(state))
+}
+
+// retryAfter returns how long until an open breaker probes again. Callers
+// hold b.mu.
+func (b *CircuitBreaker) retryAfter() time.Duration {
+	if b.state != BreakerOpen {
+		return 0
+	}
+	remaining := b.openedAt.Add(b.config.OpenTimeout).Sub(b.config.Clock.Now())
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// reject builds the error for a call failed fast. Callers hold b.mu.
+func (b *CircuitBreaker) reject(ctx context.Context, retryAfter time.Duration) error {
+	b.config.Metrics.IncCircuitRejection(b.config.Name)
+	slog.DebugContext(ctx, "[Bedrock] Circuit breaker rejected call", "breaker", b.config.Name, "state", b.state, "retry_after", retryAfter)
+
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return &services.DomainError{
+		Code:       services.ErrCodeServiceError,
+		Message:    fmt.Sprintf("Bedrock is temporarily unavailable. Please try again in %d seconds.", seconds),
+		Retryable:  true,
+		Cause:      ErrCircuitOpen,
+		RetryAfter: time.Duration(seconds) * time.Second,
+	}
+}
+
+// isBreakerFailure reports whether err says Bedrock is unavailable
+func isBreakerFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	var domainErr *services.DomainError
+	if errors.As(err, &domainErr) {
+		switch domainErr.Code {
+		case services.ErrCodeInvalidInput, services.ErrCodeUnauthorized, services.ErrCodeGuardrailBlocked:
+			return false
+		}
+	}
+	return true
+}
+
+// breakerStreamReader reports a stream's result to its breaker once the
+// stream ends: successfully, with an error, or closed before either
+type breakerStreamReader struct {
+	services.StreamReader
+	once   sync.Once
+	finish func(err error)
+}
+
+func (r *breakerStreamReader) Next(ctx context.Context) (services.StreamEvent, error) {
+	event, err := r.StreamReader.Next(ctx)
+	// A read that merely timed out leaves the stream open, so its result is
+	// not known yet
+	if err != nil && err == ctx.Err() {
+		return event, err
+	}
+	// The stream processor skips a malformed chunk and reads on, so the
+	// stream has not ended either
+	var domainErr *services.DomainError
+	if errors.As(err, &domainErr) && domainErr.Code == services.ErrCodeMalformedStream {
+		return event, err
+	}
+	// A guardrail intervention ends the answer, but Bedrock did answer
+	if err != nil || event.Type == services.StreamEventDone || event.Type == services.StreamEventGuardrail {
+		r.once.Do(func() { r.finish(err) })
+	}
+	return event, err
+}
+
+// Close closes the stream. A stream closed before it ended, e.g. because
+// the client went away, counts as canceled.
+func (r *breakerStreamReader) Close() error {
+	r.once.Do(func() { r.finish(context.Canceled) })
+	return r.StreamReader.Close()
+}
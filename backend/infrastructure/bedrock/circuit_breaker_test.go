@@ -0,0 +1,280 @@
+package bedrock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bedrock-chat-poc/backend/domain/services"
+)
+
+// scriptedService fails each call with the next scripted error; a nil entry,
+// or running out of script, succeeds
+type scriptedService struct {
+	errs  []error
+	calls int
+}
+
+func (s *scriptedService) next() error {
+	s.calls++
+	if len(s.errs) == 0 {
+		return nil
+	}
+	err := s.errs[0]
+	s.errs = s.errs[1:]
+	return err
+}
+
+func (s *scriptedService) InvokeAgent(ctx context.Context, input services.AgentInput) (*services.AgentResponse, error) {
+	if err := s.next(); err != nil {
+		return nil, err
+	}
+	return &services.AgentResponse{Content: "ok"}, nil
+}
+
+func (s *scriptedService) InvokeAgentStream(ctx context.Context, input services.AgentInput) (services.StreamReader, error) {
+	if err := s.next(); err != nil {
+		return nil, err
+	}
+	return &scriptedStreamReader{}, nil
+}
+
+// scriptedStreamReader sends one chunk, fails the reads after it with each
+// of readErrs, then ends with err
+type scriptedStreamReader struct {
+	readErrs []error
+	err      error
+	sent     bool
+}
+
+func (r *scriptedStreamReader) Next(ctx context.Context) (services.StreamEvent, error) {
+	if !r.sent {
+		r.sent = true
+		return services.StreamEvent{Type: services.StreamEventContent, Content: "chunk"}, nil
+	}
+	if len(r.readErrs) > 0 {
+		err := r.readErrs[0]
+		r.readErrs = r.readErrs[1:]
+		return services.StreamEvent{}, err
+	}
+	if r.err != nil {
+		return services.StreamEvent{}, r.err
+	}
+	return services.StreamEvent{Type: services.StreamEventDone}, nil
+}
+
+func (r *scriptedStreamReader) Close() error      { return nil }
+func (r *scriptedStreamReader) RequestID() string { return "" }
+
+var (
+	errUnavailable = &services.DomainError{Code: services.ErrCodeServiceError, Message: "Service temporarily unavailable", Retryable: true}
+	errBadInput    = &services.DomainError{Code: services.ErrCodeInvalidInput, Message: "Invalid input parameters"}
+	errBlocked     = &services.DomainError{Code: services.ErrCodeGuardrailBlocked, Message: "Blocked by a content guardrail"}
+)
+
+func newTestBreaker(service services.BedrockService, clock Clock) *CircuitBreaker {
+	return NewCircuitBreaker(service, CircuitBreakerConfig{
+		Name:           "agent",
+		FailureRatio:   0.5,
+		MinRequests:    4,
+		Window:         time.Minute,
+		OpenTimeout:    30 * time.Second,
+		HalfOpenProbes: 1,
+		Clock:          clock,
+	})
+}
+
+func TestCircuitBreaker_Opens(t *testing.T) {
+	tests := []struct {
+		name      string
+		errs      []error
+		wantState BreakerState
+	}{
+		{name: "failure ratio reached", errs: []error{errUnavailable, nil, errUnavailable, nil}, wantState: BreakerOpen},
+		{name: "below failure ratio", errs: []error{errUnavailable, nil, nil, nil}, wantState: BreakerClosed},
+		{name: "too few requests", errs: []error{errUnavailable, errUnavailable, errUnavailable}, wantState: BreakerClosed},
+		{name: "client errors are not failures", errs: []error{errBadInput, errBadInput, errBadInput, errBadInput}, wantState: BreakerClosed},
+		{name: "guardrail blocks are not failures", errs: []error{errBlocked, errBlocked, errBlocked, errBlocked}, wantState: BreakerClosed},
+		{name: "canceled calls are not counted", errs: []error{errUnavailable, context.Canceled, context.Canceled, context.Canceled, nil}, wantState: BreakerClosed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			breaker := newTestBreaker(&scriptedService{errs: append([]error(nil), tt.errs...)}, newFakeClock())
+			for range tt.errs {
+				breaker.InvokeAgent(context.Background(), services.AgentInput{})
+			}
+			if state := breaker.State(); state != tt.wantState {
+				t.Errorf("Expected state %s, got %s", tt.wantState, state)
+			}
+		})
+	}
+}
+
+func TestCircuitBreaker_WindowResets(t *testing.T) {
+	clock := newFakeClock()
+	breaker := newTestBreaker(&scriptedService{errs: []error{errUnavailable, errUnavailable, errUnavailable, errUnavailable}}, clock)
+
+	for i := 0; i < 3; i++ {
+		breaker.InvokeAgent(context.Background(), services.AgentInput{})
+	}
+	clock.now = clock.now.Add(time.Minute)
+	breaker.InvokeAgent(context.Background(), services.AgentInput{})
+
+	if state := breaker.State(); state != BreakerClosed {
+		t.Errorf("Expected failures from an old window to be forgotten, got state %s", state)
+	}
+}
+
+func TestCircuitBreaker_FailsFastWhileOpen(t *testing.T) {
+	clock := newFakeClock()
+	service := &scriptedService{errs: []error{errUnavailable, errUnavailable, errUnavailable, errUnavailable}}
+	breaker := newTestBreaker(service, clock)
+	for i := 0; i < 4; i++ {
+		breaker.InvokeAgent(context.Background(), services.AgentInput{})
+	}
+
+	clock.now = clock.now.Add(10 * time.Second)
+	_, err := breaker.InvokeAgentStream(context.Background(), services.AgentInput{})
+
+	var domainErr *services.DomainError
+	if !errors.As(err, &domainErr) {
+		t.Fatalf("Expected a domain error, got %v", err)
+	}
+	if domainErr.Code != services.ErrCodeServiceError || !domainErr.Retryable {
+		t.Errorf("Expected retryable %s, got %s (retryable %t)", services.ErrCodeServiceError, domainErr.Code, domainErr.Retryable)
+	}
+	if domainErr.RetryAfter != 20*time.Second {
+		t.Errorf("Expected a 20s retry hint, got %v", domainErr.RetryAfter)
+	}
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected the error to wrap ErrCircuitOpen, got %v", err)
+	}
+	if service.calls != 4 {
+		t.Errorf("Expected Bedrock not to be called while open, got %d calls", service.calls)
+	}
+}
+
+func TestCircuitBreaker_HalfOpen(t *testing.T) {
+	tests := []struct {
+		name      string
+		probeErr  error
+		wantState BreakerState
+	}{
+		{name: "probe succeeds", probeErr: nil, wantState: BreakerClosed},
+		{name: "probe fails", probeErr: errUnavailable, wantState: BreakerOpen},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			service := &scriptedService{errs: []error{errUnavailable, errUnavailable, errUnavailable, errUnavailable}}
+			breaker := newTestBreaker(service, clock)
+			for i := 0; i < 4; i++ {
+				breaker.InvokeAgent(context.Background(), services.AgentInput{})
+			}
+			clock.now = clock.now.Add(30 * time.Second)
+
+			// The probe stream is open, so no other call may probe
+			reader, err := breaker.InvokeAgentStream(context.Background(), services.AgentInput{})
+			if err != nil {
+				t.Fatalf("Expected the probe to be let through, got %v", err)
+			}
+			if state := breaker.State(); state != BreakerHalfOpen {
+				t.Fatalf("Expected state %s while probing, got %s", BreakerHalfOpen, state)
+			}
+			if _, err := breaker.InvokeAgent(context.Background(), services.AgentInput{}); !errors.Is(err, ErrCircuitOpen) {
+				t.Errorf("Expected a second call to fail fast while probing, got %v", err)
+			}
+
+			// The probe's result is known when its stream ends
+			reader.(*breakerStreamReader).StreamReader.(*scriptedStreamReader).err = tt.probeErr
+			for {
+				event, err := reader.Next(context.Background())
+				if event.Type == services.StreamEventDone || err != nil {
+					break
+				}
+			}
+			if state := breaker.State(); state != tt.wantState {
+				t.Errorf("Expected state %s, got %s", tt.wantState, state)
+			}
+		})
+	}
+}
+
+func TestCircuitBreaker_ProbeSkipsMalformedChunks(t *testing.T) {
+	clock := newFakeClock()
+	breaker := newTestBreaker(&scriptedService{errs: []error{errUnavailable, errUnavailable, errUnavailable, errUnavailable}}, clock)
+	for i := 0; i < 4; i++ {
+		breaker.InvokeAgent(context.Background(), services.AgentInput{})
+	}
+	clock.now = clock.now.Add(30 * time.Second)
+
+	reader, err := breaker.InvokeAgentStream(context.Background(), services.AgentInput{})
+	if err != nil {
+		t.Fatalf("Expected the probe to be let through, got %v", err)
+	}
+	reader.(*breakerStreamReader).StreamReader.(*scriptedStreamReader).readErrs = []error{
+		&services.DomainError{Code: services.ErrCodeMalformedStream, Message: "Error reading from stream"},
+	}
+
+	// The processor reads on past a malformed chunk, so the probe's result
+	// is not known yet
+	reader.Next(context.Background())
+	if _, err := reader.Next(context.Background()); err == nil {
+		t.Fatal("Expected the malformed chunk to fail its read")
+	}
+	if state := breaker.State(); state != BreakerHalfOpen {
+		t.Fatalf("Expected state %s after a malformed chunk, got %s", BreakerHalfOpen, state)
+	}
+
+	if event, err := reader.Next(context.Background()); err != nil || event.Type != services.StreamEventDone {
+		t.Fatalf("Expected the stream to end, got %v, %v", event, err)
+	}
+	if state := breaker.State(); state != BreakerClosed {
+		t.Errorf("Expected state %s, got %s", BreakerClosed, state)
+	}
+}
+
+func TestCircuitBreaker_ClosedProbeFreesSlot(t *testing.T) {
+	clock := newFakeClock()
+	breaker := newTestBreaker(&scriptedService{errs: []error{errUnavailable, errUnavailable, errUnavailable, errUnavailable}}, clock)
+	for i := 0; i < 4; i++ {
+		breaker.InvokeAgent(context.Background(), services.AgentInput{})
+	}
+	clock.now = clock.now.Add(30 * time.Second)
+
+	reader, err := breaker.InvokeAgentStream(context.Background(), services.AgentInput{})
+	if err != nil {
+		t.Fatalf("Expected the probe to be let through, got %v", err)
+	}
+	reader.Close()
+
+	if _, err := breaker.InvokeAgent(context.Background(), services.AgentInput{}); err != nil {
+		t.Errorf("Expected an abandoned probe to let another call probe, got %v", err)
+	}
+	if state := breaker.State(); state != BreakerClosed {
+		t.Errorf("Expected state %s, got %s", BreakerClosed, state)
+	}
+}
+
+func TestCircuitBreaker_CheckHealth(t *testing.T) {
+	clock := newFakeClock()
+	breaker := newTestBreaker(&scriptedService{errs: []error{errUnavailable, errUnavailable, errUnavailable, errUnavailable}}, clock)
+
+	if health := breaker.CheckHealth(context.Background()); health.Status != services.HealthUp || health.Details["circuit_breaker"] != "closed" {
+		t.Errorf("Expected up with a closed breaker, got %s %v", health.Status, health.Details)
+	}
+
+	for i := 0; i < 4; i++ {
+		breaker.InvokeAgent(context.Background(), services.AgentInput{})
+	}
+	health := breaker.CheckHealth(context.Background())
+	if health.Status != services.HealthDegraded || health.Details["circuit_breaker"] != "open" {
+		t.Errorf("Expected degraded with an open breaker, got %s %v", health.Status, health.Details)
+	}
+	if health.Message == "" {
+		t.Error("Expected a message explaining the degraded status")
+	}
+}
@@ -0,0 +1,140 @@
+package bedrock
+
+import (
+	"sort"
+)
+
+// citationKey identifies a cited passage: the same excerpt of the same source
+type citationKey struct {
+	source  string
+	excerpt string
+}
+
+// aggregatedCitation is a cited passage and every span of the answer it supports
+type aggregatedCitation struct {
+	chunk CitationChunk
+	spans []CitationSpan
+	order int
+}
+
+// citationAggregator collects the citations of one answer. Repeated citations
+// of a passage are merged into a single entry, and the entries are ranked by
+// retrieval score for the consolidated list sent before the done chunk.
+type citationAggregator struct {
+	maxCitations int
+	entries      []*aggregatedCitation
+	index        map[citationKey]*aggregatedCitation
+	streamed     int
+}
+
+// newCitationAggregator creates an aggregator for a single response stream.
+// A maxCitations of zero or less means the list is not capped.
+func newCitationAggregator(maxCitations int) *citationAggregator {
+	return &citationAggregator{
+		maxCitations: maxCitations,
+		index:        make(map[citationKey]*aggregatedCitation),
+	}
+}
+
+// add records a citation and reports whether it should be streamed now: only
+// the first citation of a passage is, and only while under the cap
+func (a *citationAggregator) add(chunk CitationChunk) bool {
+	source := chunk.SourceID
+	if source == "" {
+		source = chunk.SourceName
+	}
+	key := citationKey{source: source, excerpt: chunk.Excerpt}
+
+	if entry, ok := a.index[key]; ok {
+		entry.merge(chunk)
+		return false
+	}
+
+	entry := &aggregatedCitation{chunk: chunk, order: len(a.entries)}
+	entry.chunk.Metadata = copyMetadata(chunk.Metadata)
+	if chunk.Span != nil {
+		entry.spans = append(entry.spans, *chunk.Span)
+	}
+	a.entries = append(a.entries, entry)
+	a.index[key] = entry
+
+	if a.maxCitations > 0 && a.streamed >= a.maxCitations {
+		return false
+	}
+	a.streamed++
+	return true
+}
+
+// merge folds a repeated citation of the same passage into the entry
+func (e *aggregatedCitation) merge(chunk CitationChunk) {
+	if chunk.Confidence > e.chunk.Confidence {
+		e.chunk.Confidence = chunk.Confidence
+	}
+	if e.chunk.SourceName == "" {
+		e.chunk.SourceName = chunk.SourceName
+	}
+	if e.chunk.SourceType == "" {
+		e.chunk.SourceType = chunk.SourceType
+	}
+	if e.chunk.URL == "" {
+		e.chunk.URL = chunk.URL
+	}
+
+	// Keys already present keep the value from the first citation
+	for key, value := range chunk.Metadata {
+		if _, ok := e.chunk.Metadata[key]; ok {
+			continue
+		}
+		if e.chunk.Metadata == nil {
+			e.chunk.Metadata = make(map[string]interface{})
+		}
+		e.chunk.Metadata[key] = value
+	}
+
+	if chunk.Span != nil {
+		for _, span := range e.spans {
+			if span == *chunk.Span {
+				return
+			}
+		}
+		e.spans = append(e.spans, *chunk.Span)
+	}
+}
+
+// consolidated returns the merged citations, highest retrieval score first
+// with ties in citation order, capped at the configured maximum
+func (a *citationAggregator) consolidated() []CitationChunk {
+	ranked := make([]*aggregatedCitation, len(a.entries))
+	copy(ranked, a.entries)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].chunk.Confidence > ranked[j].chunk.Confidence
+	})
+
+	if a.maxCitations > 0 && len(ranked) > a.maxCitations {
+		ranked = ranked[:a.maxCitations]
+	}
+
+	list := make([]CitationChunk, len(ranked))
+	for i, entry := range ranked {
+		chunk := entry.chunk
+		if len(entry.spans) > 0 {
+			chunk.Span = &entry.spans[0]
+			chunk.Spans = entry.spans
+		}
+		list[i] = chunk
+	}
+	return list
+}
+
+// copyMetadata returns a shallow copy so merging never mutates a chunk that
+// has already been written
+func copyMetadata(metadata map[string]interface{}) map[string]interface{} {
+	if metadata == nil {
+		return nil
+	}
+	copied := make(map[string]interface{}, len(metadata))
+	for key, value := range metadata {
+		copied[key] = value
+	}
+	return copied
+}
@@ -0,0 +1,138 @@
+package bedrock
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bedrock-chat-poc/backend/domain/entities"
+)
+
+func TestCitationAggregator_DedupesAndMerges(t *testing.T) {
+	aggregator := newCitationAggregator(0)
+
+	first := CitationChunk{
+		SourceID:   "s3://docs/leave.pdf",
+		Excerpt:    "20 days of leave",
+		Confidence: 0.6,
+		Metadata:   map[string]interface{}{"page": 3},
+		Span:       &CitationSpan{0, 17},
+	}
+	repeat := CitationChunk{
+		SourceID:   "s3://docs/leave.pdf",
+		SourceName: "Leave Policy",
+		Excerpt:    "20 days of leave",
+		Confidence: 0.9,
+		Metadata:   map[string]interface{}{"page": 4, "section": "Annual"},
+		Span:       &CitationSpan{18, 40},
+	}
+	otherExcerpt := CitationChunk{
+		SourceID: "s3://docs/leave.pdf",
+		Excerpt:  "10 days of sick leave",
+	}
+
+	if !aggregator.add(first) {
+		t.Error("Expected first citation of a passage to be streamed")
+	}
+	if aggregator.add(repeat) {
+		t.Error("Expected repeated citation of a passage not to be streamed")
+	}
+	if aggregator.add(repeat) {
+		t.Error("Expected repeated citation of a passage not to be streamed")
+	}
+	if !aggregator.add(otherExcerpt) {
+		t.Error("Expected another excerpt of the same source to be streamed")
+	}
+
+	list := aggregator.consolidated()
+	if len(list) != 2 {
+		t.Fatalf("Expected 2 consolidated citations, got %d", len(list))
+	}
+
+	merged := list[0]
+	if merged.Confidence != 0.9 {
+		t.Errorf("Expected highest confidence 0.9, got %v", merged.Confidence)
+	}
+	if merged.SourceName != "Leave Policy" {
+		t.Errorf("Expected missing source name to be filled, got %q", merged.SourceName)
+	}
+	if merged.Metadata["page"] != 3 || merged.Metadata["section"] != "Annual" {
+		t.Errorf("Expected metadata merged with first values kept, got %v", merged.Metadata)
+	}
+	if len(merged.Spans) != 2 || merged.Spans[1] != (CitationSpan{18, 40}) {
+		t.Errorf("Expected both distinct spans, got %+v", merged.Spans)
+	}
+	if merged.Span == nil || *merged.Span != (CitationSpan{0, 17}) {
+		t.Errorf("Expected span to be the first span, got %+v", merged.Span)
+	}
+	if _, ok := first.Metadata["section"]; ok {
+		t.Error("Merging must not modify the citation as it was streamed")
+	}
+}
+
+func TestCitationAggregator_RanksAndCaps(t *testing.T) {
+	aggregator := newCitationAggregator(2)
+
+	streamed := 0
+	for _, citation := range []CitationChunk{
+		{SourceID: "a", Confidence: 0.4},
+		{SourceID: "b", Confidence: 0.8},
+		{SourceID: "c"},
+		{SourceID: "d", Confidence: 0.8},
+	} {
+		if aggregator.add(citation) {
+			streamed++
+		}
+	}
+
+	if streamed != 2 {
+		t.Errorf("Expected 2 citations streamed under the cap, got %d", streamed)
+	}
+
+	list := aggregator.consolidated()
+	if len(list) != 2 {
+		t.Fatalf("Expected list capped at 2, got %d", len(list))
+	}
+	// Equal scores keep citation order
+	if list[0].SourceID != "b" || list[1].SourceID != "d" {
+		t.Errorf("Expected ranking [b d], got [%s %s]", list[0].SourceID, list[1].SourceID)
+	}
+}
+
+func TestStreamProcessor_ProcessStream_CitationList(t *testing.T) {
+	reader := &mockStreamReader{
+		chunks: []string{"Leave is 20 days."},
+		citations: []*entities.Citation{
+			{SourceID: "s3://docs/faq.pdf", Excerpt: "FAQ", Confidence: 0.3},
+			{SourceID: "s3://docs/leave.pdf", Excerpt: "Policy", Confidence: 0.9},
+			{SourceID: "s3://docs/faq.pdf", Excerpt: "FAQ", Confidence: 0.3},
+		},
+		hangAfter: -1,
+	}
+	writer := &mockChunkWriter{}
+
+	if err := NewStreamProcessor(DefaultStreamProcessorConfig()).ProcessStream(context.Background(), reader, writer); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(writer.citationChunks) != 2 {
+		t.Errorf("Expected duplicate citation to be dropped from the stream, got %d chunks", len(writer.citationChunks))
+	}
+	if len(writer.citationList) != 2 || writer.citationList[0].SourceID != "s3://docs/leave.pdf" {
+		t.Errorf("Expected consolidated list ranked by score, got %+v", writer.citationList)
+	}
+	if !writer.doneWritten {
+		t.Error("Expected done chunk to be written")
+	}
+}
+
+func TestStreamProcessor_ProcessStream_NoCitationList(t *testing.T) {
+	reader := &mockStreamReader{chunks: []string{"Hello"}, hangAfter: -1}
+	writer := &mockChunkWriter{}
+
+	if err := NewStreamProcessor(DefaultStreamProcessorConfig()).ProcessStream(context.Background(), reader, writer); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if writer.citationList != nil {
+		t.Errorf("Expected no citation list without citations, got %+v", writer.citationList)
+	}
+}
@@ -0,0 +1,216 @@
+package bedrock
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bedrock-chat-poc/backend/domain/entities"
+)
+
+// CitationSpan locates cited text in the assistant message as delivered to
+// the client, in characters. End is exclusive.
+type CitationSpan struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// injectedMarker records a footnote marker written into the content stream
+type injectedMarker struct {
+	// rawPos is the marker's position in the written text, excluding markers
+	rawPos int
+	length int
+}
+
+// alignPoint pairs a position in the generated text with the position in the
+// written text where everything generated before it has been written
+type alignPoint struct {
+	generated int
+	written   int
+}
+
+// citationAligner tracks the text streamed for one response so each citation
+// can be numbered and tied to the characters it supports. Bedrock locates
+// cited text in the text it generated, before the stream middlewares; spans
+// are reported in the coordinates of the delivered message, i.e. the written
+// text including any injected markers.
+type citationAligner struct {
+	inlineMarkers bool
+	generated     strings.Builder
+	generatedLen  int
+	written       strings.Builder
+	writtenLen    int
+	synced        []alignPoint
+	markers       []injectedMarker
+	numbers       map[string]int
+}
+
+// newCitationAligner creates an aligner for a single response stream
+func newCitationAligner(inlineMarkers bool) *citationAligner {
+	return &citationAligner{
+		inlineMarkers: inlineMarkers,
+		numbers:       make(map[string]int),
+	}
+}
+
+// recordGenerated appends text as Bedrock generated it
+func (a *citationAligner) recordGenerated(chunk string) {
+	a.generated.WriteString(chunk)
+	a.generatedLen += utf8.RuneCountInString(chunk)
+}
+
+// recordContent appends text that has been written to the client
+func (a *citationAligner) recordContent(chunk string) {
+	a.written.WriteString(chunk)
+	a.writtenLen += utf8.RuneCountInString(chunk)
+}
+
+// sync records that all the generated text has been written, e.g. after the
+// middlewares were flushed
+func (a *citationAligner) sync() {
+	point := alignPoint{generated: a.generatedLen, written: a.writtenLen}
+	if n := len(a.synced); n == 0 || a.synced[n-1] != point {
+		a.synced = append(a.synced, point)
+	}
+}
+
+// number returns the footnote number for a citation's source, assigning the
+// next number to sources seen for the first time
+func (a *citationAligner) number(citation *entities.Citation) int {
+	key := citation.SourceID
+	if key == "" {
+		key = citation.SourceName
+	}
+	if key == "" {
+		key = citation.Excerpt
+	}
+
+	if n, ok := a.numbers[key]; ok {
+		return n
+	}
+	n := len(a.numbers) + 1
+	a.numbers[key] = n
+	return n
+}
+
+// span returns where the citation's supported text sits in the delivered message
+func (a *citationAligner) span(citation *entities.Citation) CitationSpan {
+	start, end := a.generatedSpan(citation)
+	start, end = a.writtenPos(start, false), a.writtenPos(end, true)
+	shift := a.shiftAt(start)
+	return CitationSpan{Start: start + shift, End: end + shift}
+}
+
+// generatedSpan locates the supported text in the generated text. Bedrock's
+// span is trusted only when it matches the reported text; otherwise the most
+// recent occurrence of the text is used, and a citation without either is
+// anchored at the end of what has been streamed so far.
+func (a *citationAligner) generatedSpan(citation *entities.Citation) (int, int) {
+	generated := a.generated.String()
+	textLen := utf8.RuneCountInString(citation.ResponseText)
+
+	if s := citation.ResponseSpan; s != nil && s.Start >= 0 {
+		end := s.Start + textLen
+		if textLen == 0 {
+			// Bedrock reports the index of the last cited character
+			end = s.End + 1
+		}
+		if end <= a.generatedLen && s.Start <= end {
+			if textLen == 0 || runeSlice(generated, s.Start, end) == citation.ResponseText {
+				return s.Start, end
+			}
+		}
+	}
+
+	if citation.ResponseText != "" {
+		if idx := strings.LastIndex(generated, citation.ResponseText); idx >= 0 {
+			start := utf8.RuneCountInString(generated[:idx])
+			return start, start + textLen
+		}
+	}
+
+	return a.generatedLen, a.generatedLen
+}
+
+// writtenPos maps a position in the generated text to the written text. The
+// middlewares may have rewritten the text between two sync points, so text
+// they left alone at either end of that stretch maps exactly, and a position
+// inside what they changed moves out to the edge of the change: back for a
+// span's start, forward for its end.
+func (a *citationAligner) writtenPos(pos int, end bool) int {
+	from, to := alignPoint{}, alignPoint{generated: a.generatedLen, written: a.writtenLen}
+	for _, point := range a.synced {
+		if point.generated <= pos {
+			from = point
+		}
+		if point.generated >= pos {
+			to = point
+			break
+		}
+	}
+	if pos <= from.generated {
+		return from.written
+	}
+	if pos >= to.generated {
+		return to.written
+	}
+
+	generated := []rune(a.generated.String())[from.generated:to.generated]
+	written := []rune(a.written.String())[from.written:to.written]
+	prefix := 0
+	for prefix < len(generated) && prefix < len(written) && generated[prefix] == written[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(generated)-prefix && suffix < len(written)-prefix &&
+		generated[len(generated)-1-suffix] == written[len(written)-1-suffix] {
+		suffix++
+	}
+
+	offset := pos - from.generated
+	switch {
+	case offset <= prefix:
+		return from.written + offset
+	case offset >= len(generated)-suffix:
+		return to.written - (len(generated) - offset)
+	case end:
+		return to.written - suffix
+	default:
+		return from.written + prefix
+	}
+}
+
+// shiftAt returns the length of the markers injected at or before a position
+// in the written text
+func (a *citationAligner) shiftAt(pos int) int {
+	shift := 0
+	for _, m := range a.markers {
+		if m.rawPos <= pos {
+			shift += m.length
+		}
+	}
+	return shift
+}
+
+// marker returns the inline marker text for a set of footnote numbers and
+// records it as injected at the current end of the written text
+func (a *citationAligner) marker(numbers []int) string {
+	var b strings.Builder
+	for _, n := range numbers {
+		fmt.Fprintf(&b, "[%d]", n)
+	}
+	text := b.String()
+	if text != "" {
+		a.markers = append(a.markers, injectedMarker{rawPos: a.writtenLen, length: utf8.RuneCountInString(text)})
+	}
+	return text
+}
+
+// runeSlice returns the characters of s in [start, end)
+func runeSlice(s string, start, end int) string {
+	runes := []rune(s)
+	if start < 0 || end > len(runes) || start > end {
+		return ""
+	}
+	return string(runes[start:end])
+}
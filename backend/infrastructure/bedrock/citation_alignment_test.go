@@ -0,0 +1,229 @@
+package bedrock
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/types"
+
+	"github.com/bedrock-chat-poc/backend/domain/entities"
+)
+
+func citationEvent(text string, start int32, sources ...string) *types.RetrieveAndGenerateStreamResponseOutputMemberCitation {
+	refs := make([]types.RetrievedReference, 0, len(sources))
+	for _, source := range sources {
+		refs = append(refs, types.RetrievedReference{
+			Location: &types.RetrievalResultLocation{
+				S3Location: &types.RetrievalResultS3Location{Uri: aws.String(source)},
+			},
+		})
+	}
+	return &types.RetrieveAndGenerateStreamResponseOutputMemberCitation{
+		Value: types.CitationEvent{
+			GeneratedResponsePart: &types.GeneratedResponsePart{
+				TextResponsePart: &types.TextResponsePart{
+					Text: aws.String(text),
+					Span: &types.Span{Start: aws.Int32(start), End: aws.Int32(start + int32(len(text)) - 1)},
+				},
+			},
+			RetrievedReferences: refs,
+		},
+	}
+}
+
+func outputEvent(text string) *types.RetrieveAndGenerateStreamResponseOutputMemberOutput {
+	return &types.RetrieveAndGenerateStreamResponseOutputMemberOutput{
+		Value: types.RetrieveAndGenerateOutputEvent{Text: aws.String(text)},
+	}
+}
+
+func TestStreamProcessor_CitationSpans(t *testing.T) {
+	tests := []struct {
+		name          string
+		inlineMarkers bool
+		wantContent   string
+		// wantLeaveSpans are the spans the repeated leave.pdf citation supports
+		wantLeaveSpans []CitationSpan
+	}{
+		{
+			name:           "without markers",
+			wantContent:    "Leave is 20 days. Sick leave is 10 days.",
+			wantLeaveSpans: []CitationSpan{{0, 17}, {18, 40}},
+		},
+		{
+			name:           "with inline markers",
+			inlineMarkers:  true,
+			wantContent:    "Leave is 20 days.[1][2] Sick leave is 10 days.[1]",
+			wantLeaveSpans: []CitationSpan{{0, 17}, {24, 46}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := newMockRAGEventReader(
+				outputEvent("Leave is 20 days."),
+				citationEvent("Leave is 20 days.", 0, "s3://docs/leave.pdf", "s3://docs/faq.pdf"),
+				outputEvent(" Sick leave is 10 days."),
+				citationEvent("Sick leave is 10 days.", 18, "s3://docs/leave.pdf"),
+			)
+			stream := bedrockagentruntime.NewRetrieveAndGenerateStreamEventStream(func(es *bedrockagentruntime.RetrieveAndGenerateStreamEventStream) {
+				es.Reader = reader
+			})
+
+			config := DefaultStreamProcessorConfig()
+			config.InlineCitationMarkers = tt.inlineMarkers
+			processor := NewStreamProcessor(config)
+			writer := &mockChunkWriter{}
+
+			if err := processor.ProcessStream(context.Background(), newKnowledgeBaseStreamReader(context.Background(), stream, ""), writer); err != nil {
+				t.Fatalf("ProcessStream() error = %v", err)
+			}
+
+			content := strings.Join(writer.contentChunks, "")
+			if content != tt.wantContent {
+				t.Errorf("Expected content %q, got %q", tt.wantContent, content)
+			}
+
+			// The repeated leave.pdf citation is only streamed once
+			if len(writer.citationChunks) != 2 {
+				t.Fatalf("Expected 2 citations, got %d", len(writer.citationChunks))
+			}
+			wantNumbers := []int{1, 2}
+			for i, chunk := range writer.citationChunks {
+				if chunk.Number != wantNumbers[i] {
+					t.Errorf("Citation %d: expected number %d, got %d", i, wantNumbers[i], chunk.Number)
+				}
+				if chunk.Span == nil || *chunk.Span != (CitationSpan{0, 17}) {
+					t.Errorf("Citation %d: expected span {0 17}, got %+v", i, chunk.Span)
+				}
+			}
+
+			if len(writer.citationList) != 2 {
+				t.Fatalf("Expected 2 consolidated citations, got %d", len(writer.citationList))
+			}
+			leave := writer.citationList[0]
+			if leave.SourceID != "s3://docs/leave.pdf" || len(leave.Spans) != len(tt.wantLeaveSpans) {
+				t.Fatalf("Unexpected consolidated leave citation: %+v", leave)
+			}
+			for i, span := range leave.Spans {
+				if span != tt.wantLeaveSpans[i] {
+					t.Errorf("Span %d: expected %+v, got %+v", i, tt.wantLeaveSpans[i], span)
+				}
+				if got := runeSlice(content, span.Start, span.End); !strings.HasSuffix(got, "days.") {
+					t.Errorf("Span %d covers %q", i, got)
+				}
+			}
+		})
+	}
+}
+
+func TestCitationAligner_GeneratedSpan(t *testing.T) {
+	aligner := newCitationAligner(false)
+	aligner.recordGenerated("Leave is 20 days. Sick leave is 10 days.")
+	aligner.recordContent("Leave is 20 days. Sick leave is 10 days.")
+	aligner.sync()
+
+	tests := []struct {
+		name      string
+		citation  entities.Citation
+		wantStart int
+		wantEnd   int
+	}{
+		{
+			name:      "span matches text",
+			citation:  entities.Citation{ResponseText: "Sick leave is 10 days.", ResponseSpan: &entities.TextSpan{Start: 18, End: 39}},
+			wantStart: 18,
+			wantEnd:   40,
+		},
+		{
+			name:      "span relative to an earlier chunk falls back to text search",
+			citation:  entities.Citation{ResponseText: "Sick leave is 10 days.", ResponseSpan: &entities.TextSpan{Start: 0, End: 21}},
+			wantStart: 18,
+			wantEnd:   40,
+		},
+		{
+			name:      "span without text uses inclusive end",
+			citation:  entities.Citation{ResponseSpan: &entities.TextSpan{Start: 0, End: 16}},
+			wantStart: 0,
+			wantEnd:   17,
+		},
+		{
+			name:      "no span or text anchors at the end",
+			citation:  entities.Citation{},
+			wantStart: 40,
+			wantEnd:   40,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := aligner.generatedSpan(&tt.citation)
+			if start != tt.wantStart || end != tt.wantEnd {
+				t.Errorf("generatedSpan() = (%d, %d), want (%d, %d)", start, end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestCitationAligner_Unicode(t *testing.T) {
+	aligner := newCitationAligner(true)
+	aligner.recordGenerated("Café policy: 20 días.")
+	aligner.recordContent("Café policy: 20 días.")
+	aligner.sync()
+	aligner.marker([]int{1})
+
+	span := aligner.span(&entities.Citation{ResponseText: "20 días."})
+	if span.Start != 13 || span.End != 21 {
+		t.Errorf("Expected character span (13, 21), got %+v", span)
+	}
+}
+
+func TestCitationAligner_FilteredText(t *testing.T) {
+	aligner := newCitationAligner(false)
+	aligner.recordGenerated("Mail hr@example.com today. Leave is 20 days.")
+	aligner.recordContent("Mail [EMAIL] today. Leave is 20 days.")
+	aligner.sync()
+	aligner.recordGenerated(" Call 555-123-4567.")
+	aligner.recordContent(" Call [PHONE].")
+	aligner.sync()
+
+	tests := []struct {
+		name     string
+		citation entities.Citation
+		want     string
+	}{
+		{
+			name:     "text after a redaction",
+			citation: entities.Citation{ResponseText: "Leave is 20 days.", ResponseSpan: &entities.TextSpan{Start: 27, End: 43}},
+			want:     "Leave is 20 days.",
+		},
+		{
+			name:     "text containing a redaction",
+			citation: entities.Citation{ResponseText: "Mail hr@example.com today."},
+			want:     "Mail [EMAIL] today.",
+		},
+		{
+			name:     "text ending inside a redaction",
+			citation: entities.Citation{ResponseText: "Call 555"},
+			want:     "Call [PHONE]",
+		},
+		{
+			name:     "text starting inside a redaction",
+			citation: entities.Citation{ResponseText: "example.com today."},
+			want:     "[EMAIL] today.",
+		},
+	}
+
+	written := "Mail [EMAIL] today. Leave is 20 days. Call [PHONE]."
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			span := aligner.span(&tt.citation)
+			if got := runeSlice(written, span.Start, span.End); got != tt.want {
+				t.Errorf("span() = %+v covering %q, want %q", span, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package bedrock
+
+import (
+	"encoding/json"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/document"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/types"
+
+	"github.com/bedrock-chat-poc/backend/domain/entities"
+)
+
+// Metadata keys that may hold a human-readable document title, in order of preference
+var sourceNameKeys = []string{
+	"title",
+	"x-amz-bedrock-kb-title",
+	"document_title",
+	"name",
+	"x-amz-bedrock-kb-source-uri",
+}
+
+// Metadata keys that may hold a retrieval score for a reference
+var scoreKeys = []string{
+	"score",
+	"x-amz-bedrock-kb-score",
+}
+
+// convertCitation converts a Bedrock citation into one domain citation per
+// retrieved reference. Every citation carries the response part it supports.
+func convertCitation(citation types.Citation) []entities.Citation {
+	var responseText string
+	var responseSpan *entities.TextSpan
+	if part := citation.GeneratedResponsePart; part != nil && part.TextResponsePart != nil {
+		responseText = aws.ToString(part.TextResponsePart.Text)
+		if span := part.TextResponsePart.Span; span != nil && span.Start != nil && span.End != nil {
+			responseSpan = &entities.TextSpan{
+				Start: int(aws.ToInt32(span.Start)),
+				End:   int(aws.ToInt32(span.End)),
+			}
+		}
+	}
+
+	citations := make([]entities.Citation, 0, len(citation.RetrievedReferences))
+	for _, ref := range citation.RetrievedReferences {
+		domainCitation := convertReference(ref)
+		domainCitation.ResponseText = responseText
+		domainCitation.ResponseSpan = responseSpan
+		citations = append(citations, domainCitation)
+	}
+
+	return citations
+}
+
+// convertReference converts a single retrieved reference to a domain citation
+func convertReference(ref types.RetrievedReference) entities.Citation {
+	domainCitation := entities.Citation{
+		Metadata: make(map[string]interface{}, len(ref.Metadata)),
+	}
+
+	if ref.Content != nil {
+		domainCitation.Excerpt = aws.ToString(ref.Content.Text)
+	}
+
+	for k, v := range ref.Metadata {
+		domainCitation.Metadata[k] = documentValue(v)
+	}
+
+	domainCitation.SourceType, domainCitation.SourceID, domainCitation.URL = resolveLocation(ref.Location)
+	domainCitation.SourceName = sourceName(domainCitation.Metadata, domainCitation.SourceType, domainCitation.SourceID)
+	domainCitation.Confidence = metadataScore(domainCitation.Metadata)
+
+	return domainCitation
+}
+
+// resolveLocation returns the source type, a stable source identifier and,
+// where the source has one, a URL for a retrieval result location
+func resolveLocation(location *types.RetrievalResultLocation) (sourceType, sourceID, sourceURL string) {
+	if location == nil {
+		return "", "", ""
+	}
+
+	sourceType = string(location.Type)
+	switch {
+	case location.S3Location != nil:
+		sourceID = aws.ToString(location.S3Location.Uri)
+		sourceURL = sourceID
+		if sourceType == "" {
+			sourceType = string(types.RetrievalResultLocationTypeS3)
+		}
+	case location.WebLocation != nil:
+		sourceID = aws.ToString(location.WebLocation.Url)
+		sourceURL = sourceID
+		if sourceType == "" {
+			sourceType = string(types.RetrievalResultLocationTypeWeb)
+		}
+	case location.ConfluenceLocation != nil:
+		sourceID = aws.ToString(location.ConfluenceLocation.Url)
+		sourceURL = sourceID
+		if sourceType == "" {
+			sourceType = string(types.RetrievalResultLocationTypeConfluence)
+		}
+	case location.SharePointLocation != nil:
+		sourceID = aws.ToString(location.SharePointLocation.Url)
+		sourceURL = sourceID
+		if sourceType == "" {
+			sourceType = string(types.RetrievalResultLocationTypeSharepoint)
+		}
+	case location.SalesforceLocation != nil:
+		sourceID = aws.ToString(location.SalesforceLocation.Url)
+		sourceURL = sourceID
+		if sourceType == "" {
+			sourceType = string(types.RetrievalResultLocationTypeSalesforce)
+		}
+	case location.KendraDocumentLocation != nil:
+		sourceID = aws.ToString(location.KendraDocumentLocation.Uri)
+		sourceURL = sourceID
+		if sourceType == "" {
+			sourceType = string(types.RetrievalResultLocationTypeKendra)
+		}
+	case location.CustomDocumentLocation != nil:
+		// Custom documents have an ID but nothing a browser can open
+		sourceID = aws.ToString(location.CustomDocumentLocation.Id)
+		if sourceType == "" {
+			sourceType = string(types.RetrievalResultLocationTypeCustom)
+		}
+	case location.SqlLocation != nil:
+		sourceID = aws.ToString(location.SqlLocation.Query)
+		if sourceType == "" {
+			sourceType = string(types.RetrievalResultLocationTypeSql)
+		}
+	}
+
+	return sourceType, sourceID, sourceURL
+}
+
+// sourceName derives a human-readable name for a source, preferring a title
+// from the document metadata and falling back to the last path segment of
+// its location
+func sourceName(metadata map[string]interface{}, sourceType, sourceID string) string {
+	for _, key := range sourceNameKeys {
+		if value, ok := metadata[key].(string); ok && strings.TrimSpace(value) != "" {
+			if key == "x-amz-bedrock-kb-source-uri" {
+				return locationName(value)
+			}
+			return strings.TrimSpace(value)
+		}
+	}
+
+	switch sourceType {
+	case string(types.RetrievalResultLocationTypeSql):
+		return "SQL query"
+	case string(types.RetrievalResultLocationTypeCustom):
+		return sourceID
+	}
+	return locationName(sourceID)
+}
+
+// locationName returns the last path segment of a URI, or its host when the
+// path is empty
+func locationName(location string) string {
+	if location == "" {
+		return ""
+	}
+
+	parsed, err := url.Parse(location)
+	if err != nil || parsed.Host == "" {
+		return path.Base(location)
+	}
+
+	name := path.Base(strings.TrimSuffix(parsed.Path, "/"))
+	if name == "." || name == "/" || name == "" {
+		return parsed.Host
+	}
+	if unescaped, err := url.PathUnescape(name); err == nil {
+		return unescaped
+	}
+	return name
+}
+
+// metadataScore returns the retrieval score recorded in a reference's
+// metadata. Bedrock does not report scores on citation references, so this
+// is zero unless the data source supplies one.
+func metadataScore(metadata map[string]interface{}) float64 {
+	for _, key := range scoreKeys {
+		switch value := metadata[key].(type) {
+		case float64:
+			return value
+		case interface{ Float64() (float64, error) }:
+			if score, err := value.Float64(); err == nil {
+				return score
+			}
+		case string:
+			if score, err := strconv.ParseFloat(value, 64); err == nil {
+				return score
+			}
+		}
+	}
+	return 0
+}
+
+// documentValue decodes a Smithy document into plain Go values so it can be
+// serialized as JSON
+func documentValue(doc document.Interface) interface{} {
+	if doc == nil {
+		return nil
+	}
+
+	var value interface{}
+	if err := doc.UnmarshalSmithyDocument(&value); err == nil {
+		return value
+	}
+
+	// Documents built locally are only marshalable
+	raw, err := doc.MarshalSmithyDocument()
+	if err != nil {
+		return nil
+	}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return nil
+	}
+	return value
+}
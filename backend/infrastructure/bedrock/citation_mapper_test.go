@@ -0,0 +1,200 @@
+package bedrock
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/document"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/types"
+)
+
+func TestConvertCitation_AllReferences(t *testing.T) {
+	citation := types.Citation{
+		GeneratedResponsePart: &types.GeneratedResponsePart{
+			TextResponsePart: &types.TextResponsePart{
+				Text: aws.String("Employees get 20 days of leave."),
+				Span: &types.Span{Start: aws.Int32(12), End: aws.Int32(43)},
+			},
+		},
+		RetrievedReferences: []types.RetrievedReference{
+			{
+				Content: &types.RetrievalResultContent{Text: aws.String("Full-time employees are entitled to 20 days...")},
+				Location: &types.RetrievalResultLocation{
+					Type:       types.RetrievalResultLocationTypeS3,
+					S3Location: &types.RetrievalResultS3Location{Uri: aws.String("s3://company-docs/hr/leave-policy.pdf")},
+				},
+				Metadata: map[string]document.Interface{
+					"title": document.NewLazyDocument("Leave Policy 2024"),
+					"page":  document.NewLazyDocument(3),
+				},
+			},
+			{
+				Content: &types.RetrievalResultContent{Text: aws.String("Leave accrues monthly...")},
+				Location: &types.RetrievalResultLocation{
+					Type:        types.RetrievalResultLocationTypeWeb,
+					WebLocation: &types.RetrievalResultWebLocation{Url: aws.String("https://intranet.example.com/hr/leave-faq")},
+				},
+			},
+		},
+	}
+
+	citations := convertCitation(citation)
+	if len(citations) != 2 {
+		t.Fatalf("Expected 2 citations, got %d", len(citations))
+	}
+
+	first := citations[0]
+	if first.SourceName != "Leave Policy 2024" {
+		t.Errorf("Expected title from metadata, got %q", first.SourceName)
+	}
+	if first.Excerpt != "Full-time employees are entitled to 20 days..." {
+		t.Errorf("Expected retrieved passage as excerpt, got %q", first.Excerpt)
+	}
+	if first.SourceType != "S3" || first.SourceID != "s3://company-docs/hr/leave-policy.pdf" || first.URL != first.SourceID {
+		t.Errorf("Unexpected source: type=%s id=%s url=%s", first.SourceType, first.SourceID, first.URL)
+	}
+	if page, ok := first.Metadata["page"].(float64); !ok || page != 3 {
+		t.Errorf("Expected decoded page metadata, got %#v", first.Metadata["page"])
+	}
+
+	second := citations[1]
+	if second.SourceName != "leave-faq" || second.SourceType != "WEB" {
+		t.Errorf("Unexpected second citation: name=%q type=%s", second.SourceName, second.SourceType)
+	}
+
+	// Both references support the same response part
+	for i, c := range citations {
+		if c.ResponseText != "Employees get 20 days of leave." {
+			t.Errorf("Citation %d: unexpected response text %q", i, c.ResponseText)
+		}
+		if c.ResponseSpan == nil || c.ResponseSpan.Start != 12 || c.ResponseSpan.End != 43 {
+			t.Errorf("Citation %d: unexpected response span %+v", i, c.ResponseSpan)
+		}
+	}
+}
+
+func TestConvertCitation_NoReferences(t *testing.T) {
+	citations := convertCitation(types.Citation{
+		GeneratedResponsePart: &types.GeneratedResponsePart{
+			TextResponsePart: &types.TextResponsePart{Text: aws.String("Unsupported claim.")},
+		},
+	})
+	if len(citations) != 0 {
+		t.Errorf("Expected no citations, got %d", len(citations))
+	}
+}
+
+func TestResolveLocation(t *testing.T) {
+	tests := []struct {
+		name     string
+		location *types.RetrievalResultLocation
+		wantType string
+		wantID   string
+		wantURL  string
+		wantName string
+	}{
+		{
+			name:     "nil location",
+			location: nil,
+		},
+		{
+			name:     "s3",
+			location: &types.RetrievalResultLocation{S3Location: &types.RetrievalResultS3Location{Uri: aws.String("s3://bucket/docs/Employee%20Handbook.pdf")}},
+			wantType: "S3",
+			wantID:   "s3://bucket/docs/Employee%20Handbook.pdf",
+			wantURL:  "s3://bucket/docs/Employee%20Handbook.pdf",
+			wantName: "Employee Handbook.pdf",
+		},
+		{
+			name:     "web root",
+			location: &types.RetrievalResultLocation{Type: types.RetrievalResultLocationTypeWeb, WebLocation: &types.RetrievalResultWebLocation{Url: aws.String("https://example.com/")}},
+			wantType: "WEB",
+			wantID:   "https://example.com/",
+			wantURL:  "https://example.com/",
+			wantName: "example.com",
+		},
+		{
+			name:     "confluence",
+			location: &types.RetrievalResultLocation{Type: types.RetrievalResultLocationTypeConfluence, ConfluenceLocation: &types.RetrievalResultConfluenceLocation{Url: aws.String("https://wiki.example.com/spaces/HR/pages/123/Onboarding")}},
+			wantType: "CONFLUENCE",
+			wantID:   "https://wiki.example.com/spaces/HR/pages/123/Onboarding",
+			wantURL:  "https://wiki.example.com/spaces/HR/pages/123/Onboarding",
+			wantName: "Onboarding",
+		},
+		{
+			name:     "sharepoint",
+			location: &types.RetrievalResultLocation{Type: types.RetrievalResultLocationTypeSharepoint, SharePointLocation: &types.RetrievalResultSharePointLocation{Url: aws.String("https://corp.sharepoint.com/sites/hr/Benefits.docx")}},
+			wantType: "SHAREPOINT",
+			wantID:   "https://corp.sharepoint.com/sites/hr/Benefits.docx",
+			wantURL:  "https://corp.sharepoint.com/sites/hr/Benefits.docx",
+			wantName: "Benefits.docx",
+		},
+		{
+			name:     "salesforce",
+			location: &types.RetrievalResultLocation{Type: types.RetrievalResultLocationTypeSalesforce, SalesforceLocation: &types.RetrievalResultSalesforceLocation{Url: aws.String("https://corp.my.salesforce.com/ka0123")}},
+			wantType: "SALESFORCE",
+			wantID:   "https://corp.my.salesforce.com/ka0123",
+			wantURL:  "https://corp.my.salesforce.com/ka0123",
+			wantName: "ka0123",
+		},
+		{
+			name:     "custom document",
+			location: &types.RetrievalResultLocation{Type: types.RetrievalResultLocationTypeCustom, CustomDocumentLocation: &types.RetrievalResultCustomDocumentLocation{Id: aws.String("policy-42")}},
+			wantType: "CUSTOM",
+			wantID:   "policy-42",
+			wantName: "policy-42",
+		},
+		{
+			name:     "sql",
+			location: &types.RetrievalResultLocation{Type: types.RetrievalResultLocationTypeSql, SqlLocation: &types.RetrievalResultSqlLocation{Query: aws.String("SELECT days FROM leave")}},
+			wantType: "SQL",
+			wantID:   "SELECT days FROM leave",
+			wantName: "SQL query",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sourceType, sourceID, sourceURL := resolveLocation(tt.location)
+			if sourceType != tt.wantType || sourceID != tt.wantID || sourceURL != tt.wantURL {
+				t.Errorf("resolveLocation() = (%q, %q, %q), want (%q, %q, %q)", sourceType, sourceID, sourceURL, tt.wantType, tt.wantID, tt.wantURL)
+			}
+			if name := sourceName(map[string]interface{}{}, sourceType, sourceID); name != tt.wantName {
+				t.Errorf("sourceName() = %q, want %q", name, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestSourceName_MetadataPrecedence(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata map[string]interface{}
+		want     string
+	}{
+		{name: "title wins", metadata: map[string]interface{}{"title": "Handbook", "name": "handbook-v2"}, want: "Handbook"},
+		{name: "blank title skipped", metadata: map[string]interface{}{"title": "  ", "name": "handbook-v2"}, want: "handbook-v2"},
+		{name: "source uri metadata", metadata: map[string]interface{}{"x-amz-bedrock-kb-source-uri": "s3://bucket/hr/guide.pdf"}, want: "guide.pdf"},
+		{name: "falls back to location", metadata: map[string]interface{}{}, want: "leave.pdf"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sourceName(tt.metadata, "S3", "s3://bucket/leave.pdf"); got != tt.want {
+				t.Errorf("sourceName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetadataScore(t *testing.T) {
+	if got := metadataScore(map[string]interface{}{"score": 0.82}); got != 0.82 {
+		t.Errorf("Expected 0.82, got %v", got)
+	}
+	if got := metadataScore(map[string]interface{}{"x-amz-bedrock-kb-score": "0.5"}); got != 0.5 {
+		t.Errorf("Expected 0.5, got %v", got)
+	}
+	if got := metadataScore(map[string]interface{}{}); got != 0 {
+		t.Errorf("Expected 0 without a score, got %v", got)
+	}
+}
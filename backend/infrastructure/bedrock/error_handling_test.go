@@ -421,12 +421,7 @@ func TestRetryLimitsRespected(t *testing.T) {
 
 // TestExponentialBackoffCalculation tests the exponential backoff calculation
 func TestExponentialBackoffCalculation(t *testing.T) {
-	adapter := &Adapter{
-		config: AdapterConfig{
-			InitialBackoff: 100 * time.Millisecond,
-			MaxBackoff:     5 * time.Second,
-		},
-	}
+	initial, limit := 100*time.Millisecond, 5*time.Second
 
 	tests := []struct {
 		name    string
@@ -477,9 +472,9 @@ func TestExponentialBackoffCalculation(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := adapter.calculateBackoff(tt.attempt)
+			got := exponentialBackoff(initial, limit, tt.attempt)
 			if got != tt.want {
-				t.Errorf("calculateBackoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+				t.Errorf("exponentialBackoff(%d) = %v, want %v", tt.attempt, got, tt.want)
 			}
 		})
 	}
@@ -561,6 +556,35 @@ func TestStreamingErrorHandling(t *testing.T) {
 	}
 }
 
+func TestTransformStreamError(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           error
+		wantCode      string
+		wantRetryable bool
+	}{
+		{name: "throttled mid-stream", err: &smithy.GenericAPIError{Code: "ThrottlingException"}, wantCode: services.ErrCodeRateLimit, wantRetryable: true},
+		{name: "service unavailable mid-stream", err: &smithy.GenericAPIError{Code: "ServiceUnavailableException"}, wantCode: services.ErrCodeServiceError, wantRetryable: true},
+		{name: "validation mid-stream", err: &smithy.GenericAPIError{Code: "ValidationException"}, wantCode: services.ErrCodeInvalidInput, wantRetryable: false},
+		{name: "undecodable event", err: errors.New("unexpected EOF"), wantCode: services.ErrCodeMalformedStream, wantRetryable: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var domainErr *services.DomainError
+			if !errors.As(transformStreamError(tt.err, "req-1"), &domainErr) {
+				t.Fatal("Expected a domain error")
+			}
+			if domainErr.Code != tt.wantCode || domainErr.Retryable != tt.wantRetryable {
+				t.Errorf("Expected %s (retryable %t), got %s (retryable %t)", tt.wantCode, tt.wantRetryable, domainErr.Code, domainErr.Retryable)
+			}
+			if domainErr.RequestID != "req-1" {
+				t.Errorf("Expected request ID req-1, got %q", domainErr.RequestID)
+			}
+		})
+	}
+}
+
 // TestContextCancellationDuringRetry tests context cancellation during retry attempts
 func TestContextCancellationDuringRetry(t *testing.T) {
 	mockClient := &mockBedrockClient{
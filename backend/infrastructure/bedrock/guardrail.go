@@ -0,0 +1,198 @@
+package bedrock
+
+import (
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	agenttypes "github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/types"
+	runtimetypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+
+	"github.com/bedrock-chat-poc/backend/domain/entities"
+	"github.com/bedrock-chat-poc/backend/domain/services"
+)
+
+// GuardrailConfig identifies the Bedrock guardrail applied to model and
+// knowledge base calls. Agents use the guardrail attached to the agent.
+type GuardrailConfig struct {
+	// ID is the guardrail's identifier or ARN; empty applies no guardrail
+	ID string
+	// Version is the guardrail version, e.g. "1" or "DRAFT"
+	Version string
+}
+
+// Enabled reports whether a guardrail is configured
+func (g GuardrailConfig) Enabled() bool {
+	return g.ID != ""
+}
+
+// guardrailEvent returns the stream event reporting an intervention
+func guardrailEvent(intervention entities.GuardrailIntervention) services.StreamEvent {
+	return services.StreamEvent{Type: services.StreamEventGuardrail, Guardrail: &intervention}
+}
+
+// guardrailBlockedError returns the error for an answer a guardrail blocked
+func guardrailBlockedError(intervention entities.GuardrailIntervention, requestID string) *services.DomainError {
+	return &services.DomainError{
+		Code:      services.ErrCodeGuardrailBlocked,
+		Message:   "Blocked by a content guardrail",
+		Retryable: false,
+		RequestID: requestID,
+		Guardrail: &intervention,
+	}
+}
+
+// newGuardrailIntervention builds an intervention from the categories
+// assessed on the question and on the answer. The stage is the question's
+// when it was blocked, else the answer's.
+func newGuardrailIntervention(input, output *guardrailCategories) entities.GuardrailIntervention {
+	switch {
+	case len(input.names) > 0:
+		return entities.GuardrailIntervention{Stage: entities.GuardrailStageInput, Categories: input.sorted()}
+	case len(output.names) > 0:
+		return entities.GuardrailIntervention{Stage: entities.GuardrailStageOutput, Categories: output.sorted()}
+	default:
+		return entities.GuardrailIntervention{}
+	}
+}
+
+// guardrailCategories collects the policies that acted in guardrail
+// assessments, as policy:name
+type guardrailCategories struct {
+	names map[string]struct{}
+}
+
+func (c *guardrailCategories) add(policy, name string) {
+	if c.names == nil {
+		c.names = make(map[string]struct{})
+	}
+	if name == "" {
+		name = "unknown"
+	}
+	c.names[policy+":"+name] = struct{}{}
+}
+
+func (c *guardrailCategories) sorted() []string {
+	categories := make([]string, 0, len(c.names))
+	for name := range c.names {
+		categories = append(categories, name)
+	}
+	sort.Strings(categories)
+	return categories
+}
+
+// guardrailAction is one policy decision in a guardrail assessment. The agent
+// and Converse SDKs report assessments with separate types; both are mapped
+// to actions so that one walk decides which policies acted.
+type guardrailAction struct {
+	policy string
+	name   string
+	action string
+}
+
+// addActions adds the policies whose action was more than NONE
+func (c *guardrailCategories) addActions(actions []guardrailAction) {
+	for _, a := range actions {
+		if a.action != "" && a.action != "NONE" {
+			c.add(a.policy, a.name)
+		}
+	}
+}
+
+// agentAssessmentActions maps an agent guardrail trace's assessment to actions
+func agentAssessmentActions(assessment agenttypes.GuardrailAssessment) []guardrailAction {
+	var actions []guardrailAction
+	if policy := assessment.ContentPolicy; policy != nil {
+		for _, filter := range policy.Filters {
+			actions = append(actions, guardrailAction{"content", string(filter.Type), string(filter.Action)})
+		}
+	}
+	if policy := assessment.TopicPolicy; policy != nil {
+		for _, topic := range policy.Topics {
+			actions = append(actions, guardrailAction{"topic", aws.ToString(topic.Name), string(topic.Action)})
+		}
+	}
+	if policy := assessment.WordPolicy; policy != nil {
+		for _, word := range policy.CustomWords {
+			actions = append(actions, guardrailAction{"word", "CUSTOM", string(word.Action)})
+		}
+		for _, word := range policy.ManagedWordLists {
+			actions = append(actions, guardrailAction{"word", string(word.Type), string(word.Action)})
+		}
+	}
+	if policy := assessment.SensitiveInformationPolicy; policy != nil {
+		for _, entity := range policy.PiiEntities {
+			actions = append(actions, guardrailAction{"sensitive_information", string(entity.Type), string(entity.Action)})
+		}
+		for _, regex := range policy.Regexes {
+			actions = append(actions, guardrailAction{"sensitive_information", aws.ToString(regex.Name), string(regex.Action)})
+		}
+	}
+	return actions
+}
+
+// modelAssessmentActions maps a Converse guardrail assessment to actions
+func modelAssessmentActions(assessment runtimetypes.GuardrailAssessment) []guardrailAction {
+	var actions []guardrailAction
+	if policy := assessment.ContentPolicy; policy != nil {
+		for _, filter := range policy.Filters {
+			actions = append(actions, guardrailAction{"content", string(filter.Type), string(filter.Action)})
+		}
+	}
+	if policy := assessment.TopicPolicy; policy != nil {
+		for _, topic := range policy.Topics {
+			actions = append(actions, guardrailAction{"topic", aws.ToString(topic.Name), string(topic.Action)})
+		}
+	}
+	if policy := assessment.WordPolicy; policy != nil {
+		for _, word := range policy.CustomWords {
+			actions = append(actions, guardrailAction{"word", "CUSTOM", string(word.Action)})
+		}
+		for _, word := range policy.ManagedWordLists {
+			actions = append(actions, guardrailAction{"word", string(word.Type), string(word.Action)})
+		}
+	}
+	if policy := assessment.SensitiveInformationPolicy; policy != nil {
+		for _, entity := range policy.PiiEntities {
+			actions = append(actions, guardrailAction{"sensitive_information", string(entity.Type), string(entity.Action)})
+		}
+		for _, regex := range policy.Regexes {
+			actions = append(actions, guardrailAction{"sensitive_information", aws.ToString(regex.Name), string(regex.Action)})
+		}
+	}
+	if policy := assessment.ContextualGroundingPolicy; policy != nil {
+		for _, filter := range policy.Filters {
+			actions = append(actions, guardrailAction{"contextual_grounding", string(filter.Type), string(filter.Action)})
+		}
+	}
+	return actions
+}
+
+// agentGuardrailIntervention describes the intervention in an agent's
+// guardrail trace
+func agentGuardrailIntervention(trace agenttypes.GuardrailTrace) entities.GuardrailIntervention {
+	var input, output guardrailCategories
+	for _, assessment := range trace.InputAssessments {
+		input.addActions(agentAssessmentActions(assessment))
+	}
+	for _, assessment := range trace.OutputAssessments {
+		output.addActions(agentAssessmentActions(assessment))
+	}
+	return newGuardrailIntervention(&input, &output)
+}
+
+// modelGuardrailIntervention describes the intervention in a Converse
+// guardrail trace, which may be nil if tracing was off
+func modelGuardrailIntervention(trace *runtimetypes.GuardrailTraceAssessment) entities.GuardrailIntervention {
+	var input, output guardrailCategories
+	if trace != nil {
+		for _, assessment := range trace.InputAssessment {
+			input.addActions(modelAssessmentActions(assessment))
+		}
+		for _, assessments := range trace.OutputAssessments {
+			for _, assessment := range assessments {
+				output.addActions(modelAssessmentActions(assessment))
+			}
+		}
+	}
+	return newGuardrailIntervention(&input, &output)
+}
@@ -0,0 +1,88 @@
+package bedrock
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	agenttypes "github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/types"
+	runtimetypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+
+	"github.com/bedrock-chat-poc/backend/domain/entities"
+)
+
+func TestModelGuardrailIntervention(t *testing.T) {
+	violence := runtimetypes.GuardrailAssessment{
+		ContentPolicy: &runtimetypes.GuardrailContentPolicyAssessment{Filters: []runtimetypes.GuardrailContentFilter{
+			{Type: runtimetypes.GuardrailContentFilterTypeViolence, Action: runtimetypes.GuardrailContentPolicyActionBlocked},
+			{Type: runtimetypes.GuardrailContentFilterTypeInsults, Action: runtimetypes.GuardrailContentPolicyAction("NONE")},
+		}},
+	}
+	investment := runtimetypes.GuardrailAssessment{
+		TopicPolicy: &runtimetypes.GuardrailTopicPolicyAssessment{Topics: []runtimetypes.GuardrailTopic{
+			{Name: aws.String("Investment advice"), Action: runtimetypes.GuardrailTopicPolicyActionBlocked},
+		}},
+		SensitiveInformationPolicy: &runtimetypes.GuardrailSensitiveInformationPolicyAssessment{
+			PiiEntities: []runtimetypes.GuardrailPiiEntityFilter{
+				{Type: runtimetypes.GuardrailPiiEntityTypeEmail, Action: runtimetypes.GuardrailSensitiveInformationPolicyActionAnonymized},
+			},
+		},
+	}
+
+	tests := []struct {
+		name  string
+		trace *runtimetypes.GuardrailTraceAssessment
+		want  entities.GuardrailIntervention
+	}{
+		{
+			name:  "blocked question",
+			trace: &runtimetypes.GuardrailTraceAssessment{InputAssessment: map[string]runtimetypes.GuardrailAssessment{"gr-1": violence}},
+			want:  entities.GuardrailIntervention{Stage: entities.GuardrailStageInput, Categories: []string{"content:VIOLENCE"}},
+		},
+		{
+			name: "blocked answer",
+			trace: &runtimetypes.GuardrailTraceAssessment{OutputAssessments: map[string][]runtimetypes.GuardrailAssessment{
+				"gr-1": {investment, violence},
+			}},
+			want: entities.GuardrailIntervention{
+				Stage:      entities.GuardrailStageOutput,
+				Categories: []string{"content:VIOLENCE", "sensitive_information:EMAIL", "topic:Investment advice"},
+			},
+		},
+		{
+			name:  "no trace",
+			trace: nil,
+			want:  entities.GuardrailIntervention{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := modelGuardrailIntervention(tt.trace); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAgentGuardrailIntervention(t *testing.T) {
+	trace := agenttypes.GuardrailTrace{
+		Action: agenttypes.GuardrailActionIntervened,
+		InputAssessments: []agenttypes.GuardrailAssessment{{
+			WordPolicy: &agenttypes.GuardrailWordPolicyAssessment{
+				CustomWords: []agenttypes.GuardrailCustomWord{{Match: aws.String("salary"), Action: agenttypes.GuardrailWordPolicyActionBlocked}},
+			},
+		}},
+		OutputAssessments: []agenttypes.GuardrailAssessment{{
+			ContentPolicy: &agenttypes.GuardrailContentPolicyAssessment{Filters: []agenttypes.GuardrailContentFilter{
+				{Type: agenttypes.GuardrailContentFilterTypeHate, Action: agenttypes.GuardrailContentPolicyActionBlocked},
+			}},
+		}},
+	}
+
+	// The question was blocked, so the answer's assessment is not reported
+	want := entities.GuardrailIntervention{Stage: entities.GuardrailStageInput, Categories: []string{"word:CUSTOM"}}
+	if got := agentGuardrailIntervention(trace); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+}
@@ -0,0 +1,75 @@
+package bedrock
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"github.com/bedrock-chat-poc/backend/domain/services"
+)
+
+// CheckHealth reports the agent adapter's configuration and whether its AWS
+// credentials are usable
+func (a *Adapter) CheckHealth(ctx context.Context) services.ComponentHealth {
+	return credentialsHealth(ctx, a.credentials, map[string]interface{}{
+		"agent_id": a.agentID,
+		"alias_id": a.aliasID,
+	})
+}
+
+// CheckHealth reports the knowledge base adapter's configuration and whether
+// its AWS credentials are usable
+func (a *KnowledgeBaseAdapter) CheckHealth(ctx context.Context) services.ComponentHealth {
+	return credentialsHealth(ctx, a.credentials, map[string]interface{}{
+		"knowledge_base_id": a.knowledgeBaseID,
+		"model_arn":         a.modelARN,
+	})
+}
+
+// CheckHealth reports the model adapter's configuration and whether its AWS
+// credentials are usable
+func (a *ModelAdapter) CheckHealth(ctx context.Context) services.ComponentHealth {
+	return credentialsHealth(ctx, a.credentials, map[string]interface{}{
+		"model_id": a.model.ModelID,
+	})
+}
+
+// credentialsHealth checks that AWS credentials can be retrieved and have not
+// expired. Providers from the default config cache credentials, so this only
+// reaches the credential source when they need refreshing; no Bedrock call
+// is made.
+func credentialsHealth(ctx context.Context, provider aws.CredentialsProvider, details map[string]interface{}) services.ComponentHealth {
+	if provider == nil {
+		return services.ComponentHealth{
+			Status:  services.HealthDown,
+			Message: "No AWS credentials provider configured",
+			Details: details,
+		}
+	}
+
+	creds, err := provider.Retrieve(ctx)
+	if err != nil {
+		// The cause can name credential sources and profiles, so it is only logged
+		slog.WarnContext(ctx, "[Health] AWS credentials unavailable", "error", err)
+		return services.ComponentHealth{
+			Status:  services.HealthDown,
+			Message: "AWS credentials unavailable",
+			Details: details,
+		}
+	}
+	if creds.Expired() {
+		return services.ComponentHealth{
+			Status:  services.HealthDown,
+			Message: "AWS credentials expired",
+			Details: details,
+		}
+	}
+
+	details["credentials_source"] = creds.Source
+	if creds.CanExpire {
+		details["credentials_expire_at"] = creds.Expires.UTC().Format(time.RFC3339)
+	}
+	return services.ComponentHealth{Status: services.HealthUp, Details: details}
+}
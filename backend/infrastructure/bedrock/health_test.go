@@ -0,0 +1,82 @@
+package bedrock
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"github.com/bedrock-chat-poc/backend/domain/services"
+)
+
+func TestCredentialsHealth(t *testing.T) {
+	credentialsWith := func(creds aws.Credentials, err error) aws.CredentialsProvider {
+		return aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+			return creds, err
+		})
+	}
+
+	tests := []struct {
+		name       string
+		provider   aws.CredentialsProvider
+		wantStatus services.HealthStatus
+	}{
+		{
+			name:       "no provider",
+			provider:   nil,
+			wantStatus: services.HealthDown,
+		},
+		{
+			name:       "retrieve fails",
+			provider:   credentialsWith(aws.Credentials{}, errors.New("no EC2 IMDS role found")),
+			wantStatus: services.HealthDown,
+		},
+		{
+			name: "expired",
+			provider: credentialsWith(aws.Credentials{
+				AccessKeyID: "AKID", SecretAccessKey: "secret",
+				CanExpire: true, Expires: time.Now().Add(-time.Minute),
+			}, nil),
+			wantStatus: services.HealthDown,
+		},
+		{
+			name: "static",
+			provider: credentialsWith(aws.Credentials{
+				AccessKeyID: "AKID", SecretAccessKey: "secret", Source: "EnvConfigCredentials",
+			}, nil),
+			wantStatus: services.HealthUp,
+		},
+		{
+			name: "temporary",
+			provider: credentialsWith(aws.Credentials{
+				AccessKeyID: "ASIA", SecretAccessKey: "secret", SessionToken: "token",
+				CanExpire: true, Expires: time.Now().Add(time.Hour),
+			}, nil),
+			wantStatus: services.HealthUp,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adapter := newModelAdapter(&mockConverseClient{}, ModelConfig{ModelID: "anthropic.claude-v2"}, DefaultConfig())
+			adapter.credentials = tt.provider
+
+			health := adapter.CheckHealth(context.Background())
+			if health.Status != tt.wantStatus {
+				t.Errorf("CheckHealth() status = %q, want %q (message %q)", health.Status, tt.wantStatus, health.Message)
+			}
+			if tt.wantStatus == services.HealthDown && health.Message == "" {
+				t.Error("Expected a message explaining the down status")
+			}
+			if strings.Contains(health.Message, "IMDS") {
+				t.Errorf("Expected the credential error to stay out of the message, got %q", health.Message)
+			}
+			if health.Details["model_id"] != "anthropic.claude-v2" {
+				t.Errorf("Expected model_id in details, got %v", health.Details)
+			}
+		})
+	}
+}
@@ -596,7 +596,8 @@ func TestIAMPermissions_StreamingAccess(t *testing.T) {
 	defer streamReader.Close()
 
 	// Try to read at least one chunk to verify streaming permissions work
-	chunk, done, err := streamReader.Read()
+	event, err := streamReader.Next(ctx)
+	chunk, done := event.Content, event.Type == services.StreamEventDone
 	if err != nil {
 		var domainErr *services.DomainError
 		if errors.As(err, &domainErr) {
@@ -398,7 +398,8 @@ func TestBedrockAgentConnectivity(t *testing.T) {
 
 			var streamContent strings.Builder
 			for {
-				chunk, done, err := streamReader.Read()
+				event, err := streamReader.Next(ctx)
+				chunk, done := event.Content, event.Type == services.StreamEventDone
 				if done {
 					break
 				}
@@ -447,7 +448,8 @@ func TestBedrockAgentConnectivity(t *testing.T) {
 
 		// Read all chunks from the stream
 		for {
-			chunk, done, err := streamReader.Read()
+			event, err := streamReader.Next(ctx)
+			chunk, done := event.Content, event.Type == services.StreamEventDone
 			if done {
 				break
 			}
@@ -461,8 +463,7 @@ func TestBedrockAgentConnectivity(t *testing.T) {
 			}
 
 			// Check for citations
-			citation, err := streamReader.ReadCitation()
-			if err == nil && citation != nil {
+			for _, citation := range event.Citations {
 				citationCount++
 				if citation.Excerpt == "" {
 					t.Error("Stream citation has empty excerpt")
@@ -0,0 +1,435 @@
+package bedrock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/types"
+	"github.com/aws/smithy-go"
+
+	"github.com/bedrock-chat-poc/backend/domain/entities"
+	"github.com/bedrock-chat-poc/backend/domain/services"
+	"github.com/bedrock-chat-poc/backend/infrastructure/logging"
+)
+
+const (
+	// defaultRetrieveResults is the number of passages returned when no limit is given
+	defaultRetrieveResults = 5
+	// maxRetrieveResults is the maximum number of passages a single Retrieve call may return
+	maxRetrieveResults = 100
+)
+
+// KnowledgeBaseClient interface for testing
+type KnowledgeBaseClient interface {
+	RetrieveAndGenerate(ctx context.Context, input *bedrockagentruntime.RetrieveAndGenerateInput, optFns ...func(*bedrockagentruntime.Options)) (*bedrockagentruntime.RetrieveAndGenerateOutput, error)
+	RetrieveAndGenerateStream(ctx context.Context, input *bedrockagentruntime.RetrieveAndGenerateStreamInput, optFns ...func(*bedrockagentruntime.Options)) (*bedrockagentruntime.RetrieveAndGenerateStreamOutput, error)
+	Retrieve(ctx context.Context, input *bedrockagentruntime.RetrieveInput, optFns ...func(*bedrockagentruntime.Options)) (*bedrockagentruntime.RetrieveOutput, error)
+}
+
+// KnowledgeBaseAdapter implements the BedrockService interface by querying a
+// knowledge base directly with RetrieveAndGenerate, without a Bedrock agent.
+// It also implements KnowledgeBaseRetriever for raw passage search.
+type KnowledgeBaseAdapter struct {
+	client          KnowledgeBaseClient
+	knowledgeBaseID string
+	modelARN        string
+	config          AdapterConfig
+	// credentials are checked by CheckHealth
+	credentials aws.CredentialsProvider
+
+	// Bedrock assigns its own RetrieveAndGenerate session IDs, so we map
+	// our session IDs onto them to keep multi-turn context
+	mu               sync.Mutex
+	bedrockSessionID map[string]string
+}
+
+// NewKnowledgeBaseAdapter creates a new knowledge base adapter. modelID may be a
+// foundation model ID or a full model/inference profile ARN.
+func NewKnowledgeBaseAdapter(ctx context.Context, knowledgeBaseID, modelID string, cfg AdapterConfig) (*KnowledgeBaseAdapter, error) {
+	if knowledgeBaseID == "" {
+		return nil, fmt.Errorf("knowledgeBaseID is required")
+	}
+	if modelID == "" {
+		return nil, fmt.Errorf("modelID is required")
+	}
+
+	// Load AWS configuration using IAM roles
+	awsCfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	client := bedrockagentruntime.NewFromConfig(awsCfg)
+
+	adapter := newKnowledgeBaseAdapter(client, knowledgeBaseID, modelARN(awsCfg.Region, modelID), cfg)
+	adapter.credentials = awsCfg.Credentials
+	return adapter, nil
+}
+
+// newKnowledgeBaseAdapter creates a knowledge base adapter around an existing client
+func newKnowledgeBaseAdapter(client KnowledgeBaseClient, knowledgeBaseID, modelARN string, cfg AdapterConfig) *KnowledgeBaseAdapter {
+	return &KnowledgeBaseAdapter{
+		client:           client,
+		knowledgeBaseID:  knowledgeBaseID,
+		modelARN:         modelARN,
+		config:           cfg,
+		bedrockSessionID: make(map[string]string),
+	}
+}
+
+// modelARN returns the foundation model ARN for a model ID, leaving ARNs untouched
+func modelARN(region, modelID string) string {
+	if strings.HasPrefix(modelID, "arn:") {
+		return modelID
+	}
+	return fmt.Sprintf("arn:aws:bedrock:%s::foundation-model/%s", region, modelID)
+}
+
+// InvokeAgent answers a message with RetrieveAndGenerate and returns the complete response
+func (a *KnowledgeBaseAdapter) InvokeAgent(ctx context.Context, input services.AgentInput) (*services.AgentResponse, error) {
+	if err := a.validateInput(input); err != nil {
+		return nil, &services.DomainError{
+			Code:      services.ErrCodeInvalidInput,
+			Message:   "Invalid input",
+			Retryable: false,
+			Cause:     err,
+		}
+	}
+
+	// Create request with timeout
+	reqCtx, cancel := context.WithTimeout(ctx, a.config.RequestTimeout)
+	defer cancel()
+
+	ragConfig, err := a.retrieveAndGenerateConfiguration(input.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	request := &bedrockagentruntime.RetrieveAndGenerateInput{
+		Input:                            &types.RetrieveAndGenerateInput{Text: aws.String(input.Message)},
+		RetrieveAndGenerateConfiguration: ragConfig,
+		SessionId:                        a.lookupSession(input.SessionID),
+	}
+
+	var output *bedrockagentruntime.RetrieveAndGenerateOutput
+	call := func(ctx context.Context) (string, error) {
+		var callErr error
+		output, callErr = a.client.RetrieveAndGenerate(ctx, request)
+		if callErr != nil {
+			return "", callErr
+		}
+		return responseRequestID(output.ResultMetadata), nil
+	}
+	err = a.withRetry(reqCtx, "RetrieveAndGenerate", call)
+	if err != nil && request.SessionId != nil && isSessionExpired(err) {
+		a.restartSession(ctx, input.SessionID)
+		request.SessionId = nil
+		err = a.withRetry(reqCtx, "RetrieveAndGenerate", call)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	a.storeSession(input.SessionID, output.SessionId)
+
+	if output.GuardrailAction == types.GuadrailActionIntervened {
+		// Knowledge bases do not report which policy intervened
+		requestID := responseRequestID(output.ResultMetadata)
+		slog.WarnContext(ctx, "[Bedrock] Guardrail intervened", logging.KeyRequestID, requestID)
+		return nil, guardrailBlockedError(entities.GuardrailIntervention{}, requestID)
+	}
+
+	response := &services.AgentResponse{
+		Citations: []entities.Citation{},
+		Metadata:  make(map[string]interface{}),
+		RequestID: responseRequestID(output.ResultMetadata),
+	}
+	if output.Output != nil {
+		response.Content = aws.ToString(output.Output.Text)
+	}
+	for _, citation := range output.Citations {
+		response.Citations = append(response.Citations, convertCitation(citation)...)
+	}
+
+	slog.InfoContext(ctx, "[Bedrock] RetrieveAndGenerate completed", "content_length", len(response.Content), "citations", len(response.Citations), logging.KeyRequestID, response.RequestID)
+	return response, nil
+}
+
+// InvokeAgentStream answers a message with RetrieveAndGenerateStream and returns a streaming response
+func (a *KnowledgeBaseAdapter) InvokeAgentStream(ctx context.Context, input services.AgentInput) (services.StreamReader, error) {
+	if err := a.validateInput(input); err != nil {
+		return nil, &services.DomainError{
+			Code:      services.ErrCodeInvalidInput,
+			Message:   "Invalid input",
+			Retryable: false,
+			Cause:     err,
+		}
+	}
+
+	ragConfig, err := a.retrieveAndGenerateConfiguration(input.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	request := &bedrockagentruntime.RetrieveAndGenerateStreamInput{
+		Input:                            &types.RetrieveAndGenerateInput{Text: aws.String(input.Message)},
+		RetrieveAndGenerateConfiguration: ragConfig,
+		SessionId:                        a.lookupSession(input.SessionID),
+	}
+
+	// Opening the stream, including retries, is bounded by the request timeout
+	openCtx, open := withOpenTimeout(ctx, a.config.RequestTimeout)
+	defer open.close()
+	var output *bedrockagentruntime.RetrieveAndGenerateStreamOutput
+	call := func(ctx context.Context) (string, error) {
+		var callErr error
+		output, callErr = a.client.RetrieveAndGenerateStream(ctx, request)
+		if callErr != nil {
+			return "", callErr
+		}
+		return responseRequestID(output.ResultMetadata), nil
+	}
+	err = a.withRetry(openCtx, "RetrieveAndGenerateStream", call)
+	if err != nil && request.SessionId != nil && isSessionExpired(err) {
+		a.restartSession(ctx, input.SessionID)
+		request.SessionId = nil
+		err = a.withRetry(openCtx, "RetrieveAndGenerateStream", call)
+	}
+	if err == nil {
+		err = open.opened()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	a.storeSession(input.SessionID, output.SessionId)
+
+	stream := output.GetStream()
+	if stream == nil {
+		return nil, &services.DomainError{
+			Code:      services.ErrCodeServiceError,
+			Message:   "No event stream in response",
+			Retryable: false,
+		}
+	}
+	return open.reader(newKnowledgeBaseStreamReader(ctx, stream, responseRequestID(output.ResultMetadata))), nil
+}
+
+// Retrieve returns raw passages from the knowledge base without generating a response
+func (a *KnowledgeBaseAdapter) Retrieve(ctx context.Context, input services.RetrieveInput) ([]services.RetrievedPassage, error) {
+	if strings.TrimSpace(input.Query) == "" {
+		return nil, &services.DomainError{
+			Code:      services.ErrCodeInvalidInput,
+			Message:   "Query is required",
+			Retryable: false,
+		}
+	}
+	if input.MaxResults < 0 || input.MaxResults > maxRetrieveResults {
+		return nil, &services.DomainError{
+			Code:      services.ErrCodeInvalidInput,
+			Message:   fmt.Sprintf("Result limit must be between 1 and %d", maxRetrieveResults),
+			Retryable: false,
+		}
+	}
+	if input.MaxResults == 0 {
+		input.MaxResults = defaultRetrieveResults
+	}
+
+	retrievalConfig, err := vectorSearchConfiguration(input.Filter, input.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create request with timeout
+	reqCtx, cancel := context.WithTimeout(ctx, a.config.RequestTimeout)
+	defer cancel()
+
+	request := &bedrockagentruntime.RetrieveInput{
+		KnowledgeBaseId:        aws.String(a.knowledgeBaseID),
+		RetrievalQuery:         &types.KnowledgeBaseQuery{Text: aws.String(input.Query)},
+		RetrievalConfiguration: retrievalConfig,
+	}
+
+	var output *bedrockagentruntime.RetrieveOutput
+	err = a.withRetry(reqCtx, "Retrieve", func(ctx context.Context) (string, error) {
+		var callErr error
+		output, callErr = a.client.Retrieve(ctx, request)
+		if callErr != nil {
+			return "", callErr
+		}
+		return responseRequestID(output.ResultMetadata), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	passages := make([]services.RetrievedPassage, 0, len(output.RetrievalResults))
+	for _, result := range output.RetrievalResults {
+		passages = append(passages, convertRetrievalResult(result))
+	}
+
+	slog.InfoContext(ctx, "[Bedrock] Retrieve completed", "results", len(passages))
+	return passages, nil
+}
+
+// validateInput validates the agent input
+func (a *KnowledgeBaseAdapter) validateInput(input services.AgentInput) error {
+	if input.SessionID == "" {
+		return errors.New("session ID is required")
+	}
+	if input.Message == "" {
+		return errors.New("message is required")
+	}
+	if len(input.Message) > 25000 {
+		return errors.New("message exceeds maximum length of 25000 characters")
+	}
+	if input.Filter != nil {
+		if err := input.Filter.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// retrieveAndGenerateConfiguration builds the RetrieveAndGenerate configuration for this knowledge base
+func (a *KnowledgeBaseAdapter) retrieveAndGenerateConfiguration(filter *services.RetrievalFilter) (*types.RetrieveAndGenerateConfiguration, error) {
+	retrievalConfig, err := vectorSearchConfiguration(filter, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	kbConfig := &types.KnowledgeBaseRetrieveAndGenerateConfiguration{
+		KnowledgeBaseId:        aws.String(a.knowledgeBaseID),
+		ModelArn:               aws.String(a.modelARN),
+		RetrievalConfiguration: retrievalConfig,
+	}
+	if guardrail := a.config.Guardrail; guardrail.Enabled() {
+		kbConfig.GenerationConfiguration = &types.GenerationConfiguration{
+			GuardrailConfiguration: &types.GuardrailConfiguration{
+				GuardrailId:      aws.String(guardrail.ID),
+				GuardrailVersion: aws.String(guardrail.Version),
+			},
+		}
+	}
+
+	return &types.RetrieveAndGenerateConfiguration{
+		Type:                       types.RetrieveAndGenerateTypeKnowledgeBase,
+		KnowledgeBaseConfiguration: kbConfig,
+	}, nil
+}
+
+// vectorSearchConfiguration builds the retrieval configuration for an optional
+// filter and result limit, returning nil when neither is set
+func vectorSearchConfiguration(filter *services.RetrievalFilter, maxResults int) (*types.KnowledgeBaseRetrievalConfiguration, error) {
+	if filter == nil && maxResults == 0 {
+		return nil, nil
+	}
+
+	searchConfig := &types.KnowledgeBaseVectorSearchConfiguration{}
+	if maxResults > 0 {
+		searchConfig.NumberOfResults = aws.Int32(int32(maxResults))
+	}
+	if filter != nil {
+		if err := filter.Validate(); err != nil {
+			return nil, err
+		}
+		bedrockFilter, err := toBedrockFilter(filter)
+		if err != nil {
+			return nil, &services.DomainError{
+				Code:      services.ErrCodeInvalidInput,
+				Message:   "Invalid filter",
+				Retryable: false,
+				Cause:     err,
+			}
+		}
+		searchConfig.Filter = bedrockFilter
+	}
+
+	return &types.KnowledgeBaseRetrievalConfiguration{
+		VectorSearchConfiguration: searchConfig,
+	}, nil
+}
+
+// withRetry runs a knowledge base call with the adapter's retry policy
+func (a *KnowledgeBaseAdapter) withRetry(ctx context.Context, operation string, call func(ctx context.Context) (string, error)) error {
+	slog.InfoContext(ctx, "[Bedrock] "+operation+" request", "knowledge_base_id", a.knowledgeBaseID)
+	return retryCall(ctx, a.config, operation, call)
+}
+
+// lookupSession returns the Bedrock session ID for one of our sessions, if any
+func (a *KnowledgeBaseAdapter) lookupSession(sessionID string) *string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if id, ok := a.bedrockSessionID[sessionID]; ok {
+		return aws.String(id)
+	}
+	return nil
+}
+
+// storeSession remembers the Bedrock session ID assigned to one of our sessions
+func (a *KnowledgeBaseAdapter) storeSession(sessionID string, bedrockSessionID *string) {
+	if bedrockSessionID == nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.bedrockSessionID[sessionID] = aws.ToString(bedrockSessionID)
+}
+
+// ForgetSession drops the Bedrock session ID kept for one of our sessions.
+// It is called when our session is deleted or expires.
+func (a *KnowledgeBaseAdapter) ForgetSession(sessionID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.bedrockSessionID, sessionID)
+}
+
+// restartSession drops a Bedrock session that Bedrock has expired, so the
+// retried call starts a new one without the earlier turns' context
+func (a *KnowledgeBaseAdapter) restartSession(ctx context.Context, sessionID string) {
+	slog.WarnContext(ctx, "[Bedrock] Knowledge base session expired, starting a new one", logging.KeySessionID, sessionID)
+	a.ForgetSession(sessionID)
+}
+
+// isSessionExpired reports whether a call failed because Bedrock no longer
+// knows the session it was given, e.g. after the session's lifetime ended
+func isSessionExpired(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.ErrorCode() {
+	case "ValidationException", "ResourceNotFoundException":
+		return strings.Contains(strings.ToLower(apiErr.ErrorMessage()), "session")
+	}
+	return false
+}
+
+// convertRetrievalResult converts a Bedrock retrieval result to a domain passage
+func convertRetrievalResult(result types.KnowledgeBaseRetrievalResult) services.RetrievedPassage {
+	passage := services.RetrievedPassage{
+		Score:    aws.ToFloat64(result.Score),
+		Metadata: make(map[string]interface{}),
+	}
+
+	if result.Content != nil {
+		passage.Content = aws.ToString(result.Content.Text)
+	}
+
+	_, passage.SourceID, passage.URL = resolveLocation(result.Location)
+
+	for k, v := range result.Metadata {
+		passage.Metadata[k] = documentValue(v)
+	}
+
+	return passage
+}
@@ -0,0 +1,403 @@
+package bedrock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/document"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/types"
+	"github.com/aws/smithy-go"
+
+	"github.com/bedrock-chat-poc/backend/domain/services"
+)
+
+// mockKnowledgeBaseClient is a mock implementation of the knowledge base client for testing
+type mockKnowledgeBaseClient struct {
+	ragFunc      func(ctx context.Context, input *bedrockagentruntime.RetrieveAndGenerateInput) (*bedrockagentruntime.RetrieveAndGenerateOutput, error)
+	retrieveFunc func(ctx context.Context, input *bedrockagentruntime.RetrieveInput) (*bedrockagentruntime.RetrieveOutput, error)
+	callCount    int
+}
+
+func (m *mockKnowledgeBaseClient) RetrieveAndGenerate(ctx context.Context, input *bedrockagentruntime.RetrieveAndGenerateInput, optFns ...func(*bedrockagentruntime.Options)) (*bedrockagentruntime.RetrieveAndGenerateOutput, error) {
+	m.callCount++
+	if m.ragFunc != nil {
+		return m.ragFunc(ctx, input)
+	}
+	return nil, errors.New("mock not configured")
+}
+
+func (m *mockKnowledgeBaseClient) RetrieveAndGenerateStream(ctx context.Context, input *bedrockagentruntime.RetrieveAndGenerateStreamInput, optFns ...func(*bedrockagentruntime.Options)) (*bedrockagentruntime.RetrieveAndGenerateStreamOutput, error) {
+	m.callCount++
+	return nil, errors.New("mock not configured")
+}
+
+func (m *mockKnowledgeBaseClient) Retrieve(ctx context.Context, input *bedrockagentruntime.RetrieveInput, optFns ...func(*bedrockagentruntime.Options)) (*bedrockagentruntime.RetrieveOutput, error) {
+	m.callCount++
+	if m.retrieveFunc != nil {
+		return m.retrieveFunc(ctx, input)
+	}
+	return nil, errors.New("mock not configured")
+}
+
+// mockRAGEventReader feeds canned events to a RetrieveAndGenerateStreamEventStream
+type mockRAGEventReader struct {
+	events chan types.RetrieveAndGenerateStreamResponseOutput
+	err    error
+}
+
+func newMockRAGEventReader(events ...types.RetrieveAndGenerateStreamResponseOutput) *mockRAGEventReader {
+	r := &mockRAGEventReader{events: make(chan types.RetrieveAndGenerateStreamResponseOutput, len(events))}
+	for _, e := range events {
+		r.events <- e
+	}
+	close(r.events)
+	return r
+}
+
+func (r *mockRAGEventReader) Events() <-chan types.RetrieveAndGenerateStreamResponseOutput {
+	return r.events
+}
+
+func (r *mockRAGEventReader) Close() error { return nil }
+
+func (r *mockRAGEventReader) Err() error { return r.err }
+
+func testKnowledgeBaseConfig() AdapterConfig {
+	return AdapterConfig{
+		MaxRetries:     2,
+		InitialBackoff: 1 * time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+		RequestTimeout: 5 * time.Second,
+	}
+}
+
+func TestModelARN(t *testing.T) {
+	if got := modelARN("us-east-1", "anthropic.claude-v2"); got != "arn:aws:bedrock:us-east-1::foundation-model/anthropic.claude-v2" {
+		t.Errorf("modelARN() = %s", got)
+	}
+	arn := "arn:aws:bedrock:us-east-1:123456789012:inference-profile/us.anthropic.claude-3-haiku"
+	if got := modelARN("us-east-1", arn); got != arn {
+		t.Errorf("modelARN() should leave ARNs untouched, got %s", got)
+	}
+}
+
+func TestNewKnowledgeBaseAdapter_Validation(t *testing.T) {
+	if _, err := NewKnowledgeBaseAdapter(context.Background(), "", "model", DefaultConfig()); err == nil {
+		t.Error("Expected error for empty knowledge base ID")
+	}
+	if _, err := NewKnowledgeBaseAdapter(context.Background(), "KB123", "", DefaultConfig()); err == nil {
+		t.Error("Expected error for empty model ID")
+	}
+}
+
+func TestKnowledgeBaseAdapter_InvokeAgent(t *testing.T) {
+	var requests []*bedrockagentruntime.RetrieveAndGenerateInput
+	client := &mockKnowledgeBaseClient{
+		ragFunc: func(ctx context.Context, input *bedrockagentruntime.RetrieveAndGenerateInput) (*bedrockagentruntime.RetrieveAndGenerateOutput, error) {
+			requests = append(requests, input)
+			return &bedrockagentruntime.RetrieveAndGenerateOutput{
+				SessionId: aws.String("bedrock-session-1"),
+				Output:    &types.RetrieveAndGenerateOutput{Text: aws.String("Leave is 20 days.")},
+				Citations: []types.Citation{
+					{
+						GeneratedResponsePart: &types.GeneratedResponsePart{
+							TextResponsePart: &types.TextResponsePart{Text: aws.String("Leave is 20 days.")},
+						},
+						RetrievedReferences: []types.RetrievedReference{
+							{
+								Location: &types.RetrievalResultLocation{
+									S3Location: &types.RetrievalResultS3Location{Uri: aws.String("s3://docs/leave.pdf")},
+								},
+							},
+						},
+					},
+				},
+			}, nil
+		},
+	}
+	cfg := testKnowledgeBaseConfig()
+	cfg.Guardrail = GuardrailConfig{ID: "gr-abc123", Version: "DRAFT"}
+	adapter := newKnowledgeBaseAdapter(client, "KB123", "arn:model", cfg)
+
+	input := services.AgentInput{
+		SessionID: "session-1",
+		Message:   "How much leave do I get?",
+		Filter:    &services.RetrievalFilter{Operator: services.FilterEquals, Key: "department", Value: "hr"},
+	}
+
+	response, err := adapter.InvokeAgent(context.Background(), input)
+	if err != nil {
+		t.Fatalf("InvokeAgent() error = %v", err)
+	}
+	if response.Content != "Leave is 20 days." {
+		t.Errorf("Expected content 'Leave is 20 days.', got %q", response.Content)
+	}
+	if len(response.Citations) != 1 || response.Citations[0].URL != "s3://docs/leave.pdf" {
+		t.Errorf("Unexpected citations: %+v", response.Citations)
+	}
+
+	first := requests[0]
+	if first.SessionId != nil {
+		t.Errorf("First turn should not send a session ID, got %s", aws.ToString(first.SessionId))
+	}
+	kbConfig := first.RetrieveAndGenerateConfiguration.KnowledgeBaseConfiguration
+	if aws.ToString(kbConfig.KnowledgeBaseId) != "KB123" || aws.ToString(kbConfig.ModelArn) != "arn:model" {
+		t.Errorf("Unexpected knowledge base configuration: %+v", kbConfig)
+	}
+	if kbConfig.RetrievalConfiguration == nil || kbConfig.RetrievalConfiguration.VectorSearchConfiguration.Filter == nil {
+		t.Error("Expected filter to be applied to retrieval configuration")
+	}
+	if generation := kbConfig.GenerationConfiguration; generation == nil || aws.ToString(generation.GuardrailConfiguration.GuardrailId) != "gr-abc123" ||
+		aws.ToString(generation.GuardrailConfiguration.GuardrailVersion) != "DRAFT" {
+		t.Errorf("Unexpected generation configuration: %+v", kbConfig.GenerationConfiguration)
+	}
+
+	// Second turn reuses the Bedrock session for multi-turn context
+	if _, err := adapter.InvokeAgent(context.Background(), input); err != nil {
+		t.Fatalf("InvokeAgent() error = %v", err)
+	}
+	if got := aws.ToString(requests[1].SessionId); got != "bedrock-session-1" {
+		t.Errorf("Expected second turn to reuse session 'bedrock-session-1', got %q", got)
+	}
+}
+
+func TestKnowledgeBaseAdapter_InvokeAgent_Retry(t *testing.T) {
+	client := &mockKnowledgeBaseClient{
+		ragFunc: func(ctx context.Context, input *bedrockagentruntime.RetrieveAndGenerateInput) (*bedrockagentruntime.RetrieveAndGenerateOutput, error) {
+			return nil, &smithy.GenericAPIError{Code: "ThrottlingException", Message: "slow down"}
+		},
+	}
+	adapter := newKnowledgeBaseAdapter(client, "KB123", "arn:model", testKnowledgeBaseConfig())
+
+	_, err := adapter.InvokeAgent(context.Background(), services.AgentInput{SessionID: "s", Message: "hi"})
+
+	var domainErr *services.DomainError
+	if !errors.As(err, &domainErr) || domainErr.Code != services.ErrCodeRateLimit {
+		t.Fatalf("Expected %s error, got %v", services.ErrCodeRateLimit, err)
+	}
+	if client.callCount != 3 {
+		t.Errorf("Expected 3 calls (initial + 2 retries), got %d", client.callCount)
+	}
+}
+
+func TestKnowledgeBaseAdapter_InvokeAgent_Guardrail(t *testing.T) {
+	client := &mockKnowledgeBaseClient{
+		ragFunc: func(ctx context.Context, input *bedrockagentruntime.RetrieveAndGenerateInput) (*bedrockagentruntime.RetrieveAndGenerateOutput, error) {
+			return &bedrockagentruntime.RetrieveAndGenerateOutput{
+				SessionId:       aws.String("bedrock-session-1"),
+				Output:          &types.RetrieveAndGenerateOutput{Text: aws.String("Sorry, I can't help with that.")},
+				GuardrailAction: types.GuadrailActionIntervened,
+			}, nil
+		},
+	}
+	adapter := newKnowledgeBaseAdapter(client, "KB123", "arn:model", testKnowledgeBaseConfig())
+
+	_, err := adapter.InvokeAgent(context.Background(), services.AgentInput{SessionID: "s", Message: "hi"})
+
+	var domainErr *services.DomainError
+	if !errors.As(err, &domainErr) || domainErr.Code != services.ErrCodeGuardrailBlocked || domainErr.Guardrail == nil {
+		t.Fatalf("Expected %s error with an intervention, got %v", services.ErrCodeGuardrailBlocked, err)
+	}
+}
+
+func TestKnowledgeBaseAdapter_ExpiredSession(t *testing.T) {
+	var sessionIDs []string
+	client := &mockKnowledgeBaseClient{
+		ragFunc: func(ctx context.Context, input *bedrockagentruntime.RetrieveAndGenerateInput) (*bedrockagentruntime.RetrieveAndGenerateOutput, error) {
+			sessionIDs = append(sessionIDs, aws.ToString(input.SessionId))
+			if aws.ToString(input.SessionId) == "bedrock-session-old" {
+				return nil, &smithy.GenericAPIError{Code: "ValidationException", Message: "Session with Id bedrock-session-old is not valid"}
+			}
+			return &bedrockagentruntime.RetrieveAndGenerateOutput{
+				SessionId: aws.String("bedrock-session-new"),
+				Output:    &types.RetrieveAndGenerateOutput{Text: aws.String("Leave is 20 days.")},
+			}, nil
+		},
+	}
+	adapter := newKnowledgeBaseAdapter(client, "KB123", "arn:model", testKnowledgeBaseConfig())
+	adapter.storeSession("s", aws.String("bedrock-session-old"))
+
+	// Bedrock expired its session, so a new one is started
+	response, err := adapter.InvokeAgent(context.Background(), services.AgentInput{SessionID: "s", Message: "hi"})
+	if err != nil {
+		t.Fatalf("InvokeAgent() error = %v", err)
+	}
+	if response.Content != "Leave is 20 days." {
+		t.Errorf("Unexpected content %q", response.Content)
+	}
+	if len(sessionIDs) != 2 || sessionIDs[0] != "bedrock-session-old" || sessionIDs[1] != "" {
+		t.Errorf("Expected a retry without the expired session, got %q", sessionIDs)
+	}
+	if id := aws.ToString(adapter.lookupSession("s")); id != "bedrock-session-new" {
+		t.Errorf("Expected the new Bedrock session to be kept, got %q", id)
+	}
+
+	// Once our session is removed its Bedrock session is forgotten
+	adapter.ForgetSession("s")
+	if id := adapter.lookupSession("s"); id != nil {
+		t.Errorf("Expected no Bedrock session, got %q", aws.ToString(id))
+	}
+}
+
+func TestKnowledgeBaseAdapter_Retrieve(t *testing.T) {
+	var request *bedrockagentruntime.RetrieveInput
+	client := &mockKnowledgeBaseClient{
+		retrieveFunc: func(ctx context.Context, input *bedrockagentruntime.RetrieveInput) (*bedrockagentruntime.RetrieveOutput, error) {
+			request = input
+			return &bedrockagentruntime.RetrieveOutput{
+				RetrievalResults: []types.KnowledgeBaseRetrievalResult{
+					{
+						Content: &types.RetrievalResultContent{Text: aws.String("Employees get 20 days of leave.")},
+						Location: &types.RetrievalResultLocation{
+							S3Location: &types.RetrievalResultS3Location{Uri: aws.String("s3://docs/leave.pdf")},
+						},
+						Metadata: map[string]document.Interface{"department": document.NewLazyDocument("hr")},
+						Score:    aws.Float64(0.87),
+					},
+				},
+			}, nil
+		},
+	}
+	adapter := newKnowledgeBaseAdapter(client, "KB123", "arn:model", testKnowledgeBaseConfig())
+
+	passages, err := adapter.Retrieve(context.Background(), services.RetrieveInput{Query: "leave policy"})
+	if err != nil {
+		t.Fatalf("Retrieve() error = %v", err)
+	}
+
+	if got := aws.ToInt32(request.RetrievalConfiguration.VectorSearchConfiguration.NumberOfResults); got != defaultRetrieveResults {
+		t.Errorf("Expected default of %d results, got %d", defaultRetrieveResults, got)
+	}
+	if len(passages) != 1 {
+		t.Fatalf("Expected 1 passage, got %d", len(passages))
+	}
+	passage := passages[0]
+	if passage.Content != "Employees get 20 days of leave." || passage.Score != 0.87 || passage.SourceID != "s3://docs/leave.pdf" {
+		t.Errorf("Unexpected passage: %+v", passage)
+	}
+	if passage.Metadata["department"] != "hr" {
+		t.Errorf("Expected metadata department 'hr', got %v", passage.Metadata["department"])
+	}
+}
+
+func TestKnowledgeBaseAdapter_Retrieve_Validation(t *testing.T) {
+	adapter := newKnowledgeBaseAdapter(&mockKnowledgeBaseClient{}, "KB123", "arn:model", testKnowledgeBaseConfig())
+
+	tests := []struct {
+		name  string
+		input services.RetrieveInput
+	}{
+		{name: "empty query", input: services.RetrieveInput{Query: "  "}},
+		{name: "limit too large", input: services.RetrieveInput{Query: "q", MaxResults: maxRetrieveResults + 1}},
+		{name: "invalid filter", input: services.RetrieveInput{Query: "q", Filter: &services.RetrievalFilter{Operator: services.FilterIn, Key: "k"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := adapter.Retrieve(context.Background(), tt.input)
+			var domainErr *services.DomainError
+			if !errors.As(err, &domainErr) || domainErr.Code != services.ErrCodeInvalidInput {
+				t.Errorf("Expected %s error, got %v", services.ErrCodeInvalidInput, err)
+			}
+		})
+	}
+}
+
+func TestKnowledgeBaseStreamReader(t *testing.T) {
+	reader := newMockRAGEventReader(
+		&types.RetrieveAndGenerateStreamResponseOutputMemberOutput{
+			Value: types.RetrieveAndGenerateOutputEvent{Text: aws.String("Leave is ")},
+		},
+		&types.RetrieveAndGenerateStreamResponseOutputMemberOutput{
+			Value: types.RetrieveAndGenerateOutputEvent{Text: aws.String("20 days.")},
+		},
+		&types.RetrieveAndGenerateStreamResponseOutputMemberCitation{
+			Value: types.CitationEvent{
+				GeneratedResponsePart: &types.GeneratedResponsePart{
+					TextResponsePart: &types.TextResponsePart{Text: aws.String("Leave is 20 days.")},
+				},
+				RetrievedReferences: []types.RetrievedReference{
+					{
+						Location: &types.RetrievalResultLocation{
+							S3Location: &types.RetrievalResultS3Location{Uri: aws.String("s3://docs/leave.pdf")},
+						},
+					},
+				},
+			},
+		},
+	)
+	stream := bedrockagentruntime.NewRetrieveAndGenerateStreamEventStream(func(es *bedrockagentruntime.RetrieveAndGenerateStreamEventStream) {
+		es.Reader = reader
+	})
+
+	processor := NewStreamProcessor(DefaultStreamProcessorConfig())
+	writer := &mockChunkWriter{}
+
+	err := processor.ProcessStream(context.Background(), newKnowledgeBaseStreamReader(context.Background(), stream, ""), writer)
+	if err != nil {
+		t.Fatalf("ProcessStream() error = %v", err)
+	}
+
+	if len(writer.contentChunks) != 2 || writer.contentChunks[0]+writer.contentChunks[1] != "Leave is 20 days." {
+		t.Errorf("Unexpected content chunks: %v", writer.contentChunks)
+	}
+	// The citation follows the last content chunk and must not be dropped
+	if len(writer.citationChunks) != 1 || writer.citationChunks[0].URL != "s3://docs/leave.pdf" {
+		t.Errorf("Unexpected citation chunks: %+v", writer.citationChunks)
+	}
+	if !writer.doneWritten {
+		t.Error("Expected done chunk to be written")
+	}
+}
+
+func TestKnowledgeBaseStreamReader_Guardrail(t *testing.T) {
+	reader := newMockRAGEventReader(
+		&types.RetrieveAndGenerateStreamResponseOutputMemberOutput{
+			Value: types.RetrieveAndGenerateOutputEvent{Text: aws.String("Sorry, ")},
+		},
+		&types.RetrieveAndGenerateStreamResponseOutputMemberGuardrail{
+			Value: types.GuardrailEvent{Action: types.GuadrailActionIntervened},
+		},
+	)
+	stream := bedrockagentruntime.NewRetrieveAndGenerateStreamEventStream(func(es *bedrockagentruntime.RetrieveAndGenerateStreamEventStream) {
+		es.Reader = reader
+	})
+
+	writer := &mockChunkWriter{}
+	err := NewStreamProcessor(DefaultStreamProcessorConfig()).ProcessStream(context.Background(), newKnowledgeBaseStreamReader(context.Background(), stream, ""), writer)
+
+	var domainErr *services.DomainError
+	if !errors.As(err, &domainErr) || domainErr.Code != services.ErrCodeGuardrailBlocked {
+		t.Fatalf("Expected %s error, got %v", services.ErrCodeGuardrailBlocked, err)
+	}
+	if len(writer.guardrails) != 1 {
+		t.Fatalf("Expected one guardrail chunk, got %+v", writer.guardrails)
+	}
+	if len(writer.contentChunks) != 1 || writer.doneWritten {
+		t.Errorf("Expected the content before the intervention and no done chunk, got %v, done=%v", writer.contentChunks, writer.doneWritten)
+	}
+}
+
+func TestKnowledgeBaseStreamReader_StreamError(t *testing.T) {
+	reader := newMockRAGEventReader()
+	reader.err = errors.New("connection reset")
+	stream := bedrockagentruntime.NewRetrieveAndGenerateStreamEventStream(func(es *bedrockagentruntime.RetrieveAndGenerateStreamEventStream) {
+		es.Reader = reader
+	})
+
+	sr := newKnowledgeBaseStreamReader(context.Background(), stream, "")
+	_, err := sr.Next(context.Background())
+
+	var domainErr *services.DomainError
+	if !errors.As(err, &domainErr) || domainErr.Code != services.ErrCodeMalformedStream {
+		t.Errorf("Expected %s error, got %v", services.ErrCodeMalformedStream, err)
+	}
+	// The error ends the stream
+	if event, err := sr.Next(context.Background()); event.Type != services.StreamEventDone || err != nil {
+		t.Errorf("Expected the stream to be done, got %s event, err=%v", event.Type, err)
+	}
+}
@@ -357,7 +357,8 @@ func TestKnowledgeBaseIntegration(t *testing.T) {
 
 		// Read all chunks from the stream
 		for {
-			chunk, done, err := streamReader.Read()
+			event, err := streamReader.Next(ctx)
+			chunk, done := event.Content, event.Type == services.StreamEventDone
 			if done {
 				break
 			}
@@ -371,8 +372,7 @@ func TestKnowledgeBaseIntegration(t *testing.T) {
 			}
 
 			// Check for citations in stream
-			citation, err := streamReader.ReadCitation()
-			if err == nil && citation != nil {
+			for _, citation := range event.Citations {
 				citations = append(citations, citation)
 				t.Logf("Stream citation received: %v", citation)
 			}
@@ -0,0 +1,109 @@
+package bedrock
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/types"
+
+	"github.com/bedrock-chat-poc/backend/domain/entities"
+	"github.com/bedrock-chat-poc/backend/domain/services"
+	"github.com/bedrock-chat-poc/backend/infrastructure/logging"
+)
+
+// knowledgeBaseStreamReader implements the StreamReader interface for
+// RetrieveAndGenerateStream event streams
+type knowledgeBaseStreamReader struct {
+	ctx       context.Context
+	stream    *bedrockagentruntime.RetrieveAndGenerateStreamEventStream
+	done      bool
+	requestID string
+	eventChan <-chan types.RetrieveAndGenerateStreamResponseOutput
+}
+
+// newKnowledgeBaseStreamReader creates a new knowledge base stream reader
+func newKnowledgeBaseStreamReader(ctx context.Context, stream *bedrockagentruntime.RetrieveAndGenerateStreamEventStream, requestID string) services.StreamReader {
+	return &knowledgeBaseStreamReader{
+		ctx:       ctx,
+		stream:    stream,
+		requestID: requestID,
+		eventChan: stream.Events(),
+	}
+}
+
+// Next returns the next event of the stream. It waits for the next Bedrock
+// event until ctx is done.
+func (sr *knowledgeBaseStreamReader) Next(ctx context.Context) (services.StreamEvent, error) {
+	for {
+		if sr.done {
+			return services.StreamEvent{Type: services.StreamEventDone}, nil
+		}
+
+		// Wait for the next event, the end of the stream or cancellation
+		var event types.RetrieveAndGenerateStreamResponseOutput
+		var ok bool
+		select {
+		case <-sr.ctx.Done():
+			sr.done = true
+			return services.StreamEvent{}, sr.ctx.Err()
+		case <-ctx.Done():
+			return services.StreamEvent{}, ctx.Err()
+		case event, ok = <-sr.eventChan:
+		}
+
+		if !ok {
+			sr.done = true
+			if err := sr.stream.Err(); err != nil {
+				slog.ErrorContext(sr.ctx, "[Bedrock] Knowledge base stream error", logging.KeyRequestID, sr.requestID, "error", err)
+				return services.StreamEvent{}, transformStreamError(err, sr.requestID)
+			}
+			slog.InfoContext(sr.ctx, "[Bedrock] Knowledge base stream completed", logging.KeyRequestID, sr.requestID)
+			return services.StreamEvent{Type: services.StreamEventDone}, nil
+		}
+
+		switch e := event.(type) {
+		case *types.RetrieveAndGenerateStreamResponseOutputMemberOutput:
+			if e.Value.Text != nil && *e.Value.Text != "" {
+				return services.StreamEvent{Type: services.StreamEventContent, Content: *e.Value.Text}, nil
+			}
+
+		case *types.RetrieveAndGenerateStreamResponseOutputMemberCitation:
+			// Citations arrive as separate events after the text they support
+			citations := convertCitation(types.Citation{
+				GeneratedResponsePart: e.Value.GeneratedResponsePart,
+				RetrievedReferences:   e.Value.RetrievedReferences,
+			})
+			if len(citations) > 0 {
+				return services.StreamEvent{Type: services.StreamEventCitation, Citations: citations}, nil
+			}
+
+		case *types.RetrieveAndGenerateStreamResponseOutputMemberGuardrail:
+			slog.WarnContext(sr.ctx, "[Bedrock] Guardrail event received", "action", e.Value.Action, logging.KeyRequestID, sr.requestID)
+			if e.Value.Action == types.GuadrailActionIntervened {
+				// The knowledge base doesn't say which policy triggered
+				return guardrailEvent(entities.GuardrailIntervention{}), nil
+			}
+			return services.StreamEvent{
+				Type:     services.StreamEventMetadata,
+				Metadata: map[string]interface{}{"guardrail_action": string(e.Value.Action)},
+			}, nil
+
+		default:
+			slog.DebugContext(sr.ctx, "[Bedrock] Unknown event type", "type", fmt.Sprintf("%T", e), logging.KeyRequestID, sr.requestID)
+		}
+	}
+}
+
+// RequestID returns the AWS request ID of the RetrieveAndGenerateStream call
+func (sr *knowledgeBaseStreamReader) RequestID() string {
+	return sr.requestID
+}
+
+// Close closes the stream reader
+func (sr *knowledgeBaseStreamReader) Close() error {
+	sr.done = true
+	slog.DebugContext(sr.ctx, "[Bedrock] Knowledge base stream reader closed", logging.KeyRequestID, sr.requestID)
+	return sr.stream.Close()
+}
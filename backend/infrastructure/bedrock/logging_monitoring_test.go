@@ -66,10 +66,10 @@ func testAPICallLogging(t *testing.T) {
 	if !strings.Contains(logOutput, "[Bedrock] InvokeAgent request") {
 		t.Error("Log should contain InvokeAgent request entry")
 	}
-	if !strings.Contains(logOutput, "SessionID: test-session-789") {
+	if !strings.Contains(logOutput, "session_id=test-session-789") {
 		t.Error("Log should contain session ID")
 	}
-	if !strings.Contains(logOutput, "AgentID: test-agent-123") {
+	if !strings.Contains(logOutput, "agent_id=test-agent-123") {
 		t.Error("Log should contain agent ID")
 	}
 
@@ -95,19 +95,19 @@ func testErrorLoggingWithRequestIDs(t *testing.T) {
 			name:      "ThrottlingException",
 			errorCode: "ThrottlingException",
 			errorMsg:  "Rate exceeded",
-			expectLog: "AWS API Error - Code: ThrottlingException",
+			expectLog: "code=ThrottlingException",
 		},
 		{
 			name:      "AccessDeniedException",
 			errorCode: "AccessDeniedException",
 			errorMsg:  "User is not authorized",
-			expectLog: "AWS API Error - Code: AccessDeniedException",
+			expectLog: "code=AccessDeniedException",
 		},
 		{
 			name:      "ValidationException",
 			errorCode: "ValidationException",
 			errorMsg:  "Invalid parameter",
-			expectLog: "AWS API Error - Code: ValidationException",
+			expectLog: "code=ValidationException",
 		},
 	}
 
@@ -152,7 +152,7 @@ func testErrorLoggingWithRequestIDs(t *testing.T) {
 			if !strings.Contains(logOutput, tc.errorMsg) {
 				t.Errorf("Log should contain error message: %s", tc.errorMsg)
 			}
-			if !strings.Contains(logOutput, "RequestID:") {
+			if !strings.Contains(logOutput, "request_id=") {
 				t.Error("Log should contain request ID")
 			}
 			if !strings.Contains(logOutput, "[Bedrock] InvokeAgent failed") {
@@ -340,13 +340,13 @@ func testStructuredLoggingFormat(t *testing.T) {
 			}
 			
 			// Check for key-value pairs in structured format
-			if strings.Contains(line, "SessionID:") {
-				if !strings.Contains(line, "SessionID: structured-session-123") {
+			if strings.Contains(line, "session_id=") {
+				if !strings.Contains(line, "session_id=structured-session-123") {
 					t.Error("Log should contain correct session ID format")
 				}
 			}
-			if strings.Contains(line, "AgentID:") {
-				if !strings.Contains(line, "AgentID: test-agent-structured") {
+			if strings.Contains(line, "agent_id=") {
+				if !strings.Contains(line, "agent_id=test-agent-structured") {
 					t.Error("Log should contain correct agent ID format")
 				}
 			}
@@ -354,7 +354,7 @@ func testStructuredLoggingFormat(t *testing.T) {
 	}
 
 	// Verify specific structured log patterns - focus on request logging which always happens
-	if !containsStructuredLog(logOutput, "InvokeAgent request", "SessionID", "AgentID") {
+	if !containsStructuredLog(logOutput, "InvokeAgent request", "session_id=", "agent_id=") {
 		t.Error("Log should contain structured request log")
 	}
 
@@ -411,13 +411,13 @@ func testRetryLogging(t *testing.T) {
 	logOutput := logBuffer.String()
 
 	// Verify retry logging
-	if !strings.Contains(logOutput, "[Bedrock] Retry attempt 1") {
+	if !strings.Contains(logOutput, "attempt=1") {
 		t.Error("Log should contain first retry attempt")
 	}
-	if !strings.Contains(logOutput, "[Bedrock] Retry attempt 2") {
+	if !strings.Contains(logOutput, "attempt=2") {
 		t.Error("Log should contain second retry attempt")
 	}
-	if !strings.Contains(logOutput, "RequestID:") {
+	if !strings.Contains(logOutput, "request_id=") {
 		t.Error("Log should contain request ID")
 	}
 
@@ -466,10 +466,10 @@ func testStreamLogging(t *testing.T) {
 	if !strings.Contains(logOutput, "[Bedrock] InvokeAgentStream request") {
 		t.Error("Log should contain stream request entry")
 	}
-	if !strings.Contains(logOutput, "SessionID: stream-session") {
+	if !strings.Contains(logOutput, "session_id=stream-session") {
 		t.Error("Log should contain session ID")
 	}
-	if !strings.Contains(logOutput, "AgentID: test-agent") {
+	if !strings.Contains(logOutput, "agent_id=test-agent") {
 		t.Error("Log should contain agent ID")
 	}
 
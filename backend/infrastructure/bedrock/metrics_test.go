@@ -0,0 +1,117 @@
+package bedrock
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime"
+
+	"github.com/bedrock-chat-poc/backend/domain/services"
+	"github.com/bedrock-chat-poc/backend/infrastructure/metrics"
+)
+
+// scrapeMetrics returns the metrics in the Prometheus exposition format
+func scrapeMetrics(t *testing.T, m *metrics.Metrics) string {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	m.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	return recorder.Body.String()
+}
+
+func assertMetrics(t *testing.T, output string, want ...string) {
+	t.Helper()
+	for _, line := range want {
+		if !strings.Contains(output, line) {
+			t.Errorf("Expected %q in metrics output", line)
+		}
+	}
+}
+
+func TestRetryCall_RecordsMetrics(t *testing.T) {
+	m := metrics.New()
+	cfg := testKnowledgeBaseConfig()
+	cfg.Metrics = m
+
+	calls := 0
+	client := &mockKnowledgeBaseClient{
+		retrieveFunc: func(ctx context.Context, input *bedrockagentruntime.RetrieveInput) (*bedrockagentruntime.RetrieveOutput, error) {
+			calls++
+			if calls == 1 {
+				return nil, awsResponseError("ThrottlingException", "req-1")
+			}
+			return &bedrockagentruntime.RetrieveOutput{}, nil
+		},
+	}
+	adapter := newKnowledgeBaseAdapter(client, "KB123", "arn:model", cfg)
+
+	if _, err := adapter.Retrieve(context.Background(), services.RetrieveInput{Query: "leave"}); err != nil {
+		t.Fatalf("Retrieve() error = %v", err)
+	}
+
+	assertMetrics(t, scrapeMetrics(t, m),
+		`chat_bedrock_attempts_total{operation="Retrieve",outcome="error"} 1`,
+		`chat_bedrock_attempts_total{operation="Retrieve",outcome="success"} 1`,
+		`chat_bedrock_errors_total{code="ThrottlingException",operation="Retrieve"} 1`,
+		`chat_bedrock_retries_total{operation="Retrieve"} 1`,
+		`chat_bedrock_attempt_duration_seconds_count{operation="Retrieve"} 2`,
+	)
+}
+
+func TestStreamProcessor_RecordsMetrics(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		m := metrics.New()
+		config := DefaultStreamProcessorConfig()
+		config.Metrics = m
+
+		reader := &mockStreamReader{chunks: []string{"Hello", " world"}, hangAfter: -1}
+		if err := NewStreamProcessor(config).ProcessStream(context.Background(), reader, &mockChunkWriter{}); err != nil {
+			t.Fatalf("ProcessStream() error = %v", err)
+		}
+
+		assertMetrics(t, scrapeMetrics(t, m),
+			"chat_stream_first_chunk_seconds_count 1",
+			`chat_stream_duration_seconds_count{outcome="success"} 1`,
+			"chat_stream_chunks_sum 2",
+		)
+	})
+
+	t.Run("stalled", func(t *testing.T) {
+		m := metrics.New()
+		config := StreamProcessorConfig{
+			StreamTimeout: 5 * time.Second,
+			ChunkTimeout:  20 * time.Millisecond,
+			Metrics:       m,
+		}
+
+		reader := &mockStreamReader{chunks: []string{"Hello", "never sent"}, hangAfter: 1}
+		if err := NewStreamProcessor(config).ProcessStream(context.Background(), reader, &mockChunkWriter{}); err == nil {
+			t.Fatal("Expected stall error")
+		}
+
+		assertMetrics(t, scrapeMetrics(t, m),
+			`chat_stream_duration_seconds_count{outcome="stalled"} 1`,
+			"chat_stream_stalls_total 1",
+			"chat_stream_timeouts_total 0",
+		)
+	})
+
+	t.Run("read error", func(t *testing.T) {
+		m := metrics.New()
+		config := DefaultStreamProcessorConfig()
+		config.Metrics = m
+
+		reader := &mockStreamReader{chunks: []string{""}, errors: []error{errors.New("connection reset")}, hangAfter: -1}
+		if err := NewStreamProcessor(config).ProcessStream(context.Background(), reader, &mockChunkWriter{}); err == nil {
+			t.Fatal("Expected read error")
+		}
+
+		assertMetrics(t, scrapeMetrics(t, m),
+			`chat_stream_duration_seconds_count{outcome="error"} 1`,
+			"chat_stream_first_chunk_seconds_count 0",
+		)
+	})
+}
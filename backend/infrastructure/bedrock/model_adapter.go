@@ -0,0 +1,335 @@
+package bedrock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+
+	"github.com/bedrock-chat-poc/backend/domain/entities"
+	"github.com/bedrock-chat-poc/backend/domain/services"
+	"github.com/bedrock-chat-poc/backend/infrastructure/logging"
+)
+
+// ConverseClient interface for testing
+type ConverseClient interface {
+	Converse(ctx context.Context, input *bedrockruntime.ConverseInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.ConverseOutput, error)
+	ConverseStream(ctx context.Context, input *bedrockruntime.ConverseStreamInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.ConverseStreamOutput, error)
+}
+
+// ModelConfig holds the inference settings for direct model chat
+type ModelConfig struct {
+	// ModelID is a foundation model ID, inference profile ID, or ARN
+	ModelID string
+	// SystemPrompt is sent with every request when set
+	SystemPrompt string
+	// Temperature controls response randomness (0-1)
+	Temperature float32
+	// MaxTokens caps the response length; zero uses the model's default
+	MaxTokens int32
+	// MaxHistoryMessages bounds how many earlier messages are sent; zero sends none
+	MaxHistoryMessages int
+}
+
+// DefaultModelConfig returns the default model configuration
+func DefaultModelConfig() ModelConfig {
+	return ModelConfig{
+		ModelID:            "anthropic.claude-v2",
+		Temperature:        0.7,
+		MaxTokens:          1024,
+		MaxHistoryMessages: 20,
+	}
+}
+
+// ModelAdapter implements the BedrockService interface by talking to a
+// foundation model directly through the Bedrock Runtime Converse API.
+// The model is stateless, so every request carries the session's history.
+type ModelAdapter struct {
+	client ConverseClient
+	model  ModelConfig
+	config AdapterConfig
+	// credentials are checked by CheckHealth
+	credentials aws.CredentialsProvider
+}
+
+// NewModelAdapter creates a new direct model adapter
+func NewModelAdapter(ctx context.Context, model ModelConfig, cfg AdapterConfig) (*ModelAdapter, error) {
+	if model.ModelID == "" {
+		return nil, fmt.Errorf("modelID is required")
+	}
+
+	// Load AWS configuration using IAM roles
+	awsCfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	client := bedrockruntime.NewFromConfig(awsCfg)
+
+	adapter := newModelAdapter(client, model, cfg)
+	adapter.credentials = awsCfg.Credentials
+	return adapter, nil
+}
+
+// newModelAdapter creates a model adapter around an existing client
+func newModelAdapter(client ConverseClient, model ModelConfig, cfg AdapterConfig) *ModelAdapter {
+	return &ModelAdapter{
+		client: client,
+		model:  model,
+		config: cfg,
+	}
+}
+
+// InvokeAgent sends the conversation to the model and returns the complete response
+func (a *ModelAdapter) InvokeAgent(ctx context.Context, input services.AgentInput) (*services.AgentResponse, error) {
+	if err := a.validateInput(input); err != nil {
+		return nil, &services.DomainError{
+			Code:      services.ErrCodeInvalidInput,
+			Message:   "Invalid input",
+			Retryable: false,
+			Cause:     err,
+		}
+	}
+
+	// Create request with timeout
+	reqCtx, cancel := context.WithTimeout(ctx, a.config.RequestTimeout)
+	defer cancel()
+
+	request := &bedrockruntime.ConverseInput{
+		ModelId:         aws.String(a.model.ModelID),
+		Messages:        a.buildMessages(input),
+		System:          a.systemPrompt(),
+		InferenceConfig: a.inferenceConfig(),
+		GuardrailConfig: a.guardrailConfig(),
+	}
+
+	var output *bedrockruntime.ConverseOutput
+	err := a.withRetry(reqCtx, "Converse", func(ctx context.Context) (string, error) {
+		var callErr error
+		output, callErr = a.client.Converse(ctx, request)
+		if callErr != nil {
+			return "", callErr
+		}
+		return responseRequestID(output.ResultMetadata), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response := &services.AgentResponse{
+		Citations: []entities.Citation{},
+		Metadata:  make(map[string]interface{}),
+		RequestID: responseRequestID(output.ResultMetadata),
+	}
+	if message, ok := output.Output.(*types.ConverseOutputMemberMessage); ok {
+		var content strings.Builder
+		for _, block := range message.Value.Content {
+			if text, ok := block.(*types.ContentBlockMemberText); ok {
+				content.WriteString(text.Value)
+			}
+		}
+		response.Content = content.String()
+	}
+	response.Metadata["stop_reason"] = string(output.StopReason)
+	if output.Usage != nil {
+		response.Metadata["input_tokens"] = aws.ToInt32(output.Usage.InputTokens)
+		response.Metadata["output_tokens"] = aws.ToInt32(output.Usage.OutputTokens)
+	}
+	if output.StopReason == types.StopReasonGuardrailIntervened {
+		var trace *types.GuardrailTraceAssessment
+		if output.Trace != nil {
+			trace = output.Trace.Guardrail
+		}
+		intervention := modelGuardrailIntervention(trace)
+		slog.WarnContext(ctx, "[Bedrock] Guardrail intervened",
+			"stage", intervention.Stage, "categories", intervention.Categories, logging.KeyRequestID, response.RequestID)
+		return nil, guardrailBlockedError(intervention, response.RequestID)
+	}
+
+	slog.InfoContext(ctx, "[Bedrock] Converse completed", "content_length", len(response.Content), "stop_reason", output.StopReason, logging.KeyRequestID, response.RequestID)
+	return response, nil
+}
+
+// InvokeAgentStream sends the conversation to the model and returns a streaming response
+func (a *ModelAdapter) InvokeAgentStream(ctx context.Context, input services.AgentInput) (services.StreamReader, error) {
+	if err := a.validateInput(input); err != nil {
+		return nil, &services.DomainError{
+			Code:      services.ErrCodeInvalidInput,
+			Message:   "Invalid input",
+			Retryable: false,
+			Cause:     err,
+		}
+	}
+
+	request := &bedrockruntime.ConverseStreamInput{
+		ModelId:         aws.String(a.model.ModelID),
+		Messages:        a.buildMessages(input),
+		System:          a.systemPrompt(),
+		InferenceConfig: a.inferenceConfig(),
+		GuardrailConfig: a.guardrailStreamConfig(),
+	}
+
+	// Opening the stream, including retries, is bounded by the request timeout
+	openCtx, open := withOpenTimeout(ctx, a.config.RequestTimeout)
+	defer open.close()
+	var output *bedrockruntime.ConverseStreamOutput
+	err := a.withRetry(openCtx, "ConverseStream", func(ctx context.Context) (string, error) {
+		var callErr error
+		output, callErr = a.client.ConverseStream(ctx, request)
+		if callErr != nil {
+			return "", callErr
+		}
+		return responseRequestID(output.ResultMetadata), nil
+	})
+	if err == nil {
+		err = open.opened()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	stream := output.GetStream()
+	if stream == nil {
+		return nil, &services.DomainError{
+			Code:      services.ErrCodeServiceError,
+			Message:   "No event stream in response",
+			Retryable: false,
+		}
+	}
+	return open.reader(newModelStreamReader(ctx, stream, responseRequestID(output.ResultMetadata))), nil
+}
+
+// validateInput validates the agent input
+func (a *ModelAdapter) validateInput(input services.AgentInput) error {
+	if input.SessionID == "" {
+		return errors.New("session ID is required")
+	}
+	if input.Message == "" {
+		return errors.New("message is required")
+	}
+	if len(input.Message) > 25000 {
+		return errors.New("message exceeds maximum length of 25000 characters")
+	}
+	return nil
+}
+
+// withRetry runs a model call with the adapter's retry policy
+func (a *ModelAdapter) withRetry(ctx context.Context, operation string, call func(ctx context.Context) (string, error)) error {
+	slog.InfoContext(ctx, "[Bedrock] "+operation+" request", "model_id", a.model.ModelID)
+	return retryCall(ctx, a.config, operation, call)
+}
+
+// ContinuesAnswers reports that the model can continue a partial answer: it
+// is sent as the start of the assistant's reply, which the model completes
+func (a *ModelAdapter) ContinuesAnswers() bool {
+	return true
+}
+
+// buildMessages converts the session history and the new message into a
+// Converse conversation. Failed and blocked answers are skipped, as are
+// questions a guardrail blocked. Consecutive messages from the same role are
+// merged, and the conversation always starts with the user, as the Converse
+// API requires. A continuation ends the conversation as the
+// assistant's reply so far, without trailing whitespace, which models reject.
+func (a *ModelAdapter) buildMessages(input services.AgentInput) []types.Message {
+	history := make([]entities.Message, 0, len(input.History))
+	for _, message := range input.History {
+		if message.Status == entities.StatusBlocked && message.Guardrail != nil && message.Guardrail.Stage == entities.GuardrailStageInput {
+			// A blocked question would only be blocked again
+			if n := len(history); n > 0 && history[n-1].Role == entities.RoleUser {
+				history = history[:n-1]
+			}
+			continue
+		}
+		if message.Status == entities.StatusError || message.Status == entities.StatusBlocked || strings.TrimSpace(message.Content) == "" {
+			continue
+		}
+		history = append(history, message)
+	}
+	if len(history) > a.model.MaxHistoryMessages {
+		history = history[len(history)-a.model.MaxHistoryMessages:]
+	}
+
+	messages := make([]types.Message, 0, len(history)+1)
+	appendMessage := func(role types.ConversationRole, content string) {
+		block := &types.ContentBlockMemberText{Value: content}
+		if n := len(messages); n > 0 && messages[n-1].Role == role {
+			messages[n-1].Content = append(messages[n-1].Content, block)
+			return
+		}
+		if len(messages) == 0 && role != types.ConversationRoleUser {
+			return
+		}
+		messages = append(messages, types.Message{
+			Role:    role,
+			Content: []types.ContentBlock{block},
+		})
+	}
+
+	for _, message := range history {
+		role := types.ConversationRoleUser
+		if message.Role == entities.RoleAgent {
+			role = types.ConversationRoleAssistant
+		}
+		appendMessage(role, message.Content)
+	}
+	appendMessage(types.ConversationRoleUser, input.Message)
+	if continuation := strings.TrimRightFunc(input.Continuation, unicode.IsSpace); continuation != "" {
+		appendMessage(types.ConversationRoleAssistant, continuation)
+	}
+
+	return messages
+}
+
+// systemPrompt returns the configured system prompt, or nil when none is set
+func (a *ModelAdapter) systemPrompt() []types.SystemContentBlock {
+	if a.model.SystemPrompt == "" {
+		return nil
+	}
+	return []types.SystemContentBlock{
+		&types.SystemContentBlockMemberText{Value: a.model.SystemPrompt},
+	}
+}
+
+// inferenceConfig returns the configured inference parameters
+func (a *ModelAdapter) inferenceConfig() *types.InferenceConfiguration {
+	inference := &types.InferenceConfiguration{
+		Temperature: aws.Float32(a.model.Temperature),
+	}
+	if a.model.MaxTokens > 0 {
+		inference.MaxTokens = aws.Int32(a.model.MaxTokens)
+	}
+	return inference
+}
+
+// guardrailConfig returns the configured guardrail, or nil when none is set
+func (a *ModelAdapter) guardrailConfig() *types.GuardrailConfiguration {
+	if !a.config.Guardrail.Enabled() {
+		return nil
+	}
+	return &types.GuardrailConfiguration{
+		GuardrailIdentifier: aws.String(a.config.Guardrail.ID),
+		GuardrailVersion:    aws.String(a.config.Guardrail.Version),
+		Trace:               types.GuardrailTraceEnabled,
+	}
+}
+
+// guardrailStreamConfig returns the configured guardrail for streaming calls,
+// or nil when none is set. Tracing reports which policies intervened.
+func (a *ModelAdapter) guardrailStreamConfig() *types.GuardrailStreamConfiguration {
+	if !a.config.Guardrail.Enabled() {
+		return nil
+	}
+	return &types.GuardrailStreamConfiguration{
+		GuardrailIdentifier: aws.String(a.config.Guardrail.ID),
+		GuardrailVersion:    aws.String(a.config.Guardrail.Version),
+		Trace:               types.GuardrailTraceEnabled,
+	}
+}
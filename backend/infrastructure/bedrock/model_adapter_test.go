@@ -0,0 +1,437 @@
+package bedrock
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/aws/smithy-go"
+
+	"github.com/bedrock-chat-poc/backend/domain/entities"
+	"github.com/bedrock-chat-poc/backend/domain/services"
+)
+
+// mockConverseClient is a mock implementation of the Converse client for testing
+type mockConverseClient struct {
+	converseFunc func(ctx context.Context, input *bedrockruntime.ConverseInput) (*bedrockruntime.ConverseOutput, error)
+	callCount    int
+}
+
+func (m *mockConverseClient) Converse(ctx context.Context, input *bedrockruntime.ConverseInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.ConverseOutput, error) {
+	m.callCount++
+	if m.converseFunc != nil {
+		return m.converseFunc(ctx, input)
+	}
+	return nil, errors.New("mock not configured")
+}
+
+func (m *mockConverseClient) ConverseStream(ctx context.Context, input *bedrockruntime.ConverseStreamInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.ConverseStreamOutput, error) {
+	m.callCount++
+	return nil, errors.New("mock not configured")
+}
+
+// mockConverseEventReader feeds canned events to a ConverseStreamEventStream
+type mockConverseEventReader struct {
+	events chan types.ConverseStreamOutput
+	err    error
+}
+
+func newMockConverseEventReader(events ...types.ConverseStreamOutput) *mockConverseEventReader {
+	r := &mockConverseEventReader{events: make(chan types.ConverseStreamOutput, len(events))}
+	for _, e := range events {
+		r.events <- e
+	}
+	close(r.events)
+	return r
+}
+
+func (r *mockConverseEventReader) Events() <-chan types.ConverseStreamOutput {
+	return r.events
+}
+
+func (r *mockConverseEventReader) Close() error { return nil }
+
+func (r *mockConverseEventReader) Err() error { return r.err }
+
+func textOf(t *testing.T, message types.Message) []string {
+	t.Helper()
+	var texts []string
+	for _, block := range message.Content {
+		text, ok := block.(*types.ContentBlockMemberText)
+		if !ok {
+			t.Fatalf("Expected text block, got %T", block)
+		}
+		texts = append(texts, text.Value)
+	}
+	return texts
+}
+
+func TestNewModelAdapter_Validation(t *testing.T) {
+	_, err := NewModelAdapter(context.Background(), ModelConfig{}, DefaultConfig())
+	if err == nil {
+		t.Error("Expected error for missing model ID")
+	}
+}
+
+func TestModelAdapter_BuildMessages(t *testing.T) {
+	adapter := newModelAdapter(&mockConverseClient{}, ModelConfig{ModelID: "model", MaxHistoryMessages: 4}, DefaultConfig())
+
+	tests := []struct {
+		name         string
+		history      []entities.Message
+		continuation string
+		wantRoles    []types.ConversationRole
+		wantTexts    [][]string
+	}{
+		{
+			name:      "no history",
+			wantRoles: []types.ConversationRole{types.ConversationRoleUser},
+			wantTexts: [][]string{{"What about sick leave?"}},
+		},
+		{
+			name: "alternating history",
+			history: []entities.Message{
+				{Role: entities.RoleUser, Content: "How much leave do I get?", Status: entities.StatusSent},
+				{Role: entities.RoleAgent, Content: "20 days.", Status: entities.StatusSent},
+			},
+			wantRoles: []types.ConversationRole{types.ConversationRoleUser, types.ConversationRoleAssistant, types.ConversationRoleUser},
+			wantTexts: [][]string{{"How much leave do I get?"}, {"20 days."}, {"What about sick leave?"}},
+		},
+		{
+			name: "failed response is skipped and user turns merged",
+			history: []entities.Message{
+				{Role: entities.RoleUser, Content: "How much leave do I get?", Status: entities.StatusSent},
+				{Role: entities.RoleAgent, Content: "20 da", Status: entities.StatusError},
+			},
+			wantRoles: []types.ConversationRole{types.ConversationRoleUser},
+			wantTexts: [][]string{{"How much leave do I get?", "What about sick leave?"}},
+		},
+		{
+			name: "question blocked by a guardrail is skipped",
+			history: []entities.Message{
+				{Role: entities.RoleUser, Content: "How much leave do I get?", Status: entities.StatusSent},
+				{Role: entities.RoleAgent, Content: "20 days.", Status: entities.StatusSent},
+				{Role: entities.RoleUser, Content: "What does my manager earn?", Status: entities.StatusSent},
+				{Role: entities.RoleAgent, Content: "Sorry, I can't answer that.", Status: entities.StatusBlocked,
+					Guardrail: &entities.GuardrailIntervention{Stage: entities.GuardrailStageInput}},
+			},
+			wantRoles: []types.ConversationRole{types.ConversationRoleUser, types.ConversationRoleAssistant, types.ConversationRoleUser},
+			wantTexts: [][]string{{"How much leave do I get?"}, {"20 days."}, {"What about sick leave?"}},
+		},
+		{
+			name: "history trimmed to the most recent messages",
+			history: []entities.Message{
+				{Role: entities.RoleUser, Content: "one", Status: entities.StatusSent},
+				{Role: entities.RoleAgent, Content: "two", Status: entities.StatusSent},
+				{Role: entities.RoleUser, Content: "three", Status: entities.StatusSent},
+				{Role: entities.RoleAgent, Content: "four", Status: entities.StatusSent},
+				{Role: entities.RoleUser, Content: "five", Status: entities.StatusSent},
+				{Role: entities.RoleAgent, Content: "six", Status: entities.StatusSent},
+			},
+			// The four most recent messages are kept
+			wantRoles: []types.ConversationRole{types.ConversationRoleUser, types.ConversationRoleAssistant, types.ConversationRoleUser, types.ConversationRoleAssistant, types.ConversationRoleUser},
+			wantTexts: [][]string{{"three"}, {"four"}, {"five"}, {"six"}, {"What about sick leave?"}},
+		},
+		{
+			name: "leading assistant message is dropped",
+			history: []entities.Message{
+				{Role: entities.RoleAgent, Content: "Welcome!", Status: entities.StatusSent},
+			},
+			wantRoles: []types.ConversationRole{types.ConversationRoleUser},
+			wantTexts: [][]string{{"What about sick leave?"}},
+		},
+		{
+			name:         "continuation ends the conversation without trailing space",
+			continuation: "Sick leave is 10 ",
+			wantRoles:    []types.ConversationRole{types.ConversationRoleUser, types.ConversationRoleAssistant},
+			wantTexts:    [][]string{{"What about sick leave?"}, {"Sick leave is 10"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages := adapter.buildMessages(services.AgentInput{
+				SessionID:    "session-123",
+				Message:      "What about sick leave?",
+				History:      tt.history,
+				Continuation: tt.continuation,
+			})
+
+			if len(messages) != len(tt.wantRoles) {
+				t.Fatalf("Expected %d messages, got %d", len(tt.wantRoles), len(messages))
+			}
+			for i, message := range messages {
+				if message.Role != tt.wantRoles[i] {
+					t.Errorf("Message %d: expected role %s, got %s", i, tt.wantRoles[i], message.Role)
+				}
+				texts := textOf(t, message)
+				if len(texts) != len(tt.wantTexts[i]) {
+					t.Fatalf("Message %d: expected %v, got %v", i, tt.wantTexts[i], texts)
+				}
+				for j := range texts {
+					if texts[j] != tt.wantTexts[i][j] {
+						t.Errorf("Message %d: expected %v, got %v", i, tt.wantTexts[i], texts)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestModelAdapter_InvokeAgent(t *testing.T) {
+	var captured *bedrockruntime.ConverseInput
+	client := &mockConverseClient{
+		converseFunc: func(ctx context.Context, input *bedrockruntime.ConverseInput) (*bedrockruntime.ConverseOutput, error) {
+			captured = input
+			return &bedrockruntime.ConverseOutput{
+				Output: &types.ConverseOutputMemberMessage{
+					Value: types.Message{
+						Role:    types.ConversationRoleAssistant,
+						Content: []types.ContentBlock{&types.ContentBlockMemberText{Value: "Sick leave is 10 days."}},
+					},
+				},
+				StopReason: types.StopReasonEndTurn,
+				Usage:      &types.TokenUsage{InputTokens: aws.Int32(42), OutputTokens: aws.Int32(7)},
+			}, nil
+		},
+	}
+	model := ModelConfig{
+		ModelID:            "anthropic.claude-3-haiku-20240307-v1:0",
+		SystemPrompt:       "You are an HR assistant.",
+		Temperature:        0.2,
+		MaxTokens:          512,
+		MaxHistoryMessages: 10,
+	}
+	cfg := testKnowledgeBaseConfig()
+	cfg.Guardrail = GuardrailConfig{ID: "gr-abc123", Version: "2"}
+	adapter := newModelAdapter(client, model, cfg)
+
+	response, err := adapter.InvokeAgent(context.Background(), services.AgentInput{
+		SessionID: "session-123",
+		Message:   "What about sick leave?",
+		History: []entities.Message{
+			{Role: entities.RoleUser, Content: "How much leave do I get?", Status: entities.StatusSent},
+			{Role: entities.RoleAgent, Content: "20 days.", Status: entities.StatusSent},
+		},
+	})
+	if err != nil {
+		t.Fatalf("InvokeAgent() error = %v", err)
+	}
+
+	if response.Content != "Sick leave is 10 days." {
+		t.Errorf("Unexpected content: %q", response.Content)
+	}
+	if response.Metadata["stop_reason"] != "end_turn" || response.Metadata["output_tokens"] != int32(7) {
+		t.Errorf("Unexpected metadata: %v", response.Metadata)
+	}
+
+	if aws.ToString(captured.ModelId) != model.ModelID {
+		t.Errorf("Expected model %s, got %s", model.ModelID, aws.ToString(captured.ModelId))
+	}
+	if len(captured.Messages) != 3 {
+		t.Errorf("Expected 3 messages, got %d", len(captured.Messages))
+	}
+	if len(captured.System) != 1 || captured.System[0].(*types.SystemContentBlockMemberText).Value != model.SystemPrompt {
+		t.Errorf("Unexpected system prompt: %+v", captured.System)
+	}
+	if aws.ToFloat32(captured.InferenceConfig.Temperature) != 0.2 || aws.ToInt32(captured.InferenceConfig.MaxTokens) != 512 {
+		t.Errorf("Unexpected inference config: %+v", captured.InferenceConfig)
+	}
+	if guardrail := captured.GuardrailConfig; guardrail == nil || aws.ToString(guardrail.GuardrailIdentifier) != "gr-abc123" || aws.ToString(guardrail.GuardrailVersion) != "2" {
+		t.Errorf("Unexpected guardrail config: %+v", captured.GuardrailConfig)
+	}
+}
+
+func TestModelAdapter_InvokeAgent_Errors(t *testing.T) {
+	t.Run("invalid input", func(t *testing.T) {
+		client := &mockConverseClient{}
+		adapter := newModelAdapter(client, DefaultModelConfig(), testKnowledgeBaseConfig())
+
+		_, err := adapter.InvokeAgent(context.Background(), services.AgentInput{SessionID: "session-123"})
+
+		var domainErr *services.DomainError
+		if !errors.As(err, &domainErr) || domainErr.Code != services.ErrCodeInvalidInput {
+			t.Errorf("Expected %s error, got %v", services.ErrCodeInvalidInput, err)
+		}
+		if client.callCount != 0 {
+			t.Errorf("Expected no API calls, got %d", client.callCount)
+		}
+	})
+
+	t.Run("throttling is retried", func(t *testing.T) {
+		client := &mockConverseClient{
+			converseFunc: func(ctx context.Context, input *bedrockruntime.ConverseInput) (*bedrockruntime.ConverseOutput, error) {
+				return nil, &smithy.GenericAPIError{Code: "ThrottlingException", Message: "slow down"}
+			},
+		}
+		adapter := newModelAdapter(client, DefaultModelConfig(), testKnowledgeBaseConfig())
+
+		_, err := adapter.InvokeAgent(context.Background(), services.AgentInput{SessionID: "session-123", Message: "Hello"})
+
+		var domainErr *services.DomainError
+		if !errors.As(err, &domainErr) || domainErr.Code != services.ErrCodeRateLimit {
+			t.Errorf("Expected %s error, got %v", services.ErrCodeRateLimit, err)
+		}
+		if client.callCount != 3 {
+			t.Errorf("Expected 3 attempts, got %d", client.callCount)
+		}
+	})
+
+	t.Run("guardrail intervention", func(t *testing.T) {
+		client := &mockConverseClient{
+			converseFunc: func(ctx context.Context, input *bedrockruntime.ConverseInput) (*bedrockruntime.ConverseOutput, error) {
+				return &bedrockruntime.ConverseOutput{
+					Output: &types.ConverseOutputMemberMessage{Value: types.Message{
+						Role:    types.ConversationRoleAssistant,
+						Content: []types.ContentBlock{&types.ContentBlockMemberText{Value: "Sorry, I can't help with that."}},
+					}},
+					StopReason: types.StopReasonGuardrailIntervened,
+					Trace: &types.ConverseTrace{Guardrail: &types.GuardrailTraceAssessment{
+						InputAssessment: map[string]types.GuardrailAssessment{"gr-1": {
+							TopicPolicy: &types.GuardrailTopicPolicyAssessment{Topics: []types.GuardrailTopic{
+								{Name: aws.String("Investment advice"), Action: types.GuardrailTopicPolicyActionBlocked},
+							}},
+						}},
+					}},
+				}, nil
+			},
+		}
+		adapter := newModelAdapter(client, DefaultModelConfig(), testKnowledgeBaseConfig())
+
+		_, err := adapter.InvokeAgent(context.Background(), services.AgentInput{SessionID: "session-123", Message: "Which stocks should I buy?"})
+
+		var domainErr *services.DomainError
+		if !errors.As(err, &domainErr) || domainErr.Code != services.ErrCodeGuardrailBlocked || domainErr.Retryable {
+			t.Fatalf("Expected a non-retryable %s error, got %v", services.ErrCodeGuardrailBlocked, err)
+		}
+		want := &entities.GuardrailIntervention{Stage: entities.GuardrailStageInput, Categories: []string{"topic:Investment advice"}}
+		if !reflect.DeepEqual(domainErr.Guardrail, want) {
+			t.Errorf("Expected intervention %+v, got %+v", want, domainErr.Guardrail)
+		}
+	})
+}
+
+func TestModelStreamReader(t *testing.T) {
+	reader := newMockConverseEventReader(
+		&types.ConverseStreamOutputMemberMessageStart{Value: types.MessageStartEvent{Role: types.ConversationRoleAssistant}},
+		&types.ConverseStreamOutputMemberContentBlockDelta{
+			Value: types.ContentBlockDeltaEvent{Delta: &types.ContentBlockDeltaMemberText{Value: "Sick leave "}},
+		},
+		&types.ConverseStreamOutputMemberContentBlockDelta{
+			Value: types.ContentBlockDeltaEvent{Delta: &types.ContentBlockDeltaMemberText{Value: "is 10 days."}},
+		},
+		&types.ConverseStreamOutputMemberContentBlockStop{},
+		&types.ConverseStreamOutputMemberMessageStop{Value: types.MessageStopEvent{StopReason: types.StopReasonEndTurn}},
+		&types.ConverseStreamOutputMemberMetadata{
+			Value: types.ConverseStreamMetadataEvent{Usage: &types.TokenUsage{InputTokens: aws.Int32(42), OutputTokens: aws.Int32(7)}},
+		},
+	)
+	stream := bedrockruntime.NewConverseStreamEventStream(func(es *bedrockruntime.ConverseStreamEventStream) {
+		es.Reader = reader
+	})
+
+	processor := NewStreamProcessor(DefaultStreamProcessorConfig())
+	writer := &mockChunkWriter{}
+
+	err := processor.ProcessStream(context.Background(), newModelStreamReader(context.Background(), stream, ""), writer)
+	if err != nil {
+		t.Fatalf("ProcessStream() error = %v", err)
+	}
+
+	if len(writer.contentChunks) != 2 || writer.contentChunks[0]+writer.contentChunks[1] != "Sick leave is 10 days." {
+		t.Errorf("Unexpected content chunks: %v", writer.contentChunks)
+	}
+	if len(writer.citationChunks) != 0 {
+		t.Errorf("Expected no citations, got %+v", writer.citationChunks)
+	}
+	if !writer.doneWritten {
+		t.Error("Expected done chunk to be written")
+	}
+}
+
+func TestModelStreamReader_Guardrail(t *testing.T) {
+	blocked := types.GuardrailAssessment{
+		ContentPolicy: &types.GuardrailContentPolicyAssessment{Filters: []types.GuardrailContentFilter{
+			{Type: types.GuardrailContentFilterTypeMisconduct, Action: types.GuardrailContentPolicyActionBlocked},
+		}},
+	}
+	tests := []struct {
+		name   string
+		events []types.ConverseStreamOutput
+		want   entities.GuardrailIntervention
+	}{
+		{
+			name: "with trace",
+			events: []types.ConverseStreamOutput{
+				&types.ConverseStreamOutputMemberContentBlockDelta{
+					Value: types.ContentBlockDeltaEvent{Delta: &types.ContentBlockDeltaMemberText{Value: "I can't help with that."}},
+				},
+				&types.ConverseStreamOutputMemberMessageStop{Value: types.MessageStopEvent{StopReason: types.StopReasonGuardrailIntervened}},
+				&types.ConverseStreamOutputMemberMetadata{Value: types.ConverseStreamMetadataEvent{
+					Trace: &types.ConverseStreamTrace{Guardrail: &types.GuardrailTraceAssessment{
+						InputAssessment: map[string]types.GuardrailAssessment{"gr-1": blocked},
+					}},
+				}},
+			},
+			want: entities.GuardrailIntervention{Stage: entities.GuardrailStageInput, Categories: []string{"content:MISCONDUCT"}},
+		},
+		{
+			name: "without metadata",
+			events: []types.ConverseStreamOutput{
+				&types.ConverseStreamOutputMemberContentBlockDelta{
+					Value: types.ContentBlockDeltaEvent{Delta: &types.ContentBlockDeltaMemberText{Value: "I can't help with that."}},
+				},
+				&types.ConverseStreamOutputMemberMessageStop{Value: types.MessageStopEvent{StopReason: types.StopReasonGuardrailIntervened}},
+			},
+			want: entities.GuardrailIntervention{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := bedrockruntime.NewConverseStreamEventStream(func(es *bedrockruntime.ConverseStreamEventStream) {
+				es.Reader = newMockConverseEventReader(tt.events...)
+			})
+
+			writer := &mockChunkWriter{}
+			err := NewStreamProcessor(DefaultStreamProcessorConfig()).ProcessStream(context.Background(), newModelStreamReader(context.Background(), stream, ""), writer)
+
+			var domainErr *services.DomainError
+			if !errors.As(err, &domainErr) || domainErr.Code != services.ErrCodeGuardrailBlocked || domainErr.Retryable {
+				t.Fatalf("Expected a non-retryable %s error, got %v", services.ErrCodeGuardrailBlocked, err)
+			}
+			if len(writer.guardrails) != 1 || !reflect.DeepEqual(writer.guardrails[0], tt.want) {
+				t.Errorf("Expected guardrail chunk %+v, got %+v", tt.want, writer.guardrails)
+			}
+			// The guardrail's own message is kept, and the answer is not done
+			if strings.Join(writer.contentChunks, "") != "I can't help with that." || writer.doneWritten {
+				t.Errorf("Unexpected chunks: %v, done=%v", writer.contentChunks, writer.doneWritten)
+			}
+		})
+	}
+}
+
+func TestModelStreamReader_StreamError(t *testing.T) {
+	reader := newMockConverseEventReader()
+	reader.err = errors.New("connection reset")
+	stream := bedrockruntime.NewConverseStreamEventStream(func(es *bedrockruntime.ConverseStreamEventStream) {
+		es.Reader = reader
+	})
+
+	sr := newModelStreamReader(context.Background(), stream, "")
+	_, err := sr.Next(context.Background())
+
+	var domainErr *services.DomainError
+	if !errors.As(err, &domainErr) || domainErr.Code != services.ErrCodeMalformedStream {
+		t.Errorf("Expected %s error, got %v", services.ErrCodeMalformedStream, err)
+	}
+	// The error ends the stream
+	if event, err := sr.Next(context.Background()); event.Type != services.StreamEventDone || err != nil {
+		t.Errorf("Expected the stream to be done, got %s event, err=%v", event.Type, err)
+	}
+}
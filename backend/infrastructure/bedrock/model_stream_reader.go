@@ -0,0 +1,160 @@
+package bedrock
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+
+	"github.com/bedrock-chat-poc/backend/domain/services"
+	"github.com/bedrock-chat-poc/backend/infrastructure/logging"
+)
+
+// modelStreamReader implements the StreamReader interface for ConverseStream
+// event streams. Models answer without retrieval, so it never yields citations.
+type modelStreamReader struct {
+	ctx       context.Context
+	stream    *bedrockruntime.ConverseStreamEventStream
+	done      bool
+	requestID string
+	eventChan <-chan types.ConverseStreamOutput
+	// toolCall is the tool use block being streamed, if any, and toolInput
+	// its input so far
+	toolCall  *services.ToolCall
+	toolInput strings.Builder
+	// blocked is set when a guardrail stopped the message; the intervention
+	// is reported with the trace in the metadata event that follows
+	blocked bool
+}
+
+// newModelStreamReader creates a new model stream reader
+func newModelStreamReader(ctx context.Context, stream *bedrockruntime.ConverseStreamEventStream, requestID string) services.StreamReader {
+	return &modelStreamReader{
+		ctx:       ctx,
+		stream:    stream,
+		requestID: requestID,
+		eventChan: stream.Events(),
+	}
+}
+
+// Next returns the next event of the stream. It waits for the next Bedrock
+// event until ctx is done.
+func (sr *modelStreamReader) Next(ctx context.Context) (services.StreamEvent, error) {
+	for {
+		if sr.done {
+			return services.StreamEvent{Type: services.StreamEventDone}, nil
+		}
+
+		// Wait for the next event, the end of the stream or cancellation
+		var event types.ConverseStreamOutput
+		var ok bool
+		select {
+		case <-sr.ctx.Done():
+			sr.done = true
+			return services.StreamEvent{}, sr.ctx.Err()
+		case <-ctx.Done():
+			return services.StreamEvent{}, ctx.Err()
+		case event, ok = <-sr.eventChan:
+		}
+
+		if !ok {
+			sr.done = true
+			if err := sr.stream.Err(); err != nil {
+				slog.ErrorContext(sr.ctx, "[Bedrock] Model stream error", logging.KeyRequestID, sr.requestID, "error", err)
+				return services.StreamEvent{}, transformStreamError(err, sr.requestID)
+			}
+			if sr.blocked {
+				// The stream ended without a trace
+				sr.blocked = false
+				return sr.guardrailEvent(nil), nil
+			}
+			slog.InfoContext(sr.ctx, "[Bedrock] Model stream completed", logging.KeyRequestID, sr.requestID)
+			return services.StreamEvent{Type: services.StreamEventDone}, nil
+		}
+
+		switch e := event.(type) {
+		case *types.ConverseStreamOutputMemberContentBlockDelta:
+			switch delta := e.Value.Delta.(type) {
+			case *types.ContentBlockDeltaMemberText:
+				if delta.Value != "" {
+					return services.StreamEvent{Type: services.StreamEventContent, Content: delta.Value}, nil
+				}
+			case *types.ContentBlockDeltaMemberToolUse:
+				sr.toolInput.WriteString(aws.ToString(delta.Value.Input))
+			}
+
+		case *types.ConverseStreamOutputMemberContentBlockStart:
+			// A tool use block streams its input in deltas until it stops
+			if start, ok := e.Value.Start.(*types.ContentBlockStartMemberToolUse); ok {
+				sr.toolCall = &services.ToolCall{ID: aws.ToString(start.Value.ToolUseId), Name: aws.ToString(start.Value.Name)}
+				sr.toolInput.Reset()
+			}
+
+		case *types.ConverseStreamOutputMemberContentBlockStop:
+			if sr.toolCall != nil {
+				call := sr.toolCall
+				call.Input = sr.toolInput.String()
+				sr.toolCall = nil
+				return services.StreamEvent{Type: services.StreamEventToolCall, ToolCall: call}, nil
+			}
+
+		case *types.ConverseStreamOutputMemberMessageStop:
+			slog.DebugContext(sr.ctx, "[Bedrock] Model message stopped", "stop_reason", e.Value.StopReason, logging.KeyRequestID, sr.requestID)
+			sr.blocked = e.Value.StopReason == types.StopReasonGuardrailIntervened
+			return services.StreamEvent{
+				Type:     services.StreamEventMetadata,
+				Metadata: map[string]interface{}{"stop_reason": string(e.Value.StopReason)},
+			}, nil
+
+		case *types.ConverseStreamOutputMemberMetadata:
+			// A blocked message reports the guardrail's trace instead of usage
+			if sr.blocked {
+				sr.blocked = false
+				var trace *types.GuardrailTraceAssessment
+				if e.Value.Trace != nil {
+					trace = e.Value.Trace.Guardrail
+				}
+				return sr.guardrailEvent(trace), nil
+			}
+			if e.Value.Usage != nil {
+				inputTokens, outputTokens := aws.ToInt32(e.Value.Usage.InputTokens), aws.ToInt32(e.Value.Usage.OutputTokens)
+				slog.InfoContext(sr.ctx, "[Bedrock] Model usage",
+					"input_tokens", inputTokens, "output_tokens", outputTokens, logging.KeyRequestID, sr.requestID)
+				return services.StreamEvent{
+					Type:     services.StreamEventMetadata,
+					Metadata: map[string]interface{}{"input_tokens": inputTokens, "output_tokens": outputTokens},
+				}, nil
+			}
+
+		case *types.ConverseStreamOutputMemberMessageStart:
+			// Structural events carry no content
+
+		default:
+			slog.DebugContext(sr.ctx, "[Bedrock] Unknown event type", "type", fmt.Sprintf("%T", e), logging.KeyRequestID, sr.requestID)
+		}
+	}
+}
+
+// guardrailEvent reports the guardrail intervention described by trace
+func (sr *modelStreamReader) guardrailEvent(trace *types.GuardrailTraceAssessment) services.StreamEvent {
+	intervention := modelGuardrailIntervention(trace)
+	slog.WarnContext(sr.ctx, "[Bedrock] Guardrail intervened",
+		"stage", intervention.Stage, "categories", intervention.Categories, logging.KeyRequestID, sr.requestID)
+	return guardrailEvent(intervention)
+}
+
+// RequestID returns the AWS request ID of the ConverseStream call
+func (sr *modelStreamReader) RequestID() string {
+	return sr.requestID
+}
+
+// Close closes the stream reader
+func (sr *modelStreamReader) Close() error {
+	sr.done = true
+	slog.DebugContext(sr.ctx, "[Bedrock] Model stream reader closed", logging.KeyRequestID, sr.requestID)
+	return sr.stream.Close()
+}
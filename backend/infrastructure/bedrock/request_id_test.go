@@ -0,0 +1,138 @@
+package bedrock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+
+	"github.com/bedrock-chat-poc/backend/domain/services"
+)
+
+// awsResponseError builds an API error the way the SDK returns it, with the
+// x-amzn-RequestId of the failed call
+func awsResponseError(code, requestID string) error {
+	return &awshttp.ResponseError{
+		ResponseError: &smithyhttp.ResponseError{
+			Response: &smithyhttp.Response{Response: &http.Response{StatusCode: http.StatusBadRequest}},
+			Err:      &smithy.GenericAPIError{Code: code, Message: "failed"},
+		},
+		RequestID: requestID,
+	}
+}
+
+// resultMetadata builds response metadata carrying a request ID
+func resultMetadata(requestID string) middleware.Metadata {
+	var metadata middleware.Metadata
+	awsmiddleware.SetRequestIDMetadata(&metadata, requestID)
+	return metadata
+}
+
+func TestGetRequestID(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "nil error", err: nil, want: ""},
+		{name: "response error", err: awsResponseError("ThrottlingException", "req-123"), want: "req-123"},
+		{name: "wrapped response error", err: fmt.Errorf("operation error: %w", awsResponseError("ValidationException", "req-456")), want: "req-456"},
+		{name: "API error without response", err: &smithy.GenericAPIError{Code: "ThrottlingException"}, want: ""},
+		{name: "network error", err: errors.New("connection refused"), want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getRequestID(tt.err); got != tt.want {
+				t.Errorf("getRequestID() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResponseRequestID(t *testing.T) {
+	if got := responseRequestID(resultMetadata("req-789")); got != "req-789" {
+		t.Errorf("responseRequestID() = %q, want req-789", got)
+	}
+	if got := responseRequestID(middleware.Metadata{}); got != "" {
+		t.Errorf("responseRequestID() without metadata = %q, want empty", got)
+	}
+}
+
+func TestTransformError_CarriesRequestID(t *testing.T) {
+	err := transformError(awsResponseError("ThrottlingException", "req-123"), "req-123")
+
+	var domainErr *services.DomainError
+	if !errors.As(err, &domainErr) {
+		t.Fatalf("Expected DomainError, got %v", err)
+	}
+	if domainErr.Code != services.ErrCodeRateLimit {
+		t.Errorf("Expected %s, got %s", services.ErrCodeRateLimit, domainErr.Code)
+	}
+	if domainErr.RequestID != "req-123" {
+		t.Errorf("Expected request ID req-123, got %q", domainErr.RequestID)
+	}
+}
+
+func TestKnowledgeBaseAdapter_RequestID(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		client := &mockKnowledgeBaseClient{
+			ragFunc: func(ctx context.Context, input *bedrockagentruntime.RetrieveAndGenerateInput) (*bedrockagentruntime.RetrieveAndGenerateOutput, error) {
+				return &bedrockagentruntime.RetrieveAndGenerateOutput{ResultMetadata: resultMetadata("req-ok")}, nil
+			},
+		}
+		adapter := newKnowledgeBaseAdapter(client, "KB123", "arn:model", testKnowledgeBaseConfig())
+
+		response, err := adapter.InvokeAgent(context.Background(), services.AgentInput{SessionID: "s", Message: "hi"})
+		if err != nil {
+			t.Fatalf("InvokeAgent() error = %v", err)
+		}
+		if response.RequestID != "req-ok" {
+			t.Errorf("Expected request ID req-ok, got %q", response.RequestID)
+		}
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		client := &mockKnowledgeBaseClient{
+			ragFunc: func(ctx context.Context, input *bedrockagentruntime.RetrieveAndGenerateInput) (*bedrockagentruntime.RetrieveAndGenerateOutput, error) {
+				return nil, awsResponseError("AccessDeniedException", "req-denied")
+			},
+		}
+		adapter := newKnowledgeBaseAdapter(client, "KB123", "arn:model", testKnowledgeBaseConfig())
+
+		_, err := adapter.InvokeAgent(context.Background(), services.AgentInput{SessionID: "s", Message: "hi"})
+
+		var domainErr *services.DomainError
+		if !errors.As(err, &domainErr) || domainErr.RequestID != "req-denied" {
+			t.Errorf("Expected error with request ID req-denied, got %v", err)
+		}
+	})
+}
+
+func TestKnowledgeBaseStreamReader_RequestID(t *testing.T) {
+	reader := newMockRAGEventReader()
+	reader.err = errors.New("connection reset")
+	stream := bedrockagentruntime.NewRetrieveAndGenerateStreamEventStream(func(es *bedrockagentruntime.RetrieveAndGenerateStreamEventStream) {
+		es.Reader = reader
+	})
+
+	sr := newKnowledgeBaseStreamReader(context.Background(), stream, "req-stream")
+	if sr.RequestID() != "req-stream" {
+		t.Errorf("Expected request ID req-stream, got %q", sr.RequestID())
+	}
+
+	// Errors mid-stream keep the request ID of the call that opened the stream
+	_, err := sr.Next(context.Background())
+	var domainErr *services.DomainError
+	if !errors.As(err, &domainErr) || domainErr.RequestID != "req-stream" {
+		t.Errorf("Expected stream error with request ID req-stream, got %v", err)
+	}
+}
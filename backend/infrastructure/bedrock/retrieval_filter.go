@@ -0,0 +1,80 @@
+package bedrock
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/document"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/types"
+
+	"github.com/bedrock-chat-poc/backend/domain/services"
+)
+
+// buildSessionState builds the session state for an agent invocation,
+// attaching a retrieval filter to every requested knowledge base
+func buildSessionState(input services.AgentInput) (*types.SessionState, error) {
+	if input.Filter == nil {
+		return nil, nil
+	}
+
+	filter, err := toBedrockFilter(input.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	configs := make([]types.KnowledgeBaseConfiguration, 0, len(input.KnowledgeBaseIDs))
+	for _, kbID := range input.KnowledgeBaseIDs {
+		configs = append(configs, types.KnowledgeBaseConfiguration{
+			KnowledgeBaseId: aws.String(kbID),
+			RetrievalConfiguration: &types.KnowledgeBaseRetrievalConfiguration{
+				VectorSearchConfiguration: &types.KnowledgeBaseVectorSearchConfiguration{
+					Filter: filter,
+				},
+			},
+		})
+	}
+
+	return &types.SessionState{
+		KnowledgeBaseConfigurations: configs,
+	}, nil
+}
+
+// toBedrockFilter translates a domain retrieval filter into the Bedrock
+// RetrievalFilter union
+func toBedrockFilter(f *services.RetrievalFilter) (types.RetrievalFilter, error) {
+	switch f.Operator {
+	case services.FilterEquals:
+		return &types.RetrievalFilterMemberEquals{Value: filterAttribute(f.Key, f.Value)}, nil
+
+	case services.FilterStartsWith:
+		return &types.RetrievalFilterMemberStartsWith{Value: filterAttribute(f.Key, f.Value)}, nil
+
+	case services.FilterIn:
+		return &types.RetrievalFilterMemberIn{Value: filterAttribute(f.Key, f.Values)}, nil
+
+	case services.FilterAndAll, services.FilterOrAll:
+		filters := make([]types.RetrievalFilter, 0, len(f.Filters))
+		for i := range f.Filters {
+			nested, err := toBedrockFilter(&f.Filters[i])
+			if err != nil {
+				return nil, err
+			}
+			filters = append(filters, nested)
+		}
+		if f.Operator == services.FilterAndAll {
+			return &types.RetrievalFilterMemberAndAll{Value: filters}, nil
+		}
+		return &types.RetrievalFilterMemberOrAll{Value: filters}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported filter operator %q", f.Operator)
+	}
+}
+
+// filterAttribute builds a Bedrock filter attribute for a metadata key
+func filterAttribute(key string, value interface{}) types.FilterAttribute {
+	return types.FilterAttribute{
+		Key:   aws.String(key),
+		Value: document.NewLazyDocument(value),
+	}
+}
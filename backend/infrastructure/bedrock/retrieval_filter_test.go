@@ -0,0 +1,148 @@
+package bedrock
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/types"
+
+	"github.com/bedrock-chat-poc/backend/domain/services"
+)
+
+func TestToBedrockFilter(t *testing.T) {
+	filter := &services.RetrievalFilter{
+		Operator: services.FilterAndAll,
+		Filters: []services.RetrievalFilter{
+			{Operator: services.FilterEquals, Key: "department", Value: "hr"},
+			{
+				Operator: services.FilterOrAll,
+				Filters: []services.RetrievalFilter{
+					{Operator: services.FilterIn, Key: "document_type", Values: []interface{}{"policy", "faq"}},
+					{Operator: services.FilterStartsWith, Key: "path", Value: "hr/"},
+				},
+			},
+		},
+	}
+
+	result, err := toBedrockFilter(filter)
+	if err != nil {
+		t.Fatalf("toBedrockFilter() error = %v", err)
+	}
+
+	andAll, ok := result.(*types.RetrievalFilterMemberAndAll)
+	if !ok {
+		t.Fatalf("Expected AndAll filter, got %T", result)
+	}
+	if len(andAll.Value) != 2 {
+		t.Fatalf("Expected 2 nested filters, got %d", len(andAll.Value))
+	}
+
+	equals, ok := andAll.Value[0].(*types.RetrievalFilterMemberEquals)
+	if !ok {
+		t.Fatalf("Expected Equals filter, got %T", andAll.Value[0])
+	}
+	if aws.ToString(equals.Value.Key) != "department" {
+		t.Errorf("Expected key 'department', got %q", aws.ToString(equals.Value.Key))
+	}
+	if value, err := equals.Value.Value.MarshalSmithyDocument(); err != nil || string(value) != `"hr"` {
+		t.Errorf("Expected value \"hr\", got %s (err: %v)", value, err)
+	}
+
+	orAll, ok := andAll.Value[1].(*types.RetrievalFilterMemberOrAll)
+	if !ok {
+		t.Fatalf("Expected OrAll filter, got %T", andAll.Value[1])
+	}
+	in, ok := orAll.Value[0].(*types.RetrievalFilterMemberIn)
+	if !ok {
+		t.Fatalf("Expected In filter, got %T", orAll.Value[0])
+	}
+	if values, err := in.Value.Value.MarshalSmithyDocument(); err != nil || string(values) != `["policy","faq"]` {
+		t.Errorf("Expected [\"policy\",\"faq\"], got %s (err: %v)", values, err)
+	}
+	if _, ok := orAll.Value[1].(*types.RetrievalFilterMemberStartsWith); !ok {
+		t.Errorf("Expected StartsWith filter, got %T", orAll.Value[1])
+	}
+}
+
+func TestBuildSessionState(t *testing.T) {
+	t.Run("no filter", func(t *testing.T) {
+		state, err := buildSessionState(services.AgentInput{KnowledgeBaseIDs: []string{"KB123"}})
+		if err != nil || state != nil {
+			t.Errorf("Expected nil session state, got %+v (err: %v)", state, err)
+		}
+	})
+
+	t.Run("filter applied to each knowledge base", func(t *testing.T) {
+		input := services.AgentInput{
+			KnowledgeBaseIDs: []string{"KB123", "KB456"},
+			Filter:           &services.RetrievalFilter{Operator: services.FilterEquals, Key: "department", Value: "hr"},
+		}
+
+		state, err := buildSessionState(input)
+		if err != nil {
+			t.Fatalf("buildSessionState() error = %v", err)
+		}
+		if len(state.KnowledgeBaseConfigurations) != 2 {
+			t.Fatalf("Expected 2 knowledge base configurations, got %d", len(state.KnowledgeBaseConfigurations))
+		}
+		for i, kbID := range input.KnowledgeBaseIDs {
+			cfg := state.KnowledgeBaseConfigurations[i]
+			if aws.ToString(cfg.KnowledgeBaseId) != kbID {
+				t.Errorf("Expected knowledge base %s, got %s", kbID, aws.ToString(cfg.KnowledgeBaseId))
+			}
+			if cfg.RetrievalConfiguration.VectorSearchConfiguration.Filter == nil {
+				t.Error("Expected filter to be set")
+			}
+		}
+	})
+}
+
+func TestValidateInput_Filter(t *testing.T) {
+	adapter := &Adapter{config: DefaultConfig()}
+
+	validFilter := &services.RetrievalFilter{Operator: services.FilterEquals, Key: "department", Value: "hr"}
+
+	tests := []struct {
+		name    string
+		input   services.AgentInput
+		wantErr bool
+	}{
+		{
+			name: "valid filter with knowledge base",
+			input: services.AgentInput{
+				SessionID:        "session-123",
+				Message:          "Hello",
+				KnowledgeBaseIDs: []string{"KB123"},
+				Filter:           validFilter,
+			},
+		},
+		{
+			name: "filter without knowledge base",
+			input: services.AgentInput{
+				SessionID: "session-123",
+				Message:   "Hello",
+				Filter:    validFilter,
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid filter",
+			input: services.AgentInput{
+				SessionID:        "session-123",
+				Message:          "Hello",
+				KnowledgeBaseIDs: []string{"KB123"},
+				Filter:           &services.RetrievalFilter{Operator: services.FilterAndAll},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := adapter.validateInput(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateInput() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
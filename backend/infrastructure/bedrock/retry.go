@@ -0,0 +1,386 @@
+package bedrock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/bedrock-chat-poc/backend/domain/services"
+	"github.com/bedrock-chat-poc/backend/infrastructure/logging"
+	"github.com/bedrock-chat-poc/backend/infrastructure/tracing"
+)
+
+// JitterMode selects how retry backoff is randomized. Randomizing keeps
+// clients throttled at the same moment from retrying at the same moment.
+type JitterMode string
+
+const (
+	// JitterFull waits a random time up to the exponential backoff
+	JitterFull JitterMode = "full"
+	// JitterDecorrelated waits a random time between the initial backoff and
+	// three times the previous wait
+	JitterDecorrelated JitterMode = "decorrelated"
+	// JitterNone waits exactly the exponential backoff
+	JitterNone JitterMode = "none"
+)
+
+// RetryRule says whether failures with an AWS error code are retried
+type RetryRule struct {
+	// Retry enables retries for the code
+	Retry bool
+	// MaxRetries caps retries for the code below the adapter's MaxRetries;
+	// zero leaves the adapter's limit
+	MaxRetries int
+}
+
+// DefaultRetryRules returns the AWS error codes retried by default. Internal
+// server errors get a single retry, since they rarely clear up quickly.
+func DefaultRetryRules() map[string]RetryRule {
+	return map[string]RetryRule{
+		"ThrottlingException":         {Retry: true},
+		"TooManyRequestsException":    {Retry: true},
+		"ServiceUnavailableException": {Retry: true},
+		"ModelNotReadyException":      {Retry: true},
+		"InternalServerException":     {Retry: true, MaxRetries: 1},
+	}
+}
+
+// Clock tells the time and waits out retry backoff. Tests inject a fake so
+// retries don't sleep.
+type Clock interface {
+	Now() time.Time
+	// Sleep waits for d, returning ctx's error if ctx is done first
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+// systemClock is the Clock used when none is configured
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// errStreamOpenTimeout fails a stream that did not open within the request
+// timeout
+var errStreamOpenTimeout = fmt.Errorf("stream did not open within the request timeout: %w", context.DeadlineExceeded)
+
+// retryPolicy decides whether and how long to wait before retrying a failed
+// call. It holds the state of a single call, so every call gets its own.
+type retryPolicy struct {
+	maxRetries     int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	jitter         JitterMode
+	budget         time.Duration
+	rules          map[string]RetryRule
+	clock          Clock
+	// random returns a number in [0, n); tests replace it to pin jitter
+	random func(n int64) int64
+
+	start time.Time
+	// previous is the last wait, which decorrelated jitter grows from
+	previous time.Duration
+}
+
+// newRetryPolicy creates the retry policy for one call
+func newRetryPolicy(cfg AdapterConfig) *retryPolicy {
+	policy := &retryPolicy{
+		maxRetries:     cfg.MaxRetries,
+		initialBackoff: cfg.InitialBackoff,
+		maxBackoff:     cfg.MaxBackoff,
+		jitter:         cfg.Jitter,
+		budget:         cfg.RetryBudget,
+		rules:          cfg.RetryRules,
+		clock:          cfg.Clock,
+		random:         rand.Int64N,
+	}
+	if policy.jitter == "" {
+		policy.jitter = JitterFull
+	}
+	if policy.rules == nil {
+		policy.rules = DefaultRetryRules()
+	}
+	if policy.clock == nil {
+		policy.clock = systemClock{}
+	}
+	policy.start = policy.clock.Now()
+	return policy
+}
+
+// next returns how long to wait before the given retry of a call that
+// failed with err, or false if the call should not be retried. A call is
+// retried while its error code's rule allows, and only if the wait ends
+// within the retry budget and before ctx's deadline.
+func (p *retryPolicy) next(ctx context.Context, retry int, err error) (time.Duration, bool) {
+	rule, ok := retryRule(p.rules, err)
+	if !ok || !rule.Retry {
+		return 0, false
+	}
+	limit := p.maxRetries
+	if rule.MaxRetries > 0 && rule.MaxRetries < limit {
+		limit = rule.MaxRetries
+	}
+	if retry > limit {
+		return 0, false
+	}
+
+	now := p.clock.Now()
+	delay := p.backoff(retry)
+	if after := retryAfter(err, now); after > delay {
+		delay = after
+	}
+
+	resumeAt := now.Add(delay)
+	if p.budget > 0 && resumeAt.Sub(p.start) > p.budget {
+		slog.WarnContext(ctx, "[Bedrock] Retry budget exhausted", "budget", p.budget, "backoff", delay)
+		return 0, false
+	}
+	if deadline, ok := ctx.Deadline(); ok && resumeAt.After(deadline) {
+		slog.WarnContext(ctx, "[Bedrock] Retry would outlast the request deadline", "backoff", delay)
+		return 0, false
+	}
+	return delay, true
+}
+
+// backoff returns the jittered wait before the given retry
+func (p *retryPolicy) backoff(retry int) time.Duration {
+	switch p.jitter {
+	case JitterNone:
+		return exponentialBackoff(p.initialBackoff, p.maxBackoff, retry)
+
+	case JitterDecorrelated:
+		upper := 3 * p.previous
+		if upper < p.initialBackoff {
+			upper = p.initialBackoff
+		}
+		if upper > p.maxBackoff {
+			upper = p.maxBackoff
+		}
+		wait := p.initialBackoff
+		if upper > p.initialBackoff {
+			wait += time.Duration(p.random(int64(upper-p.initialBackoff) + 1))
+		}
+		p.previous = wait
+		return wait
+
+	default:
+		ceiling := exponentialBackoff(p.initialBackoff, p.maxBackoff, retry)
+		if ceiling <= 0 {
+			return 0
+		}
+		return time.Duration(p.random(int64(ceiling) + 1))
+	}
+}
+
+// exponentialBackoff doubles initial for every retry after the first, up to
+// limit
+func exponentialBackoff(initial, limit time.Duration, retry int) time.Duration {
+	backoff := float64(initial) * math.Pow(2, float64(retry-1))
+	if backoff > float64(limit) {
+		backoff = float64(limit)
+	}
+	return time.Duration(backoff)
+}
+
+// retryRule returns the rule for err's AWS error code. Errors that are not
+// AWS API errors, including context errors, have no rule.
+func retryRule(rules map[string]RetryRule, err error) (RetryRule, bool) {
+	if err == nil || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return RetryRule{}, false
+	}
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return RetryRule{}, false
+	}
+	rule, ok := rules[apiErr.ErrorCode()]
+	return rule, ok
+}
+
+// isRetryable determines if an error is retryable under the default rules
+func isRetryable(err error) bool {
+	rule, ok := retryRule(DefaultRetryRules(), err)
+	return ok && rule.Retry
+}
+
+// retryAfter returns the wait a response asked for in its Retry-After
+// header, given in seconds or as an HTTP date, or zero if it asked for none
+func retryAfter(err error, now time.Time) time.Duration {
+	var respErr interface{ HTTPResponse() *smithyhttp.Response }
+	if !errors.As(err, &respErr) {
+		return 0
+	}
+	response := respErr.HTTPResponse()
+	if response == nil || response.Response == nil {
+		return 0
+	}
+
+	header := response.Header.Get("Retry-After")
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(header); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
+
+// streamOpen bounds opening a stream, including retries, by a timeout.
+// Unlike context.WithTimeout it leaves the stream itself unbounded. The
+// stream lives on the open's context, which is released when the reader
+// taking it over is closed, or by close if no reader did.
+type streamOpen struct {
+	cancel context.CancelCauseFunc
+	timer  *time.Timer
+
+	mu sync.Mutex
+	// done is set once the open finished or timed out, whichever came first
+	done     bool
+	timedOut bool
+	handed   bool
+}
+
+// withOpenTimeout returns the context to open a stream with and the open
+// bounding it. A timeout of zero leaves opening unbounded.
+func withOpenTimeout(ctx context.Context, timeout time.Duration) (context.Context, *streamOpen) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	open := &streamOpen{cancel: cancel}
+	if timeout > 0 {
+		open.timer = time.AfterFunc(timeout, open.expire)
+	}
+	return ctx, open
+}
+
+// expire cancels an open that has not finished
+func (o *streamOpen) expire() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.done {
+		return
+	}
+	o.done, o.timedOut = true, true
+	o.cancel(errStreamOpenTimeout)
+}
+
+// opened stops the timer once the call opening the stream has returned. It
+// fails if the timer fired first, since the stream's context is then
+// canceled.
+func (o *streamOpen) opened() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.timedOut {
+		return transformError(errStreamOpenTimeout, "")
+	}
+	o.done = true
+	if o.timer != nil {
+		o.timer.Stop()
+	}
+	return nil
+}
+
+// reader hands the stream's context over to reader, which releases it when
+// closed
+func (o *streamOpen) reader(reader services.StreamReader) services.StreamReader {
+	o.mu.Lock()
+	o.handed = true
+	o.mu.Unlock()
+	return &releasingStreamReader{StreamReader: reader, release: func() { o.cancel(nil) }}
+}
+
+// close stops the timer and, unless a reader took the stream's context
+// over, releases it
+func (o *streamOpen) close() {
+	o.mu.Lock()
+	o.done = true
+	if o.timer != nil {
+		o.timer.Stop()
+	}
+	handed := o.handed
+	o.mu.Unlock()
+	if !handed {
+		o.cancel(nil)
+	}
+}
+
+// releasingStreamReader releases its stream's context once closed
+type releasingStreamReader struct {
+	services.StreamReader
+	release func()
+}
+
+func (r *releasingStreamReader) Close() error {
+	err := r.StreamReader.Close()
+	r.release()
+	return err
+}
+
+// retryCall runs call under the adapter's retry policy and returns the final
+// error transformed into a domain error. call returns the AWS request ID of
+// a successful attempt; a failed attempt's ID is read from its error.
+func retryCall(ctx context.Context, cfg AdapterConfig, operation string, call func(ctx context.Context) (string, error), attrs ...attribute.KeyValue) error {
+	ctx, span := startCall(ctx, operation, attrs...)
+	defer span.End()
+
+	policy := newRetryPolicy(cfg)
+
+	for attempt := 0; ; attempt++ {
+		attemptCtx, attemptSpan := startAttempt(ctx, operation, attempt)
+		start := time.Now()
+		requestID, err := call(attemptCtx)
+		cfg.Metrics.ObserveBedrockAttempt(operation, time.Since(start), errorCode(err))
+		if err != nil {
+			requestID = getRequestID(err)
+		}
+		endAttempt(attemptSpan, err, requestID)
+		if err == nil {
+			return nil
+		}
+
+		// A call cut short by its context failed for the context's reason,
+		// such as a stream that did not open in time
+		if ctx.Err() != nil {
+			err = context.Cause(ctx)
+		}
+
+		backoff, retry := policy.next(ctx, attempt+1, err)
+		if !retry {
+			slog.ErrorContext(ctx, "[Bedrock] "+operation+" failed", logging.KeyRequestID, requestID, "error", err)
+			tracing.RecordError(span, err)
+			return transformError(err, requestID)
+		}
+
+		slog.WarnContext(ctx, "[Bedrock] Retry attempt", "operation", operation, "attempt", attempt+1, "backoff", backoff, logging.KeyRequestID, requestID)
+		cfg.Metrics.IncBedrockRetry(operation)
+
+		if err := waitBackoff(ctx, policy.clock, backoff, attempt+1); err != nil {
+			err = context.Cause(ctx)
+			tracing.RecordError(span, err)
+			return transformError(err, "")
+		}
+	}
+}
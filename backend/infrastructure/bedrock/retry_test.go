@@ -0,0 +1,416 @@
+package bedrock
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+
+	"github.com/bedrock-chat-poc/backend/domain/services"
+)
+
+// fakeClock records backoff waits and advances its time by them instead of
+// sleeping
+type fakeClock struct {
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Now()}
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.sleeps = append(c.sleeps, d)
+	c.now = c.now.Add(d)
+	return nil
+}
+
+// throttledError builds a throttling error whose response carries a
+// Retry-After header
+func throttledError(retryAfter string) error {
+	header := http.Header{}
+	header.Set("Retry-After", retryAfter)
+	return &awshttp.ResponseError{
+		ResponseError: &smithyhttp.ResponseError{
+			Response: &smithyhttp.Response{Response: &http.Response{StatusCode: http.StatusTooManyRequests, Header: header}},
+			Err:      &smithy.GenericAPIError{Code: "ThrottlingException", Message: "Rate exceeded"},
+		},
+		RequestID: "req-throttled",
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	cfg := AdapterConfig{
+		MaxRetries:     5,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+	}
+	// highest always picks the top of the jitter range
+	highest := func(n int64) int64 { return n - 1 }
+
+	tests := []struct {
+		name   string
+		jitter JitterMode
+		random func(n int64) int64
+		want   []time.Duration
+	}{
+		{
+			name:   "no jitter",
+			jitter: JitterNone,
+			want:   []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second},
+		},
+		{
+			name:   "full jitter at its ceiling",
+			jitter: JitterFull,
+			random: highest,
+			want:   []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second},
+		},
+		{
+			name:   "full jitter at its floor",
+			jitter: JitterFull,
+			random: func(n int64) int64 { return 0 },
+			want:   []time.Duration{0, 0, 0, 0, 0},
+		},
+		{
+			name:   "decorrelated jitter at its ceiling",
+			jitter: JitterDecorrelated,
+			random: highest,
+			want:   []time.Duration{100 * time.Millisecond, 300 * time.Millisecond, 900 * time.Millisecond, time.Second, time.Second},
+		},
+		{
+			name:   "decorrelated jitter at its floor",
+			jitter: JitterDecorrelated,
+			random: func(n int64) int64 { return 0 },
+			want:   []time.Duration{100 * time.Millisecond, 100 * time.Millisecond, 100 * time.Millisecond, 100 * time.Millisecond, 100 * time.Millisecond},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := cfg
+			cfg.Jitter = tt.jitter
+			policy := newRetryPolicy(cfg)
+			if tt.random != nil {
+				policy.random = tt.random
+			}
+
+			for i, want := range tt.want {
+				if got := policy.backoff(i + 1); got != want {
+					t.Errorf("backoff(%d) = %v, want %v", i+1, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_FullJitterStaysInRange(t *testing.T) {
+	policy := newRetryPolicy(AdapterConfig{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second})
+
+	for retry := 1; retry <= 5; retry++ {
+		ceiling := exponentialBackoff(100*time.Millisecond, time.Second, retry)
+		for i := 0; i < 100; i++ {
+			if got := policy.backoff(retry); got < 0 || got > ceiling {
+				t.Fatalf("backoff(%d) = %v, want within [0, %v]", retry, got, ceiling)
+			}
+		}
+	}
+}
+
+func TestRetryPolicy_Next(t *testing.T) {
+	throttled := &smithy.GenericAPIError{Code: "ThrottlingException"}
+	internal := &smithy.GenericAPIError{Code: "InternalServerException"}
+	validation := &smithy.GenericAPIError{Code: "ValidationException"}
+
+	tests := []struct {
+		name      string
+		cfg       AdapterConfig
+		retry     int
+		err       error
+		wantRetry bool
+		wantDelay time.Duration
+	}{
+		{name: "throttled", retry: 1, err: throttled, wantRetry: true, wantDelay: time.Second},
+		{name: "retries exhausted", retry: 4, err: throttled, wantRetry: false},
+		{name: "validation error", retry: 1, err: validation, wantRetry: false},
+		{name: "generic error", retry: 1, err: errors.New("boom"), wantRetry: false},
+		{name: "context canceled", retry: 1, err: context.Canceled, wantRetry: false},
+		{name: "internal error retried once", retry: 1, err: internal, wantRetry: true, wantDelay: time.Second},
+		{name: "internal error not retried twice", retry: 2, err: internal, wantRetry: false},
+		{
+			name:      "custom rule",
+			cfg:       AdapterConfig{RetryRules: map[string]RetryRule{"ValidationException": {Retry: true}}},
+			retry:     1,
+			err:       validation,
+			wantRetry: true,
+			wantDelay: time.Second,
+		},
+		{
+			name:      "custom rules replace the defaults",
+			cfg:       AdapterConfig{RetryRules: map[string]RetryRule{"ValidationException": {Retry: true}}},
+			retry:     1,
+			err:       throttled,
+			wantRetry: false,
+		},
+		{name: "retry after in seconds", retry: 1, err: throttledError("5"), wantRetry: true, wantDelay: 5 * time.Second},
+		{name: "retry after shorter than backoff", retry: 3, err: throttledError("1"), wantRetry: true, wantDelay: 4 * time.Second},
+		{name: "unparseable retry after", retry: 1, err: throttledError("soon"), wantRetry: true, wantDelay: time.Second},
+		{
+			name:      "retry after beyond budget",
+			cfg:       AdapterConfig{RetryBudget: 10 * time.Second},
+			retry:     1,
+			err:       throttledError("30"),
+			wantRetry: false,
+		},
+		{
+			name:      "backoff within budget",
+			cfg:       AdapterConfig{RetryBudget: 10 * time.Second},
+			retry:     3,
+			err:       throttled,
+			wantRetry: true,
+			wantDelay: 4 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.MaxRetries = 3
+			cfg.InitialBackoff = time.Second
+			cfg.MaxBackoff = 30 * time.Second
+			cfg.Jitter = JitterNone
+			cfg.Clock = newFakeClock()
+			policy := newRetryPolicy(cfg)
+
+			delay, retry := policy.next(context.Background(), tt.retry, tt.err)
+			if retry != tt.wantRetry {
+				t.Fatalf("next() retry = %v, want %v", retry, tt.wantRetry)
+			}
+			if retry && delay != tt.wantDelay {
+				t.Errorf("next() delay = %v, want %v", delay, tt.wantDelay)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_RetryAfterDate(t *testing.T) {
+	clock := newFakeClock()
+	policy := newRetryPolicy(AdapterConfig{MaxRetries: 1, InitialBackoff: time.Second, MaxBackoff: time.Second, Jitter: JitterNone, Clock: clock})
+
+	at := clock.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat)
+	delay, retry := policy.next(context.Background(), 1, throttledError(at))
+	if !retry || delay < 9*time.Second || delay > 10*time.Second {
+		t.Errorf("next() = %v, %v; want about 10s", delay, retry)
+	}
+}
+
+func TestRetryPolicy_StopsBeforeDeadline(t *testing.T) {
+	clock := newFakeClock()
+	policy := newRetryPolicy(AdapterConfig{MaxRetries: 3, InitialBackoff: time.Second, MaxBackoff: time.Second, Jitter: JitterNone, Clock: clock})
+
+	ctx, cancel := context.WithDeadline(context.Background(), clock.Now().Add(500*time.Millisecond))
+	defer cancel()
+
+	if _, retry := policy.next(ctx, 1, &smithy.GenericAPIError{Code: "ThrottlingException"}); retry {
+		t.Error("Expected no retry whose wait outlasts the deadline")
+	}
+}
+
+// TestRetryCall_UsesClock tests that both agent paths retry through the
+// shared policy, waiting on the injected clock rather than sleeping
+func TestRetryCall_UsesClock(t *testing.T) {
+	tests := []struct {
+		name   string
+		invoke func(a *Adapter) error
+	}{
+		{
+			name: "InvokeAgent",
+			invoke: func(a *Adapter) error {
+				_, err := a.InvokeAgent(context.Background(), services.AgentInput{SessionID: "session-1", Message: "Hello"})
+				return err
+			},
+		},
+		{
+			name: "InvokeAgentStream",
+			invoke: func(a *Adapter) error {
+				_, err := a.InvokeAgentStream(context.Background(), services.AgentInput{SessionID: "session-1", Message: "Hello"})
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			client := &mockBedrockClient{
+				invokeAgentFunc: func(ctx context.Context, input *bedrockagentruntime.InvokeAgentInput) (*bedrockagentruntime.InvokeAgentOutput, error) {
+					return nil, &smithy.GenericAPIError{Code: "ThrottlingException", Message: "Rate exceeded"}
+				},
+			}
+			adapter := &Adapter{
+				client:  client,
+				agentID: "test-agent",
+				aliasID: "test-alias",
+				config: AdapterConfig{
+					MaxRetries:     3,
+					InitialBackoff: 10 * time.Second,
+					MaxBackoff:     time.Minute,
+					RequestTimeout: time.Hour,
+					Jitter:         JitterNone,
+					Clock:          clock,
+				},
+			}
+
+			start := time.Now()
+			err := tt.invoke(adapter)
+			if time.Since(start) > time.Second {
+				t.Errorf("Expected retries not to sleep, took %v", time.Since(start))
+			}
+
+			var domainErr *services.DomainError
+			if !errors.As(err, &domainErr) || domainErr.Code != services.ErrCodeRateLimit {
+				t.Fatalf("Expected rate limit error, got %v", err)
+			}
+			if client.callCount != 4 {
+				t.Errorf("Expected 4 attempts, got %d", client.callCount)
+			}
+			want := []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second}
+			if len(clock.sleeps) != len(want) {
+				t.Fatalf("Expected waits %v, got %v", want, clock.sleeps)
+			}
+			for i := range want {
+				if clock.sleeps[i] != want[i] {
+					t.Errorf("Wait %d = %v, want %v", i, clock.sleeps[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRetryCall_RetryBudget(t *testing.T) {
+	clock := newFakeClock()
+	client := &mockBedrockClient{
+		invokeAgentFunc: func(ctx context.Context, input *bedrockagentruntime.InvokeAgentInput) (*bedrockagentruntime.InvokeAgentOutput, error) {
+			return nil, &smithy.GenericAPIError{Code: "ThrottlingException", Message: "Rate exceeded"}
+		},
+	}
+	adapter := &Adapter{
+		client:  client,
+		agentID: "test-agent",
+		aliasID: "test-alias",
+		config: AdapterConfig{
+			MaxRetries:     10,
+			InitialBackoff: time.Second,
+			MaxBackoff:     time.Minute,
+			RequestTimeout: time.Hour,
+			Jitter:         JitterNone,
+			RetryBudget:    10 * time.Second,
+			Clock:          clock,
+		},
+	}
+
+	_, err := adapter.InvokeAgent(context.Background(), services.AgentInput{SessionID: "session-1", Message: "Hello"})
+	if err == nil {
+		t.Fatal("Expected error once the budget is spent")
+	}
+	// Waits of 1s, 2s and 4s fit in 10s; the next 8s would not
+	if client.callCount != 4 {
+		t.Errorf("Expected 4 attempts within the budget, got %d", client.callCount)
+	}
+}
+
+func TestInvokeAgentStream_OpenTimeout(t *testing.T) {
+	t.Run("stream that does not open", func(t *testing.T) {
+		client := &mockBedrockClient{
+			invokeAgentFunc: func(ctx context.Context, input *bedrockagentruntime.InvokeAgentInput) (*bedrockagentruntime.InvokeAgentOutput, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			},
+		}
+		adapter := &Adapter{
+			client:  client,
+			agentID: "test-agent",
+			aliasID: "test-alias",
+			config:  AdapterConfig{RequestTimeout: 20 * time.Millisecond},
+		}
+
+		_, err := adapter.InvokeAgentStream(context.Background(), services.AgentInput{SessionID: "session-1", Message: "Hello"})
+		var domainErr *services.DomainError
+		if !errors.As(err, &domainErr) || domainErr.Code != services.ErrCodeTimeout {
+			t.Fatalf("Expected timeout error, got %v", err)
+		}
+	})
+
+	t.Run("failed open releases its context", func(t *testing.T) {
+		var streamCtx context.Context
+		client := &mockBedrockClient{
+			invokeAgentFunc: func(ctx context.Context, input *bedrockagentruntime.InvokeAgentInput) (*bedrockagentruntime.InvokeAgentOutput, error) {
+				streamCtx = ctx
+				return &bedrockagentruntime.InvokeAgentOutput{}, nil
+			},
+		}
+		adapter := &Adapter{
+			client:  client,
+			agentID: "test-agent",
+			aliasID: "test-alias",
+			config:  AdapterConfig{RequestTimeout: time.Minute},
+		}
+
+		// The mock output has no event stream, so the call itself fails
+		if _, err := adapter.InvokeAgentStream(context.Background(), services.AgentInput{SessionID: "session-1", Message: "Hello"}); err == nil {
+			t.Fatal("Expected an error without an event stream")
+		}
+		if streamCtx == nil || streamCtx.Err() == nil {
+			t.Error("Expected the stream's context to be released")
+		}
+	})
+}
+
+func TestStreamOpen(t *testing.T) {
+	t.Run("open stream outlives the timeout until closed", func(t *testing.T) {
+		ctx, open := withOpenTimeout(context.Background(), 20*time.Millisecond)
+		if err := open.opened(); err != nil {
+			t.Fatalf("opened() error = %v", err)
+		}
+		reader := open.reader(&eventStreamReader{})
+		open.close()
+
+		time.Sleep(50 * time.Millisecond)
+		if ctx.Err() != nil {
+			t.Fatalf("Expected the stream's context to outlive the timeout, got %v", ctx.Err())
+		}
+		reader.Close()
+		if !errors.Is(ctx.Err(), context.Canceled) || context.Cause(ctx) != context.Canceled {
+			t.Errorf("Expected closing the reader to release the context, got %v (cause %v)", ctx.Err(), context.Cause(ctx))
+		}
+	})
+
+	t.Run("timeout before the open is reported", func(t *testing.T) {
+		ctx, open := withOpenTimeout(context.Background(), time.Millisecond)
+		defer open.close()
+		<-ctx.Done()
+
+		// The call may return successfully just after the timer fired
+		err := open.opened()
+		var domainErr *services.DomainError
+		if !errors.As(err, &domainErr) || domainErr.Code != services.ErrCodeTimeout {
+			t.Fatalf("Expected timeout error, got %v", err)
+		}
+		if !errors.Is(context.Cause(ctx), errStreamOpenTimeout) {
+			t.Errorf("Expected the open timeout as the cause, got %v", context.Cause(ctx))
+		}
+	})
+}
@@ -349,7 +349,8 @@ func TestSessionContextIntegration(t *testing.T) {
 		chunkCount := 0
 
 		for {
-			chunk, done, err := streamReader.Read()
+			event, err := streamReader.Next(ctx)
+			chunk, done := event.Content, event.Type == services.StreamEventDone
 			if done {
 				break
 			}
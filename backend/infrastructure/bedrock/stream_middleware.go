@@ -0,0 +1,408 @@
+package bedrock
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// StreamMiddleware creates the ChunkFilter for one stream. The stream
+// processor chains its middlewares in order, each filtering the output of
+// the one before.
+type StreamMiddleware func() ChunkFilter
+
+// ChunkFilter transforms an answer's content as it streams. To see text that
+// spans chunks it may hold back the end of a chunk, up to a bounded
+// lookahead, until later chunks show how it continues.
+type ChunkFilter interface {
+	// Filter takes the next chunk and returns the text ready to be written
+	Filter(chunk string) string
+	// Flush returns the text still held back. It is called when the stream
+	// ends, is blocked by a guardrail or is continued on a new stream.
+	Flush() string
+}
+
+// Stream middleware names used in configuration
+const (
+	MiddlewareUTF8      = "utf8"
+	MiddlewareNullBytes = "null_bytes"
+	MiddlewarePII       = "pii"
+	MiddlewareProfanity = "profanity"
+	MiddlewareMarkdown  = "markdown"
+)
+
+// StreamMiddlewareOptions holds settings for the built-in stream middlewares
+type StreamMiddlewareOptions struct {
+	// ProfanityWords are masked by the profanity middleware in addition to
+	// its built-in list
+	ProfanityWords []string
+}
+
+// NewStreamMiddlewares returns the built-in middlewares with the given names,
+// in order
+func NewStreamMiddlewares(names []string, opts StreamMiddlewareOptions) ([]StreamMiddleware, error) {
+	middlewares := make([]StreamMiddleware, 0, len(names))
+	for _, name := range names {
+		switch strings.TrimSpace(name) {
+		case MiddlewareUTF8:
+			middlewares = append(middlewares, UTF8RepairMiddleware())
+		case MiddlewareNullBytes:
+			middlewares = append(middlewares, NullByteMiddleware())
+		case MiddlewarePII:
+			middlewares = append(middlewares, PIIRedactionMiddleware())
+		case MiddlewareProfanity:
+			middlewares = append(middlewares, ProfanityMiddleware(opts.ProfanityWords))
+		case MiddlewareMarkdown:
+			middlewares = append(middlewares, MarkdownSanitizerMiddleware())
+		default:
+			return nil, fmt.Errorf("unknown stream middleware: %q", name)
+		}
+	}
+	return middlewares, nil
+}
+
+// streamPipeline runs one stream's content through its middlewares' filters
+type streamPipeline struct {
+	filters []ChunkFilter
+}
+
+// newStreamPipeline creates fresh filters for a stream
+func newStreamPipeline(middlewares []StreamMiddleware) *streamPipeline {
+	filters := make([]ChunkFilter, 0, len(middlewares))
+	for _, middleware := range middlewares {
+		filters = append(filters, middleware())
+	}
+	return &streamPipeline{filters: filters}
+}
+
+// filter passes a chunk through every filter in turn
+func (p *streamPipeline) filter(chunk string) string {
+	for _, f := range p.filters {
+		chunk = f.Filter(chunk)
+	}
+	return chunk
+}
+
+// flush drains the text held back by every filter. Each filter's remainder
+// still passes through the filters after it.
+func (p *streamPipeline) flush() string {
+	text := ""
+	for _, f := range p.filters {
+		text = f.Filter(text) + f.Flush()
+	}
+	return text
+}
+
+// UTF8RepairMiddleware joins multi-byte characters split across chunks and
+// replaces bytes that are not valid UTF-8 with U+FFFD. It holds back at
+// most the 3 bytes of an incomplete character.
+func UTF8RepairMiddleware() StreamMiddleware {
+	return func() ChunkFilter { return &utf8Repair{} }
+}
+
+type utf8Repair struct {
+	pending string
+}
+
+func (f *utf8Repair) Filter(chunk string) string {
+	text := f.pending + chunk
+	cut := len(text) - incompleteRuneSuffix(text)
+	f.pending = text[cut:]
+	return strings.ToValidUTF8(text[:cut], string(utf8.RuneError))
+}
+
+func (f *utf8Repair) Flush() string {
+	text := strings.ToValidUTF8(f.pending, string(utf8.RuneError))
+	f.pending = ""
+	return text
+}
+
+// incompleteRuneSuffix returns the length of a character cut short at the
+// end of s, or 0 if s ends on a character boundary
+func incompleteRuneSuffix(s string) int {
+	for i := len(s) - 1; i >= 0 && i >= len(s)-utf8.UTFMax+1; i-- {
+		if utf8.RuneStart(s[i]) {
+			if utf8.FullRuneInString(s[i:]) {
+				return 0
+			}
+			return len(s) - i
+		}
+	}
+	return 0
+}
+
+// NullByteMiddleware strips null bytes, which clients and storage reject
+func NullByteMiddleware() StreamMiddleware {
+	return func() ChunkFilter { return nullByteFilter{} }
+}
+
+type nullByteFilter struct{}
+
+func (nullByteFilter) Filter(chunk string) string { return strings.ReplaceAll(chunk, "\x00", "") }
+func (nullByteFilter) Flush() string              { return "" }
+
+// patternRule replaces the matches of a pattern
+type patternRule struct {
+	pattern *regexp.Regexp
+	replace func(match string) string
+}
+
+// patternFilter applies pattern rules to streamed text. It holds back the
+// last lookahead bytes, and any match or word reaching into them, so that
+// matches spanning chunks are seen whole. Patterns must not match more than
+// lookahead bytes, and at most lookahead plus cutWindow bytes are held back.
+type patternFilter struct {
+	rules     []patternRule
+	lookahead int
+	buf       string
+}
+
+// cutWindow bounds how far a cut moves back to keep a word whole, so long
+// runs of text without word breaks, such as URLs, code or CJK text, still
+// stream
+const cutWindow = 64
+
+func newPatternMiddleware(lookahead int, rules ...patternRule) StreamMiddleware {
+	return func() ChunkFilter { return &patternFilter{rules: rules, lookahead: lookahead} }
+}
+
+func (f *patternFilter) Filter(chunk string) string {
+	f.buf += chunk
+	if len(f.buf) <= f.lookahead {
+		return ""
+	}
+
+	cut := f.safeCut(len(f.buf) - f.lookahead)
+	ready := f.apply(f.buf[:cut])
+	f.buf = f.buf[cut:]
+	return ready
+}
+
+func (f *patternFilter) Flush() string {
+	text := f.apply(f.buf)
+	f.buf = ""
+	return text
+}
+
+// safeCut moves a cut in the buffer back so that it splits neither a word,
+// whose end may still change what matches, nor a match. Only the text a
+// match or word could span is searched, and the cut never moves back past
+// lookahead plus cutWindow bytes from the end.
+func (f *patternFilter) safeCut(cut int) int {
+	for cut > 0 && !utf8.RuneStart(f.buf[cut]) {
+		cut--
+	}
+	for i := cut; i > 0 && i > cut-cutWindow; i-- {
+		if utf8.RuneStart(f.buf[i]) && !(isWordByte(f.buf[i-1]) && isWordByte(f.buf[i])) {
+			cut = i
+			break
+		}
+	}
+
+	for moved := true; moved; {
+		moved = false
+		// A match crossing the cut starts at most lookahead bytes before it;
+		// one more byte lets word boundaries at the start be seen
+		start := cut - f.lookahead - 1
+		if start < 0 {
+			start = 0
+		}
+		for _, rule := range f.rules {
+			for _, loc := range rule.pattern.FindAllStringIndex(f.buf[start:], -1) {
+				if start+loc[0] < cut && start+loc[1] >= cut {
+					cut = start + loc[0]
+					moved = true
+				}
+			}
+		}
+	}
+
+	if floor := len(f.buf) - f.lookahead - cutWindow; cut < floor {
+		cut = floor
+		for cut > 0 && !utf8.RuneStart(f.buf[cut]) {
+			cut--
+		}
+	}
+	return cut
+}
+
+// isWordByte reports whether b is an ASCII word character, as matched by \w
+func isWordByte(b byte) bool {
+	return b == '_' || '0' <= b && b <= '9' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z'
+}
+
+// apply runs every rule over text in turn
+func (f *patternFilter) apply(text string) string {
+	for _, rule := range f.rules {
+		text = rule.pattern.ReplaceAllStringFunc(text, rule.replace)
+	}
+	return text
+}
+
+// piiLookahead bounds the PII patterns: the longest email they match
+const piiLookahead = 160
+
+// PIIRedactionMiddleware replaces email addresses, card numbers, US social
+// security numbers and phone numbers with placeholders such as [EMAIL]
+func PIIRedactionMiddleware() StreamMiddleware {
+	return newPatternMiddleware(piiLookahead,
+		patternRule{
+			pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]{1,64}@[A-Za-z0-9-]{1,63}(?:\.[A-Za-z0-9-]{1,63}){0,3}\.[A-Za-z]{2,24}`),
+			replace: constant("[EMAIL]"),
+		},
+		patternRule{
+			pattern: regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+			replace: func(match string) string {
+				if luhnValid(match) {
+					return "[CARD]"
+				}
+				return match
+			},
+		},
+		patternRule{
+			pattern: regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`),
+			replace: constant("[SSN]"),
+		},
+		patternRule{
+			pattern: regexp.MustCompile(`(?:\+\d{1,3}[ .-]?)?(?:\(\d{3}\)|\b\d{3})[ .-]?\d{3}[ .-]?\d{4}\b`),
+			replace: constant("[PHONE]"),
+		},
+	)
+}
+
+// luhnValid reports whether the digits in s pass the Luhn checksum used by
+// card numbers
+func luhnValid(s string) bool {
+	sum, double := 0, false
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// profanityLookahead bounds the profanity pattern: the longest word it masks
+const profanityLookahead = 32
+
+// defaultProfanity is the built-in list of words the profanity middleware masks
+var defaultProfanity = []string{
+	"fuck", "fucking", "motherfucker", "shit", "bullshit", "bitch", "bastard",
+	"asshole", "cunt", "dick", "piss", "crap", "damn",
+}
+
+// ProfanityMiddleware masks profane words, keeping their first letter, e.g.
+// s***. words are masked in addition to the built-in list.
+func ProfanityMiddleware(words []string) StreamMiddleware {
+	all := make([]string, 0, len(defaultProfanity)+len(words))
+	for _, word := range append(append(all, defaultProfanity...), words...) {
+		if word = strings.TrimSpace(word); word != "" && len(word) < profanityLookahead {
+			all = append(all, regexp.QuoteMeta(word))
+		}
+	}
+	return newPatternMiddleware(profanityLookahead,
+		patternRule{
+			pattern: regexp.MustCompile(`(?i)\b(?:` + strings.Join(all, "|") + `)(?:s|es|ed|er|ers)?\b`),
+			replace: func(match string) string {
+				first, size := utf8.DecodeRuneInString(match)
+				return string(first) + strings.Repeat("*", utf8.RuneCountInString(match[size:]))
+			},
+		},
+	)
+}
+
+// markdownLookahead bounds the markdown patterns: the longest tag, attribute
+// or link target they rewrite
+const markdownLookahead = 256
+
+// MarkdownSanitizerMiddleware removes the markup most commonly used to run
+// script when the client renders the answer: script-like HTML tags, event
+// handler attributes such as onerror, and links, images or HTML href and src
+// targets whose URL is javascript:, vbscript: or data:. It is a filter for
+// generated answers, not an HTML sanitizer; a client rendering answers as
+// HTML must still sanitize them.
+func MarkdownSanitizerMiddleware() StreamMiddleware {
+	return newPatternMiddleware(markdownLookahead,
+		patternRule{
+			pattern: regexp.MustCompile(`(?i)<\s*/?\s*(?:script|iframe|object|embed|style|link|meta|form)\b[^>]{0,200}>`),
+			replace: constant(""),
+		},
+		patternRule{
+			pattern: regexp.MustCompile(`(?i)\]\(\s*(?:javascript|vbscript|data):[^()]{0,100}(?:\([^()]{0,50}\)[^()]{0,50})?\)`),
+			replace: constant("](#)"),
+		},
+		patternRule{
+			pattern: regexp.MustCompile(`(?i)<\s*(?:javascript|vbscript|data):[^>]{0,200}>`),
+			replace: constant(""),
+		},
+		patternRule{
+			pattern: htmlAttrPattern,
+			replace: sanitizeAttr,
+		},
+	)
+}
+
+// htmlAttrPattern matches an HTML attribute with its value. Attributes are
+// matched wherever they appear rather than within a whole tag, so a quoted >
+// earlier in the tag cannot hide them, nor can gluing them to the previous
+// attribute's closing quote.
+var htmlAttrPattern = regexp.MustCompile(`(\s{0,8})([A-Za-z][A-Za-z0-9:-]{0,31})\s{0,8}=\s{0,8}("[^"]{0,190}"|'[^']{0,190}'|[^\s"'=<>` + "`" + `]{1,190})`)
+
+// urlAttributes are the HTML attributes whose value is loaded or followed as a URL
+var urlAttributes = map[string]bool{
+	"href": true, "src": true, "action": true, "formaction": true, "xlink:href": true,
+	"background": true, "poster": true, "data": true, "lowsrc": true, "dynsrc": true,
+}
+
+// sanitizeAttr drops event handler and srcdoc attributes and points URL
+// attributes with a script or data scheme at #. Event handlers are on
+// followed by the event, the shortest being oncut.
+func sanitizeAttr(attr string) string {
+	parts := htmlAttrPattern.FindStringSubmatch(attr)
+	name := strings.ToLower(parts[2])
+	switch {
+	case strings.HasPrefix(name, "on") && len(name) >= len("oncut"), name == "srcdoc":
+		return ""
+	case urlAttributes[name] && unsafeURL(parts[3]):
+		return parts[1] + parts[2] + `="#"`
+	default:
+		return attr
+	}
+}
+
+// unsafeURL reports whether an attribute value, quoted or not, is a
+// javascript:, vbscript: or data: URL. Browsers decode entities and ignore
+// whitespace and control characters in the scheme, so both are undone first.
+func unsafeURL(value string) bool {
+	value = html.UnescapeString(strings.Trim(value, `"'`))
+	scheme := strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, value)
+	for _, unsafe := range []string{"javascript:", "vbscript:", "data:"} {
+		if strings.HasPrefix(scheme, unsafe) {
+			return true
+		}
+	}
+	return false
+}
+
+// constant returns a replace func that always returns s
+func constant(s string) func(string) string {
+	return func(string) string { return s }
+}
@@ -0,0 +1,225 @@
+package bedrock
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/bedrock-chat-poc/backend/domain/entities"
+	"github.com/bedrock-chat-poc/backend/domain/services"
+)
+
+// runPipeline streams chunks through middlewares and returns everything
+// they write
+func runPipeline(middlewares []StreamMiddleware, chunks ...string) string {
+	pipeline := newStreamPipeline(middlewares)
+	var out strings.Builder
+	for _, chunk := range chunks {
+		out.WriteString(pipeline.filter(chunk))
+	}
+	out.WriteString(pipeline.flush())
+	return out.String()
+}
+
+func TestStreamMiddlewares(t *testing.T) {
+	tests := []struct {
+		name        string
+		middlewares []string
+		chunks      []string
+		want        string
+	}{
+		{
+			name:        "utf8 joins a character split across chunks",
+			middlewares: []string{MiddlewareUTF8},
+			chunks:      []string{"caf\xc3", "\xa9 ouvert"},
+			want:        "café ouvert",
+		},
+		{
+			name:        "utf8 replaces invalid bytes",
+			middlewares: []string{MiddlewareUTF8},
+			chunks:      []string{"bad \xff byte", " and a cut \xe2\x82"},
+			want:        "bad � byte and a cut �",
+		},
+		{
+			name:        "null bytes are stripped",
+			middlewares: []string{MiddlewareNullBytes},
+			chunks:      []string{"a\x00b", "\x00c"},
+			want:        "abc",
+		},
+		{
+			name:        "pii split across chunks",
+			middlewares: []string{MiddlewarePII},
+			chunks:      []string{"Email jane.doe@exa", "mple.com or call (555) 123-", "4567."},
+			want:        "Email [EMAIL] or call [PHONE].",
+		},
+		{
+			name:        "pii card numbers need a valid checksum",
+			middlewares: []string{MiddlewarePII},
+			chunks:      []string{"Card 4111 1111 1111 1111, ticket 1234 5678 9012 3456, SSN 123-45-6789."},
+			want:        "Card [CARD], ticket 1234 5678 9012 3456, SSN [SSN].",
+		},
+		{
+			name:        "profanity is masked, not words containing it",
+			middlewares: []string{MiddlewareProfanity},
+			chunks:      []string{"This is sh", "it, not an ass", "essment of Scunthorpe."},
+			want:        "This is s***, not an assessment of Scunthorpe.",
+		},
+		{
+			name:        "markdown script and javascript links are removed",
+			middlewares: []string{MiddlewareMarkdown},
+			chunks:      []string{"See <scr", "ipt>alert(1)</script> [the policy](javascript:al", "ert(1)) or [HR](https://hr.example.com)."},
+			want:        "See alert(1) [the policy](#) or [HR](https://hr.example.com).",
+		},
+		{
+			name:        "markdown event handlers and script targets in html are removed",
+			middlewares: []string{MiddlewareMarkdown},
+			chunks: []string{
+				`<img src=x onerror=alert(1)> <svg onload="alert(1)"><circle r="4"/></svg> <svg/onload=alert(1)> <a title="a>b" onclick=alert(1)>c</a> <img src="x"onerror=alert(1)> `,
+				`<a href="javascript:alert(1)">policy</a> <a HREF=' java&#x09;script:alert(1)'>x</a> <a href="https://hr.example.com" title="HR">HR</a>`,
+			},
+			want: `<img src=x> <svg><circle r="4"/></svg> <svg/> <a title="a>b">c</a> <img src="x"> <a href="#">policy</a> <a HREF="#">x</a> <a href="https://hr.example.com" title="HR">HR</a>`,
+		},
+		{
+			name:        "middlewares run in order",
+			middlewares: []string{MiddlewareUTF8, MiddlewareNullBytes, MiddlewarePII},
+			chunks:      []string{"Write to j\x00oe@example.org \xe2\x9c", "\x93"},
+			want:        "Write to [EMAIL] ✓",
+		},
+		{
+			name:        "pii in a long run without spaces",
+			middlewares: []string{MiddlewarePII},
+			chunks:      []string{"https://hr.example.com/" + strings.Repeat("a", 300) + "?contact=jane.doe@exa", "mple.com&x=" + strings.Repeat("b", 300)},
+			want:        "https://hr.example.com/" + strings.Repeat("a", 300) + "?contact=[EMAIL]&x=" + strings.Repeat("b", 300),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			middlewares, err := NewStreamMiddlewares(tt.middlewares, StreamMiddlewareOptions{})
+			if err != nil {
+				t.Fatalf("NewStreamMiddlewares() error = %v", err)
+			}
+			if got := runPipeline(middlewares, tt.chunks...); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestStreamMiddlewares_BoundedLookahead(t *testing.T) {
+	middlewares, _ := NewStreamMiddlewares([]string{MiddlewarePII}, StreamMiddlewareOptions{})
+	pipeline := newStreamPipeline(middlewares)
+
+	// Text beyond the lookahead is written without waiting for the stream
+	// to end
+	text := strings.Repeat("word ", 100)
+	if out := pipeline.filter(text); len(text)-len(out) > piiLookahead {
+		t.Errorf("Expected at most %d bytes held back, got %d", piiLookahead, len(text)-len(out))
+	}
+}
+
+func TestStreamMiddlewares_UnbrokenText(t *testing.T) {
+	middlewares, _ := NewStreamMiddlewares([]string{MiddlewarePII}, StreamMiddlewareOptions{})
+	pipeline := newStreamPipeline(middlewares)
+
+	// Each chunk has a word break followed by a long run without one, such
+	// as base64 or CJK text; the run still streams instead of being held
+	// back behind the break
+	var sent, out strings.Builder
+	for i := 0; i < 20; i++ {
+		for _, chunk := range []string{"data: " + strings.Repeat("QUJD", 100), "。" + strings.Repeat("日本語", 100)} {
+			sent.WriteString(chunk)
+			out.WriteString(pipeline.filter(chunk))
+			if held := sent.Len() - out.Len(); held > piiLookahead+cutWindow {
+				t.Fatalf("Expected at most %d bytes held back, got %d", piiLookahead+cutWindow, held)
+			}
+		}
+	}
+	out.WriteString(pipeline.flush())
+	if out.String() != sent.String() {
+		t.Error("Expected the text to pass through unchanged")
+	}
+}
+
+func TestNewStreamMiddlewares_Unknown(t *testing.T) {
+	if _, err := NewStreamMiddlewares([]string{MiddlewareUTF8, "spellcheck"}, StreamMiddlewareOptions{}); err == nil {
+		t.Error("Expected an error for an unknown middleware")
+	}
+}
+
+func TestProfanityMiddleware_ExtraWords(t *testing.T) {
+	middlewares, _ := NewStreamMiddlewares([]string{MiddlewareProfanity}, StreamMiddlewareOptions{ProfanityWords: []string{"heck"}})
+	if got := runPipeline(middlewares, "What the heck."); got != "What the h***." {
+		t.Errorf("Expected the extra word to be masked, got %q", got)
+	}
+}
+
+func TestStreamProcessor_Middlewares(t *testing.T) {
+	tests := []struct {
+		name          string
+		inlineMarkers bool
+		wantContent   string
+		wantSpans     []string
+	}{
+		{
+			name:        "without markers",
+			wantContent: "Employees get 20 days of annual leave. Contact [EMAIL] for leave. Call [PHONE].",
+			wantSpans:   []string{"Employees get 20 days of annual leave.", "Contact [EMAIL] for leave."},
+		},
+		{
+			name:          "with inline markers",
+			inlineMarkers: true,
+			wantContent:   "Employees get 20 days of annual leave.[1] Contact [EMAIL] for leave.[2] Call [PHONE].",
+			wantSpans:     []string{"Employees get 20 days of annual leave.", "Contact [EMAIL] for leave."},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			middlewares, _ := NewStreamMiddlewares([]string{MiddlewarePII, MiddlewareMarkdown}, StreamMiddlewareOptions{})
+			config := DefaultStreamProcessorConfig()
+			config.Middlewares = middlewares
+			config.InlineCitationMarkers = tt.inlineMarkers
+			processor := NewStreamProcessor(config)
+
+			reader := &eventStreamReader{events: []services.StreamEvent{
+				{Type: services.StreamEventContent, Content: "Employees get 20 days "},
+				{Type: services.StreamEventContent, Content: "of annual leave."},
+				{Type: services.StreamEventCitation, Citations: []entities.Citation{{
+					SourceID:     "s3://docs/leave.pdf",
+					ResponseText: "Employees get 20 days of annual leave.",
+					ResponseSpan: &entities.TextSpan{Start: 0, End: 37},
+				}}},
+				{Type: services.StreamEventContent, Content: " Contact hr@exam"},
+				{Type: services.StreamEventContent, Content: "ple.com for leave."},
+				{Type: services.StreamEventCitation, Citations: []entities.Citation{{
+					SourceID:     "s3://docs/contacts.pdf",
+					ResponseText: "Contact hr@example.com for leave.",
+					ResponseSpan: &entities.TextSpan{Start: 39, End: 71},
+				}}},
+				{Type: services.StreamEventContent, Content: " Call 555-123-"},
+				{Type: services.StreamEventContent, Content: "4567."},
+			}}
+			writer := &mockChunkWriter{}
+
+			if err := processor.ProcessStream(context.Background(), reader, writer); err != nil {
+				t.Fatalf("ProcessStream() error = %v", err)
+			}
+
+			// Text held back is written before each citation, so markers
+			// follow the text they cite and spans cover the redacted text
+			content := strings.Join(writer.contentChunks, "")
+			if content != tt.wantContent {
+				t.Errorf("Unexpected content %q", content)
+			}
+			if len(writer.citationChunks) != len(tt.wantSpans) {
+				t.Fatalf("Expected %d citations, got %+v", len(tt.wantSpans), writer.citationChunks)
+			}
+			for i, chunk := range writer.citationChunks {
+				if got := runeSlice(content, chunk.Span.Start, chunk.Span.End); got != tt.wantSpans[i] {
+					t.Errorf("Citation %d: span %+v covers %q, want %q", i, *chunk.Span, got, tt.wantSpans[i])
+				}
+			}
+		})
+	}
+}
@@ -4,11 +4,19 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"runtime/debug"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/bedrock-chat-poc/backend/domain/entities"
 	"github.com/bedrock-chat-poc/backend/domain/services"
-	"github.com/gorilla/websocket"
+	"github.com/bedrock-chat-poc/backend/infrastructure/metrics"
+	"github.com/bedrock-chat-poc/backend/infrastructure/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // StreamProcessor handles processing of Bedrock streaming responses
@@ -16,14 +24,62 @@ import (
 type StreamProcessor struct {
 	streamTimeout time.Duration
 	chunkTimeout  time.Duration
+	inlineMarkers bool
+	urlResolver   services.CitationURLResolver
+	maxCitations  int
+	resumePolicy  ResumePolicy
+	maxResumes    int
+	middlewares   []StreamMiddleware
+	metrics       *metrics.Metrics
 }
 
+// ResumePolicy says what happens to an answer whose stream fails part way
+// with a retryable error, such as throttling
+type ResumePolicy string
+
+const (
+	// ResumeOff fails the answer
+	ResumeOff ResumePolicy = "off"
+	// ResumeRestart reopens the stream and answers again from the start,
+	// after telling the client to discard the partial answer
+	ResumeRestart ResumePolicy = "restart"
+	// ResumeContinue reopens the stream and continues the partial answer
+	// where services support it; others restart
+	ResumeContinue ResumePolicy = "continue"
+)
+
+// StreamOpener reopens a turn's stream after a retryable failure. partial is
+// the answer written so far when it should be continued, and empty when it
+// should be restarted. continued reports whether the new stream continues
+// partial rather than starting over.
+type StreamOpener func(ctx context.Context, partial string) (reader services.StreamReader, continued bool, err error)
+
 // StreamProcessorConfig holds configuration for the stream processor
 type StreamProcessorConfig struct {
 	// StreamTimeout is the maximum time to wait for the entire stream
 	StreamTimeout time.Duration
 	// ChunkTimeout is the maximum time to wait between chunks
 	ChunkTimeout time.Duration
+	// InlineCitationMarkers injects numbered markers such as [1] into the
+	// content stream right after the text each citation supports
+	InlineCitationMarkers bool
+	// URLResolver rewrites citation URLs before they are written; URLs are
+	// passed through unchanged when nil
+	URLResolver services.CitationURLResolver
+	// MaxCitations caps the citations streamed and listed per answer; zero
+	// means no cap
+	MaxCitations int
+	// ResumePolicy says how an answer resumes after its stream fails part
+	// way with a retryable error; empty means ResumeOff
+	ResumePolicy ResumePolicy
+	// MaxResumes bounds how many times one answer is resumed
+	MaxResumes int
+	// Middlewares transform content before it is written, in order, e.g.
+	// to repair UTF-8 or redact PII; see NewStreamMiddlewares
+	Middlewares []StreamMiddleware
+	// Metrics records stream latency, chunk counts, stalls and timeouts;
+	// nil records nothing
+	Metrics *metrics.Metrics
 }
 
 // DefaultStreamProcessorConfig returns default configuration
@@ -31,6 +87,9 @@ func DefaultStreamProcessorConfig() StreamProcessorConfig {
 	return StreamProcessorConfig{
 		StreamTimeout: 5 * time.Minute,
 		ChunkTimeout:  30 * time.Second,
+		MaxCitations:  10,
+		ResumePolicy:  ResumeOff,
+		MaxResumes:    1,
 	}
 }
 
@@ -39,6 +98,13 @@ func NewStreamProcessor(config StreamProcessorConfig) *StreamProcessor {
 	return &StreamProcessor{
 		streamTimeout: config.StreamTimeout,
 		chunkTimeout:  config.ChunkTimeout,
+		inlineMarkers: config.InlineCitationMarkers,
+		urlResolver:   config.URLResolver,
+		maxCitations:  config.MaxCitations,
+		resumePolicy:  config.ResumePolicy,
+		maxResumes:    config.MaxResumes,
+		middlewares:   config.Middlewares,
+		metrics:       config.Metrics,
 	}
 }
 
@@ -46,7 +112,14 @@ func NewStreamProcessor(config StreamProcessorConfig) *StreamProcessor {
 type ChunkWriter interface {
 	WriteContentChunk(content string) error
 	WriteCitationChunk(citation CitationChunk) error
+	WriteCitationListChunk(citations []CitationChunk) error
 	WriteErrorChunk(code, message string) error
+	// WriteGuardrailChunk tells the client a guardrail blocked the question
+	// or the answer
+	WriteGuardrailChunk(intervention entities.GuardrailIntervention) error
+	// WriteRestartChunk tells the client to discard the answer so far, which
+	// is being answered again from the start
+	WriteRestartChunk() error
 	WriteDoneChunk() error
 }
 
@@ -54,29 +127,58 @@ type ChunkWriter interface {
 type CitationChunk struct {
 	SourceID   string                 `json:"source_id"`
 	SourceName string                 `json:"source_name"`
+	SourceType string                 `json:"source_type,omitempty"`
 	Excerpt    string                 `json:"excerpt"`
 	Confidence float64                `json:"confidence,omitempty"`
 	URL        string                 `json:"url,omitempty"`
 	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+	// Number is the citation's footnote number; citations of the same source share a number
+	Number int `json:"number,omitempty"`
+	// Span locates the supported text in the assistant message
+	Span *CitationSpan `json:"span,omitempty"`
+	// Spans lists every span the citation supports; set in the consolidated list only
+	Spans []CitationSpan `json:"spans,omitempty"`
+}
+
+// MessageConn writes messages to a connection. Each value is written as one
+// message, in whatever encoding the connection negotiated with its client;
+// values are shaped by their JSON tags either way. Implementations may
+// serialize writes from several goroutines.
+type MessageConn interface {
+	WriteChunk(v interface{}) error
 }
 
 // WebSocketChunkWriter implements ChunkWriter for WebSocket connections
 type WebSocketChunkWriter struct {
-	conn *websocket.Conn
+	conn MessageConn
+	// mu guards the IDs, which may be set while a CoalescingChunkWriter
+	// writes from its own goroutine
+	mu            sync.Mutex
+	correlationID string
+	requestID     string
 }
 
 // NewWebSocketChunkWriter creates a new WebSocket chunk writer
-func NewWebSocketChunkWriter(conn *websocket.Conn) *WebSocketChunkWriter {
+func NewWebSocketChunkWriter(conn MessageConn) *WebSocketChunkWriter {
 	return &WebSocketChunkWriter{conn: conn}
 }
 
+// SetRequestIDs sets the IDs attached to error chunks: the chat turn's
+// correlation ID and the AWS request ID of the stream being written
+func (w *WebSocketChunkWriter) SetRequestIDs(correlationID, requestID string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.correlationID = correlationID
+	w.requestID = requestID
+}
+
 // WriteContentChunk writes a content chunk to the WebSocket
 func (w *WebSocketChunkWriter) WriteContentChunk(content string) error {
 	chunk := map[string]interface{}{
 		"type":    "content",
 		"content": content,
 	}
-	return w.conn.WriteJSON(chunk)
+	return w.conn.WriteChunk(chunk)
 }
 
 // WriteCitationChunk writes a citation chunk to the WebSocket
@@ -85,19 +187,84 @@ func (w *WebSocketChunkWriter) WriteCitationChunk(citation CitationChunk) error
 		"type":     "citation",
 		"citation": citation,
 	}
-	return w.conn.WriteJSON(chunk)
+	return w.conn.WriteChunk(chunk)
+}
+
+// WriteCitationListChunk writes the consolidated citation list to the WebSocket
+func (w *WebSocketChunkWriter) WriteCitationListChunk(citations []CitationChunk) error {
+	chunk := map[string]interface{}{
+		"type":      "citations",
+		"citations": citations,
+	}
+	return w.conn.WriteChunk(chunk)
 }
 
 // WriteErrorChunk writes an error chunk to the WebSocket
 func (w *WebSocketChunkWriter) WriteErrorChunk(code, message string) error {
+	errorBody := map[string]string{
+		"code":    code,
+		"message": message,
+	}
+	w.mu.Lock()
+	if w.correlationID != "" {
+		errorBody["correlation_id"] = w.correlationID
+	}
+	if w.requestID != "" {
+		errorBody["request_id"] = w.requestID
+	}
+	w.mu.Unlock()
+	chunk := map[string]interface{}{
+		"type":  "error",
+		"error": errorBody,
+	}
+	return w.conn.WriteChunk(chunk)
+}
+
+// WriteGuardrailChunk writes a GUARDRAIL_BLOCKED error chunk to the
+// WebSocket, with the stage and the policies that triggered in its details
+func (w *WebSocketChunkWriter) WriteGuardrailChunk(intervention entities.GuardrailIntervention) error {
+	message := "The request was blocked by a content guardrail"
+	switch intervention.Stage {
+	case entities.GuardrailStageInput:
+		message = "The question was blocked by a content guardrail"
+	case entities.GuardrailStageOutput:
+		message = "The answer was blocked by a content guardrail"
+	}
+	details := map[string]interface{}{}
+	if intervention.Stage != "" {
+		details["stage"] = intervention.Stage
+	}
+	if len(intervention.Categories) > 0 {
+		details["category"] = intervention.Categories[0]
+		details["categories"] = intervention.Categories
+	}
+	errorBody := map[string]interface{}{
+		"code":    services.ErrCodeGuardrailBlocked,
+		"message": message,
+		"details": details,
+	}
+	w.mu.Lock()
+	if w.correlationID != "" {
+		errorBody["correlation_id"] = w.correlationID
+	}
+	if w.requestID != "" {
+		errorBody["request_id"] = w.requestID
+	}
+	w.mu.Unlock()
+	chunk := map[string]interface{}{
+		"type":  "error",
+		"error": errorBody,
+	}
+	return w.conn.WriteChunk(chunk)
+}
+
+// WriteRestartChunk writes a restart chunk to the WebSocket
+func (w *WebSocketChunkWriter) WriteRestartChunk() error {
 	chunk := map[string]interface{}{
-		"type": "error",
-		"error": map[string]string{
-			"code":    code,
-			"message": message,
-		},
+		"type":    "restart",
+		"message": "The answer was interrupted and is starting over",
 	}
-	return w.conn.WriteJSON(chunk)
+	return w.conn.WriteChunk(chunk)
 }
 
 // WriteDoneChunk writes a done chunk to the WebSocket
@@ -105,24 +272,77 @@ func (w *WebSocketChunkWriter) WriteDoneChunk() error {
 	chunk := map[string]interface{}{
 		"type": "done",
 	}
-	return w.conn.WriteJSON(chunk)
+	return w.conn.WriteChunk(chunk)
 }
 
 // ProcessStream processes a streaming response and forwards chunks to the writer
 func (sp *StreamProcessor) ProcessStream(ctx context.Context, reader services.StreamReader, writer ChunkWriter) error {
+	return sp.ProcessResumableStream(ctx, reader, nil, writer)
+}
+
+// ProcessResumableStream processes a streaming response like ProcessStream.
+// If the stream fails part way with a retryable error, reopen is called to
+// resume the answer as the resume policy says, up to MaxResumes times. A
+// restarted answer is preceded by a restart chunk when anything was written.
+// A nil reopen never resumes.
+func (sp *StreamProcessor) ProcessResumableStream(ctx context.Context, reader services.StreamReader, reopen StreamOpener, writer ChunkWriter) error {
+	// Trace the whole stream
+	ctx, span := tracer.Start(ctx, "stream.process", trace.WithAttributes(tracing.AttrRequestID.String(reader.RequestID())))
+	defer span.End()
+
 	// Create context with overall stream timeout
 	streamCtx, cancel := context.WithTimeout(ctx, sp.streamTimeout)
 	defer cancel()
 
-	// Ensure stream is closed when done
+	// Ensure the current stream is closed when done
 	defer func() {
 		if err := reader.Close(); err != nil {
-			log.Printf("[StreamProcessor] Error closing stream: %v", err)
+			slog.WarnContext(ctx, "[StreamProcessor] Error closing stream", "error", err)
 		}
 	}()
 
-	// Track if we've received any content
+	// Track if we've received any content, and the answer's text without
+	// citation markers, which a continued stream picks up from
 	receivedContent := false
+	var answer strings.Builder
+	resumes := 0
+
+	// Record how the stream went once it ends
+	start := time.Now()
+	chunks := 0
+	outcome := metrics.OutcomeError
+	defer func() {
+		sp.metrics.ObserveStream(time.Since(start), chunks, outcome)
+		span.SetAttributes(attribute.Int("chunks", chunks), attribute.String("outcome", outcome))
+		if outcome != metrics.OutcomeSuccess && outcome != metrics.OutcomeBlocked {
+			span.SetStatus(codes.Error, outcome)
+		}
+	}()
+
+	// Tie citations to the text they support
+	aligner := newCitationAligner(sp.inlineMarkers)
+	aggregator := newCitationAggregator(sp.maxCitations)
+
+	// Content passes through the middlewares before it is written
+	pipeline := newStreamPipeline(sp.middlewares)
+	writeContent := func(text string) error {
+		if text == "" {
+			return nil
+		}
+		if chunks == 0 {
+			sp.metrics.ObserveFirstChunk(time.Since(start))
+			span.AddEvent("first_chunk")
+		}
+		receivedContent = true
+		chunks++
+		if err := writer.WriteContentChunk(text); err != nil {
+			slog.ErrorContext(ctx, "[StreamProcessor] Failed to write content chunk", "error", err)
+			return fmt.Errorf("failed to write content chunk: %w", err)
+		}
+		aligner.recordContent(text)
+		answer.WriteString(text)
+		return nil
+	}
 
 	// Process chunks in a loop
 	for {
@@ -130,9 +350,10 @@ func (sp *StreamProcessor) ProcessStream(ctx context.Context, reader services.St
 		select {
 		case <-streamCtx.Done():
 			if streamCtx.Err() == context.DeadlineExceeded {
-				log.Printf("[StreamProcessor] Stream timeout exceeded")
+				slog.ErrorContext(ctx, "[StreamProcessor] Stream timeout exceeded", "timeout", sp.streamTimeout)
+				outcome = metrics.OutcomeTimeout
 				if err := writer.WriteErrorChunk(services.ErrCodeTimeout, "Stream timed out"); err != nil {
-					log.Printf("[StreamProcessor] Failed to write timeout error: %v", err)
+					slog.WarnContext(ctx, "[StreamProcessor] Failed to write timeout error", "error", err)
 				}
 				return &services.DomainError{
 					Code:      services.ErrCodeTimeout,
@@ -141,26 +362,29 @@ func (sp *StreamProcessor) ProcessStream(ctx context.Context, reader services.St
 					Cause:     streamCtx.Err(),
 				}
 			}
+			outcome = metrics.OutcomeCanceled
 			return streamCtx.Err()
 		default:
 		}
 
-		// Read next chunk with timeout
+		// Read next event with timeout
 		chunkCtx, chunkCancel := context.WithTimeout(streamCtx, sp.chunkTimeout)
 		
-		chunk, done, err := sp.readChunkWithTimeout(chunkCtx, reader)
+		event, err := sp.nextEvent(chunkCtx, reader)
 		chunkCancel()
 
 		// Handle errors
 		if err != nil {
 			// Check if it's a timeout waiting for chunk
 			if errors.Is(err, context.DeadlineExceeded) {
-				log.Printf("[StreamProcessor] Chunk timeout - no data received within %v", sp.chunkTimeout)
+				slog.WarnContext(ctx, "[StreamProcessor] Chunk timeout - no data received", "timeout", sp.chunkTimeout)
+				outcome = metrics.OutcomeTimeout
 				
 				// If we've received some content, treat as stalled stream
 				if receivedContent {
+					outcome = metrics.OutcomeStalled
 					if writeErr := writer.WriteErrorChunk(services.ErrCodeTimeout, "Stream stalled"); writeErr != nil {
-						log.Printf("[StreamProcessor] Failed to write stall error: %v", writeErr)
+						slog.WarnContext(ctx, "[StreamProcessor] Failed to write stall error", "error", writeErr)
 					}
 					return &services.DomainError{
 						Code:      services.ErrCodeTimeout,
@@ -175,91 +399,253 @@ func (sp *StreamProcessor) ProcessStream(ctx context.Context, reader services.St
 			var domainErr *services.DomainError
 			if errors.As(err, &domainErr) {
 				if domainErr.Code == services.ErrCodeMalformedStream {
-					log.Printf("[StreamProcessor] Malformed stream chunk: %v", err)
+					slog.WarnContext(ctx, "[StreamProcessor] Malformed stream chunk", "error", err)
 					// Try to continue processing - don't fail the entire stream
 					continue
 				}
 			}
 
+			// A retryable failure, such as throttling, resumes the answer on
+			// a new stream
+			if reopen != nil && sp.resumePolicy != ResumeOff && resumes < sp.maxResumes && isResumable(err) {
+				resumes++
+				partial := ""
+				if sp.resumePolicy == ResumeContinue {
+					// The continuation picks up after all the text the
+					// middlewares have let through
+					if err := writeContent(pipeline.flush()); err != nil {
+						return err
+					}
+					partial = answer.String()
+				}
+				slog.WarnContext(ctx, "[StreamProcessor] Resuming interrupted stream", "policy", sp.resumePolicy, "resume", resumes, "error", err)
+
+				next, continued, openErr := reopen(streamCtx, partial)
+				if openErr == nil {
+					if err := reader.Close(); err != nil {
+						slog.WarnContext(ctx, "[StreamProcessor] Error closing stream", "error", err)
+					}
+					reader = next
+
+					resumed := ResumeContinue
+					if !continued {
+						resumed = ResumeRestart
+						if receivedContent || len(aggregator.entries) > 0 {
+							if err := writer.WriteRestartChunk(); err != nil {
+								slog.ErrorContext(ctx, "[StreamProcessor] Failed to write restart chunk", "error", err)
+								return fmt.Errorf("failed to write restart chunk: %w", err)
+							}
+						}
+						receivedContent = false
+						answer.Reset()
+						aligner = newCitationAligner(sp.inlineMarkers)
+						aggregator = newCitationAggregator(sp.maxCitations)
+						pipeline = newStreamPipeline(sp.middlewares)
+					}
+					sp.metrics.IncStreamResume(string(resumed))
+					span.AddEvent("stream_resumed", trace.WithAttributes(
+						attribute.String("policy", string(resumed)),
+						tracing.AttrRequestID.String(reader.RequestID()),
+					))
+					continue
+				}
+				slog.ErrorContext(ctx, "[StreamProcessor] Failed to resume stream", "error", openErr)
+				err = openErr
+			}
+
 			// For other errors, write error chunk and return
-			log.Printf("[StreamProcessor] Stream read error: %v", err)
-			if writeErr := writer.WriteErrorChunk(services.ErrCodeServiceError, "Error reading stream"); writeErr != nil {
-				log.Printf("[StreamProcessor] Failed to write error chunk: %v", writeErr)
+			slog.ErrorContext(ctx, "[StreamProcessor] Stream read error", "error", err)
+			code, message := services.ErrCodeServiceError, "Error reading stream"
+			if errors.As(err, &domainErr) && domainErr.Code == services.ErrCodeInternal {
+				code, message = domainErr.Code, domainErr.Message
+			}
+			if writeErr := writer.WriteErrorChunk(code, message); writeErr != nil {
+				slog.WarnContext(ctx, "[StreamProcessor] Failed to write error chunk", "error", writeErr)
 			}
 			return err
 		}
 
-		// If done, break the loop
-		if done {
-			log.Printf("[StreamProcessor] Stream completed successfully")
+		// If done, write what the middlewares held back and break the loop
+		if event.Type == services.StreamEventDone {
+			if err := writeContent(pipeline.flush()); err != nil {
+				return err
+			}
+			slog.InfoContext(ctx, "[StreamProcessor] Stream completed successfully")
 			break
 		}
 
-		// Process the chunk
-		if chunk != "" {
-			receivedContent = true
-			if err := writer.WriteContentChunk(chunk); err != nil {
-				log.Printf("[StreamProcessor] Failed to write content chunk: %v", err)
-				return fmt.Errorf("failed to write content chunk: %w", err)
+		switch event.Type {
+		case services.StreamEventContent:
+			if err := ValidateChunk(event.Content); err != nil {
+				// The utf8 and null_bytes middlewares repair such chunks
+				sp.metrics.IncMalformedChunk()
+				slog.WarnContext(ctx, "[StreamProcessor] Chunk failed validation", "error", err, "middlewares", len(sp.middlewares))
+			}
+			aligner.recordGenerated(event.Content)
+			if err := writeContent(pipeline.filter(event.Content)); err != nil {
+				return err
 			}
-		}
-
-		// Check for citations
-		citation, err := reader.ReadCitation()
-		if err != nil {
-			log.Printf("[StreamProcessor] Error reading citation: %v", err)
-			// Don't fail the stream for citation errors, just log
-			continue
-		}
 
-		if citation != nil {
-			citationChunk := CitationChunk{
-				SourceID:   citation.SourceID,
-				SourceName: citation.SourceName,
-				Excerpt:    citation.Excerpt,
-				Confidence: citation.Confidence,
-				URL:        citation.URL,
-				Metadata:   citation.Metadata,
+		case services.StreamEventCitation:
+			// Bedrock cites text it has finished generating, so the text the
+			// middlewares hold back is written first; markers then follow the
+			// text they cite and spans are mapped onto the filtered text
+			if err := writeContent(pipeline.flush()); err != nil {
+				return err
 			}
+			aligner.sync()
+			sp.forwardCitations(streamCtx, event.Citations, writer, aligner, aggregator)
 
-			if err := writer.WriteCitationChunk(citationChunk); err != nil {
-				log.Printf("[StreamProcessor] Failed to write citation chunk: %v", err)
-				// Don't fail the stream for citation write errors
+		case services.StreamEventGuardrail:
+			// A guardrail ends the answer; what was written before it stays
+			if err := writeContent(pipeline.flush()); err != nil {
+				return err
+			}
+			intervention := entities.GuardrailIntervention{}
+			if event.Guardrail != nil {
+				intervention = *event.Guardrail
 			}
+			slog.WarnContext(ctx, "[StreamProcessor] Guardrail intervened", "stage", intervention.Stage, "categories", intervention.Categories)
+			span.AddEvent("guardrail_intervened", trace.WithAttributes(
+				attribute.String("stage", intervention.Stage),
+				attribute.StringSlice("categories", intervention.Categories),
+			))
+			outcome = metrics.OutcomeBlocked
+			if writeErr := writer.WriteGuardrailChunk(intervention); writeErr != nil {
+				slog.WarnContext(ctx, "[StreamProcessor] Failed to write guardrail chunk", "error", writeErr)
+			}
+			return guardrailBlockedError(intervention, reader.RequestID())
+
+		case services.StreamEventTrace, services.StreamEventToolCall, services.StreamEventMetadata:
+			// Nothing to forward to the client; keep them on the trace
+			slog.DebugContext(ctx, "[StreamProcessor] Stream event", "type", event.Type, "metadata", event.Metadata)
+			attrs := []attribute.KeyValue{attribute.String("type", string(event.Type))}
+			if event.ToolCall != nil {
+				attrs = append(attrs, attribute.String("tool", event.ToolCall.Name))
+			}
+			span.AddEvent("stream_event", trace.WithAttributes(attrs...))
+
+		default:
+			// Newer kinds of event are skipped until they are supported
+			slog.DebugContext(ctx, "[StreamProcessor] Skipping unknown stream event", "type", event.Type)
+		}
+	}
+
+	// Send the deduplicated, ranked citation list
+	if len(aggregator.entries) > 0 {
+		if err := writer.WriteCitationListChunk(aggregator.consolidated()); err != nil {
+			slog.WarnContext(ctx, "[StreamProcessor] Failed to write citation list", "error", err)
 		}
 	}
 
 	// Send done signal
 	if err := writer.WriteDoneChunk(); err != nil {
-		log.Printf("[StreamProcessor] Failed to write done chunk: %v", err)
+		slog.ErrorContext(ctx, "[StreamProcessor] Failed to write done chunk", "error", err)
 		return fmt.Errorf("failed to write done chunk: %w", err)
 	}
 
+	outcome = metrics.OutcomeSuccess
 	return nil
 }
 
-// readChunkWithTimeout reads a chunk with a timeout
-func (sp *StreamProcessor) readChunkWithTimeout(ctx context.Context, reader services.StreamReader) (string, bool, error) {
-	type result struct {
-		chunk string
-		done  bool
-		err   error
+// isResumable reports whether a stream error leaves the answer worth
+// resuming on a new stream: throttling or Bedrock being briefly unavailable
+func isResumable(err error) bool {
+	var domainErr *services.DomainError
+	if !errors.As(err, &domainErr) || !domainErr.Retryable {
+		return false
 	}
+	return domainErr.Code == services.ErrCodeRateLimit || domainErr.Code == services.ErrCodeServiceError
+}
 
-	resultChan := make(chan result, 1)
+// forwardCitations numbers a citation event's citations and writes them with
+// the span of text they support. Repeated citations of a passage are only
+// recorded with the aggregator. When inline markers are enabled, the markers
+// for the event are written as content first. Write errors are logged but
+// never fail the stream.
+func (sp *StreamProcessor) forwardCitations(ctx context.Context, citations []entities.Citation, writer ChunkWriter, aligner *citationAligner, aggregator *citationAggregator) {
+	var chunks []CitationChunk
+	var markerNumbers []int
+	seen := make(map[int]bool)
+
+	for i := range citations {
+		citation := &citations[i]
+		number := aligner.number(citation)
+		span := aligner.span(citation)
+		if !seen[number] {
+			seen[number] = true
+			markerNumbers = append(markerNumbers, number)
+		}
+
+		chunk := CitationChunk{
+			SourceID:   citation.SourceID,
+			SourceName: citation.SourceName,
+			SourceType: citation.SourceType,
+			Excerpt:    citation.Excerpt,
+			Confidence: citation.Confidence,
+			URL:        sp.resolveURL(ctx, citation.URL),
+			Metadata:   citation.Metadata,
+			Number:     number,
+			Span:       &span,
+		}
+		if aggregator.add(chunk) {
+			chunks = append(chunks, chunk)
+		}
+	}
 
-	// Read in a goroutine
-	go func() {
-		chunk, done, err := reader.Read()
-		resultChan <- result{chunk: chunk, done: done, err: err}
+	if aligner.inlineMarkers && len(markerNumbers) > 0 {
+		if err := writer.WriteContentChunk(aligner.marker(markerNumbers)); err != nil {
+			slog.WarnContext(ctx, "[StreamProcessor] Failed to write citation marker", "error", err)
+		}
+	}
+
+	for _, citationChunk := range chunks {
+		if err := writer.WriteCitationChunk(citationChunk); err != nil {
+			slog.WarnContext(ctx, "[StreamProcessor] Failed to write citation chunk", "error", err)
+		}
+	}
+}
+
+// resolveURL rewrites a citation URL with the configured resolver. A URL
+// that cannot be resolved is dropped rather than exposed raw.
+func (sp *StreamProcessor) resolveURL(ctx context.Context, sourceURL string) string {
+	if sp.urlResolver == nil || sourceURL == "" {
+		return sourceURL
+	}
+
+	resolved, err := sp.urlResolver.ResolveURL(ctx, sourceURL)
+	if err != nil {
+		slog.WarnContext(ctx, "[StreamProcessor] Failed to resolve citation URL", "error", err)
+		return ""
+	}
+	return resolved
+}
+
+// nextEvent reads the next event, giving up when ctx is done. The reader
+// stops waiting itself, so a timed out read leaves nothing behind.
+func (sp *StreamProcessor) nextEvent(ctx context.Context, reader services.StreamReader) (event services.StreamEvent, err error) {
+	// A reader that panics fails its stream as an INTERNAL_ERROR, so the
+	// answer so far is still recorded and the reader is closed.
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			slog.ErrorContext(ctx, "[StreamProcessor] Recovered from panic in stream reader",
+				"panic", recovered,
+				"stack", string(debug.Stack()),
+			)
+			event, err = services.StreamEvent{}, internalError(fmt.Errorf("stream reader panicked: %v", recovered))
+		}
 	}()
 
-	// Wait for result or timeout
-	select {
-	case <-ctx.Done():
-		return "", false, ctx.Err()
-	case res := <-resultChan:
-		return res.chunk, res.done, res.err
+	return reader.Next(ctx)
+}
+
+// internalError wraps a failure in our own code, such as a recovered panic
+func internalError(cause error) *services.DomainError {
+	return &services.DomainError{
+		Code:      services.ErrCodeInternal,
+		Message:   "An unexpected error occurred",
+		Retryable: false,
+		Cause:     cause,
 	}
 }
 
@@ -102,7 +102,7 @@ func testStreamErrorLogging(t *testing.T) {
 	logOutput := logBuffer.String()
 
 	// Verify error logging
-	if !strings.Contains(logOutput, "[StreamProcessor] Stream read error:") {
+	if !strings.Contains(logOutput, "[StreamProcessor] Stream read error") {
 		t.Error("Log should contain stream read error entry")
 	}
 	if !strings.Contains(logOutput, "Test stream error") {
@@ -189,7 +189,7 @@ func testResourceCleanupLogging(t *testing.T) {
 	logOutput := logBuffer.String()
 
 	// Verify cleanup error logging
-	if !strings.Contains(logOutput, "[StreamProcessor] Error closing stream:") {
+	if !strings.Contains(logOutput, "[StreamProcessor] Error closing stream") {
 		t.Error("Log should contain stream close error entry")
 	}
 	if !strings.Contains(logOutput, "Failed to close stream") {
@@ -210,36 +210,42 @@ type loggingMockStreamReader struct {
 	closeError  error
 }
 
-func (m *loggingMockStreamReader) Read() (string, bool, error) {
+func (m *loggingMockStreamReader) Next(ctx context.Context) (services.StreamEvent, error) {
 	if m.shouldError && m.currentIdx > 0 {
-		return "", false, errors.New(m.errorMsg)
+		return services.StreamEvent{}, errors.New(m.errorMsg)
 	}
 
 	if m.hangAfter > 0 && m.currentIdx >= m.hangAfter {
-		// Simulate hanging by sleeping longer than timeout
-		time.Sleep(200 * time.Millisecond)
+		// Simulate hanging for longer than the timeout
+		select {
+		case <-ctx.Done():
+			return services.StreamEvent{}, ctx.Err()
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+
+	// Citations follow the first chunk, or end a stream without any
+	if len(m.citations) > 0 && (m.currentIdx > 0 || len(m.chunks) == 0) {
+		return services.StreamEvent{Type: services.StreamEventCitation, Citations: drainCitations(&m.citations)}, nil
 	}
 
 	if m.currentIdx >= len(m.chunks) {
-		return "", true, nil
+		return services.StreamEvent{Type: services.StreamEventDone}, nil
 	}
 
 	chunk := m.chunks[m.currentIdx]
 	m.currentIdx++
-	return chunk, false, nil
-}
-
-func (m *loggingMockStreamReader) ReadCitation() (*entities.Citation, error) {
-	if len(m.citations) > 0 && m.currentIdx <= len(m.citations) {
-		return m.citations[0], nil
-	}
-	return nil, nil
+	return services.StreamEvent{Type: services.StreamEventContent, Content: chunk}, nil
 }
 
 func (m *loggingMockStreamReader) Close() error {
 	return m.closeError
 }
 
+func (m *loggingMockStreamReader) RequestID() string {
+	return ""
+}
+
 // testChunkWriter for testing
 type testChunkWriter struct {
 	contentChunks  []string
@@ -263,11 +269,23 @@ func (w *testChunkWriter) WriteCitationChunk(citation CitationChunk) error {
 	return nil
 }
 
+func (w *testChunkWriter) WriteCitationListChunk(citations []CitationChunk) error {
+	return nil
+}
+
 func (w *testChunkWriter) WriteErrorChunk(code, message string) error {
 	w.errorChunks = append(w.errorChunks, errorChunk{code: code, message: message})
 	return nil
 }
 
+func (w *testChunkWriter) WriteGuardrailChunk(intervention entities.GuardrailIntervention) error {
+	return nil
+}
+
+func (w *testChunkWriter) WriteRestartChunk() error {
+	return nil
+}
+
 func (w *testChunkWriter) WriteDoneChunk() error {
 	w.doneReceived = true
 	return nil
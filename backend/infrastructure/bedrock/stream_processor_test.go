@@ -3,6 +3,8 @@ package bedrock
 import (
 	"context"
 	"errors"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -20,15 +22,21 @@ type mockStreamReader struct {
 	hangAfter int // Hang after this many reads (-1 = never hang)
 }
 
-func (m *mockStreamReader) Read() (string, bool, error) {
+func (m *mockStreamReader) Next(ctx context.Context) (services.StreamEvent, error) {
 	// Check if we should hang
 	if m.hangAfter >= 0 && m.index >= m.hangAfter {
-		// Simulate hanging by blocking forever
-		select {}
+		// Simulate hanging by blocking until the read is given up
+		<-ctx.Done()
+		return services.StreamEvent{}, ctx.Err()
+	}
+
+	// Citations follow the first chunk, or end a stream without any
+	if len(m.citations) > 0 && (m.index > 0 || len(m.chunks) == 0) {
+		return services.StreamEvent{Type: services.StreamEventCitation, Citations: drainCitations(&m.citations)}, nil
 	}
 
 	if m.index >= len(m.chunks) {
-		return "", true, nil
+		return services.StreamEvent{Type: services.StreamEventDone}, nil
 	}
 
 	chunk := m.chunks[m.index]
@@ -39,19 +47,20 @@ func (m *mockStreamReader) Read() (string, bool, error) {
 	m.index++
 
 	if err != nil {
-		return "", false, err
+		return services.StreamEvent{}, err
 	}
 
-	return chunk, false, nil
+	return services.StreamEvent{Type: services.StreamEventContent, Content: chunk}, nil
 }
 
-func (m *mockStreamReader) ReadCitation() (*entities.Citation, error) {
-	if len(m.citations) == 0 {
-		return nil, nil
+// drainCitations empties a mock's pending citations into a citation event's
+func drainCitations(pending *[]*entities.Citation) []entities.Citation {
+	citations := make([]entities.Citation, 0, len(*pending))
+	for _, citation := range *pending {
+		citations = append(citations, *citation)
 	}
-	citation := m.citations[0]
-	m.citations = m.citations[1:]
-	return citation, nil
+	*pending = nil
+	return citations
 }
 
 func (m *mockStreamReader) Close() error {
@@ -59,11 +68,18 @@ func (m *mockStreamReader) Close() error {
 	return nil
 }
 
+func (m *mockStreamReader) RequestID() string {
+	return ""
+}
+
 // mockChunkWriter implements ChunkWriter for testing
 type mockChunkWriter struct {
 	contentChunks  []string
 	citationChunks []CitationChunk
+	citationList   []CitationChunk
 	errorChunks    []struct{ code, message string }
+	guardrails     []entities.GuardrailIntervention
+	restarts       int
 	doneWritten    bool
 }
 
@@ -77,11 +93,26 @@ func (m *mockChunkWriter) WriteCitationChunk(citation CitationChunk) error {
 	return nil
 }
 
+func (m *mockChunkWriter) WriteCitationListChunk(citations []CitationChunk) error {
+	m.citationList = citations
+	return nil
+}
+
 func (m *mockChunkWriter) WriteErrorChunk(code, message string) error {
 	m.errorChunks = append(m.errorChunks, struct{ code, message string }{code, message})
 	return nil
 }
 
+func (m *mockChunkWriter) WriteGuardrailChunk(intervention entities.GuardrailIntervention) error {
+	m.guardrails = append(m.guardrails, intervention)
+	return nil
+}
+
+func (m *mockChunkWriter) WriteRestartChunk() error {
+	m.restarts++
+	return nil
+}
+
 func (m *mockChunkWriter) WriteDoneChunk() error {
 	m.doneWritten = true
 	return nil
@@ -181,6 +212,143 @@ func TestStreamProcessor_ProcessStream_WithCitations(t *testing.T) {
 	}
 }
 
+// eventStreamReader replays a fixed sequence of events, then ends
+type eventStreamReader struct {
+	mockStreamReader
+	events []services.StreamEvent
+}
+
+func (m *eventStreamReader) Next(ctx context.Context) (services.StreamEvent, error) {
+	if len(m.events) == 0 {
+		return services.StreamEvent{Type: services.StreamEventDone}, nil
+	}
+	event := m.events[0]
+	m.events = m.events[1:]
+	return event, nil
+}
+
+func TestStreamProcessor_ProcessStream_Events(t *testing.T) {
+	leave := entities.Citation{SourceID: "s3://docs/leave.pdf", SourceName: "leave.pdf", ResponseText: "20 days."}
+
+	tests := []struct {
+		name          string
+		events        []services.StreamEvent
+		wantContent   string
+		wantCitations int
+	}{
+		{
+			name: "citation after the final chunk",
+			events: []services.StreamEvent{
+				{Type: services.StreamEventContent, Content: "Leave is 20 days."},
+				{Type: services.StreamEventCitation, Citations: []entities.Citation{leave}},
+			},
+			wantContent:   "Leave is 20 days.",
+			wantCitations: 1,
+		},
+		{
+			name: "citation before any text",
+			events: []services.StreamEvent{
+				{Type: services.StreamEventCitation, Citations: []entities.Citation{leave}},
+				{Type: services.StreamEventContent, Content: "Leave is 20 days."},
+			},
+			wantContent:   "Leave is 20 days.",
+			wantCitations: 1,
+		},
+		{
+			name: "events without content are skipped",
+			events: []services.StreamEvent{
+				{Type: services.StreamEventTrace, Metadata: map[string]interface{}{"step": "orchestration"}},
+				{Type: services.StreamEventToolCall, ToolCall: &services.ToolCall{ID: "call-1", Name: "hr.get_leave_balance"}},
+				{Type: services.StreamEventContent, Content: "Leave is 20 days."},
+				{Type: services.StreamEventMetadata, Metadata: map[string]interface{}{"stop_reason": "end_turn"}},
+				{Type: "future_kind"},
+			},
+			wantContent: "Leave is 20 days.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := &eventStreamReader{events: tt.events}
+			writer := &mockChunkWriter{}
+
+			processor := NewStreamProcessor(DefaultStreamProcessorConfig())
+			if err := processor.ProcessStream(context.Background(), reader, writer); err != nil {
+				t.Fatalf("ProcessStream() error = %v", err)
+			}
+
+			if content := strings.Join(writer.contentChunks, ""); content != tt.wantContent {
+				t.Errorf("Expected content %q, got %q", tt.wantContent, content)
+			}
+			if len(writer.citationChunks) != tt.wantCitations || len(writer.citationList) != tt.wantCitations {
+				t.Errorf("Expected %d citations streamed and listed, got %d and %d", tt.wantCitations, len(writer.citationChunks), len(writer.citationList))
+			}
+			if !writer.doneWritten {
+				t.Error("Expected done chunk to be written")
+			}
+		})
+	}
+}
+
+// mockURLResolver implements services.CitationURLResolver for testing
+type mockURLResolver struct {
+	urls map[string]string
+	err  error
+}
+
+func (m *mockURLResolver) ResolveURL(ctx context.Context, sourceURL string) (string, error) {
+	if m.err != nil {
+		return "", m.err
+	}
+	return m.urls[sourceURL], nil
+}
+
+func TestStreamProcessor_ProcessStream_ResolvesCitationURLs(t *testing.T) {
+	tests := []struct {
+		name     string
+		resolver *mockURLResolver
+		wantURL  string
+	}{
+		{
+			name:     "resolved URL replaces source URL",
+			resolver: &mockURLResolver{urls: map[string]string{"s3://company-docs/leave.pdf": "https://company-docs.s3.amazonaws.com/leave.pdf?X-Amz-Signature=abc"}},
+			wantURL:  "https://company-docs.s3.amazonaws.com/leave.pdf?X-Amz-Signature=abc",
+		},
+		{
+			name:     "resolver failure drops the URL",
+			resolver: &mockURLResolver{err: errors.New("presign failed")},
+			wantURL:  "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := &mockStreamReader{
+				chunks:    []string{"Leave is 20 days."},
+				citations: []*entities.Citation{{SourceID: "s3://company-docs/leave.pdf", URL: "s3://company-docs/leave.pdf"}},
+				hangAfter: -1,
+			}
+			writer := &mockChunkWriter{}
+
+			config := DefaultStreamProcessorConfig()
+			config.URLResolver = tt.resolver
+			if err := NewStreamProcessor(config).ProcessStream(context.Background(), reader, writer); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			if len(writer.citationChunks) != 1 {
+				t.Fatalf("Expected 1 citation chunk, got %d", len(writer.citationChunks))
+			}
+			if got := writer.citationChunks[0].URL; got != tt.wantURL {
+				t.Errorf("Expected URL %q, got %q", tt.wantURL, got)
+			}
+			if got := writer.citationChunks[0].SourceID; got != "s3://company-docs/leave.pdf" {
+				t.Errorf("Expected source ID to keep the S3 URI, got %q", got)
+			}
+		})
+	}
+}
+
 func TestStreamProcessor_ProcessStream_MalformedChunk(t *testing.T) {
 	// Create reader that returns a malformed stream error
 	malformedErr := &services.DomainError{
@@ -289,6 +457,41 @@ func TestStreamProcessor_ProcessStream_ChunkTimeout(t *testing.T) {
 	}
 }
 
+// blockingStreamReader never sends a chunk and counts the reads still waiting
+type blockingStreamReader struct {
+	mockStreamReader
+	waiting atomic.Int32
+}
+
+func (m *blockingStreamReader) Next(ctx context.Context) (services.StreamEvent, error) {
+	m.waiting.Add(1)
+	defer m.waiting.Add(-1)
+	<-ctx.Done()
+	return services.StreamEvent{}, ctx.Err()
+}
+
+func TestStreamProcessor_ProcessStream_ChunkTimeoutStopsRead(t *testing.T) {
+	reader := &blockingStreamReader{}
+	writer := &mockChunkWriter{}
+
+	processor := NewStreamProcessor(StreamProcessorConfig{
+		StreamTimeout: time.Second,
+		ChunkTimeout:  20 * time.Millisecond,
+	})
+	err := processor.ProcessStream(context.Background(), reader, writer)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected chunk timeout, got %v", err)
+	}
+	// The timed out read has returned, rather than being left blocked
+	if waiting := reader.waiting.Load(); waiting != 0 {
+		t.Errorf("Expected no reads left waiting, got %d", waiting)
+	}
+	if !reader.closed {
+		t.Error("Expected reader to be closed")
+	}
+}
+
 func TestStreamProcessor_ProcessStream_ContextCancellation(t *testing.T) {
 	reader := &mockStreamReader{
 		chunks:    []string{"Chunk 1", "Chunk 2", "Chunk 3"},
@@ -381,6 +584,116 @@ func TestStreamProcessor_ProcessStream_NonRetryableError(t *testing.T) {
 	}
 }
 
+// panickingStreamReader panics on its first read
+type panickingStreamReader struct {
+	mockStreamReader
+}
+
+func (m *panickingStreamReader) Next(ctx context.Context) (services.StreamEvent, error) {
+	panic("reader bug")
+}
+
+func TestStreamProcessor_ProcessStream_ReaderPanic(t *testing.T) {
+	reader := &panickingStreamReader{}
+	writer := &mockChunkWriter{}
+
+	processor := NewStreamProcessor(DefaultStreamProcessorConfig())
+	err := processor.ProcessStream(context.Background(), reader, writer)
+
+	// The panic fails the stream rather than the process
+	if err == nil || !strings.Contains(err.Error(), "reader bug") {
+		t.Fatalf("Expected reader panic as an error, got %v", err)
+	}
+	var domainErr *services.DomainError
+	if !errors.As(err, &domainErr) || domainErr.Code != services.ErrCodeInternal || domainErr.Retryable {
+		t.Errorf("Expected a non-retryable %s error, got %v", services.ErrCodeInternal, err)
+	}
+	if len(writer.errorChunks) != 1 || writer.errorChunks[0].code != services.ErrCodeInternal {
+		t.Errorf("Expected one %s error chunk, got %+v", services.ErrCodeInternal, writer.errorChunks)
+	}
+	if !reader.closed {
+		t.Error("Expected reader to be closed")
+	}
+}
+
+func TestStreamProcessor_ProcessResumableStream(t *testing.T) {
+	throttled := &services.DomainError{Code: services.ErrCodeRateLimit, Message: "Rate limit exceeded", Retryable: true}
+	broken := &services.DomainError{Code: services.ErrCodeServiceError, Message: "Service error", Retryable: false}
+
+	tests := []struct {
+		name   string
+		policy ResumePolicy
+		// firstErr interrupts the first stream after "Hello "
+		firstErr error
+		// continues is whether the opener can continue an answer
+		continues bool
+		// resumedErr interrupts the reopened stream after its first chunk
+		resumedErr   error
+		wantErr      bool
+		wantContent  []string
+		wantRestarts int
+		wantPartial  string
+		wantOpens    int
+	}{
+		{name: "off", policy: ResumeOff, firstErr: throttled, wantErr: true, wantContent: []string{"Hello "}},
+		{name: "restart", policy: ResumeRestart, firstErr: throttled, continues: true, wantContent: []string{"Hello ", "Hi ", "there"}, wantRestarts: 1, wantOpens: 1},
+		{name: "continue", policy: ResumeContinue, firstErr: throttled, continues: true, wantContent: []string{"Hello ", "Hi ", "there"}, wantPartial: "Hello ", wantOpens: 1},
+		{name: "continue falls back to restart", policy: ResumeContinue, firstErr: throttled, wantContent: []string{"Hello ", "Hi ", "there"}, wantRestarts: 1, wantPartial: "Hello ", wantOpens: 1},
+		{name: "non-retryable error", policy: ResumeRestart, firstErr: broken, wantErr: true, wantContent: []string{"Hello "}},
+		{name: "resumes exhausted", policy: ResumeRestart, firstErr: throttled, resumedErr: throttled, wantErr: true, wantContent: []string{"Hello ", "Hi "}, wantRestarts: 1, wantOpens: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := &mockStreamReader{
+				chunks:    []string{"Hello ", "", "world"},
+				errors:    []error{nil, tt.firstErr},
+				hangAfter: -1,
+			}
+			resumed := &mockStreamReader{
+				chunks:    []string{"Hi ", "", "there"},
+				errors:    []error{nil, tt.resumedErr},
+				hangAfter: -1,
+			}
+
+			opens := 0
+			partial := ""
+			reopen := func(ctx context.Context, p string) (services.StreamReader, bool, error) {
+				opens++
+				partial = p
+				return resumed, p != "" && tt.continues, nil
+			}
+
+			config := DefaultStreamProcessorConfig()
+			config.ResumePolicy = tt.policy
+			writer := &mockChunkWriter{}
+			err := NewStreamProcessor(config).ProcessResumableStream(context.Background(), reader, reopen, writer)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %t, got %v", tt.wantErr, err)
+			}
+			if strings.Join(writer.contentChunks, "|") != strings.Join(tt.wantContent, "|") {
+				t.Errorf("Expected content %q, got %q", tt.wantContent, writer.contentChunks)
+			}
+			if writer.restarts != tt.wantRestarts {
+				t.Errorf("Expected %d restart chunks, got %d", tt.wantRestarts, writer.restarts)
+			}
+			if opens != tt.wantOpens {
+				t.Errorf("Expected %d reopened streams, got %d", tt.wantOpens, opens)
+			}
+			if partial != tt.wantPartial {
+				t.Errorf("Expected partial answer %q, got %q", tt.wantPartial, partial)
+			}
+			if writer.doneWritten == tt.wantErr {
+				t.Errorf("Expected done chunk %t, got %t", !tt.wantErr, writer.doneWritten)
+			}
+			if !reader.closed || (tt.wantOpens > 0 && !resumed.closed) {
+				t.Error("Expected every stream to be closed")
+			}
+		})
+	}
+}
+
 func TestValidateChunk(t *testing.T) {
 	tests := []struct {
 		name      string
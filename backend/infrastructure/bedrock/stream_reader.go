@@ -2,23 +2,26 @@ package bedrock
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
-	"log"
+	"fmt"
+	"log/slog"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/types"
+	"github.com/aws/smithy-go"
 
 	"github.com/bedrock-chat-poc/backend/domain/entities"
 	"github.com/bedrock-chat-poc/backend/domain/services"
+	"github.com/bedrock-chat-poc/backend/infrastructure/logging"
 )
 
 // streamReader implements the StreamReader interface for Bedrock event streams
 type streamReader struct {
 	ctx       context.Context
 	stream    *bedrockagentruntime.InvokeAgentEventStream
-	buffer    []string
-	citations []entities.Citation
+	pending   []services.StreamEvent
 	done      bool
 	requestID string
 	eventChan <-chan types.ResponseStream
@@ -29,105 +32,166 @@ func newStreamReader(ctx context.Context, stream *bedrockagentruntime.InvokeAgen
 	return &streamReader{
 		ctx:       ctx,
 		stream:    stream,
-		buffer:    make([]string, 0),
-		citations: make([]entities.Citation, 0),
 		done:      false,
 		requestID: requestID,
 		eventChan: stream.Events(),
 	}
 }
 
-// Read returns the next chunk of content, a done flag, and any error
-func (sr *streamReader) Read() (chunk string, done bool, err error) {
-	// Check if already done
-	if sr.done {
-		return "", true, nil
-	}
+// Next returns the next event of the stream. It waits for the next Bedrock
+// event until ctx is done; events without anything to report are skipped in
+// a loop, so long bursts of them don't grow the stack.
+func (sr *streamReader) Next(ctx context.Context) (services.StreamEvent, error) {
+	for {
+		// Events split from one Bedrock event, such as a chunk's citations,
+		// come first
+		if len(sr.pending) > 0 {
+			event := sr.pending[0]
+			sr.pending = sr.pending[1:]
+			return event, nil
+		}
 
-	// Check context cancellation
-	select {
-	case <-sr.ctx.Done():
-		sr.done = true
-		return "", true, sr.ctx.Err()
-	default:
-	}
+		if sr.done {
+			return services.StreamEvent{Type: services.StreamEventDone}, nil
+		}
 
-	// If we have buffered content, return it
-	if len(sr.buffer) > 0 {
-		chunk = sr.buffer[0]
-		sr.buffer = sr.buffer[1:]
-		return chunk, false, nil
-	}
+		// Wait for the next event, the end of the stream or cancellation
+		var event types.ResponseStream
+		var ok bool
+		select {
+		case <-sr.ctx.Done():
+			sr.done = true
+			return services.StreamEvent{}, sr.ctx.Err()
+		case <-ctx.Done():
+			return services.StreamEvent{}, ctx.Err()
+		case event, ok = <-sr.eventChan:
+		}
 
-	// Read next event from stream
-	event, ok := <-sr.eventChan
-	if !ok {
-		// Channel closed, check for errors
-		if err := sr.stream.Err(); err != nil {
+		if !ok {
+			// Channel closed, check for errors
 			sr.done = true
-			log.Printf("[Bedrock] Stream error - RequestID: %s, Error: %v", sr.requestID, err)
-			return "", true, sr.transformStreamError(err)
+			if err := sr.stream.Err(); err != nil {
+				slog.ErrorContext(sr.ctx, "[Bedrock] Stream error", logging.KeyRequestID, sr.requestID, "error", err)
+				return services.StreamEvent{}, transformStreamError(err, sr.requestID)
+			}
+			slog.InfoContext(sr.ctx, "[Bedrock] Stream completed", logging.KeyRequestID, sr.requestID)
+			return services.StreamEvent{Type: services.StreamEventDone}, nil
 		}
-		
-		sr.done = true
-		log.Printf("[Bedrock] Stream completed - RequestID: %s", sr.requestID)
-		return "", true, nil
-	}
 
-	// Process event
-	switch e := event.(type) {
-	case *types.ResponseStreamMemberChunk:
-		// Extract text content
-		if e.Value.Bytes != nil {
-			content := string(e.Value.Bytes)
-			log.Printf("[Bedrock] Stream chunk received - Length: %d, RequestID: %s", len(content), sr.requestID)
-			
-			// Store citations for later retrieval
-			if e.Value.Attribution != nil && e.Value.Attribution.Citations != nil {
+		// Process event
+		switch e := event.(type) {
+		case *types.ResponseStreamMemberChunk:
+			// Citations follow the text they support
+			if e.Value.Bytes != nil {
+				content := string(e.Value.Bytes)
+				slog.DebugContext(sr.ctx, "[Bedrock] Stream chunk received", "length", len(content), logging.KeyRequestID, sr.requestID)
+				sr.pending = append(sr.pending, services.StreamEvent{Type: services.StreamEventContent, Content: content})
+			}
+			if e.Value.Attribution != nil && len(e.Value.Attribution.Citations) > 0 {
+				var citations []entities.Citation
 				for _, citation := range e.Value.Attribution.Citations {
-					sr.citations = append(sr.citations, convertCitation(citation))
+					citations = append(citations, convertCitation(citation)...)
+				}
+				if len(citations) > 0 {
+					sr.pending = append(sr.pending, services.StreamEvent{Type: services.StreamEventCitation, Citations: citations})
 				}
 			}
 
-			return content, false, nil
-		}
-
-	case *types.ResponseStreamMemberTrace:
-		// Log trace information for debugging
-		log.Printf("[Bedrock] Trace event received - RequestID: %s", sr.requestID)
-		// Continue to next event
-		return sr.Read()
+		case *types.ResponseStreamMemberTrace:
+			if guardrail, ok := e.Value.Trace.(*types.TraceMemberGuardrailTrace); ok && guardrail.Value.Action == types.GuardrailActionIntervened {
+				intervention := agentGuardrailIntervention(guardrail.Value)
+				slog.WarnContext(sr.ctx, "[Bedrock] Guardrail intervened",
+					"stage", intervention.Stage, "categories", intervention.Categories, logging.KeyRequestID, sr.requestID)
+				return guardrailEvent(intervention), nil
+			}
+			// Log trace information for debugging
+			slog.DebugContext(sr.ctx, "[Bedrock] Trace event received", logging.KeyRequestID, sr.requestID)
+			return services.StreamEvent{
+				Type:     services.StreamEventTrace,
+				Metadata: map[string]interface{}{"step": traceStep(e.Value.Trace)},
+			}, nil
+
+		case *types.ResponseStreamMemberReturnControl:
+			// The agent hands an action group call back to the caller
+			for _, call := range convertReturnControl(e.Value) {
+				sr.pending = append(sr.pending, services.StreamEvent{Type: services.StreamEventToolCall, ToolCall: call})
+			}
 
-	default:
-		log.Printf("[Bedrock] Unknown event type: %T - RequestID: %s", e, sr.requestID)
-		// Continue to next event
-		return sr.Read()
+		default:
+			slog.DebugContext(sr.ctx, "[Bedrock] Unknown event type", "type", fmt.Sprintf("%T", e), logging.KeyRequestID, sr.requestID)
+		}
 	}
-
-	// No content in this event, read next
-	return sr.Read()
 }
 
-// ReadCitation returns the next citation if available
-func (sr *streamReader) ReadCitation() (*entities.Citation, error) {
-	if len(sr.citations) == 0 {
-		return nil, nil
-	}
-
-	citation := sr.citations[0]
-	sr.citations = sr.citations[1:]
-	return &citation, nil
+// RequestID returns the AWS request ID of the InvokeAgent call
+func (sr *streamReader) RequestID() string {
+	return sr.requestID
 }
 
 // Close closes the stream reader
 func (sr *streamReader) Close() error {
 	sr.done = true
-	log.Printf("[Bedrock] Stream reader closed - RequestID: %s", sr.requestID)
-	return nil
+	sr.pending = nil
+	slog.DebugContext(sr.ctx, "[Bedrock] Stream reader closed", logging.KeyRequestID, sr.requestID)
+	return sr.stream.Close()
+}
+
+// traceStep names the agent step a trace describes
+func traceStep(trace types.Trace) string {
+	switch trace.(type) {
+	case *types.TraceMemberPreProcessingTrace:
+		return "pre_processing"
+	case *types.TraceMemberOrchestrationTrace:
+		return "orchestration"
+	case *types.TraceMemberPostProcessingTrace:
+		return "post_processing"
+	case *types.TraceMemberCustomOrchestrationTrace:
+		return "custom_orchestration"
+	case *types.TraceMemberRoutingClassifierTrace:
+		return "routing_classifier"
+	case *types.TraceMemberGuardrailTrace:
+		return "guardrail"
+	case *types.TraceMemberFailureTrace:
+		return "failure"
+	default:
+		return "unknown"
+	}
+}
+
+// convertReturnControl converts the action group calls an agent returns to
+// the caller into tool calls. Their input is the call's parameters as a
+// JSON object.
+func convertReturnControl(payload types.ReturnControlPayload) []*services.ToolCall {
+	calls := make([]*services.ToolCall, 0, len(payload.InvocationInputs))
+	for _, input := range payload.InvocationInputs {
+		params := map[string]string{}
+		var name string
+		switch in := input.(type) {
+		case *types.InvocationInputMemberMemberFunctionInvocationInput:
+			name = aws.ToString(in.Value.ActionGroup) + "." + aws.ToString(in.Value.Function)
+			for _, p := range in.Value.Parameters {
+				params[aws.ToString(p.Name)] = aws.ToString(p.Value)
+			}
+		case *types.InvocationInputMemberMemberApiInvocationInput:
+			name = aws.ToString(in.Value.ActionGroup) + "." + aws.ToString(in.Value.HttpMethod) + " " + aws.ToString(in.Value.ApiPath)
+			for _, p := range in.Value.Parameters {
+				params[aws.ToString(p.Name)] = aws.ToString(p.Value)
+			}
+		default:
+			continue
+		}
+		inputJSON, _ := json.Marshal(params)
+		calls = append(calls, &services.ToolCall{
+			ID:    aws.ToString(payload.InvocationId),
+			Name:  name,
+			Input: string(inputJSON),
+		})
+	}
+	return calls
 }
 
 // transformStreamError transforms streaming errors to domain errors
-func (sr *streamReader) transformStreamError(err error) error {
+func transformStreamError(err error, requestID string) error {
 	if err == nil {
 		return nil
 	}
@@ -139,6 +203,7 @@ func (sr *streamReader) transformStreamError(err error) error {
 			Message:   "Stream timed out",
 			Retryable: true,
 			Cause:     err,
+			RequestID: requestID,
 		}
 	}
 
@@ -148,46 +213,26 @@ func (sr *streamReader) transformStreamError(err error) error {
 			Message:   "Stream canceled",
 			Retryable: false,
 			Cause:     err,
+			RequestID: requestID,
 		}
 	}
 
+	// AWS exceptions sent in the stream, such as throttling, keep their code;
+	// the retry rules say whether the answer can be resumed
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		domainErr := classifyError(err, requestID)
+		domainErr.Retryable = isRetryable(err)
+		domainErr.RequestID = requestID
+		return domainErr
+	}
+
 	// Generic stream error
 	return &services.DomainError{
 		Code:      services.ErrCodeMalformedStream,
 		Message:   "Error reading from stream",
 		Retryable: false,
 		Cause:     err,
+		RequestID: requestID,
 	}
 }
-
-// convertCitation converts a Bedrock citation to domain citation
-func convertCitation(citation types.Citation) entities.Citation {
-	domainCitation := entities.Citation{
-		Metadata: make(map[string]interface{}),
-	}
-
-	if citation.GeneratedResponsePart != nil && citation.GeneratedResponsePart.TextResponsePart != nil {
-		domainCitation.Excerpt = aws.ToString(citation.GeneratedResponsePart.TextResponsePart.Text)
-	}
-
-	if len(citation.RetrievedReferences) > 0 {
-		ref := citation.RetrievedReferences[0]
-		
-		if ref.Content != nil && ref.Content.Text != nil {
-			domainCitation.SourceName = aws.ToString(ref.Content.Text)
-		}
-
-		if ref.Location != nil && ref.Location.S3Location != nil {
-			domainCitation.SourceID = aws.ToString(ref.Location.S3Location.Uri)
-			domainCitation.URL = aws.ToString(ref.Location.S3Location.Uri)
-		}
-
-		if ref.Metadata != nil {
-			for k, v := range ref.Metadata {
-				domainCitation.Metadata[k] = v
-			}
-		}
-	}
-
-	return domainCitation
-}
@@ -0,0 +1,253 @@
+package bedrock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/types"
+
+	"github.com/bedrock-chat-poc/backend/domain/entities"
+	"github.com/bedrock-chat-poc/backend/domain/services"
+)
+
+// mockAgentEventReader feeds events to an InvokeAgentEventStream. The channel
+// is left open so tests decide when, or whether, the stream ends.
+type mockAgentEventReader struct {
+	events chan types.ResponseStream
+	err    error
+	closed bool
+}
+
+func (r *mockAgentEventReader) Events() <-chan types.ResponseStream {
+	return r.events
+}
+
+func (r *mockAgentEventReader) Close() error {
+	r.closed = true
+	return nil
+}
+
+func (r *mockAgentEventReader) Err() error { return r.err }
+
+func newTestStreamReader(events chan types.ResponseStream) *streamReader {
+	stream := bedrockagentruntime.NewInvokeAgentEventStream(func(es *bedrockagentruntime.InvokeAgentEventStream) {
+		es.Reader = &mockAgentEventReader{events: events}
+	})
+	return newStreamReader(context.Background(), stream, "req-stream").(*streamReader)
+}
+
+// agentChunk is a chunk event with optional citations of the given sources
+func agentChunk(text string, sources ...string) *types.ResponseStreamMemberChunk {
+	part := types.PayloadPart{}
+	if text != "" {
+		part.Bytes = []byte(text)
+	}
+	if len(sources) > 0 {
+		refs := make([]types.RetrievedReference, 0, len(sources))
+		for _, source := range sources {
+			refs = append(refs, types.RetrievedReference{
+				Location: &types.RetrievalResultLocation{
+					S3Location: &types.RetrievalResultS3Location{Uri: aws.String(source)},
+				},
+			})
+		}
+		part.Attribution = &types.Attribution{Citations: []types.Citation{{RetrievedReferences: refs}}}
+	}
+	return &types.ResponseStreamMemberChunk{Value: part}
+}
+
+func TestStreamReader_Events(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []types.ResponseStream
+		// want lists each event's type, with its content, citation sources or
+		// tool name
+		want []string
+	}{
+		{
+			name:   "citations of the final chunk",
+			events: []types.ResponseStream{agentChunk("Leave is "), agentChunk("20 days.", "s3://docs/leave.pdf", "s3://docs/faq.pdf")},
+			want:   []string{"content:Leave is ", "content:20 days.", "citation:s3://docs/leave.pdf,s3://docs/faq.pdf", "done"},
+		},
+		{
+			name:   "citations without text",
+			events: []types.ResponseStream{agentChunk("Leave is 20 days."), agentChunk("", "s3://docs/leave.pdf")},
+			want:   []string{"content:Leave is 20 days.", "citation:s3://docs/leave.pdf", "done"},
+		},
+		{
+			name: "trace and tool call",
+			events: []types.ResponseStream{
+				&types.ResponseStreamMemberTrace{Value: types.TracePart{Trace: &types.TraceMemberOrchestrationTrace{}}},
+				&types.ResponseStreamMemberReturnControl{Value: types.ReturnControlPayload{
+					InvocationId: aws.String("call-1"),
+					InvocationInputs: []types.InvocationInputMember{
+						&types.InvocationInputMemberMemberFunctionInvocationInput{Value: types.FunctionInvocationInput{
+							ActionGroup: aws.String("hr"),
+							Function:    aws.String("get_leave_balance"),
+							Parameters:  []types.FunctionParameter{{Name: aws.String("employee"), Value: aws.String("42")}},
+						}},
+					},
+				}},
+			},
+			want: []string{"trace:orchestration", "tool_call:hr.get_leave_balance", "done"},
+		},
+		{
+			name: "guardrail intervention",
+			events: []types.ResponseStream{
+				&types.ResponseStreamMemberTrace{Value: types.TracePart{Trace: &types.TraceMemberGuardrailTrace{
+					Value: types.GuardrailTrace{Action: types.GuardrailActionNone},
+				}}},
+				&types.ResponseStreamMemberTrace{Value: types.TracePart{Trace: &types.TraceMemberGuardrailTrace{
+					Value: types.GuardrailTrace{
+						Action: types.GuardrailActionIntervened,
+						OutputAssessments: []types.GuardrailAssessment{{
+							TopicPolicy: &types.GuardrailTopicPolicyAssessment{Topics: []types.GuardrailTopic{
+								{Name: aws.String("Investment advice"), Action: types.GuardrailTopicPolicyActionBlocked},
+							}},
+						}},
+					},
+				}}},
+			},
+			want: []string{"trace:guardrail", "guardrail:output:topic:Investment advice", "done"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := make(chan types.ResponseStream, len(tt.events))
+			for _, event := range tt.events {
+				events <- event
+			}
+			close(events)
+			sr := newTestStreamReader(events)
+
+			var got []string
+			for len(got) < len(tt.want) {
+				event, err := sr.Next(context.Background())
+				if err != nil {
+					t.Fatalf("Next() error = %v", err)
+				}
+				got = append(got, describeEvent(event))
+				if event.Type == services.StreamEventDone {
+					break
+				}
+			}
+
+			if strings.Join(got, " | ") != strings.Join(tt.want, " | ") {
+				t.Errorf("Expected events %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+// describeEvent summarises a stream event for comparison
+func describeEvent(event services.StreamEvent) string {
+	switch event.Type {
+	case services.StreamEventContent:
+		return "content:" + event.Content
+	case services.StreamEventCitation:
+		sources := make([]string, 0, len(event.Citations))
+		for _, citation := range event.Citations {
+			sources = append(sources, citation.SourceID)
+		}
+		return "citation:" + strings.Join(sources, ",")
+	case services.StreamEventTrace:
+		return fmt.Sprintf("trace:%v", event.Metadata["step"])
+	case services.StreamEventToolCall:
+		return "tool_call:" + event.ToolCall.Name
+	case services.StreamEventGuardrail:
+		return "guardrail:" + event.Guardrail.Stage + ":" + strings.Join(event.Guardrail.Categories, ",")
+	default:
+		return string(event.Type)
+	}
+}
+
+func TestStreamReader_SkipsEventBurst(t *testing.T) {
+	// Agents can send long bursts of events without anything to report
+	// before any content
+	const bursts = 100000
+	events := make(chan types.ResponseStream, bursts+1)
+	for i := 0; i < bursts; i++ {
+		events <- &types.ResponseStreamMemberFiles{}
+	}
+	events <- agentChunk("Hello")
+	close(events)
+
+	sr := newTestStreamReader(events)
+
+	event, err := sr.Next(context.Background())
+	if err != nil || event.Type != services.StreamEventContent || event.Content != "Hello" {
+		t.Fatalf("Expected content after skipped events, got %+v err=%v", event, err)
+	}
+	if event, err := sr.Next(context.Background()); event.Type != services.StreamEventDone || err != nil {
+		t.Errorf("Expected end of stream, got %s event, err=%v", event.Type, err)
+	}
+}
+
+func TestStreamReader_ReadStopsWhenContextDone(t *testing.T) {
+	events := make(chan types.ResponseStream, 1)
+	sr := newTestStreamReader(events)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := sr.Next(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the read to give up, got %v", err)
+	}
+
+	// The stream is still open, so a later read gets the next event
+	events <- agentChunk("Late")
+	if event, err := sr.Next(context.Background()); err != nil || event.Content != "Late" {
+		t.Errorf("Expected the late chunk, got %+v err=%v", event, err)
+	}
+}
+
+func TestProcessInvokeResponse_Guardrail(t *testing.T) {
+	events := make(chan types.ResponseStream, 2)
+	events <- agentChunk("Sorry, ")
+	events <- &types.ResponseStreamMemberTrace{Value: types.TracePart{Trace: &types.TraceMemberGuardrailTrace{Value: types.GuardrailTrace{
+		Action: types.GuardrailActionIntervened,
+		OutputAssessments: []types.GuardrailAssessment{{
+			TopicPolicy: &types.GuardrailTopicPolicyAssessment{Topics: []types.GuardrailTopic{
+				{Name: aws.String("Investment advice"), Action: types.GuardrailTopicPolicyActionBlocked},
+			}},
+		}},
+	}}}}
+	close(events)
+	stream := bedrockagentruntime.NewInvokeAgentEventStream(func(es *bedrockagentruntime.InvokeAgentEventStream) {
+		es.Reader = &mockAgentEventReader{events: events}
+	})
+
+	_, err := (&Adapter{}).processInvokeResponse(context.Background(), stream, "req-invoke")
+
+	var domainErr *services.DomainError
+	if !errors.As(err, &domainErr) || domainErr.Code != services.ErrCodeGuardrailBlocked || domainErr.RequestID != "req-invoke" {
+		t.Fatalf("Expected %s error for req-invoke, got %v", services.ErrCodeGuardrailBlocked, err)
+	}
+	want := entities.GuardrailIntervention{Stage: entities.GuardrailStageOutput, Categories: []string{"topic:Investment advice"}}
+	if domainErr.Guardrail == nil || domainErr.Guardrail.Stage != want.Stage || strings.Join(domainErr.Guardrail.Categories, ",") != "topic:Investment advice" {
+		t.Errorf("Expected intervention %+v, got %+v", want, domainErr.Guardrail)
+	}
+}
+
+func TestStreamReader_CloseClosesStream(t *testing.T) {
+	events := make(chan types.ResponseStream)
+	mock := &mockAgentEventReader{events: events}
+	stream := bedrockagentruntime.NewInvokeAgentEventStream(func(es *bedrockagentruntime.InvokeAgentEventStream) {
+		es.Reader = mock
+	})
+	reader := newStreamReader(context.Background(), stream, "req-stream")
+
+	if err := reader.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	// The event stream holds the HTTP body and the SDK's decoding goroutine
+	if !mock.closed {
+		t.Error("Expected the event stream to be closed")
+	}
+}
@@ -53,7 +53,8 @@ func TestStreamingFunctionality_BasicStreaming(t *testing.T) {
 	startTime := time.Now()
 
 	for {
-		chunk, done, err := streamReader.Read()
+		event, err := streamReader.Next(ctx)
+		chunk, done := event.Content, event.Type == services.StreamEventDone
 		if done {
 			break
 		}
@@ -136,7 +137,8 @@ func TestStreamingFunctionality_StreamCompletion(t *testing.T) {
 			completed := false
 
 			for {
-				chunk, done, err := streamReader.Read()
+				event, err := streamReader.Next(ctx)
+				chunk, done := event.Content, event.Type == services.StreamEventDone
 				if done {
 					completed = true
 					break
@@ -209,7 +211,8 @@ func TestStreamingFunctionality_CitationProcessing(t *testing.T) {
 	chunkCount := 0
 
 	for {
-		chunk, done, err := streamReader.Read()
+		event, err := streamReader.Next(ctx)
+		chunk, done := event.Content, event.Type == services.StreamEventDone
 		if done {
 			break
 		}
@@ -223,11 +226,8 @@ func TestStreamingFunctionality_CitationProcessing(t *testing.T) {
 		}
 
 		// Check for citations
-		citation, err := streamReader.ReadCitation()
-		if err != nil {
-			t.Fatalf("Citation read error: %v", err)
-		}
-		if citation != nil {
+		for i := range event.Citations {
+			citation := &event.Citations[i]
 			citations = append(citations, citation)
 			t.Logf("Found citation: Excerpt=%q, SourceName=%q, URL=%q", 
 				citation.Excerpt, citation.SourceName, citation.URL)
@@ -287,7 +287,8 @@ func TestStreamingFunctionality_ResourceCleanup(t *testing.T) {
 	}
 
 	// Read one chunk
-	chunk, done, err := streamReader.Read()
+	event, err := streamReader.Next(ctx)
+	chunk, done := event.Content, event.Type == services.StreamEventDone
 	if err != nil {
 		t.Fatalf("Stream read error: %v", err)
 	}
@@ -301,7 +302,8 @@ func TestStreamingFunctionality_ResourceCleanup(t *testing.T) {
 	}
 
 	// Verify stream is marked as done after close
-	_, done, err = streamReader.Read()
+	event, err = streamReader.Next(ctx)
+	done = event.Type == services.StreamEventDone
 	if !done {
 		t.Error("Expected stream to be done after close")
 	}
@@ -455,7 +457,8 @@ func TestStreamingFunctionality_ContextCancellation(t *testing.T) {
 
 	// Try to read - should eventually be cancelled due to timeout
 	for {
-		_, done, err := streamReader.Read()
+		event, err := streamReader.Next(ctx)
+		done := event.Type == services.StreamEventDone
 		if done {
 			t.Logf("✓ Context cancellation test: stream completed before timeout")
 			return
@@ -0,0 +1,56 @@
+package bedrock
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/bedrock-chat-poc/backend/infrastructure/tracing"
+)
+
+// tracer starts the spans for Bedrock calls and stream processing
+var tracer = tracing.Tracer("github.com/bedrock-chat-poc/backend/infrastructure/bedrock")
+
+// startCall starts the span covering a Bedrock call and all its retries
+func startCall(ctx context.Context, operation string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "bedrock."+operation, trace.WithAttributes(attrs...))
+}
+
+// startAttempt starts the span for a single attempt of a Bedrock call
+func startAttempt(ctx context.Context, operation string, attempt int) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "bedrock."+operation+".attempt",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("rpc.system", "aws-api"),
+			attribute.String("rpc.method", operation),
+			attribute.Int("attempt", attempt),
+		),
+	)
+}
+
+// endAttempt ends an attempt span with the AWS request ID and, for a failed
+// attempt, the error code
+func endAttempt(span trace.Span, err error, requestID string) {
+	if requestID != "" {
+		span.SetAttributes(tracing.AttrRequestID.String(requestID))
+	}
+	if err != nil {
+		span.SetAttributes(attribute.String("error.code", errorCode(err)))
+	}
+	tracing.EndSpan(span, err)
+}
+
+// waitBackoff waits out the backoff before a retry in its own span. It
+// returns the context's error if ctx is done first.
+func waitBackoff(ctx context.Context, clock Clock, backoff time.Duration, attempt int) error {
+	_, span := tracer.Start(ctx, "bedrock.backoff", trace.WithAttributes(
+		attribute.Int("attempt", attempt),
+		attribute.Int64("backoff_ms", backoff.Milliseconds()),
+	))
+
+	err := clock.Sleep(ctx, backoff)
+	tracing.EndSpan(span, err)
+	return err
+}
@@ -0,0 +1,119 @@
+package bedrock
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/bedrock-chat-poc/backend/domain/services"
+)
+
+var (
+	spanExporter     *tracetest.InMemoryExporter
+	spanExporterOnce sync.Once
+)
+
+// recordSpans installs an in-memory span exporter as the global tracer
+// provider and clears any spans recorded so far. The global provider can only
+// be delegated to once, so every test shares the same exporter.
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	spanExporterOnce.Do(func() {
+		spanExporter = tracetest.NewInMemoryExporter()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(spanExporter)))
+	})
+	spanExporter.Reset()
+	return spanExporter
+}
+
+// spansNamed returns the recorded spans with the given name
+func spansNamed(spans tracetest.SpanStubs, name string) tracetest.SpanStubs {
+	var matched tracetest.SpanStubs
+	for _, span := range spans {
+		if span.Name == name {
+			matched = append(matched, span)
+		}
+	}
+	return matched
+}
+
+func TestRetryCall_Spans(t *testing.T) {
+	exporter := recordSpans(t)
+
+	calls := 0
+	client := &mockKnowledgeBaseClient{
+		retrieveFunc: func(ctx context.Context, input *bedrockagentruntime.RetrieveInput) (*bedrockagentruntime.RetrieveOutput, error) {
+			calls++
+			if calls == 1 {
+				return nil, awsResponseError("ThrottlingException", "req-throttled")
+			}
+			return &bedrockagentruntime.RetrieveOutput{}, nil
+		},
+	}
+	adapter := newKnowledgeBaseAdapter(client, "KB123", "arn:model", testKnowledgeBaseConfig())
+
+	if _, err := adapter.Retrieve(context.Background(), services.RetrieveInput{Query: "leave"}); err != nil {
+		t.Fatalf("Retrieve() error = %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	call := spansNamed(spans, "bedrock.Retrieve")
+	attempts := spansNamed(spans, "bedrock.Retrieve.attempt")
+	backoffs := spansNamed(spans, "bedrock.backoff")
+
+	if len(call) != 1 || len(attempts) != 2 || len(backoffs) != 1 {
+		t.Fatalf("Expected 1 call, 2 attempt and 1 backoff spans, got %d, %d and %d", len(call), len(attempts), len(backoffs))
+	}
+	for _, child := range append(attempts, backoffs...) {
+		if child.Parent.SpanID() != call[0].SpanContext.SpanID() {
+			t.Errorf("Expected %s to be a child of the call span", child.Name)
+		}
+	}
+
+	failed := attempts[0]
+	if failed.Status.Code != codes.Error {
+		t.Errorf("Expected failed attempt to have error status, got %v", failed.Status.Code)
+	}
+	attrs := make(map[string]string)
+	for _, attr := range failed.Attributes {
+		attrs[string(attr.Key)] = attr.Value.Emit()
+	}
+	if attrs["request_id"] != "req-throttled" || attrs["error.code"] != "ThrottlingException" || attrs["attempt"] != "0" {
+		t.Errorf("Unexpected failed attempt attributes: %v", attrs)
+	}
+	if attempts[1].Status.Code == codes.Error || call[0].Status.Code == codes.Error {
+		t.Error("Expected the retried call to succeed")
+	}
+}
+
+func TestStreamProcessor_Span(t *testing.T) {
+	exporter := recordSpans(t)
+
+	reader := &mockStreamReader{chunks: []string{"Hello", " world"}, hangAfter: -1}
+	if err := NewStreamProcessor(DefaultStreamProcessorConfig()).ProcessStream(context.Background(), reader, &mockChunkWriter{}); err != nil {
+		t.Fatalf("ProcessStream() error = %v", err)
+	}
+
+	spans := spansNamed(exporter.GetSpans(), "stream.process")
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 stream span, got %d", len(spans))
+	}
+
+	span := spans[0]
+	attrs := make(map[string]string)
+	for _, attr := range span.Attributes {
+		attrs[string(attr.Key)] = attr.Value.Emit()
+	}
+	if attrs["chunks"] != "2" || attrs["outcome"] != "success" {
+		t.Errorf("Unexpected stream span attributes: %v", attrs)
+	}
+	if len(span.Events) != 1 || span.Events[0].Name != "first_chunk" {
+		t.Errorf("Expected a first_chunk event, got %+v", span.Events)
+	}
+}
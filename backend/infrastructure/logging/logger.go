@@ -0,0 +1,149 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Standard attribute keys shared by every package that logs
+const (
+	KeySessionID = "session_id"
+	KeyTurnID    = "turn_id"
+	KeyRequestID = "request_id"
+	// KeyHTTPRequestID identifies an HTTP request; KeyRequestID is the AWS request ID
+	KeyHTTPRequestID = "http_request_id"
+	// KeyContent holds user or model text; it is redacted when configured
+	KeyContent = "content"
+	// KeyQuery holds knowledge base search text; it is redacted when configured
+	KeyQuery = "query"
+)
+
+// Config holds logger configuration
+type Config struct {
+	// Level is the minimum level logged: debug, info, warn or error
+	Level string
+	// Format is the output format: text or json
+	Format string
+	// RedactContent replaces message content and search queries with their length
+	RedactContent bool
+}
+
+// New creates a logger writing to w. Attributes added to a context with
+// WithAttrs are included in every record logged with that context.
+func New(cfg Config, w io.Writer) (*slog.Logger, error) {
+	level, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	if cfg.RedactContent {
+		opts.ReplaceAttr = redactContent
+	}
+
+	var handler slog.Handler
+	switch strings.ToLower(cfg.Format) {
+	case "", "text":
+		handler = slog.NewTextHandler(w, opts)
+	case "json":
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("invalid log format: %s (must be text or json)", cfg.Format)
+	}
+
+	return slog.New(&contextHandler{Handler: handler}), nil
+}
+
+// ParseLevel parses a configured log level name
+func ParseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return slog.LevelInfo, fmt.Errorf("invalid log level: %s (must be debug, info, warn, or error)", name)
+	}
+}
+
+// redactContent replaces content attributes with their length so logs
+// never carry what users asked or what the model answered
+func redactContent(groups []string, attr slog.Attr) slog.Attr {
+	if attr.Key != KeyContent && attr.Key != KeyQuery {
+		return attr
+	}
+	if attr.Value.Kind() != slog.KindString {
+		return attr
+	}
+	return slog.String(attr.Key, fmt.Sprintf("[redacted %d chars]", len(attr.Value.String())))
+}
+
+// attrsKey is the context key for logging attributes
+type attrsKey struct{}
+
+// WithAttrs returns a context whose log records carry attrs in addition to
+// any attributes already on ctx. An attribute replaces one with the same key.
+func WithAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	existing, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	combined := make([]slog.Attr, 0, len(existing)+len(attrs))
+	for _, attr := range existing {
+		if !hasKey(attrs, attr.Key) {
+			combined = append(combined, attr)
+		}
+	}
+	combined = append(combined, attrs...)
+	return context.WithValue(ctx, attrsKey{}, combined)
+}
+
+// hasKey reports whether attrs contains an attribute with the given key
+func hasKey(attrs []slog.Attr, key string) bool {
+	for _, attr := range attrs {
+		if attr.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// contextHandler adds the attributes stored on a record's context
+type contextHandler struct {
+	slog.Handler
+}
+
+// Handle adds context attributes to the record and passes it on. Attributes
+// logged explicitly take precedence over context attributes with the same key.
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	attrs, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	if len(attrs) == 0 {
+		return h.Handler.Handle(ctx, record)
+	}
+
+	logged := make([]slog.Attr, 0, record.NumAttrs())
+	record.Attrs(func(attr slog.Attr) bool {
+		logged = append(logged, attr)
+		return true
+	})
+	for _, attr := range attrs {
+		if !hasKey(logged, attr.Key) {
+			record.AddAttrs(attr)
+		}
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+// WithAttrs returns a handler that keeps adding context attributes
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup returns a handler that keeps adding context attributes
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
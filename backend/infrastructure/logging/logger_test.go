@@ -0,0 +1,128 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNew_JSONFormat(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(Config{Level: "info", Format: "json"}, &buf)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	logger.Info("[Chat] Processing message", KeySessionID, "session-123")
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Expected a JSON record, got %q: %v", buf.String(), err)
+	}
+	if record["msg"] != "[Chat] Processing message" || record[KeySessionID] != "session-123" {
+		t.Errorf("Unexpected record: %v", record)
+	}
+}
+
+func TestNew_LevelFiltering(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(Config{Level: "warn", Format: "text"}, &buf)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	logger.Info("hidden")
+	logger.Warn("shown")
+
+	output := buf.String()
+	if strings.Contains(output, "hidden") {
+		t.Errorf("Expected info record to be filtered at warn level, got %q", output)
+	}
+	if !strings.Contains(output, "shown") {
+		t.Errorf("Expected warn record to be logged, got %q", output)
+	}
+}
+
+func TestNew_RedactContent(t *testing.T) {
+	tests := []struct {
+		name   string
+		redact bool
+		want   string
+		absent string
+	}{
+		{name: "redacted", redact: true, want: "content=\"[redacted 14 chars]\"", absent: "my salary info"},
+		{name: "not redacted", redact: false, want: "content=\"my salary info\""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger, err := New(Config{Level: "debug", RedactContent: tt.redact}, &buf)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			logger.Debug("[Chat] Message content", KeyContent, "my salary info", KeySessionID, "session-123")
+
+			output := buf.String()
+			if !strings.Contains(output, tt.want) {
+				t.Errorf("Expected %q in %q", tt.want, output)
+			}
+			if tt.absent != "" && strings.Contains(output, tt.absent) {
+				t.Errorf("Expected %q to be redacted from %q", tt.absent, output)
+			}
+			if !strings.Contains(output, "session_id=session-123") {
+				t.Errorf("Expected other attributes to be kept, got %q", output)
+			}
+		})
+	}
+}
+
+func TestWithAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(Config{}, &buf)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	ctx := WithAttrs(context.Background(), slog.String(KeySessionID, "session-123"), slog.String(KeyTurnID, "turn-1"))
+	ctx = WithAttrs(ctx, slog.String(KeyTurnID, "turn-2"), slog.String(KeyRequestID, "req-context"))
+
+	logger.InfoContext(ctx, "[Bedrock] Stream completed", KeyRequestID, "req-explicit")
+
+	output := buf.String()
+	for _, want := range []string{"session_id=session-123", "turn_id=turn-2", "request_id=req-explicit"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in %q", want, output)
+		}
+	}
+	for _, unwanted := range []string{"turn-1", "req-context"} {
+		if strings.Contains(output, unwanted) {
+			t.Errorf("Expected %q to be replaced, got %q", unwanted, output)
+		}
+	}
+	if strings.Count(output, "request_id=") != 1 {
+		t.Errorf("Expected explicit attribute to take precedence over context, got %q", output)
+	}
+}
+
+func TestNew_InvalidConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{name: "invalid level", cfg: Config{Level: "verbose"}},
+		{name: "invalid format", cfg: Config{Format: "xml"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.cfg, &bytes.Buffer{}); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
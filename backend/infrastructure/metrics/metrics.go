@@ -0,0 +1,349 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "chat"
+
+// Outcome label values
+const (
+	OutcomeSuccess  = "success"
+	OutcomeError    = "error"
+	OutcomeTimeout  = "timeout"
+	OutcomeStalled  = "stalled"
+	OutcomeCanceled = "canceled"
+	OutcomeBlocked  = "blocked"
+)
+
+// Circuit breaker state label values
+const (
+	CircuitClosed   = "closed"
+	CircuitHalfOpen = "half_open"
+	CircuitOpen     = "open"
+)
+
+// circuitStates lists every circuit state, so exactly one is set per breaker
+var circuitStates = []string{CircuitClosed, CircuitHalfOpen, CircuitOpen}
+
+// Metrics holds the Prometheus collectors for the chat backend.
+// All methods are safe to call on a nil *Metrics, which records nothing, so
+// components can be built without metrics in tests or when disabled.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	bedrockAttempts *prometheus.CounterVec
+	bedrockErrors   *prometheus.CounterVec
+	bedrockRetries  *prometheus.CounterVec
+	bedrockLatency  *prometheus.HistogramVec
+
+	circuitState      *prometheus.GaugeVec
+	circuitRejections *prometheus.CounterVec
+
+	streamFirstChunk prometheus.Histogram
+	streamDuration   *prometheus.HistogramVec
+	streamChunks     prometheus.Histogram
+	streamStalls     prometheus.Counter
+	streamTimeouts   prometheus.Counter
+	streamResumes    *prometheus.CounterVec
+	streamMalformed  prometheus.Counter
+
+	wsConnections      prometheus.Gauge
+	wsConnectionsTotal prometheus.Counter
+	wsSlowConsumers    *prometheus.CounterVec
+	turns              *prometheus.CounterVec
+	turnDuration       *prometheus.HistogramVec
+
+	sessions        prometheus.Gauge
+	sessionsCreated prometheus.Counter
+	sessionsExpired prometheus.Counter
+}
+
+// latencyBuckets cover fast API calls through long model responses
+var latencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+// New creates the collectors and registers them, along with the Go runtime
+// and process collectors, on a dedicated registry
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+
+		bedrockAttempts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "bedrock_attempts_total",
+			Help:      "Bedrock API call attempts, including retries.",
+		}, []string{"operation", "outcome"}),
+		bedrockErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "bedrock_errors_total",
+			Help:      "Failed Bedrock API call attempts by error code.",
+		}, []string{"operation", "code"}),
+		bedrockRetries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "bedrock_retries_total",
+			Help:      "Bedrock API calls retried after a retryable error.",
+		}, []string{"operation"}),
+		bedrockLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "bedrock_attempt_duration_seconds",
+			Help:      "Duration of a single Bedrock API call attempt.",
+			Buckets:   latencyBuckets,
+		}, []string{"operation"}),
+
+		circuitState: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "bedrock_circuit_state",
+			Help:      "Bedrock circuit breaker state: 1 for the current state, 0 for the others.",
+		}, []string{"breaker", "state"}),
+		circuitRejections: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "bedrock_circuit_rejections_total",
+			Help:      "Bedrock calls failed fast by an open circuit breaker.",
+		}, []string{"breaker"}),
+
+		streamFirstChunk: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "stream_first_chunk_seconds",
+			Help:      "Time from the start of stream processing to the first content chunk.",
+			Buckets:   latencyBuckets,
+		}),
+		streamDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "stream_duration_seconds",
+			Help:      "Duration of stream processing by outcome.",
+			Buckets:   latencyBuckets,
+		}, []string{"outcome"}),
+		streamChunks: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "stream_chunks",
+			Help:      "Content chunks written per stream.",
+			Buckets:   prometheus.ExponentialBuckets(1, 2, 12),
+		}),
+		streamStalls: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "stream_stalls_total",
+			Help:      "Streams that stopped sending data after content had been received.",
+		}),
+		streamTimeouts: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "stream_timeouts_total",
+			Help:      "Streams that exceeded the overall stream timeout.",
+		}),
+		streamResumes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "stream_resumes_total",
+			Help:      "Streams reopened after failing part way with a retryable error, by how the answer resumed.",
+		}, []string{"policy"}),
+		streamMalformed: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "stream_malformed_chunks_total",
+			Help:      "Content chunks received with null bytes or invalid UTF-8.",
+		}),
+
+		wsConnections: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "websocket_connections",
+			Help:      "Open WebSocket connections.",
+		}),
+		wsConnectionsTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "websocket_connections_total",
+			Help:      "WebSocket connections accepted.",
+		}),
+		wsSlowConsumers: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "websocket_slow_consumers_total",
+			Help:      "Times a client fell behind an answer's stream, by action: coarsen batching or abort the answer.",
+		}, []string{"action"}),
+		turns: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "turns_total",
+			Help:      "Chat turns processed by mode and outcome.",
+		}, []string{"mode", "outcome"}),
+		turnDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "turn_duration_seconds",
+			Help:      "Duration of a chat turn from message received to response recorded.",
+			Buckets:   latencyBuckets,
+		}, []string{"mode"}),
+
+		sessions: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "sessions",
+			Help:      "Sessions currently stored.",
+		}),
+		sessionsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "sessions_created_total",
+			Help:      "Sessions created.",
+		}),
+		sessionsExpired: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "sessions_expired_total",
+			Help:      "Sessions removed after the inactivity timeout.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.bedrockAttempts, m.bedrockErrors, m.bedrockRetries, m.bedrockLatency,
+		m.circuitState, m.circuitRejections,
+		m.streamFirstChunk, m.streamDuration, m.streamChunks, m.streamStalls, m.streamTimeouts, m.streamResumes, m.streamMalformed,
+		m.wsConnections, m.wsConnectionsTotal, m.wsSlowConsumers, m.turns, m.turnDuration,
+		m.sessions, m.sessionsCreated, m.sessionsExpired,
+	)
+
+	return m
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// ObserveBedrockAttempt records one Bedrock API call attempt. An empty
+// errorCode means the attempt succeeded.
+func (m *Metrics) ObserveBedrockAttempt(operation string, duration time.Duration, errorCode string) {
+	if m == nil {
+		return
+	}
+	m.bedrockLatency.WithLabelValues(operation).Observe(duration.Seconds())
+	if errorCode == "" {
+		m.bedrockAttempts.WithLabelValues(operation, OutcomeSuccess).Inc()
+		return
+	}
+	m.bedrockAttempts.WithLabelValues(operation, OutcomeError).Inc()
+	m.bedrockErrors.WithLabelValues(operation, errorCode).Inc()
+}
+
+// IncBedrockRetry records a Bedrock API call being retried
+func (m *Metrics) IncBedrockRetry(operation string) {
+	if m == nil {
+		return
+	}
+	m.bedrockRetries.WithLabelValues(operation).Inc()
+}
+
+// SetCircuitState records a circuit breaker's current state
+func (m *Metrics) SetCircuitState(breaker, state string) {
+	if m == nil {
+		return
+	}
+	for _, s := range circuitStates {
+		value := 0.0
+		if s == state {
+			value = 1
+		}
+		m.circuitState.WithLabelValues(breaker, s).Set(value)
+	}
+}
+
+// IncCircuitRejection records a call failed fast by an open circuit breaker
+func (m *Metrics) IncCircuitRejection(breaker string) {
+	if m == nil {
+		return
+	}
+	m.circuitRejections.WithLabelValues(breaker).Inc()
+}
+
+// ObserveFirstChunk records the latency of a stream's first content chunk
+func (m *Metrics) ObserveFirstChunk(latency time.Duration) {
+	if m == nil {
+		return
+	}
+	m.streamFirstChunk.Observe(latency.Seconds())
+}
+
+// ObserveStream records a processed stream: its duration, the content chunks
+// written and how it ended. Stalls and timeouts are also counted separately.
+func (m *Metrics) ObserveStream(duration time.Duration, chunks int, outcome string) {
+	if m == nil {
+		return
+	}
+	m.streamDuration.WithLabelValues(outcome).Observe(duration.Seconds())
+	m.streamChunks.Observe(float64(chunks))
+	switch outcome {
+	case OutcomeStalled:
+		m.streamStalls.Inc()
+	case OutcomeTimeout:
+		m.streamTimeouts.Inc()
+	}
+}
+
+// IncStreamResume records a stream reopened after a retryable failure;
+// policy is how the answer resumed, "restart" or "continue"
+func (m *Metrics) IncStreamResume(policy string) {
+	if m == nil {
+		return
+	}
+	m.streamResumes.WithLabelValues(policy).Inc()
+}
+
+// IncMalformedChunk records a content chunk that failed validation
+func (m *Metrics) IncMalformedChunk() {
+	if m == nil {
+		return
+	}
+	m.streamMalformed.Inc()
+}
+
+// WebSocketOpened records an accepted WebSocket connection
+func (m *Metrics) WebSocketOpened() {
+	if m == nil {
+		return
+	}
+	m.wsConnections.Inc()
+	m.wsConnectionsTotal.Inc()
+}
+
+// WebSocketClosed records a WebSocket connection closing
+func (m *Metrics) WebSocketClosed() {
+	if m == nil {
+		return
+	}
+	m.wsConnections.Dec()
+}
+
+// IncSlowConsumer records a client falling behind an answer's stream; action
+// is "coarsen" when content is batched more coarsely, "abort" when the answer
+// is abandoned
+func (m *Metrics) IncSlowConsumer(action string) {
+	if m == nil {
+		return
+	}
+	m.wsSlowConsumers.WithLabelValues(action).Inc()
+}
+
+// ObserveTurn records a processed chat turn
+func (m *Metrics) ObserveTurn(mode, outcome string, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.turns.WithLabelValues(mode, outcome).Inc()
+	m.turnDuration.WithLabelValues(mode).Observe(duration.Seconds())
+}
+
+// SessionCreated records a new session and the resulting session count
+func (m *Metrics) SessionCreated(count int) {
+	if m == nil {
+		return
+	}
+	m.sessionsCreated.Inc()
+	m.sessions.Set(float64(count))
+}
+
+// SessionsRemoved records the session count after sessions were deleted or
+// expired; expired is the number removed by the inactivity timeout
+func (m *Metrics) SessionsRemoved(count, expired int) {
+	if m == nil {
+		return
+	}
+	m.sessionsExpired.Add(float64(expired))
+	m.sessions.Set(float64(count))
+}
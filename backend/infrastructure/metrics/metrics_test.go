@@ -0,0 +1,142 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics_NilIsNoOp(t *testing.T) {
+	var m *Metrics
+
+	// None of these may panic
+	m.ObserveBedrockAttempt("InvokeAgent", time.Second, "ThrottlingException")
+	m.IncBedrockRetry("InvokeAgent")
+	m.SetCircuitState("agent", CircuitOpen)
+	m.IncCircuitRejection("agent")
+	m.ObserveFirstChunk(time.Second)
+	m.ObserveStream(time.Second, 3, OutcomeSuccess)
+	m.IncStreamResume("restart")
+	m.IncMalformedChunk()
+	m.WebSocketOpened()
+	m.WebSocketClosed()
+	m.IncSlowConsumer("abort")
+	m.ObserveTurn("agent", OutcomeSuccess, time.Second)
+	m.SessionCreated(1)
+	m.SessionsRemoved(0, 1)
+}
+
+func TestMetrics_BedrockAttempts(t *testing.T) {
+	m := New()
+
+	m.ObserveBedrockAttempt("InvokeAgent", 100*time.Millisecond, "ThrottlingException")
+	m.IncBedrockRetry("InvokeAgent")
+	m.ObserveBedrockAttempt("InvokeAgent", 200*time.Millisecond, "")
+
+	if got := testutil.ToFloat64(m.bedrockAttempts.WithLabelValues("InvokeAgent", OutcomeError)); got != 1 {
+		t.Errorf("Expected 1 failed attempt, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.bedrockAttempts.WithLabelValues("InvokeAgent", OutcomeSuccess)); got != 1 {
+		t.Errorf("Expected 1 successful attempt, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.bedrockErrors.WithLabelValues("InvokeAgent", "ThrottlingException")); got != 1 {
+		t.Errorf("Expected 1 throttling error, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.bedrockRetries.WithLabelValues("InvokeAgent")); got != 1 {
+		t.Errorf("Expected 1 retry, got %v", got)
+	}
+}
+
+func TestMetrics_CircuitState(t *testing.T) {
+	m := New()
+
+	m.SetCircuitState("agent", CircuitOpen)
+	m.SetCircuitState("agent", CircuitHalfOpen)
+	m.IncCircuitRejection("agent")
+
+	for state, want := range map[string]float64{CircuitClosed: 0, CircuitHalfOpen: 1, CircuitOpen: 0} {
+		if got := testutil.ToFloat64(m.circuitState.WithLabelValues("agent", state)); got != want {
+			t.Errorf("Expected %s state %v, got %v", state, want, got)
+		}
+	}
+	if got := testutil.ToFloat64(m.circuitRejections.WithLabelValues("agent")); got != 1 {
+		t.Errorf("Expected 1 rejection, got %v", got)
+	}
+}
+
+func TestMetrics_StreamOutcomes(t *testing.T) {
+	m := New()
+
+	m.ObserveStream(time.Second, 5, OutcomeSuccess)
+	m.ObserveStream(time.Second, 2, OutcomeStalled)
+	m.ObserveStream(time.Minute, 0, OutcomeTimeout)
+
+	if got := testutil.ToFloat64(m.streamStalls); got != 1 {
+		t.Errorf("Expected 1 stall, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.streamTimeouts); got != 1 {
+		t.Errorf("Expected 1 timeout, got %v", got)
+	}
+
+	m.IncStreamResume("continue")
+	if got := testutil.ToFloat64(m.streamResumes.WithLabelValues("continue")); got != 1 {
+		t.Errorf("Expected 1 continued stream, got %v", got)
+	}
+
+	m.IncMalformedChunk()
+	if got := testutil.ToFloat64(m.streamMalformed); got != 1 {
+		t.Errorf("Expected 1 malformed chunk, got %v", got)
+	}
+}
+
+func TestMetrics_ConnectionsAndSessions(t *testing.T) {
+	m := New()
+
+	m.WebSocketOpened()
+	m.WebSocketOpened()
+	m.WebSocketClosed()
+	if got := testutil.ToFloat64(m.wsConnections); got != 1 {
+		t.Errorf("Expected 1 open connection, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.wsConnectionsTotal); got != 2 {
+		t.Errorf("Expected 2 connections accepted, got %v", got)
+	}
+
+	m.IncSlowConsumer("coarsen")
+	if got := testutil.ToFloat64(m.wsSlowConsumers.WithLabelValues("coarsen")); got != 1 {
+		t.Errorf("Expected 1 coarsened stream, got %v", got)
+	}
+
+	m.SessionCreated(1)
+	m.SessionCreated(2)
+	m.SessionCreated(3)
+	m.SessionsRemoved(1, 2)
+	if got := testutil.ToFloat64(m.sessions); got != 1 {
+		t.Errorf("Expected 1 session, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.sessionsExpired); got != 2 {
+		t.Errorf("Expected 2 expired sessions, got %v", got)
+	}
+}
+
+func TestMetrics_Handler(t *testing.T) {
+	m := New()
+	m.ObserveTurn("knowledge_base", OutcomeSuccess, time.Second)
+
+	recorder := httptest.NewRecorder()
+	m.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	body := recorder.Body.String()
+	for _, want := range []string{
+		`chat_turns_total{mode="knowledge_base",outcome="success"} 1`,
+		"chat_turn_duration_seconds_bucket",
+		"go_goroutines",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %q in metrics output", want)
+		}
+	}
+}
@@ -3,10 +3,14 @@ package repositories
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"sync"
 	"time"
 
 	"github.com/bedrock-chat-poc/backend/domain/entities"
+	"github.com/bedrock-chat-poc/backend/domain/services"
+	"github.com/bedrock-chat-poc/backend/infrastructure/logging"
+	"github.com/bedrock-chat-poc/backend/infrastructure/metrics"
 )
 
 const (
@@ -21,15 +25,30 @@ type MemorySessionRepository struct {
 	mu              sync.RWMutex
 	cleanupInterval time.Duration
 	stopCleanup     chan struct{}
+	metrics         *metrics.Metrics
+	// onRemove are called with each deleted or expired session's ID
+	onRemove []func(sessionID string)
+}
+
+// MemorySessionRepositoryConfig holds configuration for the in-memory repository
+type MemorySessionRepositoryConfig struct {
+	// Metrics records the session count and expirations; nil records nothing
+	Metrics *metrics.Metrics
 }
 
 // NewMemorySessionRepository creates a new in-memory session repository
 func NewMemorySessionRepository() *MemorySessionRepository {
+	return NewMemorySessionRepositoryWithConfig(MemorySessionRepositoryConfig{})
+}
+
+// NewMemorySessionRepositoryWithConfig creates a new in-memory session repository with custom configuration
+func NewMemorySessionRepositoryWithConfig(config MemorySessionRepositoryConfig) *MemorySessionRepository {
 	repo := &MemorySessionRepository{
 		sessions:        make(map[string]*entities.Session),
 		messageHistory:  make(map[string][]*entities.Message),
 		cleanupInterval: 5 * time.Minute, // Check for expired sessions every 5 minutes
 		stopCleanup:     make(chan struct{}),
+		metrics:         config.Metrics,
 	}
 	
 	// Start background cleanup goroutine
@@ -38,6 +57,24 @@ func NewMemorySessionRepository() *MemorySessionRepository {
 	return repo
 }
 
+// OnRemove registers fn to be called with the ID of each session that is
+// deleted or expires, so state kept elsewhere for the session can be dropped.
+// fn is called without the repository's lock held.
+func (r *MemorySessionRepository) OnRemove(fn func(sessionID string)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onRemove = append(r.onRemove, fn)
+}
+
+// notifyRemoved calls the OnRemove funcs for removed sessions
+func (r *MemorySessionRepository) notifyRemoved(listeners []func(string), ids ...string) {
+	for _, id := range ids {
+		for _, fn := range listeners {
+			fn(id)
+		}
+	}
+}
+
 // Close stops the background cleanup goroutine
 func (r *MemorySessionRepository) Close() {
 	close(r.stopCleanup)
@@ -53,6 +90,8 @@ func (r *MemorySessionRepository) Create(ctx context.Context, session *entities.
 	}
 
 	r.sessions[session.ID] = session
+	r.metrics.SessionCreated(len(r.sessions))
+	slog.DebugContext(ctx, "[Repository] Session created", logging.KeySessionID, session.ID)
 	return nil
 }
 
@@ -98,14 +137,19 @@ func (r *MemorySessionRepository) Update(ctx context.Context, session *entities.
 // Delete removes a session and its message history
 func (r *MemorySessionRepository) Delete(ctx context.Context, id string) error {
 	r.mu.Lock()
-	defer r.mu.Unlock()
-
 	if _, exists := r.sessions[id]; !exists {
+		r.mu.Unlock()
 		return fmt.Errorf("session %s not found", id)
 	}
 
 	delete(r.sessions, id)
 	delete(r.messageHistory, id)
+	r.metrics.SessionsRemoved(len(r.sessions), 0)
+	listeners := r.onRemove
+	r.mu.Unlock()
+
+	r.notifyRemoved(listeners, id)
+	slog.DebugContext(ctx, "[Repository] Session deleted", logging.KeySessionID, id)
 	return nil
 }
 
@@ -122,8 +166,10 @@ func (r *MemorySessionRepository) AddMessage(ctx context.Context, message *entit
 	// Add message to history
 	r.messageHistory[message.SessionID] = append(r.messageHistory[message.SessionID], message)
 
-	// Update session metadata
-	session.MessageCount++
+	// Update session metadata; the count tracks messages the user has sent
+	if message.Role == entities.RoleUser {
+		session.MessageCount++
+	}
 	session.LastMessageAt = &message.Timestamp
 
 	return nil
@@ -158,6 +204,18 @@ func (r *MemorySessionRepository) IsExpired(session *entities.Session) bool {
 	return time.Since(lastActivity) > SessionTimeout
 }
 
+// CheckHealth reports the repository as reachable once it can take its read
+// lock, along with the number of stored sessions
+func (r *MemorySessionRepository) CheckHealth(ctx context.Context) services.ComponentHealth {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return services.ComponentHealth{
+		Status:  services.HealthUp,
+		Details: map[string]interface{}{"sessions": len(r.sessions)},
+	}
+}
+
 // cleanupExpiredSessions runs periodically to remove expired sessions
 func (r *MemorySessionRepository) cleanupExpiredSessions() {
 	ticker := time.NewTicker(r.cleanupInterval)
@@ -176,7 +234,6 @@ func (r *MemorySessionRepository) cleanupExpiredSessions() {
 // removeExpiredSessions removes all sessions that have exceeded the timeout
 func (r *MemorySessionRepository) removeExpiredSessions() {
 	r.mu.Lock()
-	defer r.mu.Unlock()
 
 	expiredIDs := []string{}
 	for id, session := range r.sessions {
@@ -188,5 +245,18 @@ func (r *MemorySessionRepository) removeExpiredSessions() {
 	for _, id := range expiredIDs {
 		delete(r.sessions, id)
 		delete(r.messageHistory, id)
+		slog.Debug("[Repository] Expired session removed", logging.KeySessionID, id)
+	}
+
+	remaining := len(r.sessions)
+	if len(expiredIDs) > 0 {
+		r.metrics.SessionsRemoved(remaining, len(expiredIDs))
+	}
+	listeners := r.onRemove
+	r.mu.Unlock()
+
+	if len(expiredIDs) > 0 {
+		r.notifyRemoved(listeners, expiredIDs...)
+		slog.Info("[Repository] Expired sessions cleaned up", "removed", len(expiredIDs), "remaining", remaining)
 	}
 }
@@ -3,10 +3,14 @@ package repositories
 import (
 	"context"
 	"fmt"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/bedrock-chat-poc/backend/domain/entities"
+	"github.com/bedrock-chat-poc/backend/domain/services"
+	"github.com/bedrock-chat-poc/backend/infrastructure/metrics"
 )
 
 func TestMemorySessionRepository_Create(t *testing.T) {
@@ -500,3 +504,92 @@ func TestMemorySessionRepository_CleanupExpiredSessions(t *testing.T) {
 		t.Errorf("Expected active session to exist: %v", err)
 	}
 }
+
+func TestMemorySessionRepository_OnRemove(t *testing.T) {
+	repo := NewMemorySessionRepository()
+	defer repo.Close()
+	ctx := context.Background()
+
+	var removed []string
+	repo.OnRemove(func(sessionID string) {
+		// Called without the lock held, so the repository is usable here
+		if _, err := repo.FindByID(ctx, sessionID); err == nil {
+			t.Errorf("Expected session %s to be gone", sessionID)
+		}
+		removed = append(removed, sessionID)
+	})
+
+	expiredTime := time.Now().Add(-31 * time.Minute)
+	for _, session := range []*entities.Session{
+		{ID: "deleted", CreatedAt: time.Now()},
+		{ID: "expired", CreatedAt: expiredTime, LastMessageAt: &expiredTime, MessageCount: 1},
+		{ID: "active", CreatedAt: time.Now()},
+	} {
+		if err := repo.Create(ctx, session); err != nil {
+			t.Fatalf("Failed to create session: %v", err)
+		}
+	}
+
+	if err := repo.Delete(ctx, "deleted"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	repo.removeExpiredSessions()
+
+	if strings.Join(removed, ",") != "deleted,expired" {
+		t.Errorf("Expected deleted and expired sessions to be reported, got %v", removed)
+	}
+}
+
+func TestMemorySessionRepository_Metrics(t *testing.T) {
+	m := metrics.New()
+	repo := NewMemorySessionRepositoryWithConfig(MemorySessionRepositoryConfig{Metrics: m})
+	defer repo.Close()
+	ctx := context.Background()
+
+	expiredTime := time.Now().Add(-31 * time.Minute)
+	for _, session := range []*entities.Session{
+		{ID: "expired", CreatedAt: expiredTime},
+		{ID: "active", CreatedAt: time.Now()},
+		{ID: "deleted", CreatedAt: time.Now()},
+	} {
+		if err := repo.Create(ctx, session); err != nil {
+			t.Fatalf("Failed to create session: %v", err)
+		}
+	}
+	if err := repo.Delete(ctx, "deleted"); err != nil {
+		t.Fatalf("Failed to delete session: %v", err)
+	}
+	repo.removeExpiredSessions()
+
+	recorder := httptest.NewRecorder()
+	m.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	output := recorder.Body.String()
+
+	for _, want := range []string{
+		"chat_sessions 1",
+		"chat_sessions_created_total 3",
+		"chat_sessions_expired_total 1",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in metrics output", want)
+		}
+	}
+}
+
+func TestMemorySessionRepository_CheckHealth(t *testing.T) {
+	repo := NewMemorySessionRepository()
+	defer repo.Close()
+	ctx := context.Background()
+
+	if err := repo.Create(ctx, &entities.Session{ID: "session-1", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	health := repo.CheckHealth(ctx)
+	if health.Status != services.HealthUp {
+		t.Errorf("Expected status up, got %q", health.Status)
+	}
+	if health.Details["sessions"] != 1 {
+		t.Errorf("Expected 1 session in details, got %v", health.Details["sessions"])
+	}
+}
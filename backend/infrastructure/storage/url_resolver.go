@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// MaxPresignExpiry is the longest lifetime SigV4 allows for a presigned URL
+const MaxPresignExpiry = 7 * 24 * time.Hour
+
+// PassthroughResolver implements CitationURLResolver by returning source URLs unchanged
+type PassthroughResolver struct{}
+
+// NewPassthroughResolver creates a resolver that leaves URLs untouched
+func NewPassthroughResolver() *PassthroughResolver {
+	return &PassthroughResolver{}
+}
+
+// ResolveURL returns the source URL unchanged
+func (r *PassthroughResolver) ResolveURL(ctx context.Context, sourceURL string) (string, error) {
+	return sourceURL, nil
+}
+
+// Presigner interface for testing
+type Presigner interface {
+	PresignGetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error)
+}
+
+// S3PresignConfig holds configuration for S3 presigned citation URLs
+type S3PresignConfig struct {
+	// Expiry is how long presigned URLs remain valid
+	Expiry time.Duration
+	// AllowedBuckets lists the buckets that may be linked; s3:// sources in
+	// any other bucket are not linked at all
+	AllowedBuckets []string
+	// Endpoint overrides the S3 endpoint, e.g. for a local S3-compatible store
+	Endpoint string
+	// UsePathStyle addresses buckets as path segments instead of subdomains
+	UsePathStyle bool
+}
+
+// DefaultS3PresignConfig returns the default presign configuration
+func DefaultS3PresignConfig() S3PresignConfig {
+	return S3PresignConfig{
+		Expiry: 15 * time.Minute,
+	}
+}
+
+// S3PresignResolver implements CitationURLResolver by replacing s3:// URIs
+// with presigned HTTPS GET URLs. Other URLs are returned unchanged.
+type S3PresignResolver struct {
+	presigner      Presigner
+	expiry         time.Duration
+	allowedBuckets map[string]bool
+}
+
+// NewS3PresignResolver creates a new S3 presign resolver
+func NewS3PresignResolver(ctx context.Context, cfg S3PresignConfig) (*S3PresignResolver, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	// Load AWS configuration using IAM roles
+	awsCfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+		o.UsePathStyle = cfg.UsePathStyle
+	})
+
+	return newS3PresignResolver(s3.NewPresignClient(client), cfg), nil
+}
+
+// newS3PresignResolver creates a presign resolver around an existing presigner
+func newS3PresignResolver(presigner Presigner, cfg S3PresignConfig) *S3PresignResolver {
+	allowed := make(map[string]bool, len(cfg.AllowedBuckets))
+	for _, bucket := range cfg.AllowedBuckets {
+		allowed[bucket] = true
+	}
+
+	return &S3PresignResolver{
+		presigner:      presigner,
+		expiry:         cfg.Expiry,
+		allowedBuckets: allowed,
+	}
+}
+
+// validate validates the presign configuration
+func (c S3PresignConfig) validate() error {
+	if c.Expiry <= 0 || c.Expiry > MaxPresignExpiry {
+		return fmt.Errorf("presign expiry must be between 1s and %v", MaxPresignExpiry)
+	}
+	if len(c.AllowedBuckets) == 0 {
+		return fmt.Errorf("at least one allowed bucket is required")
+	}
+	return nil
+}
+
+// ResolveURL presigns s3:// URIs in allowed buckets. URIs in other buckets
+// resolve to an empty URL so raw bucket paths never reach the client.
+func (r *S3PresignResolver) ResolveURL(ctx context.Context, sourceURL string) (string, error) {
+	bucket, key, ok := parseS3URI(sourceURL)
+	if !ok {
+		return sourceURL, nil
+	}
+
+	if !r.allowedBuckets[bucket] {
+		slog.WarnContext(ctx, "[Storage] Citation bucket not allowed, dropping URL", "bucket", bucket)
+		return "", nil
+	}
+
+	request, err := r.presigner.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(r.expiry))
+	if err != nil {
+		return "", fmt.Errorf("failed to presign s3://%s/%s: %w", bucket, key, err)
+	}
+
+	return request.URL, nil
+}
+
+// parseS3URI splits an s3://bucket/key URI into its bucket and object key.
+// Keys are taken verbatim, as S3 URIs are not percent-encoded.
+func parseS3URI(uri string) (bucket, key string, ok bool) {
+	rest, found := strings.CutPrefix(uri, "s3://")
+	if !found {
+		return "", "", false
+	}
+
+	bucket, key, found = strings.Cut(rest, "/")
+	if !found || bucket == "" || key == "" {
+		return "", "", false
+	}
+
+	return bucket, key, true
+}
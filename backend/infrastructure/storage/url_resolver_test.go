@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// newStandInResolver presigns against a local S3-compatible server
+func newStandInResolver(t *testing.T, endpoint string, cfg S3PresignConfig) *S3PresignResolver {
+	t.Helper()
+
+	client := s3.New(s3.Options{
+		Region:       "us-east-1",
+		Credentials:  credentials.NewStaticCredentialsProvider("test-access-key", "test-secret-key", ""),
+		BaseEndpoint: aws.String(endpoint),
+		UsePathStyle: true,
+	})
+	return newS3PresignResolver(s3.NewPresignClient(client), cfg)
+}
+
+func TestS3PresignResolver_StandIn(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/company-docs/hr/leave policy.pdf" {
+			http.Error(w, "unexpected path "+r.URL.Path, http.StatusNotFound)
+			return
+		}
+		query := r.URL.Query()
+		if query.Get("X-Amz-Signature") == "" || query.Get("X-Amz-Expires") != "900" {
+			http.Error(w, "missing presign parameters", http.StatusForbidden)
+			return
+		}
+		w.Write([]byte("leave policy"))
+	}))
+	defer server.Close()
+
+	resolver := newStandInResolver(t, server.URL, S3PresignConfig{
+		Expiry:         15 * time.Minute,
+		AllowedBuckets: []string{"company-docs"},
+	})
+
+	url, err := resolver.ResolveURL(context.Background(), "s3://company-docs/hr/leave policy.pdf")
+	if err != nil {
+		t.Fatalf("ResolveURL() error = %v", err)
+	}
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET presigned URL: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected presigned URL to be accepted, got status %d for %s", resp.StatusCode, url)
+	}
+}
+
+func TestS3PresignResolver_ResolveURL(t *testing.T) {
+	resolver := newStandInResolver(t, "http://localhost:9000", S3PresignConfig{
+		Expiry:         time.Hour,
+		AllowedBuckets: []string{"company-docs"},
+	})
+
+	tests := []struct {
+		name      string
+		sourceURL string
+		want      string
+		presigned bool
+	}{
+		{name: "allowed bucket is presigned", sourceURL: "s3://company-docs/hr/leave.pdf", presigned: true},
+		{name: "disallowed bucket is not linked", sourceURL: "s3://payroll/salaries.csv", want: ""},
+		{name: "web URL passes through", sourceURL: "https://intranet.example.com/hr", want: "https://intranet.example.com/hr"},
+		{name: "empty URL passes through", sourceURL: "", want: ""},
+		{name: "bucket without key passes through", sourceURL: "s3://company-docs", want: "s3://company-docs"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolver.ResolveURL(context.Background(), tt.sourceURL)
+			if err != nil {
+				t.Fatalf("ResolveURL() error = %v", err)
+			}
+			if tt.presigned {
+				want := "http://localhost:9000/company-docs/hr/leave.pdf?"
+				if !strings.HasPrefix(got, want) {
+					t.Errorf("Expected presigned URL starting with %q, got %q", want, got)
+				}
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ResolveURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestS3PresignConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  S3PresignConfig
+		wantErr bool
+	}{
+		{name: "valid", config: S3PresignConfig{Expiry: 15 * time.Minute, AllowedBuckets: []string{"docs"}}},
+		{name: "zero expiry", config: S3PresignConfig{AllowedBuckets: []string{"docs"}}, wantErr: true},
+		{name: "expiry beyond SigV4 limit", config: S3PresignConfig{Expiry: MaxPresignExpiry + time.Second, AllowedBuckets: []string{"docs"}}, wantErr: true},
+		{name: "no allowed buckets", config: S3PresignConfig{Expiry: 15 * time.Minute}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPassthroughResolver(t *testing.T) {
+	got, err := NewPassthroughResolver().ResolveURL(context.Background(), "s3://company-docs/hr/leave.pdf")
+	if err != nil || got != "s3://company-docs/hr/leave.pdf" {
+		t.Errorf("ResolveURL() = (%q, %v), want the source URL unchanged", got, err)
+	}
+}
@@ -0,0 +1,80 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSetup_Stdout(t *testing.T) {
+	var buf bytes.Buffer
+	shutdown, err := Setup(context.Background(), Config{
+		Exporter:    ExporterStdout,
+		ServiceName: "test-service",
+		SampleRatio: 1,
+	}, &buf)
+	if err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+
+	// Requests carrying a traceparent continue the caller's trace
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	var got trace.SpanContext
+	handler := otelhttp.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, span := Tracer("test").Start(r.Context(), "chat.turn")
+		got = span.SpanContext()
+		span.End()
+	}), "/api/sessions")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/sessions", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got.TraceID().String() != traceID {
+		t.Errorf("Expected trace ID %s from traceparent, got %s", traceID, got.TraceID())
+	}
+
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown() error = %v", err)
+	}
+	output := buf.String()
+	for _, want := range []string{`"Name":"chat.turn"`, `"Name":"/api/sessions"`, "test-service"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %s in exported spans", want)
+		}
+	}
+}
+
+func TestSetup_Exporters(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{name: "none", cfg: Config{Exporter: ExporterNone}},
+		{name: "empty means none", cfg: Config{}},
+		{name: "otlp", cfg: Config{Exporter: ExporterOTLP, Endpoint: "localhost:4318", Insecure: true, SampleRatio: 1}},
+		{name: "unknown", cfg: Config{Exporter: "zipkin"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shutdown, err := Setup(context.Background(), tt.cfg, &bytes.Buffer{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Setup() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				// Nothing was exported, so shutting down must not block on the collector
+				if err := shutdown(context.Background()); err != nil {
+					t.Errorf("shutdown() error = %v", err)
+				}
+			}
+		})
+	}
+}
@@ -4,31 +4,66 @@ import "time"
 
 // MessageRequest represents an incoming message from the client
 type MessageRequest struct {
-	SessionID string `json:"session_id"`
-	Content   string `json:"content"`
+	SessionID string            `json:"session_id"`
+	Content   string            `json:"content"`
+	Filter    *FilterExpression `json:"filter,omitempty"`
+}
+
+// FilterExpression restricts knowledge base retrieval by document metadata.
+// Exactly one operator must be set, e.g.
+//
+//	{"andAll": [
+//	  {"equals": {"key": "department", "value": "hr"}},
+//	  {"in": {"key": "document_type", "value": ["policy", "faq"]}}
+//	]}
+type FilterExpression struct {
+	Equals     *FilterCondition   `json:"equals,omitempty"`
+	In         *FilterCondition   `json:"in,omitempty"`
+	StartsWith *FilterCondition   `json:"startsWith,omitempty"`
+	AndAll     []FilterExpression `json:"andAll,omitempty"`
+	OrAll      []FilterExpression `json:"orAll,omitempty"`
+}
+
+// FilterCondition compares a metadata attribute against a value
+type FilterCondition struct {
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
 }
 
 // MessageResponse represents a message response to the client
 type MessageResponse struct {
-	MessageID string             `json:"message_id"`
-	Content   string             `json:"content"`
-	Citations []CitationResponse `json:"citations,omitempty"`
-	Timestamp time.Time          `json:"timestamp"`
+	MessageID     string             `json:"message_id"`
+	Content       string             `json:"content"`
+	Citations     []CitationResponse `json:"citations,omitempty"`
+	Timestamp     time.Time          `json:"timestamp"`
+	CorrelationID string             `json:"correlation_id,omitempty"`
+	RequestID     string             `json:"request_id,omitempty"`
 }
 
 // CitationResponse represents a citation in the response
 type CitationResponse struct {
 	SourceID   string                 `json:"source_id"`
 	SourceName string                 `json:"source_name"`
+	SourceType string                 `json:"source_type,omitempty"`
 	Excerpt    string                 `json:"excerpt"`
 	Confidence float64                `json:"confidence,omitempty"`
 	URL        string                 `json:"url,omitempty"`
 	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+	Number     int                    `json:"number,omitempty"`
+	Span       *SpanResponse          `json:"span,omitempty"`
+	Spans      []SpanResponse         `json:"spans,omitempty"`
+}
+
+// SpanResponse locates cited text in the assistant message (end exclusive)
+type SpanResponse struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
 }
 
 // SessionCreateRequest represents a request to create a new session
 type SessionCreateRequest struct {
-	// Empty for now, can add initial configuration later
+	// Mode optionally overrides the server's default chat mode ("agent", "knowledge_base" or "model")
+	Mode string `json:"mode,omitempty"`
 }
 
 // SessionResponse represents a session response
@@ -37,18 +72,43 @@ type SessionResponse struct {
 	CreatedAt     time.Time  `json:"created_at"`
 	LastMessageAt *time.Time `json:"last_message_at,omitempty"`
 	MessageCount  int        `json:"message_count"`
+	Mode          string     `json:"mode,omitempty"`
+}
+
+// SearchResponse represents the passages retrieved for a search query
+type SearchResponse struct {
+	Query   string            `json:"query"`
+	Results []PassageResponse `json:"results"`
+}
+
+// PassageResponse represents a single retrieved knowledge base passage
+type PassageResponse struct {
+	Content  string                 `json:"content"`
+	Score    float64                `json:"score"`
+	SourceID string                 `json:"source_id,omitempty"`
+	URL      string                 `json:"url,omitempty"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
 }
 
 // ErrorResponse represents an error response
 type ErrorResponse struct {
 	Code    string `json:"code"`
 	Message string `json:"message"`
+	// CorrelationID identifies the chat turn that failed (WebSocket only)
+	CorrelationID string `json:"correlation_id,omitempty"`
+	// RequestID is the AWS request ID of the failed Bedrock call, if any
+	RequestID string `json:"request_id,omitempty"`
+	// Details holds additional error context, e.g. retry_after in seconds
+	Details map[string]interface{} `json:"details,omitempty"`
 }
 
 // StreamChunk represents a chunk of streaming data
 type StreamChunk struct {
-	Type     string            `json:"type"` // "content", "citation", "error", "done"
-	Content  string            `json:"content,omitempty"`
-	Citation *CitationResponse `json:"citation,omitempty"`
-	Error    *ErrorResponse    `json:"error,omitempty"`
+	Type      string             `json:"type"` // "content", "citation", "citations", "error", "restart", "done", "server_shutdown"
+	Content   string             `json:"content,omitempty"`
+	Citation  *CitationResponse  `json:"citation,omitempty"`
+	Citations []CitationResponse `json:"citations,omitempty"`
+	Error     *ErrorResponse     `json:"error,omitempty"`
+	// Message explains a restart or server_shutdown notice
+	Message string `json:"message,omitempty"`
 }
@@ -0,0 +1,36 @@
+package chat
+
+import (
+	"bytes"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Subprotocols a client may request in the Sec-WebSocket-Protocol header to
+// choose how server messages are encoded. Without one, messages are JSON.
+const (
+	// SubprotocolJSON sends server messages as JSON text frames
+	SubprotocolJSON = "bedrock-chat.json"
+	// SubprotocolMsgPack sends server messages as MessagePack binary frames
+	SubprotocolMsgPack = "bedrock-chat.msgpack"
+)
+
+// subprotocols lists the supported subprotocols, preferred first
+var subprotocols = []string{SubprotocolMsgPack, SubprotocolJSON}
+
+// marshalMsgPack encodes v as MessagePack with the same field names and
+// omitempty rules as its JSON encoding, so both encodings carry the same
+// messages
+func marshalMsgPack(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.GetEncoder()
+	defer msgpack.PutEncoder(enc)
+	enc.Reset(&buf)
+	enc.SetCustomStructTag("json")
+	enc.UseCompactInts(true)
+	enc.UseCompactFloats(true)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
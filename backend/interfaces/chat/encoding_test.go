@@ -0,0 +1,202 @@
+package chat
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bedrock-chat-poc/backend/domain/entities"
+	"github.com/bedrock-chat-poc/backend/infrastructure/bedrock"
+	"github.com/bedrock-chat-poc/backend/infrastructure/repositories"
+	"github.com/gorilla/websocket"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// typicalAnswer is the messages of an answer with a few hundred words and
+// five citations
+func typicalAnswer() []StreamChunk {
+	var chunks []StreamChunk
+	words := strings.Fields(strings.Repeat("Employees accrue twenty days of annual leave each year, which may be carried over with approval. ", 25))
+	for i := 0; i < len(words); i += 3 {
+		end := i + 3
+		if end > len(words) {
+			end = len(words)
+		}
+		chunks = append(chunks, StreamChunk{Type: "content", Content: strings.Join(words[i:end], " ") + " "})
+	}
+
+	citations := make([]CitationResponse, 0, 5)
+	for i := 1; i <= 5; i++ {
+		citation := CitationResponse{
+			SourceID:   fmt.Sprintf("s3://hr-docs/policies/leave-policy-%d.pdf", i),
+			SourceName: fmt.Sprintf("leave-policy-%d.pdf", i),
+			SourceType: "S3",
+			Excerpt:    "Employees accrue twenty days of annual leave each year, which may be carried over with approval of their manager.",
+			Confidence: 0.87,
+			URL:        fmt.Sprintf("https://hr-docs.s3.amazonaws.com/policies/leave-policy-%d.pdf", i),
+			Metadata:   map[string]interface{}{"department": "hr", "page": i},
+			Number:     i,
+			Span:       &SpanResponse{Start: i * 100, End: i*100 + 80},
+		}
+		chunks = append(chunks, StreamChunk{Type: "citation", Citation: &citation})
+		citations = append(citations, citation)
+	}
+	chunks = append(chunks, StreamChunk{Type: "citations", Citations: citations}, StreamChunk{Type: "done"})
+	return chunks
+}
+
+func TestMarshalMsgPack_MatchesJSONFields(t *testing.T) {
+	for _, chunk := range []StreamChunk{
+		{Type: "content", Content: "Hello"},
+		{Type: "done"},
+		typicalAnswer()[len(typicalAnswer())-2],
+		{Type: "error", Error: &ErrorResponse{Code: "TIMEOUT", Message: "Stream timed out", Details: map[string]interface{}{"retry_after": 2}}},
+	} {
+		t.Run(chunk.Type, func(t *testing.T) {
+			data, err := marshalMsgPack(chunk)
+			if err != nil {
+				t.Fatalf("marshalMsgPack() error = %v", err)
+			}
+			var fromMsgPack map[string]interface{}
+			if err := msgpack.Unmarshal(data, &fromMsgPack); err != nil {
+				t.Fatalf("Failed to decode MessagePack: %v", err)
+			}
+
+			// Decoded generically, both encodings hold the same values
+			text, _ := json.Marshal(chunk)
+			var fromJSON map[string]interface{}
+			json.Unmarshal(text, &fromJSON)
+			normalized, _ := json.Marshal(fromMsgPack)
+			var fromMsgPackJSON map[string]interface{}
+			json.Unmarshal(normalized, &fromMsgPackJSON)
+			if !reflect.DeepEqual(fromJSON, fromMsgPackJSON) {
+				t.Errorf("Expected %v, got %v", fromJSON, fromMsgPackJSON)
+			}
+		})
+	}
+}
+
+func TestWebSocketMsgPackSubprotocol(t *testing.T) {
+	sessionRepo := repositories.NewMemorySessionRepository()
+	streamProcessor := bedrock.NewStreamProcessor(bedrock.DefaultStreamProcessorConfig())
+	handler := NewHandlerWithConfig(sessionRepo, &MockBedrockService{}, streamProcessor, HandlerConfig{
+		Compression:      true,
+		CompressionLevel: 6,
+	})
+
+	session := &entities.Session{ID: "test-session-ws-msgpack", CreatedAt: time.Now()}
+	if err := sessionRepo.Create(context.Background(), session); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler.HandleWebSocket))
+	defer server.Close()
+
+	dialer := websocket.Dialer{Subprotocols: []string{SubprotocolMsgPack}, EnableCompression: true}
+	ws, resp, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer ws.Close()
+	if ws.Subprotocol() != SubprotocolMsgPack {
+		t.Fatalf("Expected subprotocol %q, got %q", SubprotocolMsgPack, ws.Subprotocol())
+	}
+	if !strings.Contains(resp.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate") {
+		t.Errorf("Expected permessage-deflate to be negotiated, got %q", resp.Header.Get("Sec-WebSocket-Extensions"))
+	}
+
+	// Requests are still JSON
+	if err := ws.WriteJSON(MessageRequest{SessionID: session.ID, Content: "Test MessagePack"}); err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+
+	content := ""
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		messageType, data, err := ws.ReadMessage()
+		if err != nil {
+			t.Fatalf("Failed to read message: %v", err)
+		}
+		if messageType != websocket.BinaryMessage {
+			t.Fatalf("Expected a binary message, got type %d", messageType)
+		}
+
+		var chunk StreamChunk
+		dec := msgpack.NewDecoder(bytes.NewReader(data))
+		dec.SetCustomStructTag("json")
+		if err := dec.Decode(&chunk); err != nil {
+			t.Fatalf("Failed to decode chunk: %v", err)
+		}
+		if chunk.Type == "content" {
+			content += chunk.Content
+		}
+		if chunk.Type == "done" {
+			break
+		}
+	}
+
+	if content != "Mock streaming response" {
+		t.Errorf("Expected 'Mock streaming response', got %q", content)
+	}
+}
+
+// BenchmarkChunkEncoding compares the encodings for a typical answer. Sizes
+// are reported per answer; deflate compresses each message separately, as
+// permessage-deflate does without context takeover.
+func BenchmarkChunkEncoding(b *testing.B) {
+	answer := typicalAnswer()
+	encodings := []struct {
+		name    string
+		marshal func(v interface{}) ([]byte, error)
+	}{
+		{name: "json", marshal: json.Marshal},
+		{name: "msgpack", marshal: marshalMsgPack},
+	}
+
+	for _, encoding := range encodings {
+		for _, compression := range []struct {
+			name  string
+			level int
+		}{
+			{name: "", level: flate.NoCompression},
+			{name: "+deflate_fast", level: flate.BestSpeed},
+			{name: "+deflate_default", level: flate.DefaultCompression},
+		} {
+			level := compression.level
+			b.Run(encoding.name+compression.name, func(b *testing.B) {
+				var compressed bytes.Buffer
+				writer, _ := flate.NewWriter(&compressed, level)
+				size := 0
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					size = 0
+					for _, chunk := range answer {
+						data, err := encoding.marshal(chunk)
+						if err != nil {
+							b.Fatal(err)
+						}
+						if level != flate.NoCompression {
+							compressed.Reset()
+							writer.Reset(&compressed)
+							writer.Write(data)
+							writer.Flush()
+							// permessage-deflate drops the flush's empty
+							// block marker
+							data = compressed.Bytes()[:compressed.Len()-4]
+						}
+						size += len(data)
+					}
+				}
+				b.ReportMetric(float64(size), "bytes/answer")
+			})
+		}
+	}
+}
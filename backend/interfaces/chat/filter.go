@@ -0,0 +1,104 @@
+package chat
+
+import (
+	"fmt"
+
+	"github.com/bedrock-chat-poc/backend/domain/services"
+)
+
+// parseFilterExpression converts a client filter expression into a validated
+// domain retrieval filter. A nil expression yields a nil filter.
+func parseFilterExpression(expr *FilterExpression) (*services.RetrievalFilter, error) {
+	if expr == nil {
+		return nil, nil
+	}
+
+	filter, err := convertFilterExpression(expr, "filter", 1)
+	if err != nil {
+		return nil, &services.DomainError{
+			Code:      services.ErrCodeInvalidInput,
+			Message:   fmt.Sprintf("Invalid filter: %v", err),
+			Retryable: false,
+		}
+	}
+
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	return filter, nil
+}
+
+// convertFilterExpression maps the wire format onto the domain filter,
+// checking that each expression sets exactly one operator
+func convertFilterExpression(expr *FilterExpression, path string, depth int) (*services.RetrievalFilter, error) {
+	if depth > services.MaxFilterDepth+1 {
+		return nil, fmt.Errorf("%s: filter nesting exceeds maximum depth of %d", path, services.MaxFilterDepth)
+	}
+
+	operators := 0
+	if expr.Equals != nil {
+		operators++
+	}
+	if expr.In != nil {
+		operators++
+	}
+	if expr.StartsWith != nil {
+		operators++
+	}
+	if expr.AndAll != nil {
+		operators++
+	}
+	if expr.OrAll != nil {
+		operators++
+	}
+	if operators != 1 {
+		return nil, fmt.Errorf("%s: exactly one of equals, in, startsWith, andAll or orAll must be set", path)
+	}
+
+	switch {
+	case expr.Equals != nil:
+		return &services.RetrievalFilter{
+			Operator: services.FilterEquals,
+			Key:      expr.Equals.Key,
+			Value:    expr.Equals.Value,
+		}, nil
+
+	case expr.StartsWith != nil:
+		return &services.RetrievalFilter{
+			Operator: services.FilterStartsWith,
+			Key:      expr.StartsWith.Key,
+			Value:    expr.StartsWith.Value,
+		}, nil
+
+	case expr.In != nil:
+		values, ok := expr.In.Value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s.in: value must be a list", path)
+		}
+		return &services.RetrievalFilter{
+			Operator: services.FilterIn,
+			Key:      expr.In.Key,
+			Values:   values,
+		}, nil
+
+	default:
+		operator, children := services.FilterAndAll, expr.AndAll
+		if expr.OrAll != nil {
+			operator, children = services.FilterOrAll, expr.OrAll
+		}
+
+		filter := &services.RetrievalFilter{
+			Operator: operator,
+			Filters:  make([]services.RetrievalFilter, 0, len(children)),
+		}
+		for i := range children {
+			child, err := convertFilterExpression(&children[i], fmt.Sprintf("%s.%s[%d]", path, operator, i), depth+1)
+			if err != nil {
+				return nil, err
+			}
+			filter.Filters = append(filter.Filters, *child)
+		}
+		return filter, nil
+	}
+}
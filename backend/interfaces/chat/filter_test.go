@@ -0,0 +1,175 @@
+package chat
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/bedrock-chat-poc/backend/domain/services"
+)
+
+func TestParseFilterExpression(t *testing.T) {
+	tests := []struct {
+		name       string
+		json       string
+		wantErr    bool
+		errContain string
+		check      func(t *testing.T, f *services.RetrievalFilter)
+	}{
+		{
+			name: "equals",
+			json: `{"equals": {"key": "department", "value": "hr"}}`,
+			check: func(t *testing.T, f *services.RetrievalFilter) {
+				if f.Operator != services.FilterEquals || f.Key != "department" || f.Value != "hr" {
+					t.Errorf("unexpected filter: %+v", f)
+				}
+			},
+		},
+		{
+			name: "in",
+			json: `{"in": {"key": "document_type", "value": ["policy", "faq"]}}`,
+			check: func(t *testing.T, f *services.RetrievalFilter) {
+				if f.Operator != services.FilterIn || len(f.Values) != 2 {
+					t.Errorf("unexpected filter: %+v", f)
+				}
+			},
+		},
+		{
+			name: "startsWith",
+			json: `{"startsWith": {"key": "path", "value": "hr/"}}`,
+			check: func(t *testing.T, f *services.RetrievalFilter) {
+				if f.Operator != services.FilterStartsWith || f.Value != "hr/" {
+					t.Errorf("unexpected filter: %+v", f)
+				}
+			},
+		},
+		{
+			name: "nested andAll/orAll",
+			json: `{"andAll": [
+				{"equals": {"key": "department", "value": "hr"}},
+				{"orAll": [
+					{"equals": {"key": "year", "value": 2024}},
+					{"startsWith": {"key": "path", "value": "hr/"}}
+				]}
+			]}`,
+			check: func(t *testing.T, f *services.RetrievalFilter) {
+				if f.Operator != services.FilterAndAll || len(f.Filters) != 2 {
+					t.Fatalf("unexpected filter: %+v", f)
+				}
+				if f.Filters[1].Operator != services.FilterOrAll || len(f.Filters[1].Filters) != 2 {
+					t.Errorf("unexpected nested filter: %+v", f.Filters[1])
+				}
+			},
+		},
+		{
+			name:       "no operator",
+			json:       `{}`,
+			wantErr:    true,
+			errContain: "exactly one of",
+		},
+		{
+			name:       "multiple operators",
+			json:       `{"equals": {"key": "a", "value": "b"}, "startsWith": {"key": "a", "value": "b"}}`,
+			wantErr:    true,
+			errContain: "exactly one of",
+		},
+		{
+			name:       "missing key",
+			json:       `{"equals": {"value": "hr"}}`,
+			wantErr:    true,
+			errContain: "filter.equals: key is required",
+		},
+		{
+			name:       "object value",
+			json:       `{"equals": {"key": "department", "value": {"nested": true}}}`,
+			wantErr:    true,
+			errContain: "must be a string, number or boolean",
+		},
+		{
+			name:       "in requires list",
+			json:       `{"in": {"key": "document_type", "value": "policy"}}`,
+			wantErr:    true,
+			errContain: "value must be a list",
+		},
+		{
+			name:       "in requires non-empty list",
+			json:       `{"in": {"key": "document_type", "value": []}}`,
+			wantErr:    true,
+			errContain: "non-empty list",
+		},
+		{
+			name:       "startsWith requires string",
+			json:       `{"startsWith": {"key": "path", "value": 42}}`,
+			wantErr:    true,
+			errContain: "non-empty string",
+		},
+		{
+			name:       "andAll requires two filters",
+			json:       `{"andAll": [{"equals": {"key": "a", "value": "b"}}]}`,
+			wantErr:    true,
+			errContain: "requires at least 2 filters",
+		},
+		{
+			name: "error path points at nested filter",
+			json: `{"orAll": [
+				{"equals": {"key": "a", "value": "b"}},
+				{"equals": {"key": "", "value": "b"}}
+			]}`,
+			wantErr:    true,
+			errContain: "filter.orAll[1].equals: key is required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var expr FilterExpression
+			if err := json.Unmarshal([]byte(tt.json), &expr); err != nil {
+				t.Fatalf("Failed to unmarshal filter: %v", err)
+			}
+
+			filter, err := parseFilterExpression(&expr)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Expected error, got nil")
+				}
+				var domainErr *services.DomainError
+				if !errors.As(err, &domainErr) {
+					t.Fatalf("Expected DomainError, got %T", err)
+				}
+				if domainErr.Code != services.ErrCodeInvalidInput {
+					t.Errorf("Expected code %s, got %s", services.ErrCodeInvalidInput, domainErr.Code)
+				}
+				if !strings.Contains(domainErr.Message, tt.errContain) {
+					t.Errorf("Expected message to contain %q, got %q", tt.errContain, domainErr.Message)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			tt.check(t, filter)
+		})
+	}
+}
+
+func TestParseFilterExpression_Nil(t *testing.T) {
+	filter, err := parseFilterExpression(nil)
+	if err != nil || filter != nil {
+		t.Errorf("Expected nil filter and error, got %v, %v", filter, err)
+	}
+}
+
+func TestParseFilterExpression_MaxDepth(t *testing.T) {
+	leaf := FilterExpression{Equals: &FilterCondition{Key: "a", Value: "b"}}
+	expr := leaf
+	for i := 0; i < services.MaxFilterDepth+1; i++ {
+		expr = FilterExpression{AndAll: []FilterExpression{expr, leaf}}
+	}
+
+	_, err := parseFilterExpression(&expr)
+	if err == nil || !strings.Contains(err.Error(), "maximum depth") {
+		t.Errorf("Expected maximum depth error, got %v", err)
+	}
+}
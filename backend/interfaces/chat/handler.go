@@ -5,8 +5,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"math"
 	"net/http"
+	"runtime/debug"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,17 +18,35 @@ import (
 	"github.com/bedrock-chat-poc/backend/domain/repositories"
 	"github.com/bedrock-chat-poc/backend/domain/services"
 	"github.com/bedrock-chat-poc/backend/infrastructure/bedrock"
+	"github.com/bedrock-chat-poc/backend/infrastructure/logging"
+	"github.com/bedrock-chat-poc/backend/infrastructure/metrics"
+	"github.com/bedrock-chat-poc/backend/infrastructure/tracing"
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// tracer starts the span for each WebSocket turn
+var tracer = tracing.Tracer("github.com/bedrock-chat-poc/backend/interfaces/chat")
+
 // Handler handles HTTP and WebSocket requests for the chat interface
 type Handler struct {
-	sessionRepo     repositories.SessionRepository
-	bedrockService  services.BedrockService
-	streamProcessor *bedrock.StreamProcessor
-	upgrader        websocket.Upgrader
-	knowledgeBaseID string
+	sessionRepo      repositories.SessionRepository
+	bedrockService   services.BedrockService
+	modeServices     map[entities.ChatMode]services.BedrockService
+	defaultMode      entities.ChatMode
+	retriever        services.KnowledgeBaseRetriever
+	streamProcessor  *bedrock.StreamProcessor
+	upgrader         websocket.Upgrader
+	knowledgeBaseID  string
+	metrics          *metrics.Metrics
+	drain            *drainState
+	streamTimeout    time.Duration
+	coalescing       *bedrock.CoalescingConfig
+	compressionLevel int
+	// writeTimeout bounds each WebSocket write
+	writeTimeout time.Duration
 }
 
 // HandlerConfig holds configuration for the handler
@@ -32,6 +54,26 @@ type HandlerConfig struct {
 	ReadBufferSize   int
 	WriteBufferSize  int
 	KnowledgeBaseID  string
+	// DefaultMode is the chat mode for sessions that don't choose one (default: agent)
+	DefaultMode entities.ChatMode
+	// ModeServices are the Bedrock services sessions may select by mode.
+	// The handler's bedrockService serves DefaultMode unless overridden here.
+	ModeServices map[entities.ChatMode]services.BedrockService
+	// Retriever serves GET /api/search; search is unavailable when nil
+	Retriever services.KnowledgeBaseRetriever
+	// Metrics records WebSocket connections and chat turns; nil records nothing
+	Metrics *metrics.Metrics
+	// StreamTimeout bounds the routes that wait on Bedrock in place of the
+	// server's write timeout; zero leaves them on the server's
+	StreamTimeout time.Duration
+	// Coalescing batches streamed content and bounds how far a slow client
+	// may fall behind; nil writes each chunk as it arrives
+	Coalescing *bedrock.CoalescingConfig
+	// Compression negotiates permessage-deflate with clients that offer it
+	Compression bool
+	// CompressionLevel is the deflate level for compressed messages, from
+	// -2 (Huffman only) to 9 (best compression); zero uses the default, 1
+	CompressionLevel int
 }
 
 // NewHandler creates a new chat handler with default configuration
@@ -45,14 +87,47 @@ func NewHandler(sessionRepo repositories.SessionRepository, bedrockService servi
 
 // NewHandlerWithConfig creates a new chat handler with custom configuration
 func NewHandlerWithConfig(sessionRepo repositories.SessionRepository, bedrockService services.BedrockService, streamProcessor *bedrock.StreamProcessor, config HandlerConfig) *Handler {
+	defaultMode := config.DefaultMode
+	if defaultMode == "" {
+		defaultMode = entities.ModeAgent
+	}
+
+	modeServices := make(map[entities.ChatMode]services.BedrockService, len(config.ModeServices)+1)
+	for mode, service := range config.ModeServices {
+		if service != nil {
+			modeServices[mode] = service
+		}
+	}
+	if _, ok := modeServices[defaultMode]; !ok && bedrockService != nil {
+		modeServices[defaultMode] = bedrockService
+	}
+
+	// A client that stops reading gets as long to catch up as a coalesced
+	// answer waits for it
+	writeTimeout := bedrock.DefaultCoalescingConfig().SlowConsumerTimeout
+	if config.Coalescing != nil && config.Coalescing.SlowConsumerTimeout > 0 {
+		writeTimeout = config.Coalescing.SlowConsumerTimeout
+	}
+
 	return &Handler{
-		sessionRepo:     sessionRepo,
-		bedrockService:  bedrockService,
-		streamProcessor: streamProcessor,
-		knowledgeBaseID: config.KnowledgeBaseID,
+		sessionRepo:      sessionRepo,
+		bedrockService:   bedrockService,
+		modeServices:     modeServices,
+		defaultMode:      defaultMode,
+		retriever:        config.Retriever,
+		streamProcessor:  streamProcessor,
+		knowledgeBaseID:  config.KnowledgeBaseID,
+		metrics:          config.Metrics,
+		drain:            newDrainState(),
+		streamTimeout:    config.StreamTimeout,
+		coalescing:       config.Coalescing,
+		compressionLevel: config.CompressionLevel,
+		writeTimeout:     writeTimeout,
 		upgrader: websocket.Upgrader{
-			ReadBufferSize:  config.ReadBufferSize,
-			WriteBufferSize: config.WriteBufferSize,
+			ReadBufferSize:    config.ReadBufferSize,
+			WriteBufferSize:   config.WriteBufferSize,
+			EnableCompression: config.Compression,
+			Subprotocols:      subprotocols,
 			CheckOrigin: func(r *http.Request) bool {
 				// Allow all origins for POC - in production, restrict this
 				return true
@@ -70,15 +145,38 @@ func (h *Handler) HandleCreateSession(w http.ResponseWriter, r *http.Request) {
 
 	ctx := r.Context()
 
+	// Parse optional session configuration
+	var req SessionCreateRequest
+	if r.Body != nil {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			h.writeError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+			return
+		}
+	}
+
+	mode := entities.ChatMode(req.Mode)
+	if mode != "" {
+		if !mode.IsValid() {
+			h.writeError(w, http.StatusBadRequest, "INVALID_MODE", fmt.Sprintf("Unknown mode %q (must be agent, knowledge_base, or model)", req.Mode))
+			return
+		}
+		// In mock mode no services are configured and any mode is accepted
+		if _, ok := h.modeServices[mode]; !ok && len(h.modeServices) > 0 {
+			h.writeError(w, http.StatusBadRequest, "MODE_UNAVAILABLE", fmt.Sprintf("Mode %q is not configured on this server", req.Mode))
+			return
+		}
+	}
+
 	// Create new session
 	session := &entities.Session{
 		ID:           uuid.New().String(),
 		CreatedAt:    time.Now(),
 		MessageCount: 0,
+		Mode:         mode,
 	}
 
 	if err := h.sessionRepo.Create(ctx, session); err != nil {
-		log.Printf("Failed to create session: %v", err)
+		slog.ErrorContext(ctx, "[Chat] Failed to create session", "error", err)
 		h.writeError(w, http.StatusInternalServerError, "SESSION_CREATE_FAILED", "Failed to create session")
 		return
 	}
@@ -87,6 +185,7 @@ func (h *Handler) HandleCreateSession(w http.ResponseWriter, r *http.Request) {
 		ID:           session.ID,
 		CreatedAt:    session.CreatedAt,
 		MessageCount: session.MessageCount,
+		Mode:         string(h.sessionMode(session)),
 	}
 
 	h.writeJSON(w, http.StatusCreated, response)
@@ -99,9 +198,8 @@ func (h *Handler) HandleGetSession(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Extract session ID from URL path
-	sessionID := strings.TrimPrefix(r.URL.Path, "/api/sessions/")
-	if sessionID == "" || sessionID == "/api/sessions/" {
+	sessionID := r.PathValue("id")
+	if sessionID == "" {
 		h.writeError(w, http.StatusBadRequest, "INVALID_SESSION_ID", "Session ID is required")
 		return
 	}
@@ -109,7 +207,7 @@ func (h *Handler) HandleGetSession(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	session, err := h.sessionRepo.FindByID(ctx, sessionID)
 	if err != nil {
-		log.Printf("Failed to find session %s: %v", sessionID, err)
+		slog.WarnContext(ctx, "[Chat] Failed to find session", logging.KeySessionID, sessionID, "error", err)
 		h.writeError(w, http.StatusNotFound, "SESSION_NOT_FOUND", "Session not found")
 		return
 	}
@@ -119,6 +217,7 @@ func (h *Handler) HandleGetSession(w http.ResponseWriter, r *http.Request) {
 		CreatedAt:     session.CreatedAt,
 		LastMessageAt: session.LastMessageAt,
 		MessageCount:  session.MessageCount,
+		Mode:          string(h.sessionMode(session)),
 	}
 
 	h.writeJSON(w, http.StatusOK, response)
@@ -134,7 +233,7 @@ func (h *Handler) HandleListSessions(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	sessions, err := h.sessionRepo.List(ctx)
 	if err != nil {
-		log.Printf("Failed to list sessions: %v", err)
+		slog.ErrorContext(ctx, "[Chat] Failed to list sessions", "error", err)
 		h.writeError(w, http.StatusInternalServerError, "SESSION_LIST_FAILED", "Failed to list sessions")
 		return
 	}
@@ -146,68 +245,282 @@ func (h *Handler) HandleListSessions(w http.ResponseWriter, r *http.Request) {
 			CreatedAt:     session.CreatedAt,
 			LastMessageAt: session.LastMessageAt,
 			MessageCount:  session.MessageCount,
+			Mode:          string(h.sessionMode(session)),
 		}
 	}
 
 	h.writeJSON(w, http.StatusOK, responses)
 }
 
+// HandleSearch handles GET /api/search?q=...&limit=...&filter=...
+// It returns raw knowledge base passages without generating an answer.
+func (h *Handler) HandleSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.writeError(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed")
+		return
+	}
+
+	if h.retriever == nil {
+		h.writeError(w, http.StatusServiceUnavailable, "SEARCH_UNAVAILABLE", "Knowledge base search is not configured")
+		return
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		h.writeError(w, http.StatusBadRequest, "INVALID_REQUEST", "Query parameter q is required")
+		return
+	}
+
+	limit := 0
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed <= 0 {
+			h.writeError(w, http.StatusBadRequest, "INVALID_REQUEST", "Query parameter limit must be a positive integer")
+			return
+		}
+		limit = parsed
+	}
+
+	var filterExpr *FilterExpression
+	if filterStr := r.URL.Query().Get("filter"); filterStr != "" {
+		filterExpr = &FilterExpression{}
+		if err := json.Unmarshal([]byte(filterStr), filterExpr); err != nil {
+			h.writeError(w, http.StatusBadRequest, services.ErrCodeInvalidInput, "Invalid filter: filter must be a JSON object")
+			return
+		}
+	}
+	filter, err := parseFilterExpression(filterExpr)
+	if err != nil {
+		h.writeDomainError(w, err)
+		return
+	}
+
+	passages, err := h.retriever.Retrieve(r.Context(), services.RetrieveInput{
+		Query:      query,
+		MaxResults: limit,
+		Filter:     filter,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "[Chat] Failed to search knowledge base", logging.KeyQuery, query, "error", err)
+		h.writeDomainError(w, err)
+		return
+	}
+
+	response := SearchResponse{
+		Query:   query,
+		Results: make([]PassageResponse, len(passages)),
+	}
+	for i, passage := range passages {
+		response.Results[i] = PassageResponse{
+			Content:  passage.Content,
+			Score:    passage.Score,
+			SourceID: passage.SourceID,
+			URL:      passage.URL,
+			Metadata: passage.Metadata,
+		}
+	}
+
+	h.writeJSON(w, http.StatusOK, response)
+}
+
 // HandleWebSocket handles WebSocket connections for streaming chat
 func (h *Handler) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
-	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if h.drain.isDraining() {
+		h.writeError(w, http.StatusServiceUnavailable, "SERVER_SHUTTING_DOWN", "Server is shutting down")
+		return
+	}
+
+	upgraded, err := h.upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Printf("Failed to upgrade connection: %v", err)
+		slog.WarnContext(r.Context(), "[Chat] Failed to upgrade connection", "error", err)
 		return
 	}
+	if h.upgrader.EnableCompression && h.compressionLevel != 0 {
+		if err := upgraded.SetCompressionLevel(h.compressionLevel); err != nil {
+			slog.WarnContext(r.Context(), "[Chat] Invalid compression level", "level", h.compressionLevel, "error", err)
+		}
+	}
+	conn := newWSConn(upgraded, h.writeTimeout)
 	defer conn.Close()
 
-	log.Printf("WebSocket connection established")
+	// Shutdown may have started while upgrading
+	if !h.drain.addConn(conn) {
+		conn.WriteChunk(StreamChunk{Type: "server_shutdown", Message: shutdownNotice})
+		return
+	}
+	defer h.drain.removeConn(conn)
+
+	h.metrics.WebSocketOpened()
+	defer h.metrics.WebSocketClosed()
+
+	slog.InfoContext(r.Context(), "[Chat] WebSocket connection established",
+		"remote_addr", r.RemoteAddr,
+		"subprotocol", upgraded.Subprotocol(),
+	)
+
+	// Turns are traced under the upgrade request's span, which stays open
+	// for the life of the connection, and cancelled when shutdown stops
+	// waiting for them
+	connCtx, cancel := context.WithCancel(tracing.Detach(r.Context()))
+	defer cancel()
+	stop := context.AfterFunc(h.drain.stopped, cancel)
+	defer stop()
 
 	// Handle messages in a loop
 	for {
 		var req MessageRequest
 		err := conn.ReadJSON(&req)
 		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("WebSocket error: %v", err)
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) && !h.drain.isDraining() {
+				slog.WarnContext(r.Context(), "[Chat] WebSocket error", "error", err)
 			}
 			break
 		}
 
-		// Validate request
-		if err := h.validateMessageRequest(&req); err != nil {
-			h.sendErrorChunk(conn, "INVALID_REQUEST", err.Error())
+		if !h.drain.beginTurn() {
+			h.sendErrorChunk(conn, "", "SERVER_SHUTTING_DOWN", "Server is shutting down; reconnect to continue")
 			continue
 		}
+		h.handleTurn(connCtx, conn, &req)
+		h.drain.endTurn()
+	}
+}
 
-		// Verify session exists
-		ctx := context.Background()
-		session, err := h.sessionRepo.FindByID(ctx, req.SessionID)
-		if err != nil {
-			h.sendErrorChunk(conn, "SESSION_NOT_FOUND", "Session not found")
-			continue
+// handleTurn validates a message and streams the response to it. Every turn
+// gets its own correlation ID for logs, traces, error chunks and history.
+func (h *Handler) handleTurn(ctx context.Context, conn *wsConn, req *MessageRequest) {
+	correlationID := uuid.New().String()
+	ctx = services.WithCorrelationID(ctx, correlationID)
+	ctx = logging.WithAttrs(ctx,
+		slog.String(logging.KeySessionID, req.SessionID),
+		slog.String(logging.KeyTurnID, correlationID),
+	)
+	ctx, span := tracer.Start(ctx, "chat.turn", trace.WithAttributes(
+		tracing.AttrSessionID.String(req.SessionID),
+		tracing.AttrTurnID.String(correlationID),
+	))
+	defer span.End()
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			h.recoverTurn(ctx, span, conn, correlationID, recovered)
 		}
+	}()
 
-		// Process message and stream response
-		if err := h.processMessage(ctx, conn, session, &req); err != nil {
-			log.Printf("Failed to process message: %v", err)
-			h.sendErrorChunk(conn, "PROCESSING_FAILED", "Failed to process message")
+	// Validate request
+	if err := h.validateMessageRequest(req); err != nil {
+		tracing.RecordError(span, err)
+		h.sendErrorChunk(conn, correlationID, "INVALID_REQUEST", err.Error())
+		return
+	}
+
+	// Verify session exists
+	session, err := h.sessionRepo.FindByID(ctx, req.SessionID)
+	if err != nil {
+		tracing.RecordError(span, err)
+		h.sendErrorChunk(conn, correlationID, "SESSION_NOT_FOUND", "Session not found")
+		return
+	}
+	mode := h.sessionMode(session)
+	span.SetAttributes(attribute.String("mode", string(mode)))
+
+	// Parse optional metadata filter
+	filter, err := h.parseMessageFilter(req, session)
+	if err != nil {
+		tracing.RecordError(span, err)
+		h.sendDomainErrorChunk(conn, correlationID, err, services.ErrCodeInvalidInput, err.Error())
+		return
+	}
+
+	// Process message and stream response
+	start := time.Now()
+	outcome := metrics.OutcomeError
+	// Observed on the way out, so a turn that panics counts as failed
+	defer func() {
+		h.metrics.ObserveTurn(string(mode), outcome, time.Since(start))
+	}()
+	if err := h.processMessage(ctx, conn, session, req, filter); err != nil {
+		var domainErr *services.DomainError
+		if errors.As(err, &domainErr) && domainErr.Code == services.ErrCodeGuardrailBlocked {
+			// The client was already told why; a blocked turn is not a failure
+			outcome = metrics.OutcomeBlocked
+			return
 		}
+		tracing.RecordError(span, err)
+		slog.ErrorContext(ctx, "[Chat] Failed to process message", "error", err)
+		h.sendErrorChunk(conn, correlationID, "PROCESSING_FAILED", "Failed to process message")
+		return
 	}
+	outcome = metrics.OutcomeSuccess
+}
+
+// recoverTurn handles a panic in a chat turn. The panic is logged with its
+// stack and reported to the client, and the connection stays usable for the
+// next message.
+func (h *Handler) recoverTurn(ctx context.Context, span trace.Span, conn *wsConn, correlationID string, recovered interface{}) {
+	err := fmt.Errorf("panic: %v", recovered)
+	tracing.RecordError(span, err)
+	slog.ErrorContext(ctx, "[Chat] Recovered from panic in turn",
+		"panic", recovered,
+		"stack", string(debug.Stack()),
+	)
+	h.sendErrorChunk(conn, correlationID, "INTERNAL_ERROR", "An unexpected error occurred")
 }
 
 // processMessage processes a message and streams the response
-func (h *Handler) processMessage(ctx context.Context, conn *websocket.Conn, session *entities.Session, req *MessageRequest) error {
-	// Update session
-	now := time.Now()
-	session.LastMessageAt = &now
-	session.MessageCount++
-	if err := h.sessionRepo.Update(ctx, session); err != nil {
-		return fmt.Errorf("failed to update session: %w", err)
+func (h *Handler) processMessage(ctx context.Context, conn *wsConn, session *entities.Session, req *MessageRequest, filter *services.RetrievalFilter) error {
+	correlationID := services.CorrelationID(ctx)
+	slog.InfoContext(ctx, "[Chat] Processing message", "mode", h.sessionMode(session))
+	slog.DebugContext(ctx, "[Chat] Message content", logging.KeyContent, req.Content)
+
+	// Load earlier turns before recording this one
+	history, err := h.sessionRepo.GetMessages(ctx, session.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load session history: %w", err)
+	}
+
+	// Record the user's message (also updates the session's activity)
+	if err := h.recordMessage(ctx, &entities.Message{
+		SessionID:     session.ID,
+		Role:          entities.RoleUser,
+		Content:       req.Content,
+		Status:        entities.StatusSent,
+		CorrelationID: correlationID,
+	}); err != nil {
+		return err
 	}
 
-	// Check if Bedrock service is available
-	if h.bedrockService == nil {
+	// If the turn panics, the question still gets an answer in the history,
+	// holding whatever was streamed and marked failed
+	var writer *transcriptWriter
+	var requestID string
+	recorded := false
+	defer func() {
+		recovered := recover()
+		if recovered == nil {
+			return
+		}
+		if !recorded {
+			response := &entities.Message{
+				SessionID:     session.ID,
+				Role:          entities.RoleAgent,
+				Status:        entities.StatusError,
+				CorrelationID: correlationID,
+				RequestID:     requestID,
+			}
+			if writer != nil {
+				response.Content = writer.content.String()
+				response.Citations = writer.citations
+			}
+			if err := h.recordMessage(ctx, response); err != nil {
+				slog.ErrorContext(ctx, "[Chat] Failed to record response", "error", err)
+			}
+		}
+		panic(recovered)
+	}()
+
+	// Check if Bedrock service is available for the session's mode
+	bedrockService := h.serviceFor(session)
+	if bedrockService == nil {
 		// Mock mode - simulate streaming response
 		return h.processMockMessage(ctx, conn, req)
 	}
@@ -216,43 +529,120 @@ func (h *Handler) processMessage(ctx context.Context, conn *websocket.Conn, sess
 	input := services.AgentInput{
 		SessionID: req.SessionID,
 		Message:   req.Content,
+		Filter:    filter,
+		History:   make([]entities.Message, 0, len(history)),
+	}
+	for _, message := range history {
+		input.History = append(input.History, *message)
 	}
 
 	// Add knowledge base ID if configured
 	if h.knowledgeBaseID != "" {
 		input.KnowledgeBaseIDs = []string{h.knowledgeBaseID}
-		log.Printf("[Chat] Using Knowledge Base ID: %s", h.knowledgeBaseID)
+		slog.DebugContext(ctx, "[Chat] Using knowledge base", "knowledge_base_id", h.knowledgeBaseID)
 	}
 
 	// Invoke Bedrock agent with streaming
-	streamReader, err := h.bedrockService.InvokeAgentStream(ctx, input)
+	streamReader, err := bedrockService.InvokeAgentStream(ctx, input)
 	if err != nil {
-		log.Printf("Failed to invoke Bedrock agent: %v", err)
+		slog.ErrorContext(ctx, "[Chat] Failed to invoke Bedrock agent", "error", err)
 		
 		// Transform error to user-friendly message
-		var domainErr *services.DomainError
-		if errors.As(err, &domainErr) {
-			h.sendErrorChunk(conn, domainErr.Code, domainErr.Message)
-		} else {
-			h.sendErrorChunk(conn, services.ErrCodeServiceError, "Failed to process message")
-		}
+		h.sendDomainErrorChunk(conn, correlationID, err, services.ErrCodeServiceError, "Failed to process message")
 		return err
 	}
 
-	// Create WebSocket chunk writer
-	writer := bedrock.NewWebSocketChunkWriter(conn)
+	// Create WebSocket chunk writer that keeps a transcript of the response
+	requestID = streamReader.RequestID()
+	ctx = logging.WithAttrs(ctx, slog.String(logging.KeyRequestID, requestID))
+	wsWriter := bedrock.NewWebSocketChunkWriter(conn)
+	wsWriter.SetRequestIDs(correlationID, requestID)
+	var clientWriter bedrock.ChunkWriter = wsWriter
+	closeWriter := func() error { return nil }
+	if h.coalescing != nil {
+		// Everything queued must reach the client before the turn's own
+		// error chunks, which are written to the connection directly
+		coalescer := bedrock.NewCoalescingChunkWriter(wsWriter, *h.coalescing)
+		defer coalescer.Close()
+		clientWriter = coalescer
+		closeWriter = coalescer.Close
+	}
+	writer = newTranscriptWriter(clientWriter)
+
+	// A stream that fails part way with a retryable error is reopened. The
+	// partial answer is continued when the service can, else answered again.
+	reopen := func(ctx context.Context, partial string) (services.StreamReader, bool, error) {
+		resumed := input
+		continuer, ok := bedrockService.(services.AnswerContinuer)
+		continued := partial != "" && ok && continuer.ContinuesAnswers()
+		if continued {
+			resumed.Continuation = partial
+		}
+		reader, err := bedrockService.InvokeAgentStream(ctx, resumed)
+		if err != nil {
+			return nil, false, err
+		}
+		requestID = reader.RequestID()
+		wsWriter.SetRequestIDs(correlationID, requestID)
+		slog.InfoContext(ctx, "[Chat] Resumed interrupted stream", "continued", continued, logging.KeyRequestID, requestID)
+		return reader, continued, nil
+	}
 
 	// Process the stream
-	if err := h.streamProcessor.ProcessStream(ctx, streamReader, writer); err != nil {
-		log.Printf("Failed to process stream: %v", err)
-		return err
+	streamErr := h.streamProcessor.ProcessResumableStream(ctx, streamReader, reopen, writer)
+	if err := closeWriter(); err != nil && streamErr == nil {
+		streamErr = err
+	}
+	var domainErr *services.DomainError
+	if errors.As(streamErr, &domainErr) && domainErr.Code == services.ErrCodeSlowConsumer {
+		// The answer was abandoned before the processor could report it
+		h.sendDomainErrorChunk(conn, correlationID, streamErr, services.ErrCodeSlowConsumer, domainErr.Message)
+	}
+	status := writer.status()
+	if streamErr != nil && status != entities.StatusBlocked {
+		status = entities.StatusError
+	}
+	recorded = true
+	if err := h.recordMessage(ctx, &entities.Message{
+		SessionID:     session.ID,
+		Role:          entities.RoleAgent,
+		Content:       writer.content.String(),
+		Citations:     writer.citations,
+		Status:        status,
+		CorrelationID: correlationID,
+		RequestID:     requestID,
+		Guardrail:     writer.guardrail,
+	}); err != nil {
+		slog.ErrorContext(ctx, "[Chat] Failed to record response", "error", err)
 	}
 
+	if status == entities.StatusBlocked {
+		slog.WarnContext(ctx, "[Chat] Response blocked by guardrail", "stage", writer.guardrail.Stage, "categories", writer.guardrail.Categories)
+		return streamErr
+	}
+	if streamErr != nil {
+		slog.ErrorContext(ctx, "[Chat] Failed to process stream", "error", streamErr)
+		return streamErr
+	}
+
+	slog.DebugContext(ctx, "[Chat] Response content", logging.KeyContent, writer.content.String())
+
+	return nil
+}
+
+// recordMessage assigns a message its ID and timestamp and appends it to the
+// session's history
+func (h *Handler) recordMessage(ctx context.Context, message *entities.Message) error {
+	message.ID = uuid.New().String()
+	message.Timestamp = time.Now()
+	if err := h.sessionRepo.AddMessage(ctx, message); err != nil {
+		return fmt.Errorf("failed to record message: %w", err)
+	}
 	return nil
 }
 
 // processMockMessage simulates a streaming response for testing without Bedrock
-func (h *Handler) processMockMessage(ctx context.Context, conn *websocket.Conn, req *MessageRequest) error {
+func (h *Handler) processMockMessage(ctx context.Context, conn *wsConn, req *MessageRequest) error {
 	// Simulate streaming response chunks
 	responseText := fmt.Sprintf("Echo: %s", req.Content)
 	words := strings.Fields(responseText)
@@ -262,7 +652,7 @@ func (h *Handler) processMockMessage(ctx context.Context, conn *websocket.Conn,
 			Type:    "content",
 			Content: word + " ",
 		}
-		if err := conn.WriteJSON(chunk); err != nil {
+		if err := conn.WriteChunk(chunk); err != nil {
 			return fmt.Errorf("failed to write chunk: %w", err)
 		}
 		time.Sleep(100 * time.Millisecond) // Simulate streaming delay
@@ -272,11 +662,17 @@ func (h *Handler) processMockMessage(ctx context.Context, conn *websocket.Conn,
 	doneChunk := StreamChunk{
 		Type: "done",
 	}
-	if err := conn.WriteJSON(doneChunk); err != nil {
+	if err := conn.WriteChunk(doneChunk); err != nil {
 		return fmt.Errorf("failed to write done chunk: %w", err)
 	}
 
-	return nil
+	return h.recordMessage(ctx, &entities.Message{
+		SessionID:     req.SessionID,
+		Role:          entities.RoleAgent,
+		Content:       responseText,
+		Status:        entities.StatusSent,
+		CorrelationID: services.CorrelationID(ctx),
+	})
 }
 
 // validateMessageRequest validates the message request
@@ -297,18 +693,121 @@ func (h *Handler) validateMessageRequest(req *MessageRequest) error {
 	return nil
 }
 
-// sendErrorChunk sends an error chunk over WebSocket
-func (h *Handler) sendErrorChunk(conn *websocket.Conn, code, message string) {
+// sessionMode returns the chat mode a session uses
+func (h *Handler) sessionMode(session *entities.Session) entities.ChatMode {
+	if session.Mode != "" {
+		return session.Mode
+	}
+	return h.defaultMode
+}
+
+// serviceFor returns the Bedrock service for a session's mode, or nil in mock mode
+func (h *Handler) serviceFor(session *entities.Session) services.BedrockService {
+	if service, ok := h.modeServices[h.sessionMode(session)]; ok {
+		return service
+	}
+	return h.bedrockService
+}
+
+// parseMessageFilter parses and validates the request's metadata filter
+func (h *Handler) parseMessageFilter(req *MessageRequest, session *entities.Session) (*services.RetrievalFilter, error) {
+	filter, err := parseFilterExpression(req.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	// Agents need the knowledge base ID to scope the filter; knowledge base mode always has one
+	if filter != nil && h.sessionMode(session) == entities.ModeAgent && h.serviceFor(session) != nil && h.knowledgeBaseID == "" {
+		return nil, &services.DomainError{
+			Code:      services.ErrCodeInvalidInput,
+			Message:   "Invalid filter: no knowledge base is configured",
+			Retryable: false,
+		}
+	}
+
+	return filter, nil
+}
+
+// sendErrorChunk sends an error chunk for a chat turn over WebSocket
+func (h *Handler) sendErrorChunk(conn *wsConn, correlationID, code, message string) {
+	h.writeErrorChunk(conn, &ErrorResponse{
+		Code:          code,
+		Message:       message,
+		CorrelationID: correlationID,
+	})
+}
+
+// sendDomainErrorChunk sends an error chunk for a failed chat turn. Domain
+// errors keep their code, message and AWS request ID; other errors are sent
+// with the fallback code and message.
+func (h *Handler) sendDomainErrorChunk(conn *wsConn, correlationID string, err error, fallbackCode, fallbackMessage string) {
+	var domainErr *services.DomainError
+	if !errors.As(err, &domainErr) {
+		h.sendErrorChunk(conn, correlationID, fallbackCode, fallbackMessage)
+		return
+	}
+
+	h.writeErrorChunk(conn, &ErrorResponse{
+		Code:          domainErr.Code,
+		Message:       domainErr.Message,
+		CorrelationID: correlationID,
+		RequestID:     domainErr.RequestID,
+		Details:       retryDetails(domainErr),
+	})
+}
+
+// retryAfterSeconds returns a domain error's retry hint in whole seconds,
+// rounded up, or 0 if it has none
+func retryAfterSeconds(domainErr *services.DomainError) int {
+	if domainErr.RetryAfter <= 0 {
+		return 0
+	}
+	return int(math.Ceil(domainErr.RetryAfter.Seconds()))
+}
+
+// retryDetails returns the error details carrying a domain error's retry
+// hint, or nil if it has none
+func retryDetails(domainErr *services.DomainError) map[string]interface{} {
+	seconds := retryAfterSeconds(domainErr)
+	if seconds == 0 {
+		return nil
+	}
+	return map[string]interface{}{"retry_after": seconds}
+}
+
+// writeErrorChunk writes an error chunk over WebSocket
+func (h *Handler) writeErrorChunk(conn *wsConn, errorResponse *ErrorResponse) {
 	chunk := StreamChunk{
-		Type: "error",
-		Error: &ErrorResponse{
-			Code:    code,
-			Message: message,
+		Type:  "error",
+		Error: errorResponse,
+	}
+	if err := conn.WriteChunk(chunk); err != nil {
+		slog.Warn("[Chat] Failed to send error chunk", logging.KeyTurnID, errorResponse.CorrelationID, "error", err)
+	}
+}
+
+// CheckHealth reports the open WebSocket connections and in-flight streams.
+// The handler is down while draining for shutdown, so no new traffic is
+// routed to it, and degraded when its default mode falls back to mock mode.
+func (h *Handler) CheckHealth(ctx context.Context) services.ComponentHealth {
+	conns, streams, draining := h.drain.snapshot()
+	health := services.ComponentHealth{
+		Status: services.HealthUp,
+		Details: map[string]interface{}{
+			"connections":    conns,
+			"active_streams": streams,
 		},
 	}
-	if err := conn.WriteJSON(chunk); err != nil {
-		log.Printf("Failed to send error chunk: %v", err)
+
+	switch {
+	case draining:
+		health.Status = services.HealthDown
+		health.Message = "Draining for shutdown"
+	case h.modeServices[h.defaultMode] == nil:
+		health.Status = services.HealthDegraded
+		health.Message = fmt.Sprintf("No Bedrock service for default mode %q; answering in mock mode", h.defaultMode)
 	}
+	return health
 }
 
 // writeJSON writes a JSON response
@@ -316,7 +815,7 @@ func (h *Handler) writeJSON(w http.ResponseWriter, status int, data interface{})
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	if err := json.NewEncoder(w).Encode(data); err != nil {
-		log.Printf("Failed to encode JSON response: %v", err)
+		slog.Warn("[Chat] Failed to encode JSON response", "error", err)
 	}
 }
 
@@ -329,9 +828,35 @@ func (h *Handler) writeError(w http.ResponseWriter, status int, code, message st
 	h.writeJSON(w, status, response)
 }
 
-// SetCORSHeaders sets CORS headers for the response
-func SetCORSHeaders(w http.ResponseWriter) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+// writeDomainError writes an error response for a domain error, choosing the
+// HTTP status from its error code
+func (h *Handler) writeDomainError(w http.ResponseWriter, err error) {
+	var domainErr *services.DomainError
+	if !errors.As(err, &domainErr) {
+		h.writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "An unexpected error occurred")
+		return
+	}
+
+	status := http.StatusBadGateway
+	switch domainErr.Code {
+	case services.ErrCodeInvalidInput:
+		status = http.StatusBadRequest
+	case services.ErrCodeRateLimit:
+		status = http.StatusTooManyRequests
+	case services.ErrCodeTimeout:
+		status = http.StatusGatewayTimeout
+	case services.ErrCodeServiceError:
+		if domainErr.Retryable {
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	if seconds := retryAfterSeconds(domainErr); seconds > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	}
+	h.writeJSON(w, status, ErrorResponse{
+		Code:    domainErr.Code,
+		Message: domainErr.Message,
+		Details: retryDetails(domainErr),
+	})
 }
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/bedrock-chat-poc/backend/domain/entities"
+	"github.com/bedrock-chat-poc/backend/domain/services"
 	"github.com/bedrock-chat-poc/backend/infrastructure/bedrock"
 	"github.com/bedrock-chat-poc/backend/infrastructure/repositories"
 )
@@ -57,6 +58,7 @@ func TestHandleGetSession(t *testing.T) {
 	}
 
 	req := httptest.NewRequest(http.MethodGet, "/api/sessions/test-session-id", nil)
+	req.SetPathValue("id", "test-session-id")
 	w := httptest.NewRecorder()
 
 	handler.HandleGetSession(w, req)
@@ -85,6 +87,7 @@ func TestHandleGetSession_NotFound(t *testing.T) {
 	handler := NewHandler(sessionRepo, nil, streamProcessor)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/sessions/nonexistent", nil)
+	req.SetPathValue("id", "nonexistent")
 	w := httptest.NewRecorder()
 
 	handler.HandleGetSession(w, req)
@@ -215,19 +218,38 @@ func TestHandleCreateSession_MethodNotAllowed(t *testing.T) {
 	}
 }
 
-func TestSetCORSHeaders(t *testing.T) {
-	w := httptest.NewRecorder()
-	SetCORSHeaders(w)
+func TestHandlerCheckHealth(t *testing.T) {
+	streamProcessor := bedrock.NewStreamProcessor(bedrock.DefaultStreamProcessorConfig())
 
-	if origin := w.Header().Get("Access-Control-Allow-Origin"); origin != "*" {
-		t.Errorf("Expected Access-Control-Allow-Origin '*', got '%s'", origin)
+	tests := []struct {
+		name       string
+		service    services.BedrockService
+		shutdown   bool
+		wantStatus services.HealthStatus
+	}{
+		{name: "bedrock configured", service: &MockBedrockService{}, wantStatus: services.HealthUp},
+		{name: "mock mode", service: nil, wantStatus: services.HealthDegraded},
+		{name: "draining", service: &MockBedrockService{}, shutdown: true, wantStatus: services.HealthDown},
 	}
 
-	if methods := w.Header().Get("Access-Control-Allow-Methods"); methods == "" {
-		t.Error("Expected Access-Control-Allow-Methods to be set")
-	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessionRepo := repositories.NewMemorySessionRepository()
+			defer sessionRepo.Close()
+			handler := NewHandler(sessionRepo, tt.service, streamProcessor)
+			if tt.shutdown {
+				if err := handler.Shutdown(context.Background()); err != nil {
+					t.Fatalf("Shutdown() error = %v", err)
+				}
+			}
 
-	if headers := w.Header().Get("Access-Control-Allow-Headers"); headers == "" {
-		t.Error("Expected Access-Control-Allow-Headers to be set")
+			health := handler.CheckHealth(context.Background())
+			if health.Status != tt.wantStatus {
+				t.Errorf("CheckHealth() status = %q, want %q", health.Status, tt.wantStatus)
+			}
+			if health.Details["active_streams"] != 0 || health.Details["connections"] != 0 {
+				t.Errorf("Expected no connections or streams, got %v", health.Details)
+			}
+		})
 	}
 }
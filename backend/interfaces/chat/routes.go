@@ -0,0 +1,22 @@
+package chat
+
+import (
+	"github.com/bedrock-chat-poc/backend/interfaces/middleware"
+	"github.com/bedrock-chat-poc/backend/interfaces/router"
+)
+
+// RegisterRoutes registers the chat API on r. Search and the WebSocket
+// stream wait on Bedrock, so they are bounded by the stream timeout rather
+// than the server's write timeout.
+func (h *Handler) RegisterRoutes(r *router.Router) {
+	var bedrockRoute []middleware.Middleware
+	if h.streamTimeout > 0 {
+		bedrockRoute = append(bedrockRoute, middleware.Timeout(h.streamTimeout))
+	}
+
+	r.HandleFunc("POST /api/sessions", h.HandleCreateSession)
+	r.HandleFunc("GET /api/sessions", h.HandleListSessions)
+	r.HandleFunc("GET /api/sessions/{id}", h.HandleGetSession)
+	r.HandleFunc("GET /api/search", h.HandleSearch, bedrockRoute...)
+	r.HandleFunc("GET /api/chat/stream", h.HandleWebSocket, bedrockRoute...)
+}
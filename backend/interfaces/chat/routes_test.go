@@ -0,0 +1,54 @@
+package chat
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bedrock-chat-poc/backend/domain/entities"
+	"github.com/bedrock-chat-poc/backend/infrastructure/bedrock"
+	"github.com/bedrock-chat-poc/backend/infrastructure/repositories"
+	"github.com/bedrock-chat-poc/backend/interfaces/router"
+)
+
+func TestRegisterRoutes(t *testing.T) {
+	sessionRepo := repositories.NewMemorySessionRepository()
+	streamProcessor := bedrock.NewStreamProcessor(bedrock.DefaultStreamProcessorConfig())
+	handler := NewHandler(sessionRepo, nil, streamProcessor)
+
+	session := &entities.Session{ID: "abc", CreatedAt: time.Now()}
+	if err := sessionRepo.Create(context.Background(), session); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	r := router.New()
+	handler.RegisterRoutes(r)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+	}{
+		{name: "create session", method: http.MethodPost, path: "/api/sessions", wantStatus: http.StatusCreated},
+		{name: "list sessions", method: http.MethodGet, path: "/api/sessions", wantStatus: http.StatusOK},
+		{name: "get session", method: http.MethodGet, path: "/api/sessions/abc", wantStatus: http.StatusOK},
+		{name: "unknown session", method: http.MethodGet, path: "/api/sessions/missing", wantStatus: http.StatusNotFound},
+		{name: "nested path", method: http.MethodGet, path: "/api/sessions/abc/messages", wantStatus: http.StatusNotFound},
+		{name: "delete not allowed", method: http.MethodDelete, path: "/api/sessions", wantStatus: http.StatusMethodNotAllowed},
+		{name: "search without knowledge base", method: http.MethodGet, path: "/api/search?q=bedrock", wantStatus: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.wantStatus, recorder.Code, recorder.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bedrock-chat-poc/backend/domain/entities"
+	"github.com/bedrock-chat-poc/backend/domain/services"
+	"github.com/bedrock-chat-poc/backend/infrastructure/bedrock"
+	"github.com/bedrock-chat-poc/backend/infrastructure/repositories"
+)
+
+// mockRetriever implements services.KnowledgeBaseRetriever for testing
+type mockRetriever struct {
+	lastInput services.RetrieveInput
+	passages  []services.RetrievedPassage
+	err       error
+}
+
+func (m *mockRetriever) Retrieve(ctx context.Context, input services.RetrieveInput) ([]services.RetrievedPassage, error) {
+	m.lastInput = input
+	return m.passages, m.err
+}
+
+func TestHandleSearch(t *testing.T) {
+	retriever := &mockRetriever{
+		passages: []services.RetrievedPassage{
+			{Content: "Employees get 20 days of leave.", Score: 0.87, SourceID: "s3://docs/leave.pdf"},
+		},
+	}
+	handler := NewHandlerWithConfig(repositories.NewMemorySessionRepository(), nil,
+		bedrock.NewStreamProcessor(bedrock.DefaultStreamProcessorConfig()),
+		HandlerConfig{Retriever: retriever})
+
+	filter := url.QueryEscape(`{"equals": {"key": "department", "value": "hr"}}`)
+	req := httptest.NewRequest(http.MethodGet, "/api/search?q=leave+policy&limit=3&filter="+filter, nil)
+	w := httptest.NewRecorder()
+
+	handler.HandleSearch(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var response SearchResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Query != "leave policy" || len(response.Results) != 1 {
+		t.Fatalf("Unexpected response: %+v", response)
+	}
+	if response.Results[0].Score != 0.87 || response.Results[0].SourceID != "s3://docs/leave.pdf" {
+		t.Errorf("Unexpected passage: %+v", response.Results[0])
+	}
+
+	if retriever.lastInput.MaxResults != 3 {
+		t.Errorf("Expected limit 3, got %d", retriever.lastInput.MaxResults)
+	}
+	if retriever.lastInput.Filter == nil || retriever.lastInput.Filter.Key != "department" {
+		t.Errorf("Expected department filter, got %+v", retriever.lastInput.Filter)
+	}
+}
+
+func TestHandleSearch_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		retriever  services.KnowledgeBaseRetriever
+		query      string
+		wantStatus int
+		wantCode   string
+		// wantRetryAfter is the expected Retry-After header
+		wantRetryAfter string
+	}{
+		{
+			name:       "search not configured",
+			retriever:  nil,
+			query:      "q=leave",
+			wantStatus: http.StatusServiceUnavailable,
+			wantCode:   "SEARCH_UNAVAILABLE",
+		},
+		{
+			name:       "missing query",
+			retriever:  &mockRetriever{},
+			query:      "",
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "INVALID_REQUEST",
+		},
+		{
+			name:       "invalid limit",
+			retriever:  &mockRetriever{},
+			query:      "q=leave&limit=-1",
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "INVALID_REQUEST",
+		},
+		{
+			name:       "malformed filter",
+			retriever:  &mockRetriever{},
+			query:      "q=leave&filter=" + url.QueryEscape(`{"in": {"key": "type", "value": []}}`),
+			wantStatus: http.StatusBadRequest,
+			wantCode:   services.ErrCodeInvalidInput,
+		},
+		{
+			name:       "rate limited",
+			retriever:  &mockRetriever{err: &services.DomainError{Code: services.ErrCodeRateLimit, Message: "Rate limit exceeded"}},
+			query:      "q=leave",
+			wantStatus: http.StatusTooManyRequests,
+			wantCode:   services.ErrCodeRateLimit,
+		},
+		{
+			name: "unavailable with retry hint",
+			retriever: &mockRetriever{err: &services.DomainError{
+				Code:       services.ErrCodeServiceError,
+				Message:    "Bedrock is temporarily unavailable",
+				Retryable:  true,
+				RetryAfter: 1500 * time.Millisecond,
+			}},
+			query:          "q=leave",
+			wantStatus:     http.StatusServiceUnavailable,
+			wantCode:       services.ErrCodeServiceError,
+			wantRetryAfter: "2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewHandlerWithConfig(repositories.NewMemorySessionRepository(), nil,
+				bedrock.NewStreamProcessor(bedrock.DefaultStreamProcessorConfig()),
+				HandlerConfig{Retriever: tt.retriever})
+
+			req := httptest.NewRequest(http.MethodGet, "/api/search?"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			handler.HandleSearch(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			var response ErrorResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.Code != tt.wantCode {
+				t.Errorf("Expected code %s, got %s", tt.wantCode, response.Code)
+			}
+			if got := w.Header().Get("Retry-After"); got != tt.wantRetryAfter {
+				t.Errorf("Expected Retry-After %q, got %q", tt.wantRetryAfter, got)
+			}
+		})
+	}
+}
+
+func TestHandleCreateSession_WithMode(t *testing.T) {
+	agentService := &MockBedrockService{}
+	kbService := &MockBedrockService{}
+	newHandler := func() *Handler {
+		return NewHandlerWithConfig(repositories.NewMemorySessionRepository(), agentService,
+			bedrock.NewStreamProcessor(bedrock.DefaultStreamProcessorConfig()),
+			HandlerConfig{ModeServices: map[entities.ChatMode]services.BedrockService{
+				entities.ModeKnowledgeBase: kbService,
+			}})
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantMode   string
+		wantCode   string
+	}{
+		{name: "default mode", body: "", wantStatus: http.StatusCreated, wantMode: "agent"},
+		{name: "knowledge base mode", body: `{"mode": "knowledge_base"}`, wantStatus: http.StatusCreated, wantMode: "knowledge_base"},
+		{name: "unknown mode", body: `{"mode": "oracle"}`, wantStatus: http.StatusBadRequest, wantCode: "INVALID_MODE"},
+		{name: "malformed body", body: `{"mode":`, wantStatus: http.StatusBadRequest, wantCode: "INVALID_REQUEST"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := newHandler()
+			req := httptest.NewRequest(http.MethodPost, "/api/sessions", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+
+			handler.HandleCreateSession(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if tt.wantCode != "" {
+				var response ErrorResponse
+				json.NewDecoder(w.Body).Decode(&response)
+				if response.Code != tt.wantCode {
+					t.Errorf("Expected code %s, got %s", tt.wantCode, response.Code)
+				}
+				return
+			}
+
+			var response SessionResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.Mode != tt.wantMode {
+				t.Errorf("Expected mode %s, got %s", tt.wantMode, response.Mode)
+			}
+
+			session, err := handler.sessionRepo.FindByID(context.Background(), response.ID)
+			if err != nil {
+				t.Fatalf("Failed to find session: %v", err)
+			}
+			want := services.BedrockService(agentService)
+			if tt.wantMode == "knowledge_base" {
+				want = kbService
+			}
+			if handler.serviceFor(session) != want {
+				t.Errorf("Session routed to the wrong service for mode %s", tt.wantMode)
+			}
+		})
+	}
+}
+
+func TestHandleCreateSession_ModeUnavailable(t *testing.T) {
+	handler := NewHandler(repositories.NewMemorySessionRepository(), &MockBedrockService{},
+		bedrock.NewStreamProcessor(bedrock.DefaultStreamProcessorConfig()))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/sessions", strings.NewReader(`{"mode": "knowledge_base"}`))
+	w := httptest.NewRecorder()
+
+	handler.HandleCreateSession(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	var response ErrorResponse
+	json.NewDecoder(w.Body).Decode(&response)
+	if response.Code != "MODE_UNAVAILABLE" {
+		t.Errorf("Expected code MODE_UNAVAILABLE, got %s", response.Code)
+	}
+}
@@ -0,0 +1,190 @@
+package chat
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// shutdownNotice is sent to every connected socket when the server starts
+// draining
+const shutdownNotice = "Server is shutting down; in-progress answers will finish, then reconnect to continue"
+
+// closeWriteTimeout bounds the close frame sent to each socket on shutdown
+const closeWriteTimeout = time.Second
+
+// wsConn serializes writes to a WebSocket connection, so the shutdown notice
+// can be sent while a turn is streaming to it
+type wsConn struct {
+	*websocket.Conn
+	writeMu sync.Mutex
+	// msgpack is set when the client chose SubprotocolMsgPack
+	msgpack bool
+	// writeTimeout bounds each write, so a client that stops reading fails
+	// the write instead of holding writeMu forever; zero means no limit
+	writeTimeout time.Duration
+}
+
+// newWSConn wraps an upgraded connection, encoding messages as its client
+// chose
+func newWSConn(conn *websocket.Conn, writeTimeout time.Duration) *wsConn {
+	return &wsConn{Conn: conn, msgpack: conn.Subprotocol() == SubprotocolMsgPack, writeTimeout: writeTimeout}
+}
+
+// WriteChunk writes v as one message: a MessagePack binary frame if the
+// client chose it, else a JSON text frame. Once a write times out the
+// connection is broken and later writes fail at once.
+func (c *wsConn) WriteChunk(v interface{}) error {
+	if !c.msgpack {
+		c.writeMu.Lock()
+		defer c.writeMu.Unlock()
+		c.setWriteDeadline()
+		return c.Conn.WriteJSON(v)
+	}
+
+	data, err := marshalMsgPack(v)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.setWriteDeadline()
+	return c.Conn.WriteMessage(websocket.BinaryMessage, data)
+}
+
+// setWriteDeadline starts the time limit for the next write
+func (c *wsConn) setWriteDeadline() {
+	if c.writeTimeout > 0 {
+		c.Conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	}
+}
+
+// drainState tracks open connections and in-flight turns so shutdown can
+// wait for answers to finish
+type drainState struct {
+	mu       sync.Mutex
+	draining bool
+	conns    map[*wsConn]struct{}
+	turns    sync.WaitGroup
+	// active counts in-flight turns for health reports
+	active int
+
+	// stopped is cancelled when the drain period ends, cancelling turns
+	// still in flight
+	stopped context.Context
+	stop    context.CancelFunc
+}
+
+func newDrainState() *drainState {
+	stopped, stop := context.WithCancel(context.Background())
+	return &drainState{
+		conns:   make(map[*wsConn]struct{}),
+		stopped: stopped,
+		stop:    stop,
+	}
+}
+
+// isDraining reports whether shutdown has started
+func (d *drainState) isDraining() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.draining
+}
+
+// addConn registers a connection. It returns false once shutdown has started.
+func (d *drainState) addConn(conn *wsConn) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.draining {
+		return false
+	}
+	d.conns[conn] = struct{}{}
+	return true
+}
+
+// removeConn unregisters a connection
+func (d *drainState) removeConn(conn *wsConn) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.conns, conn)
+}
+
+// beginTurn registers an in-flight turn. It returns false once shutdown has
+// started; otherwise the caller must call endTurn when the turn is done.
+func (d *drainState) beginTurn() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.draining {
+		return false
+	}
+	d.turns.Add(1)
+	d.active++
+	return true
+}
+
+// endTurn marks an in-flight turn as done
+func (d *drainState) endTurn() {
+	d.mu.Lock()
+	d.active--
+	d.mu.Unlock()
+	d.turns.Done()
+}
+
+// snapshot returns the open connection and in-flight turn counts and
+// whether shutdown has started
+func (d *drainState) snapshot() (conns, turns int, draining bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.conns), d.active, d.draining
+}
+
+// Shutdown drains WebSocket connections. It stops accepting new connections
+// and turns, sends a "server_shutdown" chunk to every connected socket, and
+// waits for in-flight turns to finish or ctx to be done, whichever is first.
+// Turns still running then are cancelled. Finally every socket is closed.
+// It returns ctx's error if the drain period ended before all turns finished.
+func (h *Handler) Shutdown(ctx context.Context) error {
+	d := h.drain
+	d.mu.Lock()
+	d.draining = true
+	conns := make([]*wsConn, 0, len(d.conns))
+	for conn := range d.conns {
+		conns = append(conns, conn)
+	}
+	d.mu.Unlock()
+
+	slog.InfoContext(ctx, "[Chat] Draining WebSocket connections", "connections", len(conns))
+	for _, conn := range conns {
+		notice := StreamChunk{Type: "server_shutdown", Message: shutdownNotice}
+		if err := conn.WriteChunk(notice); err != nil {
+			slog.WarnContext(ctx, "[Chat] Failed to send shutdown notice", "remote_addr", conn.RemoteAddr().String(), "error", err)
+		}
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		d.turns.Wait()
+		close(drained)
+	}()
+
+	var err error
+	select {
+	case <-drained:
+		slog.InfoContext(ctx, "[Chat] In-flight turns finished")
+	case <-ctx.Done():
+		err = ctx.Err()
+		slog.WarnContext(ctx, "[Chat] Drain period ended, cancelling in-flight turns", "error", err)
+	}
+	d.stop()
+
+	closeMessage := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutdown")
+	for _, conn := range conns {
+		conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(closeWriteTimeout))
+		conn.Close()
+	}
+
+	return err
+}
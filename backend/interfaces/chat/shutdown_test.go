@@ -0,0 +1,204 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bedrock-chat-poc/backend/domain/entities"
+	"github.com/bedrock-chat-poc/backend/domain/services"
+	"github.com/bedrock-chat-poc/backend/infrastructure/bedrock"
+	"github.com/bedrock-chat-poc/backend/infrastructure/repositories"
+	"github.com/gorilla/websocket"
+)
+
+// hangingBedrockService streams one chunk, then blocks until released
+type hangingBedrockService struct {
+	MockBedrockService
+	release chan struct{}
+}
+
+func (m *hangingBedrockService) InvokeAgentStream(ctx context.Context, input services.AgentInput) (services.StreamReader, error) {
+	return &hangingStreamReader{release: m.release}, nil
+}
+
+type hangingStreamReader struct {
+	MockStreamReader
+	release chan struct{}
+	sent    bool
+}
+
+func (m *hangingStreamReader) Next(ctx context.Context) (services.StreamEvent, error) {
+	if !m.sent {
+		m.sent = true
+		return services.StreamEvent{Type: services.StreamEventContent, Content: "Partial "}, nil
+	}
+	select {
+	case <-m.release:
+		return services.StreamEvent{Type: services.StreamEventDone}, nil
+	case <-ctx.Done():
+		return services.StreamEvent{}, ctx.Err()
+	}
+}
+
+// startShutdownTest starts a WebSocket server for handler with one session
+// and connects a client to it
+func startShutdownTest(t *testing.T, bedrockService services.BedrockService) (*Handler, *httptest.Server, *websocket.Conn) {
+	t.Helper()
+	sessionRepo := repositories.NewMemorySessionRepository()
+	t.Cleanup(sessionRepo.Close)
+	if err := sessionRepo.Create(context.Background(), &entities.Session{ID: "shutdown-session", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	handler := NewHandler(sessionRepo, bedrockService, bedrock.NewStreamProcessor(bedrock.DefaultStreamProcessorConfig()))
+	server := httptest.NewServer(http.HandlerFunc(handler.HandleWebSocket))
+	t.Cleanup(server.Close)
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Failed to connect WebSocket: %v", err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	return handler, server, ws
+}
+
+func TestHandlerShutdown_DrainsInFlightTurn(t *testing.T) {
+	handler, server, ws := startShutdownTest(t, nil)
+
+	if err := ws.WriteJSON(MessageRequest{SessionID: "shutdown-session", Content: "drain this answer"}); err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+
+	var chunk StreamChunk
+	if err := ws.ReadJSON(&chunk); err != nil || chunk.Type != "content" {
+		t.Fatalf("Expected first content chunk, got %+v (err %v)", chunk, err)
+	}
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		shutdownErr <- handler.Shutdown(ctx)
+	}()
+
+	// The notice arrives mid-answer and the answer still completes
+	var types []string
+	for {
+		var chunk StreamChunk
+		err := ws.ReadJSON(&chunk)
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+				t.Fatalf("Expected going away close, got %v", err)
+			}
+			break
+		}
+		if chunk.Type == "server_shutdown" && chunk.Message == "" {
+			t.Error("Expected shutdown notice to carry a message")
+		}
+		if chunk.Type != "content" {
+			types = append(types, chunk.Type)
+		}
+	}
+
+	if strings.Join(types, ",") != "server_shutdown,done" {
+		t.Errorf("Expected server_shutdown then done, got %v", types)
+	}
+	if err := <-shutdownErr; err != nil {
+		t.Errorf("Shutdown() error = %v", err)
+	}
+
+	// New connections are refused once draining
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("GET error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 after shutdown, got %d", resp.StatusCode)
+	}
+}
+
+func TestHandlerShutdown_CancelsTurnsAfterDrainTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	handler, _, ws := startShutdownTest(t, &hangingBedrockService{release: release})
+
+	if err := ws.WriteJSON(MessageRequest{SessionID: "shutdown-session", Content: "never finishes"}); err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+	var chunk StreamChunk
+	if err := ws.ReadJSON(&chunk); err != nil || chunk.Type != "content" {
+		t.Fatalf("Expected first content chunk, got %+v (err %v)", chunk, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := handler.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// The cancelled turn ends, so nothing is left in flight
+	drained := make(chan struct{})
+	go func() {
+		handler.drain.turns.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the in-flight turn to be cancelled")
+	}
+}
+
+func TestWSConn_WriteTimeout(t *testing.T) {
+	written := make(chan error, 1)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgraded, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			written <- err
+			return
+		}
+		conn := newWSConn(upgraded, 50*time.Millisecond)
+		defer conn.Close()
+
+		// The client never reads, so the socket buffers fill and a write
+		// times out instead of blocking the turn
+		chunk := StreamChunk{Type: "content", Content: strings.Repeat("x", 256*1024)}
+		for {
+			if err := conn.WriteChunk(chunk); err != nil {
+				// The connection stays broken, so later writes fail at once
+				start := time.Now()
+				if err := conn.WriteChunk(StreamChunk{Type: "error"}); err == nil || time.Since(start) > time.Second {
+					err = errors.New("expected the next write to fail at once")
+				}
+				written <- err
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Failed to connect WebSocket: %v", err)
+	}
+	defer ws.Close()
+
+	select {
+	case err := <-written:
+		var netErr interface{ Timeout() bool }
+		if err == nil || (!errors.As(err, &netErr) && !strings.Contains(err.Error(), "timeout")) {
+			t.Errorf("Expected a write timeout, got %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Expected the write to a client that stopped reading to time out")
+	}
+}
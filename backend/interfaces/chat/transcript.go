@@ -0,0 +1,95 @@
+package chat
+
+import (
+	"strings"
+
+	"github.com/bedrock-chat-poc/backend/domain/entities"
+	"github.com/bedrock-chat-poc/backend/infrastructure/bedrock"
+)
+
+// transcriptWriter wraps a ChunkWriter and keeps a copy of the response so
+// the turn can be stored in the session's history once streaming finishes
+type transcriptWriter struct {
+	bedrock.ChunkWriter
+	content   strings.Builder
+	citations []entities.Citation
+	failed    bool
+	// guardrail records why a guardrail blocked the response, if it did
+	guardrail *entities.GuardrailIntervention
+}
+
+// newTranscriptWriter creates a transcript writer around an existing writer
+func newTranscriptWriter(writer bedrock.ChunkWriter) *transcriptWriter {
+	return &transcriptWriter{ChunkWriter: writer}
+}
+
+// WriteContentChunk records and forwards a content chunk
+func (w *transcriptWriter) WriteContentChunk(content string) error {
+	w.content.WriteString(content)
+	return w.ChunkWriter.WriteContentChunk(content)
+}
+
+// WriteCitationChunk records and forwards a citation chunk
+func (w *transcriptWriter) WriteCitationChunk(citation bedrock.CitationChunk) error {
+	w.citations = append(w.citations, storedCitation(citation))
+	return w.ChunkWriter.WriteCitationChunk(citation)
+}
+
+// WriteCitationListChunk replaces the recorded citations with the
+// consolidated list and forwards it
+func (w *transcriptWriter) WriteCitationListChunk(citations []bedrock.CitationChunk) error {
+	w.citations = make([]entities.Citation, len(citations))
+	for i, citation := range citations {
+		w.citations[i] = storedCitation(citation)
+	}
+	return w.ChunkWriter.WriteCitationListChunk(citations)
+}
+
+// storedCitation converts a streamed citation into its stored form
+func storedCitation(citation bedrock.CitationChunk) entities.Citation {
+	stored := entities.Citation{
+		SourceID:   citation.SourceID,
+		SourceName: citation.SourceName,
+		SourceType: citation.SourceType,
+		Excerpt:    citation.Excerpt,
+		Confidence: citation.Confidence,
+		URL:        citation.URL,
+		Metadata:   citation.Metadata,
+	}
+	if citation.Span != nil {
+		// Spans from the stream already account for any injected markers
+		stored.ResponseSpan = &entities.TextSpan{Start: citation.Span.Start, End: citation.Span.End}
+	}
+	return stored
+}
+
+// WriteErrorChunk marks the response as failed and forwards the error chunk
+func (w *transcriptWriter) WriteErrorChunk(code, message string) error {
+	w.failed = true
+	return w.ChunkWriter.WriteErrorChunk(code, message)
+}
+
+// WriteGuardrailChunk records the intervention and forwards it
+func (w *transcriptWriter) WriteGuardrailChunk(intervention entities.GuardrailIntervention) error {
+	w.guardrail = &intervention
+	return w.ChunkWriter.WriteGuardrailChunk(intervention)
+}
+
+// WriteRestartChunk discards the recorded response, which is being answered
+// again from the start, and forwards the restart chunk
+func (w *transcriptWriter) WriteRestartChunk() error {
+	w.content.Reset()
+	w.citations = nil
+	return w.ChunkWriter.WriteRestartChunk()
+}
+
+// status returns the status the recorded response should be stored with
+func (w *transcriptWriter) status() entities.MessageStatus {
+	if w.guardrail != nil {
+		return entities.StatusBlocked
+	}
+	if w.failed {
+		return entities.StatusError
+	}
+	return entities.StatusSent
+}
@@ -0,0 +1,302 @@
+package chat
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bedrock-chat-poc/backend/domain/entities"
+	"github.com/bedrock-chat-poc/backend/domain/services"
+	"github.com/bedrock-chat-poc/backend/infrastructure/bedrock"
+	"github.com/bedrock-chat-poc/backend/infrastructure/repositories"
+	"github.com/gorilla/websocket"
+)
+
+// recordingBedrockService captures the inputs it is invoked with
+type recordingBedrockService struct {
+	MockBedrockService
+	inputs []services.AgentInput
+}
+
+func (m *recordingBedrockService) InvokeAgentStream(ctx context.Context, input services.AgentInput) (services.StreamReader, error) {
+	m.inputs = append(m.inputs, input)
+	return m.MockBedrockService.InvokeAgentStream(ctx, input)
+}
+
+func TestWebSocketConversationHistory(t *testing.T) {
+	sessionRepo := repositories.NewMemorySessionRepository()
+	service := &recordingBedrockService{}
+	handler := NewHandler(sessionRepo, service, bedrock.NewStreamProcessor(bedrock.DefaultStreamProcessorConfig()))
+
+	session := &entities.Session{ID: "test-session-history", CreatedAt: time.Now()}
+	if err := sessionRepo.Create(context.Background(), session); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler.HandleWebSocket))
+	defer server.Close()
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Failed to connect WebSocket: %v", err)
+	}
+	defer ws.Close()
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	for _, content := range []string{"How much leave do I get?", "What about sick leave?"} {
+		if err := ws.WriteJSON(MessageRequest{SessionID: session.ID, Content: content}); err != nil {
+			t.Fatalf("Failed to send message: %v", err)
+		}
+		for {
+			var chunk StreamChunk
+			if err := ws.ReadJSON(&chunk); err != nil {
+				t.Fatalf("Failed to read chunk: %v", err)
+			}
+			if chunk.Type == "done" {
+				break
+			}
+		}
+	}
+
+	if len(service.inputs) != 2 {
+		t.Fatalf("Expected 2 invocations, got %d", len(service.inputs))
+	}
+	if len(service.inputs[0].History) != 0 {
+		t.Errorf("Expected empty history for first message, got %d messages", len(service.inputs[0].History))
+	}
+
+	history := service.inputs[1].History
+	if len(history) != 2 {
+		t.Fatalf("Expected 2 history messages, got %d", len(history))
+	}
+	if history[0].Role != entities.RoleUser || history[0].Content != "How much leave do I get?" {
+		t.Errorf("Unexpected first history message: %+v", history[0])
+	}
+	if history[1].Role != entities.RoleAgent || history[1].Content != "Mock streaming response" || history[1].Status != entities.StatusSent {
+		t.Errorf("Unexpected second history message: %+v", history[1])
+	}
+
+	// Both turns are stored, but only user messages count towards the session
+	messages, err := sessionRepo.GetMessages(context.Background(), session.ID)
+	if err != nil {
+		t.Fatalf("Failed to get messages: %v", err)
+	}
+	if len(messages) != 4 {
+		t.Errorf("Expected 4 stored messages, got %d", len(messages))
+	}
+	updated, _ := sessionRepo.FindByID(context.Background(), session.ID)
+	if updated.MessageCount != 2 {
+		t.Errorf("Expected message count 2, got %d", updated.MessageCount)
+	}
+}
+
+// discardChunkWriter accepts and drops every chunk
+type discardChunkWriter struct{}
+
+func (discardChunkWriter) WriteContentChunk(string) error                           { return nil }
+func (discardChunkWriter) WriteCitationChunk(bedrock.CitationChunk) error           { return nil }
+func (discardChunkWriter) WriteCitationListChunk([]bedrock.CitationChunk) error     { return nil }
+func (discardChunkWriter) WriteErrorChunk(string, string) error                     { return nil }
+func (discardChunkWriter) WriteGuardrailChunk(entities.GuardrailIntervention) error { return nil }
+func (discardChunkWriter) WriteRestartChunk() error                                 { return nil }
+func (discardChunkWriter) WriteDoneChunk() error                                    { return nil }
+
+func TestTranscriptWriter_CitationListReplacesCitations(t *testing.T) {
+	writer := newTranscriptWriter(discardChunkWriter{})
+
+	writer.WriteCitationChunk(bedrock.CitationChunk{SourceID: "s3://docs/faq.pdf"})
+	writer.WriteCitationChunk(bedrock.CitationChunk{SourceID: "s3://docs/leave.pdf"})
+	writer.WriteCitationListChunk([]bedrock.CitationChunk{
+		{SourceID: "s3://docs/leave.pdf", Span: &bedrock.CitationSpan{Start: 0, End: 17}},
+	})
+
+	if len(writer.citations) != 1 || writer.citations[0].SourceID != "s3://docs/leave.pdf" {
+		t.Fatalf("Expected the consolidated list to be recorded, got %+v", writer.citations)
+	}
+	if span := writer.citations[0].ResponseSpan; span == nil || span.End != 17 {
+		t.Errorf("Expected span to be stored, got %+v", span)
+	}
+}
+
+// guardrailBedrockService streams the start of an answer and then a
+// guardrail blocking it
+type guardrailBedrockService struct {
+	MockBedrockService
+}
+
+func (m *guardrailBedrockService) InvokeAgentStream(ctx context.Context, input services.AgentInput) (services.StreamReader, error) {
+	return &guardrailStreamReader{}, nil
+}
+
+type guardrailStreamReader struct {
+	MockStreamReader
+}
+
+func (m *guardrailStreamReader) Next(ctx context.Context) (services.StreamEvent, error) {
+	m.index++
+	switch m.index {
+	case 1:
+		return services.StreamEvent{Type: services.StreamEventContent, Content: "Sorry, I can't discuss that."}, nil
+	case 2:
+		return services.StreamEvent{Type: services.StreamEventGuardrail, Guardrail: &entities.GuardrailIntervention{
+			Stage:      entities.GuardrailStageInput,
+			Categories: []string{"topic:Salaries"},
+		}}, nil
+	default:
+		return services.StreamEvent{Type: services.StreamEventDone}, nil
+	}
+}
+
+func TestWebSocketGuardrailBlocked(t *testing.T) {
+	sessionRepo := repositories.NewMemorySessionRepository()
+	handler := NewHandler(sessionRepo, &guardrailBedrockService{}, bedrock.NewStreamProcessor(bedrock.DefaultStreamProcessorConfig()))
+
+	session := &entities.Session{ID: "test-session-guardrail", CreatedAt: time.Now()}
+	if err := sessionRepo.Create(context.Background(), session); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler.HandleWebSocket))
+	defer server.Close()
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Failed to connect WebSocket: %v", err)
+	}
+	defer ws.Close()
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	if err := ws.WriteJSON(MessageRequest{SessionID: session.ID, Content: "What does my manager earn?"}); err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+
+	var errorChunk *ErrorResponse
+	for errorChunk == nil {
+		var chunk StreamChunk
+		if err := ws.ReadJSON(&chunk); err != nil {
+			t.Fatalf("Failed to read chunk: %v", err)
+		}
+		if chunk.Type == "error" {
+			errorChunk = chunk.Error
+		}
+	}
+	if errorChunk.Code != services.ErrCodeGuardrailBlocked || errorChunk.CorrelationID == "" {
+		t.Errorf("Expected a %s error for the turn, got %+v", services.ErrCodeGuardrailBlocked, errorChunk)
+	}
+	if errorChunk.Details["stage"] != "input" || errorChunk.Details["category"] != "topic:Salaries" {
+		t.Errorf("Unexpected details: %v", errorChunk.Details)
+	}
+
+	// No generic failure follows the guardrail's error
+	ws.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	var extra StreamChunk
+	if err := ws.ReadJSON(&extra); err == nil {
+		t.Errorf("Expected no more chunks, got %+v", extra)
+	}
+
+	messages, err := sessionRepo.GetMessages(context.Background(), session.ID)
+	if err != nil {
+		t.Fatalf("Failed to get messages: %v", err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("Expected 2 stored messages, got %d", len(messages))
+	}
+	answer := messages[1]
+	if answer.Status != entities.StatusBlocked || answer.Guardrail == nil || answer.Guardrail.Categories[0] != "topic:Salaries" {
+		t.Errorf("Expected the answer to be stored as blocked, got %+v", answer)
+	}
+	if answer.Content != "Sorry, I can't discuss that." {
+		t.Errorf("Expected the guardrail's message to be stored, got %q", answer.Content)
+	}
+}
+
+func TestWebSocketRequestIDs(t *testing.T) {
+	dial := func(t *testing.T, handler *Handler) *websocket.Conn {
+		server := httptest.NewServer(http.HandlerFunc(handler.HandleWebSocket))
+		t.Cleanup(server.Close)
+
+		ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+		if err != nil {
+			t.Fatalf("Failed to connect WebSocket: %v", err)
+		}
+		t.Cleanup(func() { ws.Close() })
+		ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+		return ws
+	}
+
+	t.Run("stored on the turn's messages", func(t *testing.T) {
+		sessionRepo := repositories.NewMemorySessionRepository()
+		service := &MockBedrockService{requestID: "req-stream"}
+		handler := NewHandler(sessionRepo, service, bedrock.NewStreamProcessor(bedrock.DefaultStreamProcessorConfig()))
+		if err := sessionRepo.Create(context.Background(), &entities.Session{ID: "test-session-ids", CreatedAt: time.Now()}); err != nil {
+			t.Fatalf("Failed to create session: %v", err)
+		}
+
+		ws := dial(t, handler)
+		if err := ws.WriteJSON(MessageRequest{SessionID: "test-session-ids", Content: "Hello"}); err != nil {
+			t.Fatalf("Failed to send message: %v", err)
+		}
+		for {
+			var chunk StreamChunk
+			if err := ws.ReadJSON(&chunk); err != nil {
+				t.Fatalf("Failed to read chunk: %v", err)
+			}
+			if chunk.Type == "done" {
+				break
+			}
+		}
+
+		messages, err := sessionRepo.GetMessages(context.Background(), "test-session-ids")
+		if err != nil || len(messages) != 2 {
+			t.Fatalf("Expected 2 messages, got %d (err: %v)", len(messages), err)
+		}
+		question, answer := messages[0], messages[1]
+		if question.CorrelationID == "" || question.CorrelationID != answer.CorrelationID {
+			t.Errorf("Expected question and answer to share a correlation ID, got %q and %q", question.CorrelationID, answer.CorrelationID)
+		}
+		if answer.RequestID != "req-stream" {
+			t.Errorf("Expected answer request ID req-stream, got %q", answer.RequestID)
+		}
+	})
+
+	t.Run("included in error chunks", func(t *testing.T) {
+		sessionRepo := repositories.NewMemorySessionRepository()
+		service := &MockBedrockService{
+			shouldError: true,
+			errorCode:   services.ErrCodeRateLimit,
+			errorMsg:    "Rate limit exceeded",
+			requestID:   "req-throttled",
+		}
+		handler := NewHandler(sessionRepo, service, bedrock.NewStreamProcessor(bedrock.DefaultStreamProcessorConfig()))
+		if err := sessionRepo.Create(context.Background(), &entities.Session{ID: "test-session-error-ids", CreatedAt: time.Now()}); err != nil {
+			t.Fatalf("Failed to create session: %v", err)
+		}
+
+		ws := dial(t, handler)
+		if err := ws.WriteJSON(MessageRequest{SessionID: "test-session-error-ids", Content: "Hello"}); err != nil {
+			t.Fatalf("Failed to send message: %v", err)
+		}
+
+		var chunk StreamChunk
+		if err := ws.ReadJSON(&chunk); err != nil {
+			t.Fatalf("Failed to read chunk: %v", err)
+		}
+		if chunk.Type != "error" || chunk.Error == nil {
+			t.Fatalf("Expected error chunk, got %+v", chunk)
+		}
+		if chunk.Error.RequestID != "req-throttled" {
+			t.Errorf("Expected request ID req-throttled, got %q", chunk.Error.RequestID)
+		}
+		if chunk.Error.CorrelationID == "" {
+			t.Error("Expected error chunk to carry a correlation ID")
+		}
+
+		messages, err := sessionRepo.GetMessages(context.Background(), "test-session-error-ids")
+		if err != nil || len(messages) == 0 || messages[0].CorrelationID != chunk.Error.CorrelationID {
+			t.Errorf("Expected the recorded question to share the error's correlation ID")
+		}
+	})
+}
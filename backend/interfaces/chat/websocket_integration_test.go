@@ -600,6 +600,7 @@ type MockBedrockService struct {
 	shouldError bool
 	errorCode   string
 	errorMsg    string
+	requestID   string
 }
 
 func (m *MockBedrockService) InvokeAgent(ctx context.Context, input services.AgentInput) (*services.AgentResponse, error) {
@@ -620,41 +621,43 @@ func (m *MockBedrockService) InvokeAgent(ctx context.Context, input services.Age
 func (m *MockBedrockService) InvokeAgentStream(ctx context.Context, input services.AgentInput) (services.StreamReader, error) {
 	if m.shouldError {
 		return nil, &services.DomainError{
-			Code:    m.errorCode,
-			Message: m.errorMsg,
+			Code:      m.errorCode,
+			Message:   m.errorMsg,
+			RequestID: m.requestID,
 		}
 	}
 
 	return &MockStreamReader{
-		chunks: []string{"Mock ", "streaming ", "response"},
-		index:  0,
+		chunks:    []string{"Mock ", "streaming ", "response"},
+		index:     0,
+		requestID: m.requestID,
 	}, nil
 }
 
 type MockStreamReader struct {
-	chunks []string
-	index  int
+	chunks    []string
+	index     int
+	requestID string
 }
 
-func (m *MockStreamReader) Read() (chunk string, done bool, err error) {
+func (m *MockStreamReader) Next(ctx context.Context) (services.StreamEvent, error) {
 	if m.index >= len(m.chunks) {
-		return "", true, nil
+		return services.StreamEvent{Type: services.StreamEventDone}, nil
 	}
 
-	chunk = m.chunks[m.index]
+	chunk := m.chunks[m.index]
 	m.index++
-	return chunk, false, nil
-}
-
-func (m *MockStreamReader) ReadCitation() (*entities.Citation, error) {
-	// No citations in mock
-	return nil, nil
+	return services.StreamEvent{Type: services.StreamEventContent, Content: chunk}, nil
 }
 
 func (m *MockStreamReader) Close() error {
 	return nil
 }
 
+func (m *MockStreamReader) RequestID() string {
+	return m.requestID
+}
+
 // TestWebSocketWithBedrockService tests integration with Bedrock service
 func TestWebSocketWithBedrockService(t *testing.T) {
 	sessionRepo := repositories.NewMemorySessionRepository()
@@ -785,3 +788,304 @@ func TestWebSocketBedrockError(t *testing.T) {
 		t.Errorf("Expected error code %s, got %s", services.ErrCodeRateLimit, chunk.Error.Code)
 	}
 }
+
+// panickingBedrockService streams a cited answer on its first call, then
+// behaves like MockBedrockService
+type panickingBedrockService struct {
+	MockBedrockService
+	calls int
+}
+
+func (m *panickingBedrockService) InvokeAgentStream(ctx context.Context, input services.AgentInput) (services.StreamReader, error) {
+	m.calls++
+	if m.calls == 1 {
+		return &citingStreamReader{MockStreamReader: MockStreamReader{chunks: []string{"Partial "}}}, nil
+	}
+	return m.MockBedrockService.InvokeAgentStream(ctx, input)
+}
+
+// citingStreamReader cites a source after its chunks
+type citingStreamReader struct {
+	MockStreamReader
+	cited bool
+}
+
+func (m *citingStreamReader) Next(ctx context.Context) (services.StreamEvent, error) {
+	if m.index >= len(m.chunks) && !m.cited {
+		m.cited = true
+		return services.StreamEvent{
+			Type:      services.StreamEventCitation,
+			Citations: []entities.Citation{{SourceID: "s3://docs/leave.pdf", URL: "s3://docs/leave.pdf"}},
+		}, nil
+	}
+	return m.MockStreamReader.Next(ctx)
+}
+
+// panickingURLResolver panics when resolving a citation URL, which the
+// stream processor does on the handler's goroutine
+type panickingURLResolver struct{}
+
+func (panickingURLResolver) ResolveURL(ctx context.Context, sourceURL string) (string, error) {
+	panic("resolver bug")
+}
+
+// TestWebSocketTurnPanic tests that a panicking turn is reported to the
+// client, recorded as failed, and leaves the connection usable
+func TestWebSocketTurnPanic(t *testing.T) {
+	sessionRepo := repositories.NewMemorySessionRepository()
+	config := bedrock.DefaultStreamProcessorConfig()
+	config.URLResolver = panickingURLResolver{}
+	streamProcessor := bedrock.NewStreamProcessor(config)
+	handler := NewHandler(sessionRepo, &panickingBedrockService{}, streamProcessor)
+
+	session := &entities.Session{
+		ID:        "test-session-panic",
+		CreatedAt: time.Now(),
+	}
+	if err := sessionRepo.Create(context.Background(), session); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler.HandleWebSocket))
+	defer server.Close()
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer ws.Close()
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	// readTurn returns the chunk that ends a turn
+	readTurn := func() StreamChunk {
+		for {
+			var chunk StreamChunk
+			if err := ws.ReadJSON(&chunk); err != nil {
+				t.Fatalf("Failed to read response: %v", err)
+			}
+			if chunk.Type == "done" || chunk.Type == "error" {
+				return chunk
+			}
+		}
+	}
+
+	if err := ws.WriteJSON(MessageRequest{SessionID: session.ID, Content: "First"}); err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+	chunk := readTurn()
+	if chunk.Type != "error" || chunk.Error == nil || chunk.Error.Code != "INTERNAL_ERROR" {
+		t.Fatalf("Expected INTERNAL_ERROR chunk, got %+v", chunk)
+	}
+	if chunk.Error.CorrelationID == "" {
+		t.Error("Expected the error to carry the turn's correlation ID")
+	}
+
+	// The same connection answers the next message
+	if err := ws.WriteJSON(MessageRequest{SessionID: session.ID, Content: "Second"}); err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+	if chunk := readTurn(); chunk.Type != "done" {
+		t.Fatalf("Expected second turn to complete, got %+v", chunk)
+	}
+
+	messages, err := sessionRepo.GetMessages(context.Background(), session.ID)
+	if err != nil {
+		t.Fatalf("Failed to get messages: %v", err)
+	}
+	if len(messages) != 4 {
+		t.Fatalf("Expected 4 messages, got %d", len(messages))
+	}
+	failed := messages[1]
+	if failed.Role != entities.RoleAgent || failed.Status != entities.StatusError {
+		t.Errorf("Expected failed agent message, got %s %s", failed.Role, failed.Status)
+	}
+	if failed.Content != "Partial " {
+		t.Errorf("Expected partial answer to be kept, got %q", failed.Content)
+	}
+	if failed.CorrelationID != chunk.Error.CorrelationID {
+		t.Errorf("Expected correlation ID %s, got %s", chunk.Error.CorrelationID, failed.CorrelationID)
+	}
+	if messages[3].Status != entities.StatusSent {
+		t.Errorf("Expected second answer to be sent, got %s", messages[3].Status)
+	}
+}
+
+// interruptedBedrockService streams a reader that is throttled mid-answer on
+// its first call, then behaves like MockBedrockService
+type interruptedBedrockService struct {
+	MockBedrockService
+	continues    bool
+	calls        int
+	continuation string
+}
+
+func (m *interruptedBedrockService) InvokeAgentStream(ctx context.Context, input services.AgentInput) (services.StreamReader, error) {
+	m.calls++
+	if m.calls == 1 {
+		return &interruptedStreamReader{MockStreamReader: MockStreamReader{chunks: []string{"Partial "}, requestID: "req-1"}}, nil
+	}
+	m.continuation = input.Continuation
+	return &MockStreamReader{chunks: []string{"Mock ", "streaming ", "response"}, requestID: "req-2"}, nil
+}
+
+func (m *interruptedBedrockService) ContinuesAnswers() bool {
+	return m.continues
+}
+
+// interruptedStreamReader fails with a throttling error after its chunks
+type interruptedStreamReader struct {
+	MockStreamReader
+}
+
+func (m *interruptedStreamReader) Next(ctx context.Context) (services.StreamEvent, error) {
+	if m.index >= len(m.chunks) {
+		return services.StreamEvent{}, &services.DomainError{Code: services.ErrCodeRateLimit, Message: "Rate limit exceeded", Retryable: true}
+	}
+	return m.MockStreamReader.Next(ctx)
+}
+
+// TestWebSocketStreamResume tests that an answer throttled mid-stream is
+// resumed, and stored as the client saw it
+func TestWebSocketStreamResume(t *testing.T) {
+	tests := []struct {
+		name             string
+		policy           bedrock.ResumePolicy
+		continues        bool
+		wantTypes        string
+		wantContent      string
+		wantContinuation string
+	}{
+		{
+			name:        "restart",
+			policy:      bedrock.ResumeRestart,
+			continues:   true,
+			wantTypes:   "content,restart,content,content,content,done",
+			wantContent: "Mock streaming response",
+		},
+		{
+			name:             "continue",
+			policy:           bedrock.ResumeContinue,
+			continues:        true,
+			wantTypes:        "content,content,content,content,done",
+			wantContent:      "Partial Mock streaming response",
+			wantContinuation: "Partial ",
+		},
+		{
+			name:        "continue unsupported",
+			policy:      bedrock.ResumeContinue,
+			wantTypes:   "content,restart,content,content,content,done",
+			wantContent: "Mock streaming response",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessionRepo := repositories.NewMemorySessionRepository()
+			config := bedrock.DefaultStreamProcessorConfig()
+			config.ResumePolicy = tt.policy
+			service := &interruptedBedrockService{continues: tt.continues}
+			handler := NewHandler(sessionRepo, service, bedrock.NewStreamProcessor(config))
+
+			session := &entities.Session{ID: "test-session-resume", CreatedAt: time.Now()}
+			if err := sessionRepo.Create(context.Background(), session); err != nil {
+				t.Fatalf("Failed to create session: %v", err)
+			}
+
+			server := httptest.NewServer(http.HandlerFunc(handler.HandleWebSocket))
+			defer server.Close()
+
+			ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+			if err != nil {
+				t.Fatalf("Failed to connect: %v", err)
+			}
+			defer ws.Close()
+			ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+			if err := ws.WriteJSON(MessageRequest{SessionID: session.ID, Content: "Question"}); err != nil {
+				t.Fatalf("Failed to send message: %v", err)
+			}
+
+			var types []string
+			for {
+				var chunk StreamChunk
+				if err := ws.ReadJSON(&chunk); err != nil {
+					t.Fatalf("Failed to read response: %v", err)
+				}
+				types = append(types, chunk.Type)
+				if chunk.Type == "done" || chunk.Type == "error" {
+					break
+				}
+			}
+			if got := strings.Join(types, ","); got != tt.wantTypes {
+				t.Errorf("Expected chunks %s, got %s", tt.wantTypes, got)
+			}
+			if service.continuation != tt.wantContinuation {
+				t.Errorf("Expected continuation %q, got %q", tt.wantContinuation, service.continuation)
+			}
+
+			messages, err := sessionRepo.GetMessages(context.Background(), session.ID)
+			if err != nil {
+				t.Fatalf("Failed to get messages: %v", err)
+			}
+			if len(messages) != 2 {
+				t.Fatalf("Expected 2 messages, got %d", len(messages))
+			}
+			answer := messages[1]
+			if answer.Content != tt.wantContent || answer.Status != entities.StatusSent {
+				t.Errorf("Expected sent answer %q, got %s %q", tt.wantContent, answer.Status, answer.Content)
+			}
+			if answer.RequestID != "req-2" {
+				t.Errorf("Expected the resumed stream's request ID, got %q", answer.RequestID)
+			}
+		})
+	}
+}
+
+// TestWebSocketCoalescedStreaming tests that content is batched before it
+// reaches the client
+func TestWebSocketCoalescedStreaming(t *testing.T) {
+	sessionRepo := repositories.NewMemorySessionRepository()
+	streamProcessor := bedrock.NewStreamProcessor(bedrock.DefaultStreamProcessorConfig())
+	handler := NewHandlerWithConfig(sessionRepo, &MockBedrockService{}, streamProcessor, HandlerConfig{
+		Coalescing: &bedrock.CoalescingConfig{MaxBytes: 1024, FlushInterval: time.Second, QueueSize: 4},
+	})
+
+	session := &entities.Session{ID: "test-session-ws-coalesce", CreatedAt: time.Now()}
+	if err := sessionRepo.Create(context.Background(), session); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler.HandleWebSocket))
+	defer server.Close()
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer ws.Close()
+
+	if err := ws.WriteJSON(MessageRequest{SessionID: session.ID, Content: "Test coalescing"}); err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+
+	// The done chunk flushes the batch, so the answer arrives as one chunk
+	// well before the flush interval
+	var contents []string
+	ws.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
+	for {
+		var chunk StreamChunk
+		if err := ws.ReadJSON(&chunk); err != nil {
+			t.Fatalf("Failed to read chunk: %v", err)
+		}
+		if chunk.Type == "content" {
+			contents = append(contents, chunk.Content)
+		}
+		if chunk.Type == "done" {
+			break
+		}
+	}
+
+	if len(contents) != 1 || contents[0] != "Mock streaming response" {
+		t.Errorf("Expected the answer in one chunk, got %q", contents)
+	}
+}
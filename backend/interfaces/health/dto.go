@@ -0,0 +1,16 @@
+package health
+
+// Response is the body of the liveness and readiness endpoints
+type Response struct {
+	// Status is "up", "degraded" or "down"
+	Status     string                       `json:"status"`
+	Components map[string]ComponentResponse `json:"components,omitempty"`
+}
+
+// ComponentResponse reports the health of a single component
+type ComponentResponse struct {
+	Status    string                 `json:"status"`
+	Message   string                 `json:"message,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	LatencyMS int64                  `json:"latency_ms"`
+}
@@ -0,0 +1,144 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bedrock-chat-poc/backend/domain/services"
+)
+
+// DefaultCheckTimeout bounds how long the readiness probe waits for all
+// components to report
+const DefaultCheckTimeout = 2 * time.Second
+
+// component is a registered health checker
+type component struct {
+	name    string
+	checker services.HealthChecker
+}
+
+// Handler serves the liveness and readiness endpoints
+type Handler struct {
+	components []component
+	timeout    time.Duration
+}
+
+// NewHandler creates a health handler with the default check timeout
+func NewHandler() *Handler {
+	return NewHandlerWithTimeout(DefaultCheckTimeout)
+}
+
+// NewHandlerWithTimeout creates a health handler whose readiness checks are
+// bounded by timeout
+func NewHandlerWithTimeout(timeout time.Duration) *Handler {
+	return &Handler{timeout: timeout}
+}
+
+// Register adds a component to the readiness report. Components must be
+// registered before the handler serves requests.
+func (h *Handler) Register(name string, checker services.HealthChecker) {
+	h.components = append(h.components, component{name: name, checker: checker})
+}
+
+// HandleLive handles GET /health/live. The process is live as long as it
+// can serve HTTP, so no components are checked.
+func (h *Handler) HandleLive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, Response{Status: string(services.HealthUp)})
+}
+
+// HandleReady handles GET /health/ready. It checks every registered component
+// concurrently and responds 503 if any is down; degraded components leave
+// the server ready.
+func (h *Handler) HandleReady(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	response := h.check(r.Context())
+	status := http.StatusOK
+	if response.Status == string(services.HealthDown) {
+		status = http.StatusServiceUnavailable
+		slog.WarnContext(r.Context(), "[Health] Not ready", "components", response.Components)
+	}
+	h.writeJSON(w, status, response)
+}
+
+// check runs every component's health check. A component that does not
+// report within the timeout is down.
+func (h *Handler) check(ctx context.Context) Response {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	results := make([]ComponentResponse, len(h.components))
+	var wg sync.WaitGroup
+	for i, c := range h.components {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = checkComponent(ctx, c.checker)
+		}()
+	}
+	wg.Wait()
+
+	response := Response{
+		Status:     string(services.HealthUp),
+		Components: make(map[string]ComponentResponse, len(h.components)),
+	}
+	for i, c := range h.components {
+		result := results[i]
+		response.Components[c.name] = result
+		switch {
+		case result.Status == string(services.HealthDown):
+			response.Status = string(services.HealthDown)
+		case result.Status == string(services.HealthDegraded) && response.Status == string(services.HealthUp):
+			response.Status = string(services.HealthDegraded)
+		}
+	}
+	return response
+}
+
+// checkComponent runs a single health check, giving up when ctx is done
+func checkComponent(ctx context.Context, checker services.HealthChecker) ComponentResponse {
+	start := time.Now()
+	done := make(chan services.ComponentHealth, 1)
+	go func() {
+		done <- checker.CheckHealth(ctx)
+	}()
+
+	var health services.ComponentHealth
+	select {
+	case health = <-done:
+	case <-ctx.Done():
+		health = services.ComponentHealth{
+			Status:  services.HealthDown,
+			Message: "Health check timed out",
+		}
+	}
+
+	return ComponentResponse{
+		Status:    string(health.Status),
+		Message:   health.Message,
+		Details:   health.Details,
+		LatencyMS: time.Since(start).Milliseconds(),
+	}
+}
+
+// writeJSON writes a JSON response that caches and proxies must not store
+func (h *Handler) writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		slog.Warn("[Health] Failed to encode JSON response", "error", err)
+	}
+}
@@ -0,0 +1,158 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bedrock-chat-poc/backend/domain/services"
+)
+
+// staticChecker reports a fixed status
+func staticChecker(status services.HealthStatus) services.HealthChecker {
+	return services.HealthCheckFunc(func(ctx context.Context) services.ComponentHealth {
+		return services.ComponentHealth{Status: status}
+	})
+}
+
+func TestHandleReady(t *testing.T) {
+	hanging := services.HealthCheckFunc(func(ctx context.Context) services.ComponentHealth {
+		<-ctx.Done()
+		time.Sleep(time.Second) // ignores cancellation for a while
+		return services.ComponentHealth{Status: services.HealthUp}
+	})
+
+	tests := []struct {
+		name           string
+		components     map[string]services.HealthChecker
+		wantStatusCode int
+		wantStatus     string
+	}{
+		{
+			name:           "no components",
+			wantStatusCode: http.StatusOK,
+			wantStatus:     "up",
+		},
+		{
+			name: "all up",
+			components: map[string]services.HealthChecker{
+				"repository": staticChecker(services.HealthUp),
+				"chat":       staticChecker(services.HealthUp),
+			},
+			wantStatusCode: http.StatusOK,
+			wantStatus:     "up",
+		},
+		{
+			name: "degraded stays ready",
+			components: map[string]services.HealthChecker{
+				"repository": staticChecker(services.HealthUp),
+				"chat":       staticChecker(services.HealthDegraded),
+			},
+			wantStatusCode: http.StatusOK,
+			wantStatus:     "degraded",
+		},
+		{
+			name: "down is not ready",
+			components: map[string]services.HealthChecker{
+				"repository":    staticChecker(services.HealthUp),
+				"chat":          staticChecker(services.HealthDegraded),
+				"bedrock_agent": staticChecker(services.HealthDown),
+			},
+			wantStatusCode: http.StatusServiceUnavailable,
+			wantStatus:     "down",
+		},
+		{
+			name: "timed out check is down",
+			components: map[string]services.HealthChecker{
+				"bedrock_agent": hanging,
+			},
+			wantStatusCode: http.StatusServiceUnavailable,
+			wantStatus:     "down",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewHandlerWithTimeout(50 * time.Millisecond)
+			for name, checker := range tt.components {
+				handler.Register(name, checker)
+			}
+
+			recorder := httptest.NewRecorder()
+			start := time.Now()
+			handler.HandleReady(recorder, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+			if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+				t.Errorf("Expected readiness to respond within the timeout, took %v", elapsed)
+			}
+
+			if recorder.Code != tt.wantStatusCode {
+				t.Errorf("Expected status code %d, got %d", tt.wantStatusCode, recorder.Code)
+			}
+			var response Response
+			if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.Status != tt.wantStatus {
+				t.Errorf("Expected status %q, got %q", tt.wantStatus, response.Status)
+			}
+			if len(response.Components) != len(tt.components) {
+				t.Errorf("Expected %d components, got %d", len(tt.components), len(response.Components))
+			}
+		})
+	}
+}
+
+func TestHandleReady_ComponentDetails(t *testing.T) {
+	handler := NewHandler()
+	handler.Register("chat", services.HealthCheckFunc(func(ctx context.Context) services.ComponentHealth {
+		return services.ComponentHealth{
+			Status:  services.HealthDown,
+			Message: "Draining for shutdown",
+			Details: map[string]interface{}{"active_streams": 2},
+		}
+	}))
+
+	recorder := httptest.NewRecorder()
+	handler.HandleReady(recorder, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+
+	var response Response
+	if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	chat := response.Components["chat"]
+	if chat.Status != "down" || chat.Message != "Draining for shutdown" || chat.Details["active_streams"] != float64(2) {
+		t.Errorf("Unexpected chat component: %+v", chat)
+	}
+	if recorder.Header().Get("Cache-Control") != "no-store" {
+		t.Error("Expected health responses not to be cached")
+	}
+}
+
+func TestHandleLive(t *testing.T) {
+	handler := NewHandler()
+	handler.Register("bedrock_agent", staticChecker(services.HealthDown))
+
+	recorder := httptest.NewRecorder()
+	handler.HandleLive(recorder, httptest.NewRequest(http.MethodGet, "/health/live", nil))
+
+	// Liveness ignores component health so a Bedrock outage does not restart the process
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected status code 200, got %d", recorder.Code)
+	}
+	var response Response
+	if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Status != "up" {
+		t.Errorf("Expected status up, got %q", response.Status)
+	}
+
+	recorder = httptest.NewRecorder()
+	handler.HandleLive(recorder, httptest.NewRequest(http.MethodPost, "/health/live", nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status code 405 for POST, got %d", recorder.Code)
+	}
+}
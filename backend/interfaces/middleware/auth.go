@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// accessTokenParam carries the token for WebSocket upgrades, since browsers
+// cannot set headers on them
+const accessTokenParam = "access_token"
+
+// BearerAuth requires requests to present one of tokens, either as an
+// "Authorization: Bearer <token>" header or an access_token query parameter.
+// With no tokens configured, authentication is disabled.
+func BearerAuth(tokens []string) Middleware {
+	return func(next http.Handler) http.Handler {
+		if len(tokens) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !validToken(requestToken(r), tokens) {
+				slog.WarnContext(r.Context(), "[HTTP] Unauthorized request", "method", r.Method, "path", r.URL.Path)
+				w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+				writeError(w, http.StatusUnauthorized, "UNAUTHORIZED", "A valid access token is required")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requestToken returns the token a request presents, or "" if none
+func requestToken(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, token, ok := strings.Cut(header, " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+		return ""
+	}
+	return r.URL.Query().Get(accessTokenParam)
+}
+
+// validToken compares token against every configured token in constant time
+func validToken(token string, tokens []string) bool {
+	if token == "" {
+		return false
+	}
+	valid := false
+	for _, candidate := range tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(candidate)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
@@ -0,0 +1,27 @@
+package middleware
+
+import "net/http"
+
+// CORS sets the cross-origin headers on every response and answers
+// preflight OPTIONS requests itself, before routing and authentication.
+func CORS() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			SetCORSHeaders(w)
+			if r.Method == http.MethodOptions {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// SetCORSHeaders sets CORS headers for the response
+func SetCORSHeaders(w http.ResponseWriter) {
+	// Allow all origins for POC - in production, restrict this
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, "+RequestIDHeader)
+	w.Header().Set("Access-Control-Expose-Headers", RequestIDHeader)
+}
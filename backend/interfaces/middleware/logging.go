@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Logging logs every request once it completes, with its status, size and
+// duration. Server errors log at error level and client errors at warn. For
+// a WebSocket the duration is the life of the connection. The route is
+// known only if no middleware between this one and the ServeMux replaces
+// the request.
+func Logging() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := wrapStatusWriter(w)
+
+			next.ServeHTTP(sw, r)
+
+			status := sw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			level := slog.LevelInfo
+			switch {
+			case status >= 500:
+				level = slog.LevelError
+			case status >= 400:
+				level = slog.LevelWarn
+			}
+
+			slog.Log(r.Context(), level, "[HTTP] Request completed",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"route", r.Pattern,
+				"status", status,
+				"bytes", sw.bytes,
+				"duration", time.Since(start),
+				"remote_addr", r.RemoteAddr,
+			)
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bedrock-chat-poc/backend/infrastructure/logging"
+)
+
+// captureLogs sends slog output to a buffer for the rest of the test
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	logger, err := logging.New(logging.Config{Level: "debug"}, &buf)
+	if err != nil {
+		t.Fatalf("logging.New() error = %v", err)
+	}
+	previous := slog.Default()
+	slog.SetDefault(logger)
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+func TestChain(t *testing.T) {
+	var order []string
+	record := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	handler := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "handler")
+	}), record("outer"), record("inner"))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got := strings.Join(order, ","); got != "outer,inner,handler" {
+		t.Errorf("Expected outer,inner,handler, got %s", got)
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	logs := captureLogs(t)
+
+	t.Run("before response", func(t *testing.T) {
+		handler := Recovery()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("nil map write")
+		}))
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/sessions", nil))
+
+		if recorder.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status 500, got %d", recorder.Code)
+		}
+		var response errorResponse
+		if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil || response.Code != "INTERNAL_ERROR" {
+			t.Errorf("Expected INTERNAL_ERROR body, got %+v (err %v)", response, err)
+		}
+		if output := logs.String(); !strings.Contains(output, `panic="nil map write"`) || !strings.Contains(output, "stack=") {
+			t.Errorf("Expected panic and stack in logs, got %s", output)
+		}
+	})
+
+	t.Run("after response started", func(t *testing.T) {
+		handler := Recovery()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+			panic("late failure")
+		}))
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if recorder.Code != http.StatusAccepted || recorder.Body.Len() != 0 {
+			t.Errorf("Expected the started response to be left alone, got %d %q", recorder.Code, recorder.Body.String())
+		}
+	})
+
+	t.Run("abort handler", func(t *testing.T) {
+		handler := Recovery()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic(http.ErrAbortHandler)
+		}))
+
+		defer func() {
+			if recovered := recover(); recovered != http.ErrAbortHandler {
+				t.Errorf("Expected ErrAbortHandler to propagate, got %v", recovered)
+			}
+		}()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+}
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		wantSame bool
+	}{
+		{name: "generated", incoming: "", wantSame: false},
+		{name: "client supplied", incoming: "client-req-42", wantSame: true},
+		{name: "unsafe replaced", incoming: "bad id\nwith newline", wantSame: false},
+		{name: "too long replaced", incoming: strings.Repeat("a", maxRequestIDLength+1), wantSame: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLogs(t)
+			handler := RequestID()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				slog.InfoContext(r.Context(), "handled")
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.incoming != "" {
+				req.Header.Set(RequestIDHeader, tt.incoming)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			id := recorder.Header().Get(RequestIDHeader)
+			if id == "" {
+				t.Fatal("Expected a request ID response header")
+			}
+			if (id == tt.incoming) != tt.wantSame {
+				t.Errorf("Request ID %q, incoming %q, want reused = %v", id, tt.incoming, tt.wantSame)
+			}
+			if !strings.Contains(logs.String(), "http_request_id="+id) {
+				t.Errorf("Expected http_request_id=%s in logs, got %s", id, logs.String())
+			}
+		})
+	}
+}
+
+func TestLogging(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		wantLevel string
+	}{
+		{name: "success", status: http.StatusOK, wantLevel: "level=INFO"},
+		{name: "client error", status: http.StatusNotFound, wantLevel: "level=WARN"},
+		{name: "server error", status: http.StatusBadGateway, wantLevel: "level=ERROR"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLogs(t)
+			handler := Logging()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte("body"))
+			}))
+
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/sessions", nil))
+
+			output := logs.String()
+			for _, want := range []string{tt.wantLevel, "method=GET", "path=/api/sessions", "bytes=4"} {
+				if !strings.Contains(output, want) {
+					t.Errorf("Expected %s in logs, got %s", want, output)
+				}
+			}
+		})
+	}
+}
+
+func TestCORS(t *testing.T) {
+	called := false
+	handler := CORS()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	// Preflight is answered without reaching the route
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodOptions, "/api/sessions", nil))
+	if recorder.Code != http.StatusOK || called {
+		t.Errorf("Expected preflight to return 200 without calling the route, got %d (called %v)", recorder.Code, called)
+	}
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/sessions", nil))
+	if !called {
+		t.Error("Expected GET to reach the route")
+	}
+	if recorder.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Error("Expected CORS headers on every response")
+	}
+}
+
+func TestSetCORSHeaders(t *testing.T) {
+	w := httptest.NewRecorder()
+	SetCORSHeaders(w)
+
+	if origin := w.Header().Get("Access-Control-Allow-Origin"); origin != "*" {
+		t.Errorf("Expected Access-Control-Allow-Origin '*', got '%s'", origin)
+	}
+
+	if methods := w.Header().Get("Access-Control-Allow-Methods"); methods == "" {
+		t.Error("Expected Access-Control-Allow-Methods to be set")
+	}
+
+	if headers := w.Header().Get("Access-Control-Allow-Headers"); headers == "" {
+		t.Error("Expected Access-Control-Allow-Headers to be set")
+	}
+}
+
+func TestBearerAuth(t *testing.T) {
+	const token = "0123456789abcdef"
+
+	tests := []struct {
+		name       string
+		tokens     []string
+		header     string
+		query      string
+		wantStatus int
+	}{
+		{name: "disabled", tokens: nil, wantStatus: http.StatusOK},
+		{name: "missing token", tokens: []string{token}, wantStatus: http.StatusUnauthorized},
+		{name: "bearer header", tokens: []string{token}, header: "Bearer " + token, wantStatus: http.StatusOK},
+		{name: "lowercase scheme", tokens: []string{token}, header: "bearer " + token, wantStatus: http.StatusOK},
+		{name: "wrong token", tokens: []string{token}, header: "Bearer fedcba9876543210", wantStatus: http.StatusUnauthorized},
+		{name: "basic scheme", tokens: []string{token}, header: "Basic " + token, wantStatus: http.StatusUnauthorized},
+		{name: "query parameter for WebSocket", tokens: []string{token}, query: "?access_token=" + token, wantStatus: http.StatusOK},
+		{name: "second token", tokens: []string{"fedcba9876543210", token}, header: "Bearer " + token, wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := BearerAuth(tt.tokens)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/chat/stream"+tt.query, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, recorder.Code)
+			}
+			if tt.wantStatus == http.StatusUnauthorized && recorder.Header().Get("WWW-Authenticate") == "" {
+				t.Error("Expected a WWW-Authenticate challenge")
+			}
+		})
+	}
+}
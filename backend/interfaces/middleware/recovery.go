@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+)
+
+// Recovery recovers panics in the wrapped handlers, logs them with a stack
+// trace and responds 500 if the response has not started. A panic then only
+// fails its own request rather than the whole server.
+func Recovery() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sw := wrapStatusWriter(w)
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+				// The server uses this panic to abort a response silently
+				if recovered == http.ErrAbortHandler {
+					panic(recovered)
+				}
+
+				slog.ErrorContext(r.Context(), "[HTTP] Recovered from panic",
+					"method", r.Method,
+					"path", r.URL.Path,
+					"panic", recovered,
+					"stack", string(debug.Stack()),
+				)
+				if !sw.wroteHeader() {
+					writeError(sw, http.StatusInternalServerError, "INTERNAL_ERROR", "An unexpected error occurred")
+				}
+			}()
+
+			next.ServeHTTP(sw, r)
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Timeout gives the wrapped routes their own timeout in place of the
+// server's write timeout. The response may be written for up to d after the
+// handler starts, and the request context is cancelled at the same time so
+// work whose response can no longer be delivered stops. Zero means no
+// timeout.
+//
+// A WebSocket upgrade clears connection deadlines, so for streaming routes d
+// bounds only the handshake and the lifetime of work tied to the request
+// context.
+func Timeout(d time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var deadline time.Time
+			if d > 0 {
+				deadline = time.Now().Add(d)
+				ctx, cancel := context.WithDeadline(r.Context(), deadline)
+				defer cancel()
+				r = r.WithContext(ctx)
+			}
+
+			if err := http.NewResponseController(w).SetWriteDeadline(deadline); err != nil {
+				slog.WarnContext(r.Context(), "[HTTP] Failed to set route write deadline", "path", r.URL.Path, "error", err)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
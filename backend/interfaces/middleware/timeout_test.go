@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	// slow responds after outliving the server's write timeout
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(150 * time.Millisecond)
+		w.Write([]byte("answer"))
+	})
+
+	tests := []struct {
+		name     string
+		handler  http.Handler
+		wantBody bool
+	}{
+		{name: "server write timeout", handler: slow, wantBody: false},
+		{name: "longer route timeout", handler: Timeout(time.Second)(slow), wantBody: true},
+		{name: "no route timeout", handler: Timeout(0)(slow), wantBody: true},
+		{name: "shorter route timeout", handler: Timeout(50 * time.Millisecond)(slow), wantBody: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewUnstartedServer(tt.handler)
+			server.Config.WriteTimeout = 50 * time.Millisecond
+			server.Start()
+			defer server.Close()
+
+			resp, err := http.Get(server.URL)
+			var body []byte
+			if err == nil {
+				body, err = io.ReadAll(resp.Body)
+				resp.Body.Close()
+			}
+
+			gotBody := err == nil && string(body) == "answer"
+			if gotBody != tt.wantBody {
+				t.Errorf("Expected response delivered = %v, got body %q (err %v)", tt.wantBody, body, err)
+			}
+		})
+	}
+}
+
+func TestTimeout_CancelsRequestContext(t *testing.T) {
+	var deadline time.Time
+	var hasDeadline bool
+	handler := Timeout(time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadline, hasDeadline = r.Context().Deadline()
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/search", nil))
+
+	if !hasDeadline || time.Until(deadline) < 59*time.Second {
+		t.Errorf("Expected a request deadline about a minute away, got %v (set %v)", deadline, hasDeadline)
+	}
+}
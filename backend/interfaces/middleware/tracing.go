@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// Tracing traces requests, continuing any trace context from an incoming
+// traceparent header. Spans are named after the matched route pattern, e.g.
+// "GET /api/sessions/{id}", so it must wrap handlers inside the ServeMux.
+func Tracing() Middleware {
+	return func(next http.Handler) http.Handler {
+		return otelhttp.NewHandler(next, "http.request",
+			otelhttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
+				if r.Pattern != "" {
+					return r.Pattern
+				}
+				return operation
+			}),
+		)
+	}
+}
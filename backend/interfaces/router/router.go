@@ -0,0 +1,59 @@
+package router
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/bedrock-chat-poc/backend/interfaces/middleware"
+)
+
+// Router registers routes on a ServeMux using Go 1.22 patterns such as
+// "GET /api/sessions/{id}" and wraps them in middleware. Requests whose path
+// matches no pattern get 404, and those whose method matches none get 405.
+type Router struct {
+	mux *http.ServeMux
+	// handler is the ServeMux wrapped in the global middleware
+	handler http.Handler
+	// chain is the middleware this group applies to its routes
+	chain []middleware.Middleware
+}
+
+// New creates a router. The global middleware wraps every request, including
+// those that match no route.
+func New(global ...middleware.Middleware) *Router {
+	mux := http.NewServeMux()
+	return &Router{
+		mux:     mux,
+		handler: middleware.Chain(mux, global...),
+	}
+}
+
+// Group returns a router that registers routes on the same ServeMux, wrapped
+// in this router's middleware followed by mw. Route middleware runs inside
+// the ServeMux, so it sees the matched pattern and path values.
+func (r *Router) Group(mw ...middleware.Middleware) *Router {
+	return &Router{
+		mux:     r.mux,
+		handler: r.handler,
+		chain:   append(slices.Clone(r.chain), mw...),
+	}
+}
+
+// Handle registers handler for pattern, wrapped in the group's middleware
+// followed by mw
+func (r *Router) Handle(pattern string, handler http.Handler, mw ...middleware.Middleware) {
+	chain := append(slices.Clone(r.chain), mw...)
+	r.mux.Handle(pattern, middleware.Chain(handler, chain...))
+}
+
+// HandleFunc registers handler for pattern, wrapped in the group's
+// middleware followed by mw
+func (r *Router) HandleFunc(pattern string, handler http.HandlerFunc, mw ...middleware.Middleware) {
+	r.Handle(pattern, handler, mw...)
+}
+
+// ServeHTTP dispatches the request through the global middleware to the
+// matching route
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.handler.ServeHTTP(w, req)
+}
@@ -0,0 +1,88 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bedrock-chat-poc/backend/interfaces/middleware"
+)
+
+// tag appends name to the X-Chain response header when it runs
+func tag(name string) middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Chain", name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestRouter(t *testing.T) {
+	r := New(tag("global"))
+	r.HandleFunc("GET /health", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	api := r.Group(tag("api"))
+	api.HandleFunc("GET /api/sessions/{id}", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("session " + req.PathValue("id")))
+	}, tag("route"))
+	api.HandleFunc("POST /api/sessions", func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantBody   string
+		wantChain  string
+	}{
+		{name: "path value", method: http.MethodGet, path: "/api/sessions/abc", wantStatus: http.StatusOK, wantBody: "session abc", wantChain: "global,api,route"},
+		{name: "extra segment is not an ID", method: http.MethodGet, path: "/api/sessions/abc/def", wantStatus: http.StatusNotFound, wantChain: "global"},
+		{name: "wrong method", method: http.MethodDelete, path: "/api/sessions", wantStatus: http.StatusMethodNotAllowed, wantChain: "global"},
+		{name: "group without route middleware", method: http.MethodPost, path: "/api/sessions", wantStatus: http.StatusCreated, wantChain: "global,api"},
+		{name: "root route skips group middleware", method: http.MethodGet, path: "/health", wantStatus: http.StatusOK, wantBody: "ok", wantChain: "global"},
+		{name: "GET route serves HEAD", method: http.MethodHead, path: "/health", wantStatus: http.StatusOK, wantChain: "global"},
+		{name: "unknown path", method: http.MethodGet, path: "/missing", wantStatus: http.StatusNotFound, wantChain: "global"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, recorder.Code)
+			}
+			if tt.wantBody != "" && recorder.Body.String() != tt.wantBody {
+				t.Errorf("Expected body %q, got %q", tt.wantBody, recorder.Body.String())
+			}
+			if chain := strings.Join(recorder.Header().Values("X-Chain"), ","); chain != tt.wantChain {
+				t.Errorf("Expected middleware %s, got %s", tt.wantChain, chain)
+			}
+		})
+	}
+}
+
+func TestRouter_GroupsDoNotShareMiddleware(t *testing.T) {
+	r := New()
+	api := r.Group(tag("api"))
+	admin := api.Group(tag("admin"))
+	api.HandleFunc("GET /api/sessions", func(w http.ResponseWriter, req *http.Request) {})
+	admin.HandleFunc("GET /api/admin", func(w http.ResponseWriter, req *http.Request) {})
+
+	for path, want := range map[string]string{
+		"/api/sessions": "api",
+		"/api/admin":    "api,admin",
+	} {
+		recorder := httptest.NewRecorder()
+		r.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		if chain := strings.Join(recorder.Header().Values("X-Chain"), ","); chain != want {
+			t.Errorf("%s: expected middleware %s, got %s", path, want, chain)
+		}
+	}
+}
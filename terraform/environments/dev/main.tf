@@ -66,6 +66,8 @@ module "bedrock_agent" {
   agent_instruction                 = var.agent_instruction
   agent_role_arn                    = module.iam.agent_role_arn
   idle_session_ttl                  = var.idle_session_ttl
+  guardrail_id                      = var.guardrail_id
+  guardrail_version                 = var.guardrail_version
   knowledge_base_id                 = module.knowledge_base.knowledge_base_id
   enable_knowledge_base_association = true   # Enable knowledge base integration
   tags                              = var.tags
@@ -55,6 +55,20 @@ variable "idle_session_ttl" {
   default     = 1800
 }
 
+# Guardrail applied to the agent; the backend uses the same values as
+# BEDROCK_GUARDRAIL_ID and BEDROCK_GUARDRAIL_VERSION
+variable "guardrail_id" {
+  description = "ID or ARN of the Bedrock guardrail applied to the agent (null for none)"
+  type        = string
+  default     = null
+}
+
+variable "guardrail_version" {
+  description = "Version of the guardrail: a number or DRAFT"
+  type        = string
+  default     = "DRAFT"
+}
+
 # Knowledge Base Configuration
 # Note: Knowledge Base is now fully managed by Terraform
 # No manual variables needed - module creates everything automatically
@@ -76,5 +76,7 @@ module "bedrock_agent" {
   agent_instruction = var.agent_instruction
   agent_role_arn    = module.iam.agent_role_arn
   idle_session_ttl  = var.idle_session_ttl
+  guardrail_id      = var.guardrail_id
+  guardrail_version = var.guardrail_version
   tags              = var.tags
 }
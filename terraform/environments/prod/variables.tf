@@ -55,6 +55,20 @@ variable "idle_session_ttl" {
   default     = 3600
 }
 
+# Guardrail applied to the agent; the backend uses the same values as
+# BEDROCK_GUARDRAIL_ID and BEDROCK_GUARDRAIL_VERSION
+variable "guardrail_id" {
+  description = "ID or ARN of the Bedrock guardrail applied to the agent (null for none)"
+  type        = string
+  default     = null
+}
+
+variable "guardrail_version" {
+  description = "Version of the guardrail: a number, or DRAFT (unreleased edits, avoid outside development)"
+  type        = string
+  default     = "1"
+}
+
 # Knowledge Base Configuration
 variable "knowledge_base_name" {
   description = "Name of the Knowledge Base"
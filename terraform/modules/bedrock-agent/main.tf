@@ -23,6 +23,14 @@ resource "aws_bedrockagent_agent" "this" {
   instruction                 = var.agent_instruction
   idle_session_ttl_in_seconds = var.idle_session_ttl
 
+  dynamic "guardrail_configuration" {
+    for_each = var.guardrail_id == null ? [] : [var.guardrail_id]
+    content {
+      guardrail_identifier = guardrail_configuration.value
+      guardrail_version    = var.guardrail_version
+    }
+  }
+
   tags = var.tags
 }
 
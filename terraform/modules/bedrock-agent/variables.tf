@@ -66,6 +66,23 @@ variable "enable_knowledge_base_association" {
   default     = true
 }
 
+variable "guardrail_id" {
+  description = "ID or ARN of the Bedrock guardrail applied to the agent"
+  type        = string
+  default     = null
+}
+
+variable "guardrail_version" {
+  description = "Version of the guardrail: a number or DRAFT"
+  type        = string
+  default     = "DRAFT"
+
+  validation {
+    condition     = var.guardrail_version == "DRAFT" || can(regex("^[1-9][0-9]*$", var.guardrail_version))
+    error_message = "Guardrail version must be DRAFT or a positive number"
+  }
+}
+
 variable "tags" {
   description = "Resource tags"
   type        = map(string)
@@ -81,6 +81,11 @@ resource "aws_iam_role_policy" "agent_policy" {
           "bedrock:RetrieveAndGenerate"
         ]
         Resource = "arn:aws:bedrock:${local.region}:${local.account_id}:knowledge-base/${var.bedrock_knowledge_base_id}"
+      },
+      {
+        Effect   = "Allow"
+        Action   = "bedrock:ApplyGuardrail"
+        Resource = "arn:aws:bedrock:${local.region}:${local.account_id}:guardrail/*"
       }
     ]
   })
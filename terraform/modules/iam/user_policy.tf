@@ -36,6 +36,11 @@ resource "aws_iam_policy" "bedrock_agent_user_policy" {
         ]
         Resource = "*"
       },
+      {
+        Effect   = "Allow"
+        Action   = "bedrock:ApplyGuardrail"
+        Resource = "arn:aws:bedrock:*:*:guardrail/*"
+      },
       {
         Effect = "Allow"
         Action = [